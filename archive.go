@@ -0,0 +1,339 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	nsKeyedArchiverName = "NSKeyedArchiver"
+	nsKeyedArchiveNull  = "$null"
+)
+
+// An ArchivedObject is an object from a keyed archive, as passed to an ArchiveDecodeFunc.
+type ArchivedObject struct {
+	// Classes is the object's class hierarchy, most specific class first.
+	Classes []string
+
+	// Fields holds the object's encoded keys, except for $class. Any UID references have been
+	// resolved to the objects they refer to, which are themselves decoded.
+	Fields map[string]interface{}
+}
+
+// An ArchiveDecodeFunc converts an archived object into a Go value.
+type ArchiveDecodeFunc func(obj *ArchivedObject) (interface{}, error)
+
+// A ClassRegistry maps the class names found in keyed archives to the Go types or functions
+// used to decode them. It is safe for concurrent use.
+type ClassRegistry struct {
+	mu      sync.RWMutex
+	classes map[string]ArchiveDecodeFunc
+}
+
+// NewClassRegistry returns an empty ClassRegistry.
+func NewClassRegistry() *ClassRegistry {
+	return &ClassRegistry{classes: make(map[string]ArchiveDecodeFunc)}
+}
+
+// RegisterFunc registers f to decode objects of the class named classname.
+func (r *ClassRegistry) RegisterFunc(classname string, f ArchiveDecodeFunc) {
+	r.mu.Lock()
+	r.classes[classname] = f
+	r.mu.Unlock()
+}
+
+// Register registers the type of v to decode objects of the class named classname.
+// Each object's fields are unmarshaled into a new value of that type, as if by Unmarshal,
+// and a pointer to it is returned.
+func (r *ClassRegistry) Register(classname string, v interface{}) {
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	r.RegisterFunc(classname, func(obj *ArchivedObject) (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(runtime.Error); ok {
+					panic(r)
+				}
+				err = r.(error)
+			}
+		}()
+
+		pval := (&Encoder{}).marshal(reflect.ValueOf(obj.Fields))
+		val := reflect.New(typ)
+		if err := (&Decoder{}).unmarshal(pval, val); err != nil {
+			return nil, err
+		}
+		return val.Interface(), nil
+	})
+}
+
+func (r *ClassRegistry) lookup(classes []string) ArchiveDecodeFunc {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range classes {
+		if f, ok := r.classes[c]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+type unarchiver struct {
+	registry *ClassRegistry
+	objects  []cfValue
+	decoded  map[uint64]interface{}
+	decoding map[uint64]bool
+}
+
+// Unarchive decodes the root object of an NSKeyedArchiver archive, in any property list format.
+//
+// Objects whose class (or any superclass) is registered in registry are decoded by the registered type or function.
+// Otherwise, the following Foundation classes are decoded to Go values:
+//
+//	NSArray, NSMutableArray, NSSet, NSMutableSet    []interface{}
+//	NSDictionary, NSMutableDictionary               map[string]interface{}
+//	NSString, NSMutableString                       string
+//	NSData, NSMutableData                           []byte
+//	NSDate                                          time.Time
+//
+// Objects of any other class are returned as *ArchivedObject. registry may be nil.
+//
+// Archives containing reference cycles cannot be decoded.
+func Unarchive(data []byte, registry *ClassRegistry) (interface{}, error) {
+	pval, err := NewDecoder(bytes.NewReader(data)).parse()
+	if err != nil {
+		return nil, err
+	}
+
+	archive, ok := pval.(*cfDictionary)
+	if !ok {
+		return nil, errors.New("plist: keyed archive is not a dictionary")
+	}
+
+	var archiver cfValue
+	var top, objects cfValue
+	for i, k := range archive.keys {
+		switch k {
+		case "$archiver":
+			archiver = archive.values[i]
+		case "$top":
+			top = archive.values[i]
+		case "$objects":
+			objects = archive.values[i]
+		}
+	}
+
+	if s, ok := archiver.(cfString); !ok || s != nsKeyedArchiverName {
+		return nil, errors.New("plist: not an NSKeyedArchiver archive")
+	}
+
+	objectArray, ok := objects.(*cfArray)
+	if !ok {
+		return nil, errors.New("plist: keyed archive is missing its $objects array")
+	}
+
+	topDict, ok := top.(*cfDictionary)
+	if !ok {
+		return nil, errors.New("plist: keyed archive is missing its $top dictionary")
+	}
+
+	var root cfValue
+	for i, k := range topDict.keys {
+		if k == "root" {
+			root = topDict.values[i]
+		}
+	}
+	if root == nil {
+		return nil, errors.New("plist: keyed archive has no root object")
+	}
+
+	u := &unarchiver{
+		registry: registry,
+		objects:  objectArray.values,
+		decoded:  make(map[uint64]interface{}),
+		decoding: make(map[uint64]bool),
+	}
+	return u.resolve(root)
+}
+
+// resolve converts an archived value to a Go value, following UID references.
+func (u *unarchiver) resolve(pval cfValue) (interface{}, error) {
+	switch pval := pval.(type) {
+	case cfUID:
+		return u.object(uint64(pval))
+	case *cfArray:
+		out := make([]interface{}, len(pval.values))
+		for i, subv := range pval.values {
+			v, err := u.resolve(subv)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			out[i] = v
+		}
+		return out, nil
+	case *cfDictionary:
+		out := make(map[string]interface{}, len(pval.keys))
+		for i, k := range pval.keys {
+			v, err := u.resolve(pval.values[i])
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k, err)
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+	return (&Decoder{}).valueInterface(pval), nil
+}
+
+func (u *unarchiver) object(uid uint64) (interface{}, error) {
+	if v, ok := u.decoded[uid]; ok {
+		return v, nil
+	}
+	if u.decoding[uid] {
+		return nil, fmt.Errorf("plist: keyed archive object %d refers to itself", uid)
+	}
+	if uid >= uint64(len(u.objects)) {
+		return nil, fmt.Errorf("plist: keyed archive object %d out of range (only %d exist)", uid, len(u.objects))
+	}
+
+	u.decoding[uid] = true
+	defer delete(u.decoding, uid)
+
+	var v interface{}
+	var err error
+	switch pval := u.objects[uid].(type) {
+	case cfString:
+		if pval != nsKeyedArchiveNull {
+			v = string(pval)
+		}
+	case *cfDictionary:
+		v, err = u.instance(pval)
+	default:
+		v, err = u.resolve(pval)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", uid, err)
+	}
+
+	u.decoded[uid] = v
+	return v, nil
+}
+
+func (u *unarchiver) instance(dict *cfDictionary) (interface{}, error) {
+	obj := &ArchivedObject{Fields: make(map[string]interface{}, len(dict.keys))}
+	for i, k := range dict.keys {
+		if k == "$class" {
+			classes, err := u.classes(dict.values[i])
+			if err != nil {
+				return nil, err
+			}
+			obj.Classes = classes
+			continue
+		}
+
+		v, err := u.resolve(dict.values[i])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k, err)
+		}
+		obj.Fields[k] = v
+	}
+
+	if obj.Classes == nil {
+		// Not an object at all: just a plain dictionary.
+		return obj.Fields, nil
+	}
+
+	if f := u.registry.lookup(obj.Classes); f != nil {
+		return f(obj)
+	}
+
+	switch obj.Classes[0] {
+	case "NSArray", "NSMutableArray", "NSSet", "NSMutableSet":
+		objects, _ := obj.Fields["NS.objects"].([]interface{})
+		if objects == nil {
+			objects = []interface{}{}
+		}
+		return objects, nil
+	case "NSDictionary", "NSMutableDictionary":
+		keys, _ := obj.Fields["NS.keys"].([]interface{})
+		values, _ := obj.Fields["NS.objects"].([]interface{})
+		if len(keys) != len(values) {
+			return nil, fmt.Errorf("%s has %d keys but %d values", obj.Classes[0], len(keys), len(values))
+		}
+		out := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s contains non-string key %v", obj.Classes[0], k)
+			}
+			out[ks] = values[i]
+		}
+		return out, nil
+	case "NSString", "NSMutableString":
+		if s, ok := obj.Fields["NS.string"].(string); ok {
+			return s, nil
+		}
+	case "NSData", "NSMutableData":
+		if b, ok := obj.Fields["NS.data"].([]byte); ok {
+			return b, nil
+		}
+	case "NSDate":
+		if t, ok := obj.Fields["NS.time"].(float64); ok {
+			// NSDate stores seconds since the Apple epoch (2001-01-01T00:00:00Z).
+			sec, fsec := math.Modf(t + 978307200)
+			return time.Unix(int64(sec), int64(fsec*float64(time.Second))).In(time.UTC), nil
+		}
+	}
+	return obj, nil
+}
+
+func (u *unarchiver) classes(pval cfValue) ([]string, error) {
+	uid, ok := pval.(cfUID)
+	if !ok || uint64(uid) >= uint64(len(u.objects)) {
+		return nil, errors.New("invalid $class reference")
+	}
+
+	class, ok := u.objects[uid].(*cfDictionary)
+	if !ok {
+		return nil, fmt.Errorf("$class reference %d is not a dictionary", uid)
+	}
+
+	var classes []string
+	var classname cfString
+	for i, k := range class.keys {
+		switch k {
+		case "$classes":
+			if a, ok := class.values[i].(*cfArray); ok {
+				for _, c := range a.values {
+					if s, ok := c.(cfString); ok {
+						classes = append(classes, string(s))
+					}
+				}
+			}
+		case "$classname":
+			classname, _ = class.values[i].(cfString)
+		}
+	}
+
+	if len(classes) == 0 && classname != "" {
+		classes = []string{string(classname)}
+	}
+
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("$class reference %d has no class name", uid)
+	}
+	return classes, nil
+}
@@ -0,0 +1,252 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var keyedArchiveAsXML = xmlPreamble + `<plist version="1.0"><dict>
+<key>$archiver</key><string>NSKeyedArchiver</string>
+<key>$version</key><integer>100000</integer>
+<key>$top</key><dict><key>root</key><dict><key>CF$UID</key><integer>1</integer></dict></dict>
+<key>$objects</key><array>
+	<string>$null</string>
+	<dict>
+		<key>$class</key><dict><key>CF$UID</key><integer>6</integer></dict>
+		<key>NS.keys</key><array><dict><key>CF$UID</key><integer>2</integer></dict><dict><key>CF$UID</key><integer>3</integer></dict></array>
+		<key>NS.objects</key><array><dict><key>CF$UID</key><integer>4</integer></dict><dict><key>CF$UID</key><integer>7</integer></dict></array>
+	</dict>
+	<string>person</string>
+	<string>when</string>
+	<dict>
+		<key>$class</key><dict><key>CF$UID</key><integer>5</integer></dict>
+		<key>name</key><dict><key>CF$UID</key><integer>8</integer></dict>
+		<key>age</key><integer>42</integer>
+		<key>spouse</key><dict><key>CF$UID</key><integer>0</integer></dict>
+	</dict>
+	<dict><key>$classname</key><string>MyPerson</string><key>$classes</key><array><string>MyPerson</string><string>NSObject</string></array></dict>
+	<dict><key>$classname</key><string>NSMutableDictionary</string><key>$classes</key><array><string>NSMutableDictionary</string><string>NSDictionary</string><string>NSObject</string></array></dict>
+	<dict>
+		<key>$class</key><dict><key>CF$UID</key><integer>9</integer></dict>
+		<key>NS.time</key><real>0.5</real>
+	</dict>
+	<string>Alice</string>
+	<dict><key>$classname</key><string>NSDate</string><key>$classes</key><array><string>NSDate</string><string>NSObject</string></array></dict>
+</array>
+</dict></plist>`
+
+type archivedPerson struct {
+	Name string `plist:"name"`
+	Age  int    `plist:"age"`
+}
+
+func TestUnarchive(t *testing.T) {
+	when := time.Date(2001, 1, 1, 0, 0, 0, int(time.Second/2), time.UTC)
+
+	val, err := Unarchive([]byte(keyedArchiveAsXML), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"person": &ArchivedObject{
+			Classes: []string{"MyPerson", "NSObject"},
+			Fields:  map[string]interface{}{"name": "Alice", "age": uint64(42), "spouse": nil},
+		},
+		"when": when,
+	}
+	if !reflect.DeepEqual(expected, val) {
+		t.Logf("Expected: %#v", expected)
+		t.Logf("Received: %#v", val)
+		t.Fail()
+	}
+
+	registry := NewClassRegistry()
+	registry.Register("MyPerson", archivedPerson{})
+	val, err = Unarchive([]byte(keyedArchiveAsXML), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected["person"] = &archivedPerson{Name: "Alice", Age: 42}
+	if !reflect.DeepEqual(expected, val) {
+		t.Logf("Expected: %#v", expected)
+		t.Logf("Received: %#v", val)
+		t.Fail()
+	}
+
+	registry = NewClassRegistry()
+	registry.RegisterFunc("NSObject", func(obj *ArchivedObject) (interface{}, error) {
+		return obj.Classes[0], nil
+	})
+	val, err = Unarchive([]byte(keyedArchiveAsXML), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual("NSMutableDictionary", val) {
+		t.Errorf("Expected superclass decoder to be used, received %#v", val)
+	}
+}
+
+func TestInvalidUnarchive(t *testing.T) {
+	archives := []string{
+		`<string>hello</string>`,
+		`{a=b;}`,
+		`{$archiver=NSKeyedArchiver;$top={root={CF$UID=1;};};}`,
+		`{$archiver=NSKeyedArchiver;$objects=();}`,
+		`{$archiver=NSKeyedArchiver;$objects=();$top={root={CF$UID=1;};};}`,
+		`{$archiver=NSKeyedArchiver;$objects=($null,({CF$UID=1;}));$top={root={CF$UID=1;};};}`,
+	}
+
+	for _, archive := range archives {
+		_, err := Unarchive([]byte(archive), nil)
+		t.Logf("Error: %v", err)
+		if err == nil {
+			t.Errorf("%s: Expected error, received nothing.", archive)
+		}
+	}
+}
+
+func TestResolveUIDs(t *testing.T) {
+	type person struct {
+		Name   string  `plist:"name"`
+		Age    int     `plist:"age"`
+		Spouse *person `plist:"spouse"`
+	}
+	type dictionary struct {
+		Keys    []string `plist:"NS.keys"`
+		Objects []person `plist:"NS.objects"`
+	}
+	var archive struct {
+		Top struct {
+			Root dictionary `plist:"root"`
+		} `plist:"$top"`
+	}
+
+	var resolved []UID
+	d := NewDecoder(bytes.NewReader([]byte(keyedArchiveAsXML)))
+	d.ResolveUIDs(func(uid UID, v interface{}, decode func(interface{}) error) error {
+		resolved = append(resolved, uid)
+		if uid == 0 || uid == 7 {
+			// $null and the NSDate; leave them unset.
+			return nil
+		}
+		return decode(v)
+	})
+	if err := d.Decode(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	root := archive.Top.Root
+	if !reflect.DeepEqual(root.Keys, []string{"person", "when"}) {
+		t.Errorf("Unexpected keys %#v", root.Keys)
+	}
+	if len(root.Objects) != 2 || root.Objects[0].Name != "Alice" || root.Objects[0].Age != 42 || root.Objects[0].Spouse != nil {
+		t.Errorf("Unexpected objects %#v", root.Objects)
+	}
+	if !reflect.DeepEqual(resolved, []UID{1, 2, 3, 4, 8, 0, 7}) {
+		t.Errorf("Unexpected resolution order %v", resolved)
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte(`({CF$UID=5;})`)))
+	d.ResolveUIDs(func(uid UID, v interface{}, decode func(interface{}) error) error {
+		return decode(v)
+	})
+	var strs []string
+	if err := d.Decode(&strs); err == nil {
+		t.Error("Expected error resolving UID outside of a keyed archive, received nothing.")
+	}
+
+	type node struct {
+		Next *node `plist:"next"`
+	}
+	d = NewDecoder(bytes.NewReader([]byte(`{$top={root={CF$UID=1;};};$objects=($null,{next={CF$UID=1;};},);}`)))
+	d.ResolveUIDs(func(uid UID, v interface{}, decode func(interface{}) error) error {
+		return decode(v)
+	})
+	var cyclic struct {
+		Top struct {
+			Root node `plist:"root"`
+		} `plist:"$top"`
+	}
+	if err := d.Decode(&cyclic); err == nil {
+		t.Error("Expected error resolving an object that refers to itself, received nothing.")
+	}
+}
+
+func TestResolveUIDPointers(t *testing.T) {
+	type node struct {
+		Name    string  `plist:"name"`
+		Spouse  *node   `plist:"spouse"`
+		Friends []*node `plist:"friends"`
+		Best    *node   `plist:"best"`
+	}
+
+	archive := `{
+		$archiver = NSKeyedArchiver;
+		$objects = (
+			$null,
+			{ name = Alice; spouse = {CF$UID=2;}; friends = ({CF$UID=2;}, {CF$UID=1;}); },
+			{ name = Bob; spouse = {CF$UID=1;}; best = {CF$UID=0;}; },
+		);
+		$top = { root = {CF$UID=1;}; };
+	}`
+	var doc struct {
+		Top struct {
+			Root *node `plist:"root"`
+		} `plist:"$top"`
+	}
+	d := NewDecoder(strings.NewReader(archive))
+	d.ResolveUIDPointers(nil)
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := doc.Top.Root
+	if alice == nil || alice.Name != "Alice" || alice.Spouse == nil || alice.Spouse.Name != "Bob" {
+		t.Fatalf("Unexpected root %#v", alice)
+	}
+	bob := alice.Spouse
+	if bob.Spouse != alice || bob.Best != nil {
+		t.Errorf("Expected Bob's spouse to be Alice, and his best friend to be nil: %#v", bob)
+	}
+	if len(alice.Friends) != 2 || alice.Friends[0] != bob || alice.Friends[1] != alice {
+		t.Errorf("Expected Alice's friends to be Bob and Alice: %#v", alice.Friends)
+	}
+
+	// Objects may come from elsewhere, and be decoded in parallel.
+	refs := make([]interface{}, 100)
+	for i := range refs {
+		refs[i] = UID(i % 2)
+	}
+	data, err := Marshal(refs, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := []interface{}{
+		map[string]interface{}{"name": "Carol"},
+		map[string]interface{}{"name": "Dave", "spouse": UID(0)},
+	}
+	var nodes []*node
+	d = NewDecoder(bytes.NewReader(data))
+	d.ResolveUIDPointers(objects)
+	d.ParallelArrays(2)
+	if err := d.Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		if n != nodes[i%2] {
+			t.Fatalf("Expected element %d to share the pointer of element %d", i, i%2)
+		}
+	}
+	if nodes[0].Name != "Carol" || nodes[1].Name != "Dave" || nodes[1].Spouse != nodes[0] {
+		t.Errorf("Unexpected nodes %#v, %#v", nodes[0], nodes[1])
+	}
+
+	d = NewDecoder(strings.NewReader(`({CF$UID=2;})`))
+	d.ResolveUIDPointers(objects)
+	if err := d.Decode(&nodes); err == nil {
+		t.Error("Expected error resolving a UID beyond the object table, received nothing.")
+	}
+}
@@ -0,0 +1,140 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+
+	// The range of the signed 128-bit integers that binary property lists can store.
+	minInt128 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+)
+
+// newIntegerValue returns n as an integer, or nil if it cannot be stored in a property list.
+func newIntegerValue(n *big.Int) *cfNumber {
+	switch {
+	case n.Sign() >= 0 && n.IsUint64():
+		return &cfNumber{signed: false, value: n.Uint64()}
+	case n.IsInt64():
+		return &cfNumber{signed: true, value: uint64(n.Int64())}
+	case n.Cmp(minInt128) < 0 || n.Cmp(maxInt128) > 0:
+		return nil
+	}
+	lo := new(big.Int).And(n, new(big.Int).SetUint64(1<<64-1)).Uint64() // the low 64 bits, in two's complement
+	return &cfNumber{signed: n.Sign() < 0, value: lo, big: new(big.Int).Set(n)}
+}
+
+// int128Value returns the 128-bit two's complement integer whose halves are hi and lo.
+func int128Value(hi, lo uint64) *big.Int {
+	n := new(big.Int).SetUint64(hi)
+	n.Lsh(n, 64).Or(n, new(big.Int).SetUint64(lo))
+	if hi>>63 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return n
+}
+
+// int128Halves returns the halves of the 128-bit two's complement representation of n, which must fit.
+func int128Halves(n *big.Int) (hi, lo uint64) {
+	u := new(big.Int).Set(n)
+	if u.Sign() < 0 {
+		u.Add(u, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	lo = new(big.Int).And(u, new(big.Int).SetUint64(1<<64-1)).Uint64()
+	hi = u.Rsh(u, 64).Uint64()
+	return hi, lo
+}
+
+// bigInt returns the value of p.
+func (p *cfNumber) bigInt() *big.Int {
+	switch {
+	case p.big != nil:
+		return new(big.Int).Set(p.big)
+	case p.signed:
+		return big.NewInt(int64(p.value))
+	}
+	return new(big.Int).SetUint64(p.value)
+}
+
+// mustParseInteger parses s, an integer in base, as a property list integer of up to 128 bits. It panics with the
+// error from strconv if s is not an integer, or does not fit.
+func mustParseInteger(s string, base int) *cfNumber {
+	var err error
+	if len(s) > 0 && s[0] == '-' {
+		var n int64
+		if n, err = strconv.ParseInt(s, base, 64); err == nil {
+			return &cfNumber{signed: true, value: uint64(n)}
+		}
+	} else {
+		var n uint64
+		if n, err = strconv.ParseUint(s, base, 64); err == nil {
+			return &cfNumber{signed: false, value: n}
+		}
+	}
+
+	if n, ok := new(big.Int).SetString(s, base); ok && errors.Is(err, strconv.ErrRange) {
+		if pval := newIntegerValue(n); pval != nil {
+			return pval
+		}
+	}
+	panic(err)
+}
+
+// mustParseIntegerLiteral parses s, a decimal or 0x-prefixed hexadecimal integer with an optional minus sign, as
+// mustParseInteger does.
+func mustParseIntegerLiteral(s string) *cfNumber {
+	return mustParseInteger(integerBase(s))
+}
+
+func marshalBigInt(n *big.Int) cfValue {
+	pval := newIntegerValue(n)
+	if pval == nil {
+		panic(fmt.Errorf("plist: integer %v requires more than 128 bits", n))
+	}
+	return pval
+}
+
+func marshalBigFloat(f *big.Float) cfValue {
+	v, _ := f.Float64()
+	return &cfReal{wide: true, value: v}
+}
+
+// unmarshalBigInt stores the integer pval in val, a big.Int.
+func unmarshalBigInt(pval *cfNumber, val reflect.Value) {
+	val.Addr().Interface().(*big.Int).Set(pval.bigInt())
+}
+
+// unmarshalBigFloat stores the integer or real pval in val, a big.Float. A big.Float whose precision is 0 is given
+// enough precision to hold the value exactly: a real read from a textual property list is parsed from its text.
+func unmarshalBigFloat(pval cfValue, val reflect.Value) error {
+	f := val.Addr().Interface().(*big.Float)
+	switch pval := pval.(type) {
+	case *cfNumber:
+		f.SetInt(pval.bigInt())
+	case *cfReal:
+		if pval.value != pval.value {
+			return fmt.Errorf("plist: NaN cannot be stored in %v", val.Type())
+		}
+		if pval.text == "" {
+			f.SetFloat64(pval.value)
+			return nil
+		}
+		if f.Prec() == 0 {
+			f.SetPrec(uint(4 * len(pval.text))) // more than the bits needed by each decimal digit
+			if f.Prec() < 64 {
+				f.SetPrec(64)
+			}
+		}
+		if _, ok := f.SetString(pval.text); !ok {
+			f.SetFloat64(pval.value)
+		}
+	}
+	return nil
+}
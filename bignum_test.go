@@ -0,0 +1,114 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBigInt(t *testing.T) {
+	values := []string{
+		"5",
+		"-5",
+		"18446744073709551615",
+		"18446744073709551616",
+		"-9223372036854775809",
+		"170141183460469231731687303715884105727",  // the largest 128-bit integer
+		"-170141183460469231731687303715884105728", // the smallest
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		for _, s := range values {
+			n, _ := new(big.Int).SetString(s, 10)
+			doc, err := Marshal(struct{ N *big.Int }{n}, format)
+			if err != nil {
+				t.Fatalf("%s: %s: %v", FormatNames[format], s, err)
+			}
+
+			var decoded struct{ N *big.Int }
+			if _, err := Unmarshal(doc, &decoded); err != nil {
+				t.Fatalf("%s: %s: %v", FormatNames[format], s, err)
+			}
+			if decoded.N == nil || decoded.N.Cmp(n) != 0 {
+				t.Errorf("%s: Expected %s to round-trip, received %v", FormatNames[format], s, decoded.N)
+			}
+
+			var num Number
+			if _, err := Unmarshal(doc, &struct{ N *Number }{&num}); err != nil || string(num) != s {
+				t.Errorf("%s: Expected Number %s, received %s (%v)", FormatNames[format], s, num, err)
+			}
+		}
+	}
+
+	big127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	if _, err := Marshal(big127, BinaryFormat); err == nil {
+		t.Error("Expected error marshaling an integer of more than 128 bits, received nothing.")
+	}
+	if _, err := Unmarshal([]byte(`<*I170141183460469231731687303715884105728>`), new(big.Int)); err == nil {
+		t.Error("Expected error unmarshaling an integer of more than 128 bits, received nothing.")
+	}
+
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	enc.BinaryFeatures(BinaryFeaturesBaseline)
+	if err := enc.Encode(new(big.Int).Lsh(big.NewInt(1), 100)); err == nil {
+		t.Error("Expected error encoding a 128-bit integer with BinaryFeaturesBaseline, received nothing.")
+	}
+
+	// A 128-bit integer does not fit in an int64, or in any other integer type.
+	doc, _ := Marshal(new(big.Int).Lsh(big.NewInt(1), 100), BinaryFormat)
+	var i64 int64
+	if _, err := Unmarshal(doc, &i64); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected an overflow error, received %v", err)
+	}
+	var u64 uint64
+	if _, err := Unmarshal(doc, &u64); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected an overflow error, received %v", err)
+	}
+
+	// A ,string field holds the whole integer.
+	doc, err := Marshal(struct {
+		N *big.Int `plist:",string"`
+	}{new(big.Int).Sub(big127, big.NewInt(1))}, XMLFormat)
+	if err != nil || !bytes.Contains(doc, []byte("<string>170141183460469231731687303715884105727</string>")) {
+		t.Errorf("Expected a 128-bit integer to be marshaled as a string, received %s (%v)", doc, err)
+	}
+
+	// Strings are still unmarshaled by big.Int's UnmarshalText.
+	var n big.Int
+	if _, err := Unmarshal([]byte(`<string>123456789012345678901234567890</string>`), &n); err != nil || n.String() != "123456789012345678901234567890" {
+		t.Errorf("Expected a big.Int to be unmarshaled from a string, received %v (%v)", &n, err)
+	}
+}
+
+func TestBigFloat(t *testing.T) {
+	doc, err := Marshal(big.NewFloat(1.5), XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(doc, []byte("<real>1.5</real>")) {
+		t.Errorf("Expected a big.Float to be marshaled as a real, received %s", doc)
+	}
+
+	// A real read as text is parsed from its text, without passing through a float64.
+	var f big.Float
+	if _, err := Unmarshal([]byte(`<*R0.1>`), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Prec() < 64 || f.Cmp(big.NewFloat(0.1)) == 0 {
+		t.Errorf("Expected 0.1 to be parsed with more precision than a float64, received %v at precision %d", &f, f.Prec())
+	}
+
+	var g big.Float
+	if _, err := Unmarshal([]byte(`<*I18446744073709551617>`), &g); err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := new(big.Int).SetString("18446744073709551617", 10); !g.IsInt() || new(big.Float).SetInt(expected).Cmp(&g) != 0 {
+		t.Errorf("Expected an integer to be stored exactly, received %v", &g)
+	}
+
+	if _, err := Unmarshal([]byte(`<*Rnan>`), &g); err == nil {
+		t.Error("Expected error unmarshaling NaN into a big.Float, received nothing.")
+	}
+}
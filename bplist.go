@@ -22,5 +22,7 @@ const (
 	bpTagUTF16String       = 0x60
 	bpTagUID               = 0x80
 	bpTagArray             = 0xA0
+	bpTagOrderedSet        = 0xB0 // read as an array; never written
+	bpTagSet               = 0xC0
 	bpTagDictionary        = 0xD0
 )
@@ -0,0 +1,78 @@
+package plist
+
+import "fmt"
+
+// A BinaryDefect classifies the structural problems that can be found in a binary property list.
+type BinaryDefect int
+
+// Binary property list defects, for use with BinaryFormatError.
+const (
+	// DefectHeader: the document is too short, or does not begin with a supported bplist header.
+	DefectHeader BinaryDefect = iota + 1
+
+	// DefectTrailer: the trailer is inconsistent with the document, or with itself; for example, the offset
+	// table it describes does not lie between the header and the trailer.
+	DefectTrailer
+
+	// DefectOffset: an entry in the offset table points outside of the objects that precede it.
+	DefectOffset
+
+	// DefectOverlap: two objects occupy some of the same bytes.
+	DefectOverlap
+
+	// DefectReference: an object reference, or the trailer's top object, names an object that does not exist.
+	DefectReference
+
+	// DefectCycle: a dictionary or array contains itself.
+	DefectCycle
+
+	// DefectLength: the contents of an object extend beyond the end of the objects.
+	DefectLength
+
+	// DefectObject: an object is malformed; for example, it has an unknown type, or a dictionary has a key that
+	// is not a string.
+	DefectObject
+)
+
+var binaryDefectNames = map[BinaryDefect]string{
+	DefectHeader:    "header",
+	DefectTrailer:   "trailer",
+	DefectOffset:    "offset",
+	DefectOverlap:   "overlap",
+	DefectReference: "reference",
+	DefectCycle:     "cycle",
+	DefectLength:    "length",
+	DefectObject:    "object",
+}
+
+func (d BinaryDefect) String() string {
+	if name, ok := binaryDefectNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("BinaryDefect(%d)", int(d))
+}
+
+// A BinaryFormatError describes a structural defect in a binary property list. The errors returned when a binary
+// property list cannot be read wrap a *BinaryFormatError wherever the defect is in the document's structure, so it
+// may be retrieved with errors.As.
+type BinaryFormatError struct {
+	Defect BinaryDefect
+	Offset uint64 // the offset in the document at which the defect was found
+	Object int64  // the index of the object in which the defect was found, or -1 if it is outside of any object
+	Detail string // a description of the defect
+}
+
+func (e *BinaryFormatError) Error() string {
+	if e.Object < 0 {
+		return fmt.Sprintf("%s (at 0x%x)", e.Detail, e.Offset)
+	}
+	return fmt.Sprintf("%s (in object#%d, at 0x%x)", e.Detail, e.Object, e.Offset)
+}
+
+// defect panics with a BinaryFormatError. If object is -1, the defect is attributed to the object being parsed.
+func (p *bplistParser) defect(d BinaryDefect, off offset, object int64, format string, args ...interface{}) {
+	if object < 0 {
+		object = p.current
+	}
+	panic(&BinaryFormatError{Defect: d, Offset: uint64(off), Object: object, Detail: fmt.Sprintf(format, args...)})
+}
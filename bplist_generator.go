@@ -1,10 +1,13 @@
 package plist
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"strconv"
 	"time"
 	"unicode/utf16"
 )
@@ -34,7 +37,20 @@ type bplistGenerator struct {
 	writer   *countedWriter
 	objmap   map[interface{}]uint64 // maps pValue.hash()es to object locations
 	objtable []cfValue
+	tables   *bplistTables // the pooled storage behind objmap and objtable
 	trailer  bplistTrailer
+	features int
+
+	// deduplicate causes identical subtrees, as well as identical scalars, to share one object.
+	deduplicate bool
+
+	ctx context.Context // checked before each object is written, if set
+}
+
+// bplistSubtreeKey identifies a container by its type and the objects it refers to.
+type bplistSubtreeKey struct {
+	tag  uint8
+	refs string
 }
 
 func (p *bplistGenerator) flattenPlistValue(pval cfValue) {
@@ -64,22 +80,71 @@ func (p *bplistGenerator) flattenPlistValue(pval cfValue) {
 	}
 }
 
+// flattenDeduplicatedPlistValue is like flattenPlistValue, but it flattens each container's children
+// before the container itself, so that identical containers can be recognized by their children.
+func (p *bplistGenerator) flattenDeduplicatedPlistValue(pval cfValue) uint64 {
+	var key interface{}
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		pval.sort()
+		refs := make([]byte, 0, 16*len(pval.keys))
+		for _, k := range pval.keys {
+			refs = strconv.AppendUint(append(refs, ','), p.flattenDeduplicatedPlistValue(cfString(k)), 10)
+		}
+		for _, v := range pval.values {
+			refs = strconv.AppendUint(append(refs, ','), p.flattenDeduplicatedPlistValue(v), 10)
+		}
+		key = bplistSubtreeKey{bpTagDictionary, string(refs)}
+	case *cfArray:
+		refs := make([]byte, 0, 8*len(pval.values))
+		for _, v := range pval.values {
+			refs = strconv.AppendUint(append(refs, ','), p.flattenDeduplicatedPlistValue(v), 10)
+		}
+		key = bplistSubtreeKey{p.arrayTag(pval), string(refs)}
+	default:
+		key = pval.hash()
+	}
+
+	idx, ok := p.objmap[key]
+	if !ok {
+		idx = uint64(len(p.objtable))
+		p.objmap[key] = idx
+		p.objtable = append(p.objtable, pval)
+	}
+	p.objmap[pval.hash()] = idx
+	return idx
+}
+
 func (p *bplistGenerator) indexForPlistValue(pval cfValue) (uint64, bool) {
 	v, ok := p.objmap[pval.hash()]
 	return v, ok
 }
 
 func (p *bplistGenerator) generateDocument(root cfValue) {
-	p.objtable = make([]cfValue, 0, 16)
-	p.objmap = make(map[interface{}]uint64)
-	p.flattenPlistValue(root)
+	if p.tables == nil {
+		p.tables = getBplistTables()
+	} else {
+		p.tables.objtable = p.objtable
+		p.tables.reset()
+	}
+	p.objmap, p.objtable = p.tables.objmap, p.tables.objtable
+	if p.deduplicate {
+		p.flattenDeduplicatedPlistValue(root)
+	} else {
+		p.flattenPlistValue(root)
+	}
 
 	p.trailer.NumObjects = uint64(len(p.objtable))
 	p.trailer.ObjectRefSize = uint8(bplistMinimumIntSize(p.trailer.NumObjects))
 
 	p.writer.Write([]byte("bplist00"))
 
-	offtable := make([]uint64, p.trailer.NumObjects)
+	offtable := p.tables.offtable
+	if uint64(cap(offtable)) < p.trailer.NumObjects {
+		offtable = make([]uint64, p.trailer.NumObjects)
+	}
+	offtable = offtable[:p.trailer.NumObjects]
+	p.tables.offtable = offtable
 	for i, pval := range p.objtable {
 		offtable[i] = uint64(p.writer.BytesWritten())
 		p.writePlistValue(pval)
@@ -100,18 +165,23 @@ func (p *bplistGenerator) writePlistValue(pval cfValue) {
 	if pval == nil {
 		return
 	}
+	checkContext(p.ctx)
 
 	switch pval := pval.(type) {
 	case *cfDictionary:
 		p.writeDictionaryTag(pval)
 	case *cfArray:
-		p.writeArrayTag(pval.values)
+		p.writeArrayTag(p.arrayTag(pval), pval.values)
 	case cfString:
 		p.writeStringTag(string(pval))
 	case *cfNumber:
-		p.writeIntTag(pval.signed, pval.value)
+		if pval.big != nil {
+			p.writeInt128Tag(pval.big)
+		} else {
+			p.writeIntTag(pval.signed, pval.value)
+		}
 	case *cfReal:
-		if pval.wide {
+		if pval.wide || p.features == BinaryFeaturesBaseline {
 			p.writeRealTag(pval.value, 64)
 		} else {
 			p.writeRealTag(pval.value, 32)
@@ -120,6 +190,9 @@ func (p *bplistGenerator) writePlistValue(pval cfValue) {
 		p.writeBoolTag(bool(pval))
 	case cfData:
 		p.writeDataTag([]byte(pval))
+	case *cfDataReader:
+		p.writeCountedTag(bpTagData, uint64(pval.size))
+		pval.copyTo(p.writer)
 	case cfDate:
 		p.writeDateTag(time.Time(pval))
 	case cfUID:
@@ -168,6 +241,9 @@ func (p *bplistGenerator) writeIntTag(signed bool, n uint64) {
 		val = uint32(n)
 		tag = bpTagInteger | 0x2
 	case n > uint64(0x7fffffffffffffff) && !signed:
+		if p.features == BinaryFeaturesBaseline {
+			panic(fmt.Errorf("plist: integer %d requires 128-bit storage, which is not permitted by BinaryFeaturesBaseline", n))
+		}
 		// 64-bit values are always *signed* in format 00.
 		// Any unsigned value that doesn't intersect with the signed
 		// range must be sign-extended and stored as a SInt128
@@ -189,6 +265,17 @@ func (p *bplistGenerator) writeIntTag(signed bool, n uint64) {
 	binary.Write(p.writer, binary.BigEndian, val)
 }
 
+// writeInt128Tag writes n, which must fit in 128 bits, as a signed 128-bit integer.
+func (p *bplistGenerator) writeInt128Tag(n *big.Int) {
+	if p.features == BinaryFeaturesBaseline {
+		panic(fmt.Errorf("plist: integer %v requires 128-bit storage, which is not permitted by BinaryFeaturesBaseline", n))
+	}
+	hi, lo := int128Halves(n)
+	binary.Write(p.writer, binary.BigEndian, uint8(bpTagInteger|0x4))
+	binary.Write(p.writer, binary.BigEndian, hi)
+	binary.Write(p.writer, binary.BigEndian, lo)
+}
+
 func (p *bplistGenerator) writeUIDTag(u UID) {
 	nbytes := bplistMinimumIntSize(uint64(u))
 	tag := uint8(bpTagUID | (nbytes - 1))
@@ -280,8 +367,16 @@ func (p *bplistGenerator) writeDictionaryTag(dict *cfDictionary) {
 	}
 }
 
-func (p *bplistGenerator) writeArrayTag(arr []cfValue) {
-	p.writeCountedTag(bpTagArray, uint64(len(arr)))
+// arrayTag returns the tag with which arr is written: bpTagSet, for a set, unless the baseline feature set is in use.
+func (p *bplistGenerator) arrayTag(arr *cfArray) uint8 {
+	if arr.set && p.features != BinaryFeaturesBaseline {
+		return bpTagSet
+	}
+	return bpTagArray
+}
+
+func (p *bplistGenerator) writeArrayTag(tag uint8, arr []cfValue) {
+	p.writeCountedTag(tag, uint64(len(arr)))
 	for _, v := range arr {
 		objIdx, ok := p.indexForPlistValue(v)
 		if !ok {
@@ -292,8 +387,12 @@ func (p *bplistGenerator) writeArrayTag(arr []cfValue) {
 	}
 }
 
-func (p *bplistGenerator) Indent(i string) {
-	// There's nothing to indent.
+func (p *bplistGenerator) Options(o EncoderOptions) {
+	// There's nothing to lay out.
+}
+
+func (p *bplistGenerator) DateLayout(layout string) {
+	// Dates are stored as numbers.
 }
 
 func newBplistGenerator(w io.Writer) *bplistGenerator {
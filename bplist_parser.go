@@ -2,6 +2,7 @@ package plist
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"io/ioutil"
 	"math"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 	"unicode/utf16"
 )
@@ -20,7 +23,10 @@ const (
 type offset uint64
 
 type bplistParser struct {
-	buffer []byte
+	// The document is read from buffer if it is set, and otherwise from readerAt.
+	buffer   []byte
+	readerAt io.ReaderAt
+	size     int64 // the length of the document
 
 	reader        io.ReadSeeker
 	version       int
@@ -29,36 +35,72 @@ type bplistParser struct {
 	trailerOffset uint64
 
 	containerStack []offset // slice of object offsets; manipulated during container deserialization
+	current        int64    // the index of the object being parsed, or -1
+	maxDepth       int      // the limit on the length of containerStack, or 0 for none
+
+	ctx context.Context // checked before each object is parsed, if set
+
+	// detached causes strings and data to be copied out of buffer, which may not outlive the parser.
+	detached bool
+
+	// streamData causes data read from readerAt to be read only when it is used; see Decoder.StreamData.
+	streamData bool
+
+	scratch [32]byte // holds the fields read by fieldAt from readerAt
 }
 
-func (p *bplistParser) validateDocumentTrailer() {
-	if p.trailer.OffsetTableOffset >= p.trailerOffset {
-		panic(fmt.Errorf("offset table beyond beginning of trailer (0x%x, trailer@0x%x)", p.trailer.OffsetTableOffset, p.trailerOffset))
+// trailerError returns the first inconsistency in the trailer, or nil if it describes a valid document.
+func (p *bplistParser) trailerError() error {
+	t := &p.trailer
+	bad := func(d BinaryDefect, format string, args ...interface{}) error {
+		return &BinaryFormatError{Defect: d, Offset: p.trailerOffset, Object: -1, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	if t.OffsetTableOffset >= p.trailerOffset {
+		return bad(DefectTrailer, "offset table beyond beginning of trailer (0x%x, trailer@0x%x)", t.OffsetTableOffset, p.trailerOffset)
 	}
 
-	if p.trailer.OffsetTableOffset < 9 {
-		panic(fmt.Errorf("offset table begins inside header (0x%x)", p.trailer.OffsetTableOffset))
+	if t.OffsetTableOffset < 9 {
+		return bad(DefectTrailer, "offset table begins inside header (0x%x)", t.OffsetTableOffset)
 	}
 
-	if p.trailerOffset > (p.trailer.NumObjects*uint64(p.trailer.OffsetIntSize))+p.trailer.OffsetTableOffset {
-		panic(errors.New("garbage between offset table and trailer"))
+	if t.OffsetIntSize < 1 || t.OffsetIntSize > 8 {
+		return bad(DefectTrailer, "invalid offset size (%d bytes)", t.OffsetIntSize)
 	}
 
-	if p.trailer.OffsetTableOffset+(uint64(p.trailer.OffsetIntSize)*p.trailer.NumObjects) > p.trailerOffset {
-		panic(errors.New("offset table isn't long enough to address every object"))
+	if t.ObjectRefSize < 1 || t.ObjectRefSize > 8 {
+		return bad(DefectTrailer, "invalid object ref size (%d bytes)", t.ObjectRefSize)
 	}
 
-	maxObjectRef := uint64(1) << (8 * p.trailer.ObjectRefSize)
-	if p.trailer.NumObjects > maxObjectRef {
-		panic(fmt.Errorf("more objects (%v) than object ref size (%v bytes) can support", p.trailer.NumObjects, p.trailer.ObjectRefSize))
+	// The offset table must exactly fill the space before the trailer. NumObjects is compared by division,
+	// as a crafted count could overflow the table's length.
+	tableLength := p.trailerOffset - t.OffsetTableOffset
+	if t.NumObjects > tableLength/uint64(t.OffsetIntSize) {
+		return bad(DefectTrailer, "offset table isn't long enough to address every object")
+	}
+	if t.NumObjects*uint64(t.OffsetIntSize) < tableLength {
+		return bad(DefectTrailer, "garbage between offset table and trailer")
+	}
+
+	// An eight-byte object ref can address every object; 1<<64 would overflow.
+	maxObjectRef := uint64(1) << (8 * t.ObjectRefSize)
+	if t.ObjectRefSize < 8 && t.NumObjects > maxObjectRef {
+		return bad(DefectTrailer, "more objects (%v) than object ref size (%v bytes) can support", t.NumObjects, t.ObjectRefSize)
 	}
 
-	if p.trailer.OffsetIntSize < uint8(8) && (uint64(1)<<(8*p.trailer.OffsetIntSize)) <= p.trailer.OffsetTableOffset {
-		panic(errors.New("offset size isn't big enough to address entire file"))
+	if t.OffsetIntSize < uint8(8) && (uint64(1)<<(8*t.OffsetIntSize)) <= t.OffsetTableOffset {
+		return bad(DefectTrailer, "offset size isn't big enough to address entire file")
 	}
 
-	if p.trailer.TopObject >= p.trailer.NumObjects {
-		panic(fmt.Errorf("top object #%d is out of range (only %d exist)", p.trailer.TopObject, p.trailer.NumObjects))
+	if t.TopObject >= t.NumObjects {
+		return bad(DefectReference, "top object #%d is out of range (only %d exist)", t.TopObject, t.NumObjects)
+	}
+	return nil
+}
+
+func (p *bplistParser) validateDocumentTrailer() {
+	if err := p.trailerError(); err != nil {
+		panic(err)
 	}
 }
 
@@ -73,31 +115,121 @@ func (p *bplistParser) parseDocument() (pval cfValue, parseError error) {
 		}
 	}()
 
-	p.buffer, _ = ioutil.ReadAll(p.reader)
+	p.parseHeaderAndTrailer()
+
+	pval = p.objectAtIndex(p.trailer.TopObject)
+	p.checkOverlaps()
+	return
+}
+
+// checkOverlaps panics if any two of the objects that have been parsed occupy the same bytes. A well-formed
+// document never shares bytes between objects; a crafted one can, to make a small document decode as a large one.
+func (p *bplistParser) checkOverlaps() {
+	type extent struct {
+		index      uint64
+		start, end offset
+	}
+
+	extents := make([]extent, 0, len(p.objects))
+	for i, pval := range p.objects {
+		if pval == nil {
+			continue
+		}
+		start := p.offsetForObject(uint64(i))
+		extents = append(extents, extent{uint64(i), start, p.objectEnd(start)})
+	}
+
+	sort.Slice(extents, func(i, j int) bool { return extents[i].start < extents[j].start })
+	for i := 1; i < len(extents); i++ {
+		prev, cur := extents[i-1], extents[i]
+		if cur.start < prev.end {
+			p.defect(DefectOverlap, cur.start, int64(cur.index), "object#%d@0x%x overlaps object#%d@0x%x", cur.index, cur.start, prev.index, prev.start)
+		}
+	}
+}
+
+// objectEnd returns the offset just beyond the object at off, which must already have been parsed successfully.
+func (p *bplistParser) objectEnd(off offset) offset {
+	tag := p.byteAt(off)
+	switch tag & 0xF0 {
+	case bpTagNull:
+		return off + 1
+	case bpTagInteger, bpTagReal:
+		return off + 1 + offset(uint64(1)<<(tag&0xF))
+	case bpTagDate:
+		return off + 9
+	case bpTagUID:
+		return off + 1 + offset(tag&0xF) + 1
+	}
+
+	cnt, start := p.countForTagAtOffset(off)
+	switch tag & 0xF0 {
+	case bpTagUTF16String:
+		cnt *= 2
+	case bpTagArray, bpTagOrderedSet, bpTagSet:
+		cnt *= uint64(p.trailer.ObjectRefSize)
+	case bpTagDictionary:
+		cnt *= 2 * uint64(p.trailer.ObjectRefSize)
+	}
+	return start + offset(cnt)
+}
+
+// parseHeaderAndTrailer validates the document's header and trailer and prepares the object table.
+// It panics on failure.
+//
+// If the reader implements io.ReaderAt, objects are read from it as they are needed; otherwise, or if the reader
+// already holds the document in memory, the entire document is read into memory.
+func (p *bplistParser) parseHeaderAndTrailer() {
+	if p.buffer == nil {
+		if ra, ok := p.reader.(io.ReaderAt); ok && !isMemoryReader(p.reader) {
+			start, err := p.reader.Seek(0, io.SeekCurrent)
+			if err != nil {
+				panic(err)
+			}
+			end, err := p.reader.Seek(0, io.SeekEnd)
+			if err != nil {
+				panic(err)
+			}
+			p.reader.Seek(start, io.SeekStart)
+			p.readerAt = io.NewSectionReader(ra, start, end-start)
+			p.size = end - start
+		} else {
+			p.buffer, _ = ioutil.ReadAll(p.reader)
+		}
+	}
+	if p.buffer != nil {
+		p.size = int64(len(p.buffer))
+	}
 
-	l := len(p.buffer)
+	l := int(p.size)
+	if int64(l) != p.size {
+		panic(errors.New("document is too large"))
+	}
 	if l < 40 {
-		panic(errors.New("not enough data"))
+		p.defect(DefectHeader, 0, -1, "not enough data")
 	}
 
-	if !bytes.Equal(p.buffer[0:6], []byte{'b', 'p', 'l', 'i', 's', 't'}) {
-		panic(errors.New("incomprehensible magic"))
+	if !bytes.Equal(p.bytesAt(0, 6), []byte{'b', 'p', 'l', 'i', 's', 't'}) {
+		p.defect(DefectHeader, 0, -1, "incomprehensible magic")
 	}
 
-	p.version = int(((p.buffer[6] - '0') * 10) + (p.buffer[7] - '0'))
+	version := p.bytesAt(6, 2)
+	p.version = int(((version[0] - '0') * 10) + (version[1] - '0'))
 
 	if p.version > 1 {
-		panic(fmt.Errorf("unexpected version %d", p.version))
+		p.defect(DefectHeader, 6, -1, "unexpected version %d", p.version)
 	}
 
-	p.trailerOffset = uint64(l - 32)
-	p.trailer = bplistTrailer{
-		SortVersion:       p.buffer[p.trailerOffset+5],
-		OffsetIntSize:     p.buffer[p.trailerOffset+6],
-		ObjectRefSize:     p.buffer[p.trailerOffset+7],
-		NumObjects:        binary.BigEndian.Uint64(p.buffer[p.trailerOffset+8:]),
-		TopObject:         binary.BigEndian.Uint64(p.buffer[p.trailerOffset+16:]),
-		OffsetTableOffset: binary.BigEndian.Uint64(p.buffer[p.trailerOffset+24:]),
+	p.readTrailer(l)
+	if !p.trailerIsValid() {
+		// The buffer may hold several concatenated documents: look for the end of the first one.
+		if end := p.findDocumentEnd(l); end > 0 {
+			p.size = int64(end)
+			if p.buffer != nil {
+				p.buffer = p.buffer[:end]
+			}
+		}
+		p.readTrailer(int(p.size))
 	}
 
 	p.validateDocumentTrailer()
@@ -110,9 +242,121 @@ func (p *bplistParser) parseDocument() (pval cfValue, parseError error) {
 	// - Top object is in range
 
 	p.objects = make([]cfValue, p.trailer.NumObjects)
+}
 
-	pval = p.objectAtIndex(p.trailer.TopObject)
-	return
+// readTrailer reads the trailer of a document that ends at offset end.
+func (p *bplistParser) readTrailer(end int) {
+	p.trailerOffset = uint64(end - 32)
+	b := p.fieldAt(offset(p.trailerOffset), 32)
+	p.trailer = bplistTrailer{
+		SortVersion:       b[5],
+		OffsetIntSize:     b[6],
+		ObjectRefSize:     b[7],
+		NumObjects:        binary.BigEndian.Uint64(b[8:]),
+		TopObject:         binary.BigEndian.Uint64(b[16:]),
+		OffsetTableOffset: binary.BigEndian.Uint64(b[24:]),
+	}
+}
+
+// findDocumentEnd returns the end of the first of several concatenated documents that together end at l, or 0 if
+// there is no such document. A trailer is only valid if it immediately follows an offset table that it describes,
+// so it is unlikely to be found by accident. The document is read a window at a time, and each possible end is
+// checked cheaply before its trailer is validated in full, so that rejecting a corrupt document stays fast.
+func (p *bplistParser) findDocumentEnd(l int) int {
+	const window = 64 * 1024
+	var buf []byte
+	base := 0
+	for end := 40; end < l; end++ {
+		if end > base+len(buf) {
+			base = end - 32
+			n := l - base
+			if n > window {
+				n = window
+			}
+			buf = p.bytesAt(offset(base), uint64(n))
+		}
+		if !plausibleTrailer(buf[end-32-base:end-base], uint64(end-32)) {
+			continue
+		}
+		p.readTrailer(end)
+		if p.trailerIsValid() {
+			return end
+		}
+	}
+	return 0
+}
+
+// plausibleTrailer reports whether b, read at trailerOffset, could be a trailer: whether it describes an offset
+// table that ends where it begins.
+func plausibleTrailer(b []byte, trailerOffset uint64) bool {
+	offsetIntSize := uint64(b[6])
+	numObjects := binary.BigEndian.Uint64(b[8:])
+	offsetTableOffset := binary.BigEndian.Uint64(b[24:])
+	if offsetIntSize < 1 || offsetIntSize > 8 || offsetTableOffset >= trailerOffset {
+		return false
+	}
+	tableLength := trailerOffset - offsetTableOffset
+	return numObjects <= tableLength/offsetIntSize && numObjects*offsetIntSize == tableLength
+}
+
+// bytesAt returns the n bytes of the document that begin at off. The result refers to buffer, if it is set,
+// and must not be modified.
+func (p *bplistParser) bytesAt(off offset, n uint64) []byte {
+	p.checkBounds(off, n)
+	if p.buffer != nil {
+		return p.buffer[off : uint64(off)+n]
+	}
+	return p.readBytesAt(make([]byte, n), off)
+}
+
+// fieldAt is like bytesAt, for the integers, references and other fields of at most 32 bytes that make up the
+// document. Rather than allocating, it reads them from readerAt into scratch, so the result is only valid until
+// the next call.
+func (p *bplistParser) fieldAt(off offset, n uint64) []byte {
+	p.checkBounds(off, n)
+	if p.buffer != nil {
+		return p.buffer[off : uint64(off)+n]
+	}
+	return p.readBytesAt(p.scratch[:n], off)
+}
+
+func (p *bplistParser) checkBounds(off offset, n uint64) {
+	if uint64(off) > uint64(p.size) || n > uint64(p.size)-uint64(off) {
+		p.defect(DefectLength, off, -1, "%d bytes at 0x%x extend beyond the end of the document", n, off)
+	}
+}
+
+func (p *bplistParser) readBytesAt(b []byte, off offset) []byte {
+	if _, err := p.readerAt.ReadAt(b, int64(off)); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// byteAt returns the byte of the document at off.
+func (p *bplistParser) byteAt(off offset) byte {
+	return p.fieldAt(off, 1)[0]
+}
+
+// isMemoryReader reports whether r reads from memory, from which it is cheaper to copy the whole document once
+// than to read each of its fields through ReadAt.
+func isMemoryReader(r io.Reader) bool {
+	switch r.(type) {
+	case *bytes.Reader, *strings.Reader:
+		return true
+	}
+	return false
+}
+
+func (p *bplistParser) trailerIsValid() bool {
+	return p.trailerError() == nil
+}
+
+// contentsFit reports whether count items of size bytes, beginning at start, end before the offset table.
+// It is careful not to overflow, as count is read from the document.
+func (p *bplistParser) contentsFit(start offset, count, size uint64) bool {
+	limit := p.trailer.OffsetTableOffset
+	return uint64(start) <= limit && count <= (limit-uint64(start))/size
 }
 
 // parseSizedInteger returns a 128-bit integer as low64, high64
@@ -121,26 +365,29 @@ func (p *bplistParser) parseSizedInteger(off offset, nbytes int) (lo uint64, hi
 	// 1, 2 or 4-byte integers be interpreted as unsigned. 8-byte integers are
 	// signed (always?) and therefore must be sign extended here.
 	// negative 1, 2, or 4-byte integers are always emitted as 64-bit.
+	if nbytes > 16 || (nbytes > 8 && nbytes < 16) {
+		p.defect(DefectObject, off, -1, "illegal integer size")
+	}
+	b := p.fieldAt(off, uint64(nbytes))
 	switch nbytes {
 	case 1:
-		lo, hi = uint64(p.buffer[off]), 0
+		lo, hi = uint64(b[0]), 0
 	case 2:
-		lo, hi = uint64(binary.BigEndian.Uint16(p.buffer[off:])), 0
+		lo, hi = uint64(binary.BigEndian.Uint16(b)), 0
 	case 4:
-		lo, hi = uint64(binary.BigEndian.Uint32(p.buffer[off:])), 0
+		lo, hi = uint64(binary.BigEndian.Uint32(b)), 0
 	case 8:
-		lo = binary.BigEndian.Uint64(p.buffer[off:])
-		if p.buffer[off]&0x80 != 0 {
+		lo = binary.BigEndian.Uint64(b)
+		if b[0]&0x80 != 0 {
 			// sign extend if lo is signed
 			hi = signedHighBits
 		}
 	case 16:
-		lo, hi = binary.BigEndian.Uint64(p.buffer[off+8:]), binary.BigEndian.Uint64(p.buffer[off:])
+		lo, hi = binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b)
 	default:
-		if nbytes > 8 {
-			panic(errors.New("illegal integer size"))
+		for _, c := range b {
+			lo = lo<<8 | uint64(c)
 		}
-		lo, hi = binary.BigEndian.Uint64(p.buffer[off-(8-offset(nbytes)):]) & ((1<<offset(nbytes*8))-1), 0
 	}
 	newOffset = off + offset(nbytes)
 	return
@@ -158,22 +405,44 @@ func (p *bplistParser) parseOffsetAtOffset(off offset) (offset, offset) {
 
 func (p *bplistParser) objectAtIndex(index uint64) cfValue {
 	if index >= p.trailer.NumObjects {
-		panic(fmt.Errorf("invalid object#%d (max %d)", index, p.trailer.NumObjects))
+		p.defect(DefectReference, 0, int64(index), "invalid object#%d (max %d)", index, p.trailer.NumObjects)
 	}
 
 	if pval := p.objects[index]; pval != nil {
 		return pval
 	}
 
-	off, _ := p.parseOffsetAtOffset(offset(p.trailer.OffsetTableOffset + (index * uint64(p.trailer.OffsetIntSize))))
-	if off > offset(p.trailer.OffsetTableOffset-1) {
-		panic(fmt.Errorf("object#%d starts beyond beginning of object table (0x%x, table@0x%x)", index, off, p.trailer.OffsetTableOffset))
-	}
+	checkContext(p.ctx)
+	pval := p.parseObject(index)
+	p.objects[index] = pval
+	return pval
+}
 
+// parseObject parses the object with the given index. Defects found in the object itself, rather than in the
+// objects it refers to, are attributed to it.
+func (p *bplistParser) parseObject(index uint64) cfValue {
+	off := p.offsetForObject(index)
+	outer := p.current
+	p.current = int64(index)
 	pval := p.parseTagAtOffset(off)
-	p.objects[index] = pval
+	p.current = outer
 	return pval
+}
 
+func (p *bplistParser) offsetForObject(index uint64) offset {
+	if index >= p.trailer.NumObjects {
+		p.defect(DefectReference, 0, int64(index), "invalid object#%d (max %d)", index, p.trailer.NumObjects)
+	}
+
+	entry := offset(p.trailer.OffsetTableOffset + (index * uint64(p.trailer.OffsetIntSize)))
+	off, _ := p.parseOffsetAtOffset(entry)
+	if off > offset(p.trailer.OffsetTableOffset-1) {
+		p.defect(DefectOffset, entry, int64(index), "object#%d starts beyond beginning of object table (0x%x, table@0x%x)", index, off, p.trailer.OffsetTableOffset)
+	}
+	if off < 8 {
+		p.defect(DefectOffset, entry, int64(index), "object#%d starts inside the header (0x%x)", index, off)
+	}
+	return off
 }
 
 func (p *bplistParser) pushNestedObject(off offset) {
@@ -182,6 +451,9 @@ func (p *bplistParser) pushNestedObject(off offset) {
 			p.panicNestedObject(off)
 		}
 	}
+	if p.maxDepth > 0 && len(p.containerStack) >= p.maxDepth {
+		panic(maxDepthError(p.maxDepth))
+	}
 	p.containerStack = append(p.containerStack, off)
 }
 
@@ -192,7 +464,7 @@ func (p *bplistParser) panicNestedObject(off offset) {
 	}
 
 	// %s0x%d: ids above ends with " > "
-	panic(fmt.Errorf("self-referential collection@0x%x (%s0x%x) cannot be deserialized", off, ids, off))
+	p.defect(DefectCycle, off, -1, "self-referential collection@0x%x (%s0x%x) cannot be deserialized", off, ids, off)
 }
 
 func (p *bplistParser) popNestedObject() {
@@ -200,7 +472,7 @@ func (p *bplistParser) popNestedObject() {
 }
 
 func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 
 	switch tag & 0xF0 {
 	case bpTagNull:
@@ -210,23 +482,28 @@ func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
 		}
 	case bpTagInteger:
 		lo, hi, _ := p.parseIntegerAtOffset(off)
-		return &cfNumber{
+		n := &cfNumber{
 			signed: hi == signedHighBits, // a signed integer is stored as a 128-bit integer with the top 64 bits set
 			value:  lo,
 		}
+		if hi != 0 && !(n.signed && int64(lo) < 0) {
+			// Beyond the range of int64 and uint64, so only the low half is in value.
+			n.big = int128Value(hi, lo)
+		}
+		return n
 	case bpTagReal:
 		nbytes := 1 << (tag & 0x0F)
 		switch nbytes {
 		case 4:
-			bits := binary.BigEndian.Uint32(p.buffer[off+1:])
+			bits := binary.BigEndian.Uint32(p.fieldAt(off+1, 4))
 			return &cfReal{wide: false, value: float64(math.Float32frombits(bits))}
 		case 8:
-			bits := binary.BigEndian.Uint64(p.buffer[off+1:])
+			bits := binary.BigEndian.Uint64(p.fieldAt(off+1, 8))
 			return &cfReal{wide: true, value: math.Float64frombits(bits)}
 		}
-		panic(errors.New("illegal float size"))
+		p.defect(DefectObject, off, -1, "illegal float size")
 	case bpTagDate:
-		bits := binary.BigEndian.Uint64(p.buffer[off+1:])
+		bits := binary.BigEndian.Uint64(p.fieldAt(off+1, 8))
 		val := math.Float64frombits(bits)
 
 		// Apple Epoch is 20110101000000Z
@@ -237,6 +514,9 @@ func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
 		time := time.Unix(int64(sec), int64(fsec*float64(time.Second))).In(time.UTC)
 		return cfDate(time)
 	case bpTagData:
+		if p.streamData && p.buffer == nil {
+			return p.parseDataReaderAtOffset(off)
+		}
 		data := p.parseDataAtOffset(off)
 		return cfData(data)
 	case bpTagASCIIString:
@@ -250,19 +530,24 @@ func (p *bplistParser) parseTagAtOffset(off offset) cfValue {
 		return cfUID(lo)
 	case bpTagDictionary:
 		return p.parseDictionaryAtOffset(off)
-	case bpTagArray:
+	case bpTagArray, bpTagOrderedSet:
 		return p.parseArrayAtOffset(off)
+	case bpTagSet:
+		arr := p.parseArrayAtOffset(off)
+		arr.set = true
+		return arr
 	}
-	panic(fmt.Errorf("unexpected atom 0x%2.02x at offset 0x%x", tag, off))
+	p.defect(DefectObject, off, -1, "unexpected atom 0x%2.02x at offset 0x%x", tag, off)
+	return nil
 }
 
 func (p *bplistParser) parseIntegerAtOffset(off offset) (uint64, uint64, offset) {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 	return p.parseSizedInteger(off+1, 1<<(tag&0xF))
 }
 
 func (p *bplistParser) countForTagAtOffset(off offset) (uint64, offset) {
-	tag := p.buffer[off]
+	tag := p.byteAt(off)
 	cnt := uint64(tag & 0x0F)
 	if cnt == 0xF {
 		cnt, _, off = p.parseIntegerAtOffset(off + 1)
@@ -273,46 +558,68 @@ func (p *bplistParser) countForTagAtOffset(off offset) (uint64, offset) {
 
 func (p *bplistParser) parseDataAtOffset(off offset) []byte {
 	len, start := p.countForTagAtOffset(off)
-	if start+offset(len) > offset(p.trailer.OffsetTableOffset) {
-		panic(fmt.Errorf("data@0x%x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start)))
+	if !p.contentsFit(start, len, 1) {
+		p.defect(DefectLength, off, -1, "data@0x%x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start))
+	}
+	data := p.bytesAt(start, len)
+	if p.detached && p.buffer != nil {
+		data = append(make([]byte, 0, len), data...)
+	}
+	return data
+}
+
+// parseDataReaderAtOffset is like parseDataAtOffset, but the data it returns is read from the document only when
+// it is used.
+func (p *bplistParser) parseDataReaderAtOffset(off offset) *cfDataReader {
+	len, start := p.countForTagAtOffset(off)
+	if !p.contentsFit(start, len, 1) {
+		p.defect(DefectLength, off, -1, "data@0x%x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start))
 	}
-	return p.buffer[start : start+offset(len)]
+	return &cfDataReader{ra: p.readerAt, off: int64(start), size: int64(len)}
 }
 
 func (p *bplistParser) parseASCIIStringAtOffset(off offset) string {
 	len, start := p.countForTagAtOffset(off)
-	if start+offset(len) > offset(p.trailer.OffsetTableOffset) {
-		panic(fmt.Errorf("ascii string@0x%x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start)))
+	if !p.contentsFit(start, len, 1) {
+		p.defect(DefectLength, off, -1, "ascii string@0x%x too long (%v bytes, max is %v)", off, len, p.trailer.OffsetTableOffset-uint64(start))
 	}
 
+	if p.detached || p.buffer == nil {
+		return string(p.bytesAt(start, len))
+	}
 	return zeroCopy8BitString(p.buffer, int(start), int(len))
 }
 
 func (p *bplistParser) parseUTF16StringAtOffset(off offset) string {
 	len, start := p.countForTagAtOffset(off)
-	bytes := len * 2
-	if start+offset(bytes) > offset(p.trailer.OffsetTableOffset) {
-		panic(fmt.Errorf("utf16 string@0x%x too long (%v bytes, max is %v)", off, bytes, p.trailer.OffsetTableOffset-uint64(start)))
+	if !p.contentsFit(start, len, 2) {
+		p.defect(DefectLength, off, -1, "utf16 string@0x%x too long (%v characters, max is %v)", off, len, (p.trailer.OffsetTableOffset-uint64(start))/2)
 	}
+	bytes := len * 2
 
+	b := p.bytesAt(start, bytes)
 	u16s := make([]uint16, len)
-	for i := offset(0); i < offset(len); i++ {
-		u16s[i] = binary.BigEndian.Uint16(p.buffer[start+(i*2):])
+	for i := range u16s {
+		u16s[i] = binary.BigEndian.Uint16(b[i*2:])
 	}
 	runes := utf16.Decode(u16s)
 	return string(runes)
 }
 
 func (p *bplistParser) parseObjectListAtOffset(off offset, count uint64) []cfValue {
-	if off+offset(count*uint64(p.trailer.ObjectRefSize)) > offset(p.trailer.OffsetTableOffset) {
-		panic(fmt.Errorf("list@0x%x length (%v) puts its end beyond the offset table at 0x%x", off, count, p.trailer.OffsetTableOffset))
+	if !p.contentsFit(off, count, uint64(p.trailer.ObjectRefSize)) {
+		p.defect(DefectLength, off, -1, "list@0x%x length (%v) puts its end beyond the offset table at 0x%x", off, count, p.trailer.OffsetTableOffset)
 	}
 	objects := make([]cfValue, count)
 
 	next := off
-	var oid uint64
 	for i := uint64(0); i < count; i++ {
+		ref := next
+		var oid uint64
 		oid, next = p.parseObjectRefAtOffset(next)
+		if oid >= p.trailer.NumObjects {
+			p.defect(DefectReference, ref, -1, "invalid object#%d (max %d)", oid, p.trailer.NumObjects)
+		}
 		objects[i] = p.objectAtIndex(oid)
 	}
 
@@ -332,7 +639,7 @@ func (p *bplistParser) parseDictionaryAtOffset(off offset) *cfDictionary {
 		if str, ok := objects[i].(cfString); ok {
 			keys[i] = string(str)
 		} else {
-			panic(fmt.Errorf("dictionary@0x%x contains non-string key at index %d", off, i))
+			p.defect(DefectObject, off, -1, "dictionary@0x%x contains non-string key at index %d", off, i)
 		}
 	}
 
@@ -346,11 +653,11 @@ func (p *bplistParser) parseArrayAtOffset(off offset) *cfArray {
 	p.pushNestedObject(off)
 	defer p.popNestedObject()
 
-	// an array is just an object list
+	// an array (or an ordered or unordered set) is just an object list
 	cnt, start := p.countForTagAtOffset(off)
-	return &cfArray{p.parseObjectListAtOffset(start, cnt)}
+	return &cfArray{values: p.parseObjectListAtOffset(start, cnt)}
 }
 
 func newBplistParser(r io.ReadSeeker) *bplistParser {
-	return &bplistParser{reader: r}
+	return &bplistParser{reader: r, current: -1}
 }
@@ -0,0 +1,260 @@
+package plist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// bplistStreamRefSize is the width of every object reference in a streamed binary property list.
+// The number of objects is not known until the document is complete, so references are always 64 bits wide.
+const bplistStreamRefSize = 8
+
+// bplistStreamFrame records a container whose contents are still being written.
+type bplistStreamFrame struct {
+	dictionary bool
+	start      int64  // the position of the container's first reference in the reference file
+	count      uint64 // the number of references; keys and values alternate in dictionaries
+}
+
+// bplistStreamGenerator writes a binary property list as it is produced, rather than assembling it in memory.
+// Each object is written as soon as it is complete, so that containers follow their contents and the root object
+// comes last. The offset of every object, and the references held by each unfinished container, are kept in
+// temporary files; memory use is bounded by the largest single value passed to writePlistValue.
+//
+// Strings are not uniqued, and dictionary keys are written in the order given.
+type bplistStreamGenerator struct {
+	*bplistGenerator
+	output *bufio.Writer
+	dir    string
+
+	offsets  *bplistStreamTempFile // the offset of each object, as a 64-bit integer
+	refs     *bplistStreamTempFile // the references held by every frame, in order
+	frames   []bplistStreamFrame
+	nobjects uint64
+}
+
+// bplistStreamTempFile is a buffered temporary file whose end can be moved back, so that it may be used as a stack.
+type bplistStreamTempFile struct {
+	*os.File
+	w    *bufio.Writer
+	size int64
+}
+
+func newBplistStreamTempFile(dir string) *bplistStreamTempFile {
+	f, err := ioutil.TempFile(dir, "plist-*")
+	if err != nil {
+		panic(err)
+	}
+	t := &bplistStreamTempFile{File: f}
+	t.w = bufio.NewWriter(mustWriter{t})
+	return t
+}
+
+// Write writes at the end of the file, as set by truncate.
+func (t *bplistStreamTempFile) Write(b []byte) (int, error) {
+	n, err := t.File.WriteAt(b, t.size)
+	t.size += int64(n)
+	return n, err
+}
+
+func (t *bplistStreamTempFile) writeUint64(n uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	t.w.Write(b[:])
+}
+
+func (t *bplistStreamTempFile) end() int64 {
+	return t.size + int64(t.w.Buffered())
+}
+
+// reader returns a reader for the 64-bit integers stored in the file from start onwards.
+func (t *bplistStreamTempFile) reader(start int64) *bufio.Reader {
+	if err := t.w.Flush(); err != nil {
+		panic(err)
+	}
+	return bufio.NewReader(io.NewSectionReader(t.File, start, t.size-start))
+}
+
+// truncate discards everything in the file from size onwards.
+func (t *bplistStreamTempFile) truncate(size int64) {
+	if err := t.w.Flush(); err != nil {
+		panic(err)
+	}
+	t.size = size
+}
+
+func (t *bplistStreamTempFile) remove() {
+	t.File.Close()
+	os.Remove(t.File.Name())
+}
+
+func readUint64(r io.Reader) uint64 {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (p *bplistStreamGenerator) beginDocument() {
+	p.offsets = newBplistStreamTempFile(p.dir)
+	p.refs = newBplistStreamTempFile(p.dir)
+	p.frames = p.frames[:0]
+	p.nobjects = 0
+	p.writer.Write([]byte("bplist00"))
+}
+
+func (p *bplistStreamGenerator) endDocument() {
+	defer p.offsets.remove()
+	defer p.refs.remove()
+
+	p.trailer.NumObjects = p.nobjects
+	p.trailer.ObjectRefSize = bplistStreamRefSize
+	p.trailer.TopObject = p.nobjects - 1
+	p.trailer.OffsetIntSize = uint8(bplistMinimumIntSize(uint64(p.writer.BytesWritten())))
+	p.trailer.OffsetTableOffset = uint64(p.writer.BytesWritten())
+
+	r := p.offsets.reader(0)
+	for i := uint64(0); i < p.nobjects; i++ {
+		p.writeSizedInt(readUint64(r), int(p.trailer.OffsetIntSize))
+	}
+
+	binary.Write(p.writer, binary.BigEndian, p.trailer)
+	if err := p.output.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+// discard removes the temporary files of a property list that will not be completed.
+func (p *bplistStreamGenerator) discard() {
+	if p.offsets != nil {
+		p.offsets.remove()
+		p.refs.remove()
+		p.offsets, p.refs = nil, nil
+	}
+}
+
+func (p *bplistStreamGenerator) generateDocument(root cfValue) {
+	p.beginDocument()
+	p.writePlistValue(root)
+	p.endDocument()
+}
+
+// beginObject records the offset of a new object, and returns its index.
+func (p *bplistStreamGenerator) beginObject() uint64 {
+	p.offsets.writeUint64(uint64(p.writer.BytesWritten()))
+	p.nobjects++
+	return p.nobjects - 1
+}
+
+// addRef adds a reference to the innermost unfinished container.
+func (p *bplistStreamGenerator) addRef(idx uint64) {
+	if n := len(p.frames); n > 0 {
+		p.refs.writeUint64(idx)
+		p.frames[n-1].count++
+	}
+}
+
+// writeObject writes pval, and every object it contains, returning the index of pval.
+func (p *bplistStreamGenerator) writeObject(pval cfValue) uint64 {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		pval.sort()
+		refs := make([]uint64, 0, 2*len(pval.keys))
+		for _, k := range pval.keys {
+			refs = append(refs, p.writeObject(cfString(k)))
+		}
+		for _, v := range pval.values {
+			refs = append(refs, p.writeObject(v))
+		}
+		idx := p.beginObject()
+		p.writeCountedTag(bpTagDictionary, uint64(len(pval.keys)))
+		for _, ref := range refs {
+			p.writeSizedInt(ref, bplistStreamRefSize)
+		}
+		return idx
+	case *cfArray:
+		refs := make([]uint64, 0, len(pval.values))
+		for _, v := range pval.values {
+			refs = append(refs, p.writeObject(v))
+		}
+		idx := p.beginObject()
+		p.writeCountedTag(p.arrayTag(pval), uint64(len(refs)))
+		for _, ref := range refs {
+			p.writeSizedInt(ref, bplistStreamRefSize)
+		}
+		return idx
+	}
+	idx := p.beginObject()
+	p.bplistGenerator.writePlistValue(pval)
+	return idx
+}
+
+func (p *bplistStreamGenerator) beginDictionary() {
+	p.frames = append(p.frames, bplistStreamFrame{dictionary: true, start: p.refs.end()})
+}
+
+func (p *bplistStreamGenerator) beginArray() {
+	p.frames = append(p.frames, bplistStreamFrame{start: p.refs.end()})
+}
+
+// endContainer writes the innermost unfinished container, and discards its references.
+func (p *bplistStreamGenerator) endContainer() {
+	n := len(p.frames)
+	frame := p.frames[n-1]
+	p.frames = p.frames[:n-1]
+
+	idx := p.beginObject()
+	if frame.dictionary {
+		// Keys and values were recorded in pairs, but all of the keys must precede all of the values.
+		p.writeCountedTag(bpTagDictionary, frame.count/2)
+		for half := uint64(0); half < 2; half++ {
+			r := p.refs.reader(frame.start)
+			for i := uint64(0); i < frame.count; i++ {
+				ref := readUint64(r)
+				if i%2 == half {
+					p.writeSizedInt(ref, bplistStreamRefSize)
+				}
+			}
+		}
+	} else {
+		p.writeCountedTag(bpTagArray, frame.count)
+		r := p.refs.reader(frame.start)
+		for i := uint64(0); i < frame.count; i++ {
+			p.writeSizedInt(readUint64(r), bplistStreamRefSize)
+		}
+	}
+	p.refs.truncate(frame.start)
+	p.addRef(idx)
+}
+
+func (p *bplistStreamGenerator) endDictionary() {
+	p.endContainer()
+}
+
+func (p *bplistStreamGenerator) endArray() {
+	p.endContainer()
+}
+
+func (p *bplistStreamGenerator) writeKey(k string) {
+	p.addRef(p.writeObject(cfString(k)))
+}
+
+func (p *bplistStreamGenerator) beginElement(inDictionary bool) {}
+func (p *bplistStreamGenerator) endElement(inDictionary bool)   {}
+
+func (p *bplistStreamGenerator) writePlistValue(pval cfValue) {
+	p.addRef(p.writeObject(pval))
+}
+
+func newBplistStreamGenerator(w io.Writer, dir string) *bplistStreamGenerator {
+	output := bufio.NewWriter(w)
+	return &bplistStreamGenerator{
+		bplistGenerator: newBplistGenerator(output),
+		output:          output,
+		dir:             dir,
+	}
+}
@@ -3,8 +3,11 @@ package plist
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
+	"reflect"
 	"testing"
 )
 
@@ -36,6 +39,18 @@ func TestBplistInt128(t *testing.T) {
 	if pinteger, ok := pval.(*cfNumber); !ok || pinteger.value != expected {
 		t.Error("Expected", expected, "received", pval)
 	}
+
+	// The whole 128-bit value is stored in an empty interface, and is written back as it was read.
+	var v interface{}
+	if _, err := Unmarshal(bplist, &v); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := v.(*big.Int); !ok || n.Text(16) != "102030405060708090a0b0c0d0e0f10" {
+		t.Errorf("Expected a big.Int, received %#v", v)
+	}
+	if doc, err := Marshal(v, BinaryFormat); err != nil || !bytes.Contains(doc, bplist[8:25]) {
+		t.Errorf("Expected the 128-bit integer to be written back, received % x (%v)", doc, err)
+	}
 }
 
 func TestBplistSignedIntValues(t *testing.T) {
@@ -176,3 +191,135 @@ func TestBplistNonPowerOfTwoOffsetIntSizes(t *testing.T) {
 		t.Error("Unexpected error", err)
 	}
 }
+
+// countingReaderAt counts the bytes read from a document.
+type countingReaderAt struct {
+	*io.SectionReader
+	n int
+}
+
+func (r *countingReaderAt) Read(b []byte) (int, error) {
+	n, err := r.SectionReader.Read(b)
+	r.n += n
+	return n, err
+}
+
+func (r *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := r.SectionReader.ReadAt(b, off)
+	r.n += n
+	return n, err
+}
+
+func TestBplistReaderAt(t *testing.T) {
+	value := map[string]interface{}{
+		"large": bytes.Repeat([]byte{0xAA}, 64*1024),
+		"name":  "small",
+		"text":  "café",
+	}
+	doc, err := Marshal(value, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &countingReaderAt{SectionReader: io.NewSectionReader(bytes.NewReader(doc), 0, int64(len(doc)))}
+	var decoded map[string]interface{}
+	if err := NewDecoder(r).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %#v, received %#v", value, decoded)
+	}
+
+	// Reading one small value should not read the large one.
+	r = &countingReaderAt{SectionReader: io.NewSectionReader(bytes.NewReader(doc), 0, int64(len(doc)))}
+	lp, err := NewLazyPlist(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := lp.Decode(&name, "name"); err != nil || name != "small" {
+		t.Errorf("Expected small, received %q (%v)", name, err)
+	}
+	if r.n >= len(doc)/2 {
+		t.Errorf("Expected to read a fraction of the %d byte document, read %d bytes", len(doc), r.n)
+	}
+
+	// Truncated documents must fail cleanly.
+	truncated := doc[:len(doc)-40]
+	var v interface{}
+	if err := NewDecoder(io.NewSectionReader(bytes.NewReader(truncated), 0, int64(len(truncated)))).Decode(&v); err == nil {
+		t.Error("Expected an error decoding a truncated document")
+	}
+}
+
+func TestBplistSets(t *testing.T) {
+	markers := []struct {
+		name   string
+		marker byte
+	}{
+		{"Set", bpTagSet},
+		{"OrderedSet", bpTagOrderedSet},
+	}
+	for _, m := range markers {
+		m := m
+		subtest(t, m.name, func(t *testing.T) {
+			bplist := []byte{
+				'b', 'p', 'l', 'i', 's', 't', '0', '0',
+
+				// Set (2 entries)
+				m.marker | 2, 0x01, 0x02,
+
+				// "a", "b"
+				0x51, 'a',
+				0x51, 'b',
+
+				// Offset table
+				0x08, 0x0B, 0x0D,
+
+				// Trailer
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0F,
+			}
+			expected := []string{"a", "b"}
+
+			var strs []string
+			if _, err := Unmarshal(bplist, &strs); err != nil || !reflect.DeepEqual(strs, expected) {
+				t.Errorf("Expected %v, received %v (%v)", expected, strs, err)
+			}
+
+			var v interface{}
+			if _, err := Unmarshal(bplist, &v); err != nil || !reflect.DeepEqual(v, []interface{}{"a", "b"}) {
+				t.Errorf("Expected %v, received %#v (%v)", expected, v, err)
+			}
+
+			var toks []Token
+			dec := NewDecoder(bytes.NewReader(bplist))
+			for {
+				tok, err := dec.Token()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				toks = append(toks, tok)
+			}
+			if !reflect.DeepEqual(toks, []Token{ArrayStart{}, "a", "b", ArrayEnd{}}) {
+				t.Errorf("Unexpected tokens %#v", toks)
+			}
+
+			lp, err := NewLazyPlist(bytes.NewReader(bplist))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s string
+			if n, err := lp.Len(); err != nil || n != 2 {
+				t.Errorf("Expected a length of 2, received %d (%v)", n, err)
+			}
+			if err := lp.Decode(&s, 1); err != nil || s != "b" {
+				t.Errorf("Expected b, received %q (%v)", s, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package plist
+
+import "time"
+
+// canonicalOptions fixes the layout of canonical property lists, so that it does not change with the Encoder's
+// defaults.
+var canonicalOptions = EncoderOptions{
+	Newline:        "\n",
+	XMLDeclaration: `<?xml version="1.0" encoding="UTF-8"?>`,
+	XMLDoctype:     `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`,
+	PlistVersion:   "1.0",
+}
+
+// Canonical returns the canonical encoding of v, which is marshaled as for Marshal. Values that are equal as
+// property lists have the same canonical encoding, whichever Go types they are marshaled from and whatever the
+// order of their keys, so it is suitable for hashing and signing. The canonical encoding is an XML property list:
+//
+//   - It begins with the XML declaration and document type declaration written by Apple's tools, each followed by
+//     a single newline, and has no other whitespace between elements, and no final newline.
+//   - The keys of every dictionary are sorted in increasing order of their bytes. Comments are omitted.
+//   - Integers are written in decimal, without leading zeros or a + sign, whether they are signed or unsigned.
+//   - Reals are written in the shortest form that reads back exactly, as by strconv.FormatFloat with format 'g'; 0
+//     is never written with a sign, and infinities and NaN are written as inf, -inf and nan.
+//   - Dates are written in UTC, as by time.RFC3339, so any fraction of a second is discarded.
+//   - Data is written as a single line of standard base64, with padding.
+//   - Strings are written as UTF-8, with <, >, &, ', ", tab, newline and carriage return escaped as xml.EscapeText
+//     escapes them. Strings are compared byte for byte, without Unicode normalization.
+//
+// The canonical encoding will not change in future versions of this package.
+func Canonical(v interface{}) ([]byte, error) {
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
+	enc := NewEncoder(buf)
+	enc.SetOptions(canonicalOptions)
+	enc.DateLayout(time.RFC3339)
+	enc.KeyOrder(KeyOrderSorted)
+	enc.canonical = true
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// canonicalize prepares pval, which has just been marshaled, to be written in canonical form, and returns it.
+func canonicalize(pval cfValue) cfValue {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		// Dictionaries marshaled from RawValues retain the order in which they were read; sort them, too.
+		pval.ordered = false
+		pval.comments = nil
+		for i, v := range pval.values {
+			pval.values[i] = canonicalize(v)
+		}
+	case *cfArray:
+		for i, v := range pval.values {
+			pval.values[i] = canonicalize(v)
+		}
+	case *cfReal:
+		if pval.value == 0 {
+			pval.value = 0 // clears the sign of -0
+		}
+	case cfDate:
+		// Dates marshaled from RawValues retain the time zone in which they were read.
+		return cfDate(time.Time(pval).In(time.UTC))
+	}
+	return pval
+}
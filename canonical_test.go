@@ -0,0 +1,57 @@
+package plist
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCanonical(t *testing.T) {
+	type payload struct {
+		Name    string
+		Count   int8
+		Ratio   float64
+		Created time.Time
+	}
+
+	fromStruct := payload{"a&b", 3, math.Copysign(0, -1), time.Date(2020, 1, 2, 4, 4, 5, 999, time.FixedZone("", 3600))}
+	fromMap := map[string]interface{}{
+		"Ratio":   0.0,
+		"Created": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"Count":   uint64(3),
+		"Name":    "a&b",
+	}
+	fromOrderedDict := &OrderedDict{
+		Keys:     []string{"Name", "Count", "Ratio", "Created"},
+		Values:   []interface{}{"a&b", int64(3), float64(0), time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Comments: map[string]string{"Name": "the name"},
+	}
+	fromRaw := RawValue(`<plist><dict>
+		<!-- unsorted, with comments and whitespace -->
+		<key>Ratio</key><real>-0.0</real>
+		<key>Name</key><string>a&amp;b</string>
+		<key>Created</key><date>2020-01-02T03:04:05Z</date>
+		<key>Count</key><integer>0x3</integer>
+	</dict></plist>`)
+	fromText := RawValue(`{Count=<*I3>;Created=<*D2020-01-02 05:04:05 +0200>;Name="a&b";Ratio=<*R0>;}`)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0"><dict><key>Count</key><integer>3</integer><key>Created</key><date>2020-01-02T03:04:05Z</date><key>Name</key><string>a&amp;b</string><key>Ratio</key><real>0</real></dict></plist>`
+
+	for name, v := range map[string]interface{}{
+		"struct":      fromStruct,
+		"map":         fromMap,
+		"OrderedDict": fromOrderedDict,
+		"RawValue":    fromRaw,
+		"text":        fromText,
+	} {
+		out, err := Canonical(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(out) != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, expected, out)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Goplist converts, checks, prints and extracts from property lists, in the manner of Apple's plutil, on any
+// platform. It is built entirely on github.com/wartiva/go-plist.
+//
+//	goplist -convert <format> [-o <path> | -e <extension>] [-r] <file>...
+//	goplist -lint [-s] <file>...
+//	goplist -p <file>...
+//	goplist -extract <keypath> <format> [-o <path>] [-r] <file>
+//
+// See README.md for details. A file named - is read from standard input.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wartiva/go-plist"
+)
+
+const usage = `usage:
+  goplist -convert <format> [-o <path> | -e <extension>] [-r] <file>...
+      rewrite each file in format: xml1, binary1, openstep, gnustep or json
+  goplist -lint [-s] <file>...
+      check that each file is a valid property list
+  goplist -p <file>...
+      print each file in a human-readable form
+  goplist -extract <keypath> <format> [-o <path>] [-r] <file>
+      write the value at keypath in format, or raw, to standard output or path
+
+options:
+  -o <path>       write to path (- for standard output) rather than in place; with one input file only
+  -e <extension>  write to a file named after the input, with extension in place of its own
+  -r              indent JSON output
+  -s              print nothing for files that are valid
+`
+
+// jsonFormat and rawFormat are the output formats, besides the property list formats, that goplist writes.
+const (
+	jsonFormat = -1 - iota
+	rawFormat
+)
+
+var formatNames = map[string]int{
+	"xml1":     plist.XMLFormat,
+	"binary1":  plist.BinaryFormat,
+	"openstep": plist.OpenStepFormat,
+	"gnustep":  plist.GNUStepFormat,
+	"json":     jsonFormat,
+}
+
+type options struct {
+	output    string
+	extension string
+	readable  bool
+	silent    bool
+	files     []string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command in args, and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-help" || args[0] == "-h" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	command, args := args[0], args[1:]
+	var operands []string
+	switch command {
+	case "-convert":
+		operands, args = shift(args, 1)
+	case "-extract":
+		operands, args = shift(args, 2)
+	case "-lint", "-p":
+	default:
+		fmt.Fprintf(stderr, "goplist: unknown command %s\n\n%s", command, usage)
+		return 2
+	}
+
+	opts, err := parseOptions(args)
+	if err == nil && operands == nil && (command == "-convert" || command == "-extract") {
+		err = fmt.Errorf("%s requires more arguments", command)
+	}
+	if err == nil && len(opts.files) == 0 {
+		err = errors.New("no files given")
+	}
+	if err == nil && opts.output != "" && len(opts.files) > 1 {
+		err = errors.New("-o may only be used with a single file")
+	}
+	if err == nil && command == "-extract" && len(opts.files) > 1 {
+		err = errors.New("-extract reads a single file")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "goplist: %v\n\n%s", err, usage)
+		return 2
+	}
+
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr, opts: opts}
+	status := 0
+	for _, file := range opts.files {
+		var err error
+		switch command {
+		case "-convert":
+			err = c.convert(file, operands[0])
+		case "-lint":
+			err = c.lint(file)
+		case "-p":
+			err = c.print(file)
+		case "-extract":
+			err = c.extract(file, operands[0], operands[1])
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", file, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// shift returns the first n of args, and the rest, or nil and args if there are fewer than n.
+func shift(args []string, n int) ([]string, []string) {
+	if len(args) < n {
+		return nil, args
+	}
+	return args[:n], args[n:]
+}
+
+func parseOptions(args []string) (opts options, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			opts.files = append(opts.files, args[i+1:]...)
+			return opts, nil
+		case arg == "-o" || arg == "-e":
+			if i+1 == len(args) {
+				return opts, fmt.Errorf("%s requires an argument", arg)
+			}
+			i++
+			if arg == "-o" {
+				opts.output = args[i]
+			} else {
+				opts.extension = strings.TrimPrefix(args[i], ".")
+			}
+		case arg == "-r":
+			opts.readable = true
+		case arg == "-s":
+			opts.silent = true
+		case arg != "-" && strings.HasPrefix(arg, "-"):
+			return opts, fmt.Errorf("unknown option %s", arg)
+		default:
+			opts.files = append(opts.files, arg)
+		}
+	}
+	return opts, nil
+}
+
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	opts           options
+}
+
+func (c *cli) read(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(c.stdin)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// decode reads the property list in file, retaining the order of its keys.
+func (c *cli) decode(file string) (interface{}, error) {
+	data, err := c.read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := plist.NewDecoder(bytes.NewReader(data))
+	dec.UseOrderedDict()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// write writes data to the output for file: the path given by -o or -e, or else defaultPath.
+func (c *cli) write(file, defaultPath string, data []byte) error {
+	path := defaultPath
+	switch {
+	case c.opts.output != "":
+		path = c.opts.output
+	case c.opts.extension != "" && file != "-":
+		path = strings.TrimSuffix(file, filepath.Ext(file)) + "." + c.opts.extension
+	}
+
+	if path == "-" {
+		_, err := c.stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (c *cli) convert(file, formatName string) error {
+	format, ok := formatNames[formatName]
+	if !ok {
+		return fmt.Errorf("unknown format %s", formatName)
+	}
+
+	v, err := c.decode(file)
+	if err != nil {
+		return err
+	}
+	data, err := c.encode(v, format)
+	if err != nil {
+		return err
+	}
+	return c.write(file, file, data)
+}
+
+// encode encodes v in format, as goplist writes it.
+func (c *cli) encode(v interface{}, format int) ([]byte, error) {
+	switch format {
+	case jsonFormat:
+		// ToJSON reads a property list; a binary one is the cheapest to produce and read.
+		doc, err := plist.Marshal(v, plist.BinaryFormat)
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		if err := plist.ToJSON(bytes.NewReader(doc), &out); err != nil {
+			return nil, err
+		}
+		if c.opts.readable {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, out.Bytes(), "", "\t"); err != nil {
+				return nil, err
+			}
+			out = indented
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	case rawFormat:
+		return rawValue(v)
+	case plist.BinaryFormat:
+		return plist.Marshal(v, format)
+	}
+
+	var out bytes.Buffer
+	enc := plist.NewEncoderForFormat(&out, format)
+	enc.SetOptions(plist.EncoderOptions{Indent: "\t", FinalNewline: true})
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// rawValue returns v as plutil's raw format writes it: strings and data as they are, and numbers, booleans and
+// dates as text. Dictionaries and arrays cannot be written raw.
+func rawValue(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v + "\n"), nil
+	case []byte:
+		return v, nil
+	case bool, uint64, int64, plist.UID:
+		return []byte(fmt.Sprintf("%v\n", v)), nil
+	case *big.Int:
+		return []byte(v.String() + "\n"), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64) + "\n"), nil
+	case float32:
+		return []byte(strconv.FormatFloat(float64(v), 'g', -1, 32) + "\n"), nil
+	case time.Time:
+		return []byte(v.UTC().Format(time.RFC3339) + "\n"), nil
+	case *plist.OrderedDict, []interface{}:
+		return nil, errors.New("a dictionary or array cannot be written raw")
+	}
+	return nil, fmt.Errorf("a value of type %T cannot be written raw", v)
+}
+
+func (c *cli) lint(file string) error {
+	data, err := c.read(file)
+	if err != nil {
+		return err
+	}
+	if err := plist.Validate(data); err != nil {
+		return err
+	}
+	if !c.opts.silent {
+		fmt.Fprintf(c.stdout, "%s: OK\n", file)
+	}
+	return nil
+}
+
+func (c *cli) print(file string) error {
+	v, err := c.decode(file)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	printValue(&out, v, "")
+	out.WriteByte('\n')
+	_, err = out.WriteTo(c.stdout)
+	return err
+}
+
+func (c *cli) extract(file, keypath, formatName string) error {
+	format, ok := formatNames[formatName]
+	if formatName == "raw" {
+		format, ok = rawFormat, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown format %s", formatName)
+	}
+
+	v, err := c.decode(file)
+	if err != nil {
+		return err
+	}
+	v, err = lookup(v, keypath)
+	if err != nil {
+		return err
+	}
+	data, err := c.encode(v, format)
+	if err != nil {
+		return err
+	}
+	return c.write(file, "-", data)
+}
+
+// lookup returns the value at keypath within v. Keypaths are written as for plist.ParsePath, except that, as for
+// plutil, a key made of digits may also index an array: PayloadContent.0.PayloadType is PayloadContent[0].PayloadType.
+func lookup(v interface{}, keypath string) (interface{}, error) {
+	elems, err := plist.ParsePath(keypath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, elem := range elems {
+		if key, ok := elem.(string); ok {
+			if _, isArray := v.([]interface{}); isArray {
+				if n, err := strconv.Atoi(key); err == nil && n >= 0 {
+					elem = n
+				}
+			}
+		}
+
+		var ok bool
+		switch elem := elem.(type) {
+		case string:
+			if d, isDict := v.(*plist.OrderedDict); isDict {
+				v, ok = d.Get(elem)
+			}
+		case int:
+			if a, isArray := v.([]interface{}); isArray && elem < len(a) {
+				v, ok = a[elem], true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("no value at keypath %s", formatKeypath(elems[:i+1]))
+		}
+	}
+	return v, nil
+}
+
+func formatKeypath(elems []interface{}) string {
+	parts := make([]string, len(elems))
+	for i, elem := range elems {
+		parts[i] = fmt.Sprint(elem)
+	}
+	return strings.Join(parts, ".")
+}
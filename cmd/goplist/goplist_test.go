@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wartiva/go-plist"
+)
+
+const profile = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadIdentifier</key>
+	<string>com.example.profile</string>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.wifi.managed</string>
+			<key>Enabled</key>
+			<true/>
+		</dict>
+	</array>
+	<key>Version</key>
+	<integer>2</integer>
+	<key>Huge</key>
+	<integer>170141183460469231731687303715884105727</integer>
+</dict>
+</plist>
+`
+
+// testDir returns a temporary directory holding profile.plist, and a function that removes it.
+func testDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "goplist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "profile.plist"), []byte(profile), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// runCommand runs goplist with args, returning its exit status and what it wrote to standard output and error.
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(""), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestConvert(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+	input := filepath.Join(dir, "profile.plist")
+
+	var expected interface{}
+	if _, err := plist.Unmarshal([]byte(profile), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, format := range map[string]int{"xml1": plist.XMLFormat, "binary1": plist.BinaryFormat, "openstep": plist.OpenStepFormat} {
+		name, format := name, format
+		t.Run(name, func(t *testing.T) {
+			output := filepath.Join(dir, name+".plist")
+			if status, _, stderr := runCommand("-convert", name, "-o", output, input); status != 0 {
+				t.Fatalf("Exited with status %d: %s", status, stderr)
+			}
+
+			data, err := ioutil.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var converted interface{}
+			decodedFormat, err := plist.Unmarshal(data, &converted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decodedFormat != format {
+				t.Errorf("Expected %s output, received %s", plist.FormatNames[format], plist.FormatNames[decodedFormat])
+			}
+			// OpenStep property lists hold only strings, so their values cannot be compared with the original.
+			if format != plist.OpenStepFormat && !reflect.DeepEqual(converted, expected) {
+				t.Errorf("Expected %#v, received %#v", expected, converted)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		status, stdout, stderr := runCommand("-convert", "json", "-o", "-", input)
+		if status != 0 {
+			t.Fatalf("Exited with status %d: %s", status, stderr)
+		}
+		expected := `{"PayloadIdentifier":"com.example.profile","PayloadContent":[{"PayloadType":"com.apple.wifi.managed","Enabled":true}],"Version":2,"Huge":170141183460469231731687303715884105727}` + "\n"
+		if stdout != expected {
+			t.Errorf("Expected %s, received %s", expected, stdout)
+		}
+	})
+
+	t.Run("extension", func(t *testing.T) {
+		if status, _, stderr := runCommand("-convert", "binary1", "-e", "bplist", input); status != 0 {
+			t.Fatalf("Exited with status %d: %s", status, stderr)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "profile.bplist")); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if status, _, stderr := runCommand("-convert", "yaml", "-o", "-", input); status != 1 || !strings.Contains(stderr, "unknown format yaml") {
+			t.Errorf("Expected an unknown format error, received status %d: %s", status, stderr)
+		}
+	})
+}
+
+func TestLint(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+	valid := filepath.Join(dir, "profile.plist")
+	invalid := filepath.Join(dir, "invalid.plist")
+	if err := ioutil.WriteFile(invalid, []byte("<plist><dict><key>a</key></dict></plist>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, stdout, stderr := runCommand("-lint", valid, invalid)
+	if status != 1 {
+		t.Errorf("Expected status 1, received %d", status)
+	}
+	if stdout != valid+": OK\n" {
+		t.Errorf("Expected %s to be reported valid, received %q", valid, stdout)
+	}
+	if !strings.HasPrefix(stderr, invalid+": ") {
+		t.Errorf("Expected %s to be reported invalid, received %q", invalid, stderr)
+	}
+
+	if status, stdout, _ := runCommand("-lint", "-s", valid); status != 0 || stdout != "" {
+		t.Errorf("Expected -s to print nothing, received status %d: %q", status, stdout)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	status, stdout, stderr := runCommand("-p", filepath.Join(dir, "profile.plist"))
+	if status != 0 {
+		t.Fatalf("Exited with status %d: %s", status, stderr)
+	}
+	expected := `{
+  "PayloadIdentifier" => "com.example.profile"
+  "PayloadContent" => [
+    0 => {
+      "PayloadType" => "com.apple.wifi.managed"
+      "Enabled" => true
+    }
+  ]
+  "Version" => 2
+  "Huge" => 170141183460469231731687303715884105727
+}
+`
+	if stdout != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, stdout)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+	input := filepath.Join(dir, "profile.plist")
+
+	tests := []struct {
+		name, keypath, format, expected, err string
+	}{
+		{"Raw String", "PayloadContent.0.PayloadType", "raw", "com.apple.wifi.managed\n", ""},
+		{"Raw Boolean", "PayloadContent[0].Enabled", "raw", "true\n", ""},
+		{"Raw Integer", "Version", "raw", "2\n", ""},
+		{"Raw Big Integer", "Huge", "raw", "170141183460469231731687303715884105727\n", ""},
+		{"JSON", "PayloadContent[0]", "json", `{"PayloadType":"com.apple.wifi.managed","Enabled":true}` + "\n", ""},
+		{"OpenStep", "PayloadContent.0", "openstep", "{\n\tPayloadType = \"com.apple.wifi.managed\";\n\tEnabled = 1;\n}\n", ""},
+		{"Raw Dictionary", "PayloadContent.0", "raw", "", "a dictionary or array cannot be written raw"},
+		{"Missing", "PayloadContent.1", "raw", "", "no value at keypath PayloadContent.1"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			status, stdout, stderr := runCommand("-extract", test.keypath, test.format, input)
+			if test.err != "" {
+				if status != 1 || !strings.Contains(stderr, test.err) {
+					t.Errorf("Expected an error containing %q, received status %d: %s", test.err, status, stderr)
+				}
+				return
+			}
+			if status != 0 {
+				t.Fatalf("Exited with status %d: %s", status, stderr)
+			}
+			if stdout != test.expected {
+				t.Errorf("Expected %q, received %q", test.expected, stdout)
+			}
+		})
+	}
+}
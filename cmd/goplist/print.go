@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wartiva/go-plist"
+)
+
+// printValue writes v to w in the form printed by plutil -p, with nested lines preceded by indent.
+func printValue(w *bytes.Buffer, v interface{}, indent string) {
+	switch v := v.(type) {
+	case *plist.OrderedDict:
+		w.WriteString("{\n")
+		for i, k := range v.Keys {
+			fmt.Fprintf(w, "%s  %s => ", indent, strconv.Quote(k))
+			printValue(w, v.Values[i], indent+"  ")
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "}")
+	case []interface{}:
+		w.WriteString("[\n")
+		for i, e := range v {
+			fmt.Fprintf(w, "%s  %d => ", indent, i)
+			printValue(w, e, indent+"  ")
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "]")
+	case string:
+		w.WriteString(strconv.Quote(v))
+	case []byte:
+		fmt.Fprintf(w, "{length = %d, bytes = 0x%s}", len(v), hex.EncodeToString(v))
+	case time.Time:
+		w.WriteString(v.UTC().Format("2006-01-02 15:04:05 -0700"))
+	case float64:
+		w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case plist.UID:
+		fmt.Fprintf(w, "<CFKeyedArchiverUID>{value = %d}", uint64(v))
+	default:
+		fmt.Fprint(w, v)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindBytes
+	kindTime
+	kindStruct  // a struct type for which methods are being generated
+	kindPointer // a pointer to such a struct
+	kindSlice
+)
+
+type fieldType struct {
+	kind kind
+	name string     // the Go type, for all but slices
+	elem *fieldType // the element type of a slice
+}
+
+func (t *fieldType) String() string {
+	switch t.kind {
+	case kindSlice:
+		return "[]" + t.elem.String()
+	case kindPointer:
+		return "*" + t.name
+	}
+	return t.name
+}
+
+var basicKinds = map[string]kind{
+	"string": kindString,
+	"bool":   kindBool,
+	"int":    kindInt, "int8": kindInt, "int16": kindInt, "int32": kindInt, "int64": kindInt,
+	"uint": kindUint, "uint8": kindUint, "uint16": kindUint, "uint32": kindUint, "uint64": kindUint,
+	"float32": kindFloat, "float64": kindFloat,
+}
+
+type field struct {
+	name      string // the Go field name
+	key       string // the dictionary key
+	omitEmpty bool
+	typ       *fieldType
+}
+
+type generator struct {
+	w         io.Writer
+	structs   map[string]*ast.StructType // every struct type in the package
+	generated map[string]bool            // the types methods are being generated for
+	imports   map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.w, format, args...)
+}
+
+// resolve describes the type of a field, or returns an error if plistgen cannot encode it.
+func (g *generator) resolve(expr ast.Expr, inSlice bool) (*fieldType, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if k, ok := basicKinds[expr.Name]; ok {
+			return &fieldType{kind: k, name: expr.Name}, nil
+		}
+		if g.generated[expr.Name] {
+			return &fieldType{kind: kindStruct, name: expr.Name}, nil
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := expr.X.(*ast.Ident); ok && pkg.Name == "time" && expr.Sel.Name == "Time" {
+			return &fieldType{kind: kindTime, name: "time.Time"}, nil
+		}
+	case *ast.StarExpr:
+		if ident, ok := expr.X.(*ast.Ident); ok && g.generated[ident.Name] && !inSlice {
+			return &fieldType{kind: kindPointer, name: ident.Name}, nil
+		}
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			break
+		}
+		if ident, ok := expr.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+			return &fieldType{kind: kindBytes, name: "[]byte"}, nil
+		}
+		elem, err := g.resolve(expr.Elt, true)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindSlice, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typeString(expr))
+}
+
+func typeString(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return typeString(expr.X) + "." + expr.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeString(expr.X)
+	case *ast.ArrayType:
+		return "[...]" + typeString(expr.Elt)
+	case *ast.MapType:
+		return "map[" + typeString(expr.Key) + "]" + typeString(expr.Value)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// fields returns the fields of the named struct that are encoded, sorted by key, as the Encoder sorts them.
+func (g *generator) fields(name string) ([]field, error) {
+	var fields []field
+	for _, f := range g.structs[name].Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+
+		var tag string
+		if f.Tag != nil {
+			s, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(s).Get("plist")
+		}
+		if tag == "-" {
+			continue
+		}
+		flags := strings.Split(tag, ",")
+
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+
+			fi := field{name: ident.Name, key: flags[0]}
+			if fi.key == "" {
+				fi.key = ident.Name
+			}
+			for _, flag := range flags[1:] {
+				if flag != "omitempty" {
+					return nil, fmt.Errorf("%s.%s: the %q flag is not supported", name, ident.Name, flag)
+				}
+				fi.omitEmpty = true
+			}
+
+			typ, err := g.resolve(f.Type, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", name, ident.Name, err)
+			}
+			fi.typ = typ
+			fields = append(fields, fi)
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	for i := 1; i < len(fields); i++ {
+		if fields[i].key == fields[i-1].key {
+			return nil, fmt.Errorf("%s: fields %s and %s share the key %q", name, fields[i-1].name, fields[i].name, fields[i].key)
+		}
+	}
+	return fields, nil
+}
+
+func (g *generator) generateType(name string) error {
+	fields, err := g.fields(name)
+	if err != nil {
+		return err
+	}
+
+	g.printf("\n// MarshalPlist implements plist.Marshaler.\n")
+	g.printf("func (v %s) MarshalPlist() (interface{}, error) {\n\treturn v.plistDict(), nil\n}\n\n", name)
+
+	g.printf("func (v %s) plistDict() *plist.OrderedDict {\n", name)
+	g.printf("d := &plist.OrderedDict{\nKeys: make([]string, 0, %d),\nValues: make([]interface{}, 0, %d),\n}\n", len(fields), len(fields))
+	for _, f := range fields {
+		expr := "v." + f.name
+		// Slices are built in a block of their own, so that each may use the same variable names.
+		cond := nonEmptyCondition(expr, f)
+		if cond != "" {
+			g.printf("if %s {\n", cond)
+		} else if f.typ.kind == kindSlice {
+			g.printf("{\n")
+		}
+		key := strconv.Quote(f.key)
+		g.encode(expr, f.typ, 0, func(value string) {
+			g.printf("d.Keys = append(d.Keys, %s)\nd.Values = append(d.Values, %s)\n", key, value)
+		})
+		if cond != "" || f.typ.kind == kindSlice {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return d\n}\n\n")
+
+	// Values that are not in the form Marshal writes them (numbers in an OpenStep property list, which are strings,
+	// for example) are left to the Decoder, which decodes the whole dictionary into a copy of the type without
+	// methods.
+	g.printf("// UnmarshalPlist implements plist.Unmarshaler.\n")
+	g.printf("func (v *%s) UnmarshalPlist(unmarshal func(interface{}) error) error {\n", name)
+	g.printf("var d map[string]interface{}\nif err := unmarshal(&d); err != nil {\nreturn err\n}\n")
+	g.printf("ok, err := v.plistFromDict(func(key string) (interface{}, bool) {\nvalue, ok := d[key]\nreturn value, ok\n})\n")
+	g.printf("if ok || err != nil {\nreturn err\n}\n")
+	g.printf("type plain %s\nreturn unmarshal((*plain)(v))\n}\n\n", name)
+
+	g.printf("func (v *%s) plistFromDict(get func(string) (interface{}, bool)) (bool, error) {\n", name)
+	for _, f := range fields {
+		g.printf("if value, ok := get(%q); ok {\n", f.key)
+		g.decode("v."+f.name, "value", f.typ, 0)
+		g.printf("}\n")
+	}
+	g.printf("return true, nil\n}\n")
+	return nil
+}
+
+// nonEmptyCondition returns the condition under which the field f, held in expr, is written, or "" if it always is.
+// Nil pointers are never written; other fields are omitted when empty only if they are tagged omitempty, following
+// the Encoder's rules (structs and times are never empty).
+func nonEmptyCondition(expr string, f field) string {
+	if f.typ.kind == kindPointer {
+		return expr + " != nil"
+	}
+	if !f.omitEmpty {
+		return ""
+	}
+	switch f.typ.kind {
+	case kindString:
+		return expr + ` != ""`
+	case kindBool:
+		return expr
+	case kindInt, kindUint, kindFloat:
+		return expr + " != 0"
+	case kindBytes, kindSlice:
+		return "len(" + expr + ") != 0"
+	}
+	return ""
+}
+
+// encode writes statements that pass the property list representation of expr, of type t, to store.
+func (g *generator) encode(expr string, t *fieldType, depth int, store func(value string)) {
+	switch t.kind {
+	case kindStruct, kindPointer:
+		store(expr + ".plistDict()")
+	case kindSlice:
+		a, i, e := fmt.Sprintf("a%d", depth), fmt.Sprintf("i%d", depth), fmt.Sprintf("e%d", depth)
+		g.printf("%s := make([]interface{}, len(%s))\n", a, expr)
+		g.printf("for %s, %s := range %s {\n", i, e, expr)
+		g.encode(e, t.elem, depth+1, func(value string) {
+			g.printf("%s[%s] = %s\n", a, i, value)
+		})
+		g.printf("}\n")
+		store(a)
+	default:
+		store(expr)
+	}
+}
+
+// decode writes statements that store the decoded value src in target, of type t. They return false if src is not
+// in the form Marshal writes it, and an OverflowError if src is a number that target cannot hold, as the Decoder
+// does.
+func (g *generator) decode(target, src string, t *fieldType, depth int) {
+	x := fmt.Sprintf("x%d", depth)
+	g.printf("switch %s := %s.(type) {\n", x, src)
+
+	overflow := func(cond, value string) {
+		g.imports["reflect"] = true
+		g.imports["strconv"] = true
+		g.printf("if %s {\nreturn false, &plist.OverflowError{Value: %s, Dest: reflect.TypeOf(%s(0))}\n}\n", cond, value, t.name)
+	}
+	switch t.kind {
+	case kindString:
+		g.printf("case string:\n%s = %s\n", target, x)
+	case kindBool:
+		g.printf("case bool:\n%s = %s\n", target, x)
+	case kindInt, kindUint:
+		formatInt, formatUint := "strconv.FormatInt("+x+", 10)", "strconv.FormatUint("+x+", 10)"
+		g.printf("case int64:\nn := %s(%s)\n", t.name, x)
+		if t.kind == kindInt {
+			overflow("int64(n) != "+x, formatInt)
+		} else {
+			overflow(x+" < 0 || int64(n) != "+x, formatInt)
+		}
+		g.printf("%s = n\n", target)
+		g.printf("case uint64:\nn := %s(%s)\n", t.name, x)
+		if t.kind == kindInt {
+			overflow("n < 0 || uint64(n) != "+x, formatUint)
+		} else {
+			overflow("uint64(n) != "+x, formatUint)
+		}
+		g.printf("%s = n\n", target)
+	case kindFloat:
+		g.printf("case float64:\n")
+		if t.name == "float32" {
+			g.imports["math"] = true
+			overflow("a := math.Abs("+x+"); a > math.MaxFloat32 && a <= math.MaxFloat64", "strconv.FormatFloat("+x+", 'g', -1, 64)")
+		}
+		g.printf("%s = %s(%s)\n", target, t.name, x)
+		g.printf("case float32:\n%s = %s(%s)\n", target, t.name, x)
+	case kindBytes:
+		g.printf("case []byte:\n%s = %s\n", target, x)
+	case kindTime:
+		g.imports["time"] = true
+		g.printf("case time.Time:\n%s = %s\n", target, x)
+	case kindStruct, kindPointer:
+		alloc := ""
+		if t.kind == kindPointer {
+			alloc = fmt.Sprintf("if %s == nil {\n%s = new(%s)\n}\n", target, target, t.name)
+		}
+		g.printf("case map[string]interface{}:\n%s", alloc)
+		g.printf("if ok, err := %s.plistFromDict(func(key string) (interface{}, bool) {\ne, ok := %s[key]\nreturn e, ok\n}); !ok {\nreturn false, err\n}\n", target, x)
+		g.printf("case *plist.OrderedDict:\n%s", alloc)
+		g.printf("if ok, err := %s.plistFromDict(%s.Get); !ok {\nreturn false, err\n}\n", target, x)
+	case kindSlice:
+		s, i, e := fmt.Sprintf("s%d", depth), fmt.Sprintf("i%d", depth), fmt.Sprintf("e%d", depth)
+		// An empty array leaves the slice nil, as it does for the Decoder.
+		g.printf("case []interface{}:\nvar %s %s\nif len(%s) != 0 {\n%s = make(%s, len(%s))\n}\n", s, t, x, s, t, x)
+		g.printf("for %s, %s := range %s {\n", i, e, x)
+		g.decode(s+"["+i+"]", e, t.elem, depth+1)
+		g.printf("}\n%s = %s\n", target, s)
+	}
+	g.printf("default:\nreturn false, nil\n}\n")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateGolden checks the methods generated for the types in internal/example against those checked in
+// beside them, which that package's tests compile and compare with Marshal and Unmarshal.
+func TestGenerateGolden(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	src, err := generate(dir, "config_plist.go", []string{"Config", "Device"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := ioutil.ReadFile(filepath.Join(dir, "config_plist.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, golden) {
+		t.Errorf("Generated code differs from %s; run go generate there and review the difference.\n%s", dir, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name, decl, err string
+	}{
+		{"Map", "type T struct{ M map[string]string }", "unsupported type"},
+		{"Flag", "type T struct{ A string `plist:\",inline\"` }", `the "inline" flag is not supported`},
+		{"Embedded", "type E struct{}\ntype T struct{ E }", "embedded fields are not supported"},
+		{"Duplicate", "type T struct{ A string; B string `plist:\"A\"` }", `share the key "A"`},
+		{"Pointer Slice", "type T struct{ S []*T }", "unsupported type"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plistgen")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n\n"+test.decl+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := generate(dir, "t_plist.go", []string{"T"}); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error containing %q, received %v", test.err, err)
+			}
+		})
+	}
+}
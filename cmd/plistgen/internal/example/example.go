@@ -0,0 +1,29 @@
+// Package example declares types for which plistgen generates methods, so that its tests can check the generated
+// code against the output of Marshal and Unmarshal.
+package example
+
+import "time"
+
+//go:generate go run ../.. -t Config -t Device
+
+type Config struct {
+	Name     string
+	Enabled  bool      `plist:"enabled"`
+	Retries  int8      `plist:"retries,omitempty"`
+	Port     uint16    `plist:"port"`
+	Ratio    float64   `plist:"ratio,omitempty"`
+	Scale    float32   `plist:"scale"`
+	Token    []byte    `plist:"token,omitempty"`
+	Created  time.Time `plist:"created"`
+	Primary  Device    `plist:"primary"`
+	Backup   *Device   `plist:"backup"`
+	Devices  []Device  `plist:"devices,omitempty"`
+	Matrix   [][]int64 `plist:"matrix"`
+	Internal string    `plist:"-"`
+	private  string
+}
+
+type Device struct {
+	ID     uint64   `plist:"id"`
+	Labels []string `plist:"labels,omitempty"`
+}
@@ -0,0 +1,157 @@
+package example
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wartiva/go-plist"
+)
+
+// reflectConfig and reflectDevice mirror Config and Device without their generated methods, so that Marshal and
+// Unmarshal walk their fields by reflection.
+type reflectConfig struct {
+	Name     string
+	Enabled  bool            `plist:"enabled"`
+	Retries  int8            `plist:"retries,omitempty"`
+	Port     uint16          `plist:"port"`
+	Ratio    float64         `plist:"ratio,omitempty"`
+	Scale    float32         `plist:"scale"`
+	Token    []byte          `plist:"token,omitempty"`
+	Created  time.Time       `plist:"created"`
+	Primary  reflectDevice   `plist:"primary"`
+	Backup   *reflectDevice  `plist:"backup"`
+	Devices  []reflectDevice `plist:"devices,omitempty"`
+	Matrix   [][]int64       `plist:"matrix"`
+	Internal string          `plist:"-"`
+}
+
+type reflectDevice struct {
+	ID     uint64   `plist:"id"`
+	Labels []string `plist:"labels,omitempty"`
+}
+
+func mirrorDevice(d Device) reflectDevice {
+	return reflectDevice{ID: d.ID, Labels: d.Labels}
+}
+
+func mirror(c Config) reflectConfig {
+	m := reflectConfig{
+		Name:    c.Name,
+		Enabled: c.Enabled,
+		Retries: c.Retries,
+		Port:    c.Port,
+		Ratio:   c.Ratio,
+		Scale:   c.Scale,
+		Token:   c.Token,
+		Created: c.Created,
+		Primary: mirrorDevice(c.Primary),
+		Matrix:  c.Matrix,
+	}
+	if c.Backup != nil {
+		b := mirrorDevice(*c.Backup)
+		m.Backup = &b
+	}
+	for _, d := range c.Devices {
+		m.Devices = append(m.Devices, mirrorDevice(d))
+	}
+	return m
+}
+
+func TestGeneratedMethods(t *testing.T) {
+	configs := map[string]Config{
+		"full": {
+			Name:    "main",
+			Enabled: true,
+			Retries: -3,
+			Port:    8080,
+			Ratio:   0.25,
+			Scale:   1.5,
+			Token:   []byte{1, 2, 3},
+			Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Primary: Device{ID: 1, Labels: []string{"a", "b"}},
+			Backup:  &Device{ID: 2},
+			Devices: []Device{{ID: 3}, {ID: 4, Labels: []string{"c"}}},
+			Matrix:  [][]int64{{1, 2}, nil, {-3}},
+		},
+		"empty": {Created: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for name, config := range configs {
+		config := config
+		for _, format := range []int{plist.XMLFormat, plist.BinaryFormat, plist.OpenStepFormat, plist.GNUStepFormat} {
+			format := format
+			t.Run(name+"/"+plist.FormatNames[format], func(t *testing.T) {
+				mirrored := mirror(config)
+
+				generated, err := plist.Marshal(config, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reflected, err := plist.Marshal(mirrored, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(generated, reflected) {
+					t.Errorf("Generated MarshalPlist disagrees with Marshal:\n%s\n%s", generated, reflected)
+				}
+
+				var decoded Config
+				if _, err := plist.Unmarshal(reflected, &decoded); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(mirror(decoded), mirrored) {
+					t.Errorf("Generated UnmarshalPlist disagrees with Unmarshal:\n%#v\n%#v", mirror(decoded), mirrored)
+				}
+
+				var decodedMirror reflectConfig
+				if _, err := plist.Unmarshal(generated, &decodedMirror); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(decodedMirror, mirrored) {
+					t.Errorf("Expected %#v, received %#v", mirrored, decodedMirror)
+				}
+			})
+		}
+	}
+}
+
+// TestGeneratedMethodsInvalid checks that the generated UnmarshalPlist accepts and rejects the same documents as
+// Unmarshal.
+func TestGeneratedMethodsInvalid(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		err       error
+	}{
+		{"Overflow", `<plist><dict><key>retries</key><integer>300</integer></dict></plist>`, plist.ErrOverflow},
+		{"Negative Unsigned", `<plist><dict><key>port</key><integer>-1</integer></dict></plist>`, plist.ErrOverflow},
+		{"Nested Overflow", `<plist><dict><key>matrix</key><array><array><integer>9223372036854775808</integer></array></array></dict></plist>`, plist.ErrOverflow},
+		{"Float32 Overflow", `<plist><dict><key>scale</key><real>1e300</real></dict></plist>`, plist.ErrOverflow},
+		{"String In XML", `<plist><dict><key>port</key><string>7</string></dict></plist>`, plist.ErrTypeMismatch},
+		{"Nested String In XML", `<plist><dict><key>primary</key><dict><key>id</key><string>7</string></dict></dict></plist>`, plist.ErrTypeMismatch},
+		{"OpenStep", `{port=7;enabled=true;primary={id=3;};matrix=((1,-2));created="2020-01-02 03:04:05 +0000";}`, nil},
+		{"OpenStep Overflow", `{port=70000;}`, plist.ErrOverflow},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var mirrored reflectConfig
+			_, err := plist.Unmarshal([]byte(test.doc), &mirrored)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected Unmarshal to return %v, received %v", test.err, err)
+			}
+
+			var decoded Config
+			_, err = plist.Unmarshal([]byte(test.doc), &decoded)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected UnmarshalPlist to return %v, received %v", test.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(mirror(decoded), mirrored) {
+				t.Errorf("Generated UnmarshalPlist disagrees with Unmarshal:\n%#v\n%#v", mirror(decoded), mirrored)
+			}
+		})
+	}
+}
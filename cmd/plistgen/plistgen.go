@@ -0,0 +1,198 @@
+// Plistgen generates property list encoding and decoding methods for struct types, so that the Encoder and Decoder
+// need not look up the fields of those types by reflection.
+//
+// It is intended to be run by go generate, from a comment in the package that declares the types:
+//
+//	//go:generate plistgen -t Config -t Device
+//
+// For each type T, plistgen writes a MarshalPlist method (on T) and an UnmarshalPlist method (on *T) to
+// <first type>_plist.go, or the file named by -o. See README.md for the field types and tags it supports.
+//
+// With -s, plistgen instead declares the type named by -t, inferring its fields from a sample property list, and
+// writes it to <type>.go, or the file named by -o:
+//
+//	plistgen -s sample.plist -t Config
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/wartiva/go-plist"
+)
+
+var opts struct {
+	Types  []string `short:"t" long:"type" description:"a struct type to generate methods for (may be repeated)" required:"true" value-name:"<type>"`
+	Output string   `short:"o" long:"out" description:"output filename (default <type>_plist.go, or <type>.go with -s)" default:"" value-name:"<filename>"`
+	Sample string   `short:"s" long:"sample" description:"declare the type, inferred from a sample property list, instead of generating methods" default:"" value-name:"<filename>"`
+}
+
+func main() {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.Usage = "[OPTIONS] [directory]"
+	args, err := parser.Parse()
+	if err != nil {
+		// flags.Default implies flags.PrintError; there's no reason to print it here
+		os.Exit(2)
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	output := opts.Output
+	if output == "" && opts.Sample != "" {
+		output = strings.ToLower(opts.Types[0]) + ".go"
+	} else if output == "" {
+		output = strings.ToLower(opts.Types[0]) + "_plist.go"
+	}
+	output = filepath.Join(dir, output)
+
+	var src []byte
+	if opts.Sample != "" {
+		src, err = generateFromSample(dir, filepath.Base(output), opts.Sample, opts.Types)
+	} else {
+		src, err = generate(dir, filepath.Base(output), opts.Types)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "plistgen:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "plistgen:", err)
+		os.Exit(1)
+	}
+}
+
+// generate returns the source of a file declaring the methods for types, which are declared in the package in dir.
+// The file named output, if it exists, is ignored, so that it may be regenerated.
+func generate(dir, output string, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	g := &generator{structs: make(map[string]*ast.StructType), imports: make(map[string]bool)}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					g.structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+
+	g.generated = make(map[string]bool)
+	for _, name := range types {
+		if g.structs[name] == nil {
+			return nil, fmt.Errorf("no struct type %s in package %s", name, pkg.Name)
+		}
+		g.generated[name] = true
+	}
+
+	var body bytes.Buffer
+	g.w = &body
+	for _, name := range types {
+		if err := g.generateType(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by plistgen -t %s; DO NOT EDIT.\n\n", strings.Join(types, " -t "))
+	fmt.Fprintf(&out, "package %s\n\n", pkg.Name)
+	fmt.Fprintln(&out, "import (")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "\tplist \"github.com/wartiva/go-plist\"")
+	fmt.Fprintln(&out, ")")
+	body.WriteTo(&out)
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// generateFromSample returns the source of a file declaring the type named by types, which must name one type,
+// inferred from the property list in the file sample. The file is in the package in dir, if there is one, and
+// otherwise in a package named after dir. The file named output, if it exists, is ignored.
+func generateFromSample(dir, output, sample string, types []string) ([]byte, error) {
+	if len(types) != 1 {
+		return nil, fmt.Errorf("-s declares one type, but %d were given", len(types))
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg string
+	for name := range pkgs {
+		if pkg != "" {
+			return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+		}
+		pkg = name
+	}
+	if pkg == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		pkg = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+				return r
+			}
+			return -1
+		}, strings.ToLower(filepath.Base(abs)))
+		if pkg == "" || pkg[0] >= '0' && pkg[0] <= '9' {
+			pkg = "main"
+		}
+	}
+
+	f, err := os.Open(sample)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return plist.GenerateStructs(f, pkg, types[0])
+}
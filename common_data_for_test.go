@@ -1048,6 +1048,132 @@ var tests = []TestData{
 			GNUStepFormat: []byte(`{O=sentinel;One="";Three="";Two="";}`),
 		},
 	},
+	{
+		Name: "Inline struct and map",
+		Value: InlinePayload{
+			Header: InlineHeader{Version: 1, Kind: "app"},
+			Name:   "payload",
+			Extra:  map[string]string{"Other": "x"},
+		},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Kind=app;Name=payload;Other=x;Version=<*I1>;}`),
+		},
+	},
+	{
+		Name: "Inline map does not shadow fields",
+		Value: InlinePayload{
+			Name:  "payload",
+			Extra: map[string]string{"Name": "shadowed"},
+		},
+		DecodeValue: InlinePayload{
+			Name: "payload",
+		},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Kind="";Name=payload;Version=<*I0>;}`),
+		},
+	},
+	{
+		Name:  "Inline nil struct pointer",
+		Value: InlinePointer{Name: "payload"},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Name=payload;}`),
+		},
+	},
+	{
+		Name: "Quoted scalar fields",
+		Value: QuotedScalars{
+			Int:        -3,
+			Uint:       7,
+			Float:      1.5,
+			Bool:       true,
+			IntPtr:     &nestedPtrIntVal,
+			Unaffected: "x",
+		},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Bool=true;Float=1.5;Int=-3;IntPtr=3;Uint=7;Unaffected=x;}`),
+			XMLFormat:     []byte(xmlPreamble + `<plist version="1.0"><dict><key>Bool</key><string>true</string><key>Float</key><string>1.5</string><key>Int</key><string>-3</string><key>IntPtr</key><string>3</string><key>Uint</key><string>7</string><key>Unaffected</key><string>x</string></dict></plist>`),
+		},
+	},
+	{
+		Name:        "Structure with zero omitzero fields",
+		Value:       OmitZeroFields{Name: "a", Custom: ZeroWhenNegative(-1), Point: [2]int{0, 1}},
+		DecodeValue: OmitZeroFields{Name: "a", Point: [2]int{0, 1}},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Name=a;Point=(<*I0>,<*I1>,);}`),
+		},
+	},
+	{
+		Name: "Structure with non-zero omitzero fields",
+		Value: OmitZeroFields{
+			Name:   "a",
+			Date:   time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC),
+			Custom: ZeroWhenNegative(0),
+			Inner:  &InnerStructWithSimpleField{},
+		},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Custom=<*I0>;Date=<*D2013-11-27 00:34:00 +0000>;Inner={S="";};Name=a;}`),
+		},
+	},
+	{
+		Name: "Duration fields",
+		Value: DurationFields{
+			Default:      1500 * time.Millisecond,
+			Seconds:      1500 * time.Millisecond,
+			Milliseconds: 1500 * time.Millisecond,
+			String:       90 * time.Minute,
+		},
+		Documents: map[int][]byte{
+			GNUStepFormat: []byte(`{Default=<*I1500000000>;Milliseconds=<*I1500>;Seconds=<*R1.5>;String=1h30m0s;}`),
+			XMLFormat:     []byte(xmlPreamble + `<plist version="1.0"><dict><key>Default</key><integer>1500000000</integer><key>Milliseconds</key><integer>1500</integer><key>Seconds</key><real>1.5</real><key>String</key><string>1h30m0s</string></dict></plist>`),
+		},
+	},
+}
+
+type DurationFields struct {
+	Default      time.Duration
+	Seconds      time.Duration  `plist:",seconds"`
+	Milliseconds time.Duration  `plist:",milliseconds"`
+	String       time.Duration  `plist:",durationstring"`
+	Missing      *time.Duration `plist:",omitempty,seconds"`
+}
+
+type ZeroWhenNegative int
+
+func (z ZeroWhenNegative) IsZero() bool {
+	return z < 0
+}
+
+type OmitZeroFields struct {
+	Name   string
+	Date   time.Time                   `plist:",omitzero"`
+	Custom ZeroWhenNegative            `plist:",omitzero"`
+	Point  [2]int                      `plist:",omitzero"`
+	Inner  *InnerStructWithSimpleField `plist:",omitzero"`
+}
+
+type QuotedScalars struct {
+	Int        int     `plist:",string"`
+	Uint       uint8   `plist:",string"`
+	Float      float32 `plist:",string"`
+	Bool       bool    `plist:",string"`
+	IntPtr     *int    `plist:",string"`
+	Unaffected string  `plist:",string"`
+}
+
+type InlineHeader struct {
+	Version int
+	Kind    string
+}
+
+type InlinePayload struct {
+	Header InlineHeader `plist:",inline"`
+	Name   string
+	Extra  map[string]string `plist:",inline"`
+}
+
+type InlinePointer struct {
+	Header *InlineHeader `plist:",inline"`
+	Name   string
 }
 
 type StructWithDeeplyNestedPointer struct {
@@ -1164,5 +1290,5 @@ func init() {
 	setupPlistValues()
 
 	// Pre-warm the type info struct to remove it from benchmarking
-	getTypeInfo(reflect.ValueOf(plistValueTreeRawData).Type())
+	getTypeInfo(reflect.ValueOf(plistValueTreeRawData).Type(), false)
 }
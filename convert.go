@@ -0,0 +1,75 @@
+package plist
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"time"
+)
+
+// Convert reads a property list in any format from src and writes it to dst in the specified format, without
+// decoding it into Go values. Dictionary keys are written in the order in which they were read, and the comments
+// that precede dictionary entries are carried over into XML, OpenStep and GNUStep property lists. If options are
+// given, the last of them sets the layout of the output, as Encoder.SetOptions does.
+//
+// If src is an io.ReadSeeker, the data in a binary property list is copied from it as it is written, rather than
+// being held in memory; other property lists, and any src that cannot seek, are read into memory first.
+//
+// Values that the output format cannot represent are written as Encode writes them: OpenStep property lists,
+// for example, hold numbers, booleans and dates as strings. Dates are written in UTC, whatever time zone they
+// were read in, as Apple's XML date format requires.
+func Convert(dst io.Writer, src io.Reader, format int, options ...EncoderOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+
+	rs, ok := src.(io.ReadSeeker)
+	if !ok {
+		buf, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		rs = bytes.NewReader(buf)
+	}
+
+	dec := NewDecoder(rs)
+	dec.PreserveComments()
+	dec.StreamData()
+	pval, err := dec.parse()
+	if err != nil {
+		return err
+	}
+	pval = prepareConverted(pval)
+
+	enc := NewEncoderForFormat(dst, format)
+	if len(options) > 0 {
+		enc.SetOptions(options[len(options)-1])
+	}
+	enc.generate(pval)
+	return nil
+}
+
+// prepareConverted readies pval, as Convert read it, to be written. Every dictionary is marked as ordered, so that
+// its keys are written in the order in which they were read, and every date is converted to UTC.
+func prepareConverted(pval cfValue) cfValue {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		pval.ordered = true
+		for i, v := range pval.values {
+			pval.values[i] = prepareConverted(v)
+		}
+	case *cfArray:
+		for i, v := range pval.values {
+			pval.values[i] = prepareConverted(v)
+		}
+	case cfDate:
+		return cfDate(time.Time(pval).In(time.UTC))
+	}
+	return pval
+}
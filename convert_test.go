@@ -0,0 +1,63 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	src := `{
+	// The name
+	zed = "Z";
+	alpha = (<*I1>, <*R2.5>, <*BY>, <0fbd77>);
+}`
+
+	var xmlOut bytes.Buffer
+	if err := Convert(&xmlOut, bytes.NewReader([]byte(src)), XMLFormat, EncoderOptions{OmitXMLHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<plist version="1.0"><dict><!-- The name --><key>zed</key><string>Z</string><key>alpha</key><array><integer>1</integer><real>2.5</real><true/><data>D713</data></array></dict></plist>`
+	if xmlOut.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, xmlOut.String())
+	}
+
+	// Through a binary property list and back. A bytes.Buffer cannot seek, and is read into memory.
+	var binOut bytes.Buffer
+	if err := Convert(&binOut, &xmlOut, BinaryFormat); err != nil {
+		t.Fatal(err)
+	}
+	var textOut bytes.Buffer
+	if err := Convert(&textOut, bytes.NewReader(binOut.Bytes()), GNUStepFormat); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{zed=Z;alpha=(<*I1>,<*R2.5>,<*BY>,<0fbd77>,);}`
+	if textOut.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, textOut.String())
+	}
+
+	var a, b interface{}
+	if _, err := Unmarshal([]byte(src), &a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unmarshal(binOut.Bytes(), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Expected %#v, received %#v", a, b)
+	}
+
+	// Apple's XML date format requires dates in UTC.
+	xmlOut.Reset()
+	if err := Convert(&xmlOut, bytes.NewReader([]byte(`<*D2020-01-01 10:00:00 +0200>`)), XMLFormat, EncoderOptions{OmitXMLHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected = `<plist version="1.0"><date>2020-01-01T08:00:00Z</date></plist>`
+	if xmlOut.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, xmlOut.String())
+	}
+
+	if err := Convert(&textOut, bytes.NewReader([]byte(`<plist><dict>`)), XMLFormat); err == nil {
+		t.Error("Expected error converting an invalid property list, received nothing.")
+	}
+}
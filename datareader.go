@@ -0,0 +1,133 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A DataReader is marshaled as data whose contents are read from Reader as the property list is written, so that
+// large data, such as a firmware image, need not be held in memory. Size must be the number of bytes to read:
+// binary property lists record the length of data before its contents. Encoding fails if Reader yields fewer than
+// Size bytes; any beyond them are left unread.
+//
+// Reader is read only once, so a value holding a DataReader can only be encoded once. A DataReader whose Reader is
+// nil is treated like any other nil value and omitted.
+//
+// Data may be unmarshaled into a DataReader, whose Reader then yields the data. A Decoder with StreamData stores
+// data in empty interfaces, and reports it from Token, as DataReaders.
+type DataReader struct {
+	Reader io.Reader
+	Size   int64
+}
+
+var (
+	dataReaderType = reflect.TypeOf(DataReader{})
+	writerType     = reflect.TypeOf((*io.Writer)(nil)).Elem()
+)
+
+// dataValue is data, held in memory or read as it is written.
+type dataValue interface {
+	cfValue
+	copyTo(w io.Writer)
+}
+
+// cfDataReader is data that is read while it is being written; see DataReader. It is read either once, from r,
+// or as often as it is needed, from ra, in which a Decoder with StreamData found it.
+type cfDataReader struct {
+	r    io.Reader
+	ra   io.ReaderAt
+	off  int64
+	size int64
+}
+
+func (*cfDataReader) typeName() string {
+	return "data"
+}
+
+// hash returns p itself: as its contents have not been read, it is never shared with other data.
+func (p *cfDataReader) hash() interface{} {
+	return p
+}
+
+// open returns a reader of the contents of p.
+func (p *cfDataReader) open() io.Reader {
+	if p.ra != nil {
+		return io.NewSectionReader(p.ra, p.off, p.size)
+	}
+	return p.r
+}
+
+// copyTo writes the contents of p to w.
+func (p *cfDataReader) copyTo(w io.Writer) {
+	_, err := io.CopyN(w, p.open(), p.size)
+	if err == io.EOF {
+		err = p.shortError()
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// readFull reads the next len(b) bytes of r, which p opened, into b.
+func (p *cfDataReader) readFull(r io.Reader, b []byte) {
+	_, err := io.ReadFull(r, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = p.shortError()
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// readAll reads the whole of p into memory.
+func (p *cfDataReader) readAll() cfData {
+	b := make([]byte, p.size)
+	p.readFull(p.open(), b)
+	return cfData(b)
+}
+
+func (p *cfDataReader) shortError() error {
+	return fmt.Errorf("plist: DataReader yielded fewer than its %d bytes", p.size)
+}
+
+func marshalDataReader(d DataReader) cfValue {
+	if d.Reader == nil {
+		return nil
+	}
+	if d.Size < 0 {
+		panic(errors.New("plist: DataReader has a negative size"))
+	}
+	return &cfDataReader{r: d.Reader, size: d.Size}
+}
+
+// dataReaderValue returns a DataReader of the data pval.
+func dataReaderValue(pval dataValue) DataReader {
+	if r, ok := pval.(*cfDataReader); ok {
+		return DataReader{Reader: r.open(), Size: r.size}
+	}
+	data := pval.(cfData)
+	return DataReader{Reader: bytes.NewReader(data), Size: int64(len(data))}
+}
+
+// unmarshalStreamedData stores the data pval in val if it is a DataReader or, with StreamData, if it is an
+// io.Writer, to which the data is written. It reports whether it did so.
+func (p *Decoder) unmarshalStreamedData(pval dataValue, val reflect.Value) bool {
+	if val.Type() == dataReaderType {
+		p.account(pval)
+		val.Set(reflect.ValueOf(dataReaderValue(pval)))
+		return true
+	}
+
+	if !p.streamData {
+		return false
+	}
+	if receiver, can := implementsInterface(val, writerType); can {
+		p.account(pval)
+		pval.copyTo(receiver.(io.Writer))
+		return true
+	}
+	return false
+}
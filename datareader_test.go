@@ -0,0 +1,162 @@
+package plist
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDataReader(t *testing.T) {
+	data := make([]byte, 10003) // more than one buffer's worth, and not a multiple of four bytes
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	type inMemory struct {
+		Name  string
+		Data  []byte
+		Empty []byte
+	}
+	type streamed struct {
+		Name  string
+		Data  DataReader
+		Empty *DataReader
+	}
+
+	encode := func(v interface{}, format int, configure func(*Encoder)) []byte {
+		var buf bytes.Buffer
+		enc := NewEncoderForFormat(&buf, format)
+		enc.Indent("\t")
+		configure(enc)
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("%s: %v", FormatNames[format], err)
+		}
+		return buf.Bytes()
+	}
+
+	configurations := map[string]func(*Encoder){
+		"default": func(*Encoder) {},
+		"wrapped": func(enc *Encoder) {
+			enc.SetOptions(EncoderOptions{Indent: "\t", DataLineWidth: 76})
+		},
+		"streamed": func(enc *Encoder) {
+			enc.StreamBinary("")
+		},
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, OpenStepFormat, GNUStepFormat} {
+		for name, configure := range configurations {
+			expected := encode(inMemory{"x", data, []byte{}}, format, configure)
+			received := encode(streamed{"x", DataReader{bytes.NewReader(data), int64(len(data))}, &DataReader{strings.NewReader(""), 0}}, format, configure)
+			if !bytes.Equal(received, expected) {
+				t.Errorf("%s, %s: Expected a DataReader to be encoded as its data is", FormatNames[format], name)
+			}
+		}
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		short := DataReader{bytes.NewReader(data), int64(len(data)) + 1}
+		if _, err := Marshal(short, format); err == nil {
+			t.Errorf("%s: Expected error encoding a DataReader that yields too few bytes, received nothing.", FormatNames[format])
+		}
+	}
+
+	// Bytes beyond the size are left unread.
+	r := bytes.NewReader(data)
+	if _, err := Marshal(DataReader{r, 4}, XMLFormat); err != nil || r.Len() != len(data)-4 {
+		t.Errorf("Expected only four bytes to be read, %d remain (%v)", r.Len(), err)
+	}
+}
+
+// countingReader counts the bytes read from it with ReadAt.
+type countingReader struct {
+	*bytes.Reader
+	n int
+}
+
+func (r *countingReader) ReadAt(b []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(b, off)
+	r.n += n
+	return n, err
+}
+
+func TestStreamData(t *testing.T) {
+	data := bytes.Repeat([]byte("firmware"), 10000)
+	type image struct {
+		Name    string
+		Payload []byte
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		doc, err := Marshal(image{"x", data}, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Data is written to io.Writers, whether they are fields or the values of interface fields.
+		var received struct {
+			Name    string
+			Payload bytes.Buffer
+		}
+		var other bytes.Buffer
+		var viaInterface struct{ Payload io.Writer }
+		viaInterface.Payload = &other
+		for _, v := range []interface{}{&received, &viaInterface} {
+			dec := NewDecoder(bytes.NewReader(doc))
+			dec.StreamData()
+			if err := dec.Decode(v); err != nil {
+				t.Fatalf("%s: %v", FormatNames[format], err)
+			}
+		}
+		if !bytes.Equal(received.Payload.Bytes(), data) || !bytes.Equal(other.Bytes(), data) {
+			t.Errorf("%s: Expected the data to be written to each io.Writer", FormatNames[format])
+		}
+
+		// Without StreamData, an io.Writer is decoded into as usual.
+		if _, err := Unmarshal(doc, &viaInterface); err == nil {
+			t.Errorf("%s: Expected error decoding data into an io.Writer without StreamData, received nothing.", FormatNames[format])
+		}
+	}
+
+	// The data of a binary property list read from an io.ReaderAt is read only when it is used.
+	doc, _ := Marshal(image{"x", data}, BinaryFormat)
+	r := &countingReader{Reader: bytes.NewReader(doc)}
+	dec := NewDecoder(r)
+	dec.StreamData()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if r.n >= len(data) {
+		t.Errorf("Expected the data not to be read while decoding, but %d bytes were read", r.n)
+	}
+	payload, ok := v["Payload"].(DataReader)
+	if !ok || payload.Size != int64(len(data)) {
+		t.Fatalf("Expected a DataReader of %d bytes, received %#v", len(data), v["Payload"])
+	}
+	if b, err := ioutil.ReadAll(payload.Reader); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("Expected the DataReader to yield the data (%v)", err)
+	}
+
+	// Tokens report data as DataReaders too, and data can be unmarshaled into a DataReader without StreamData.
+	dec = NewDecoder(bytes.NewReader(doc))
+	dec.StreamData()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, ok := tok.(DataReader); ok {
+			if d.Size != int64(len(data)) {
+				t.Errorf("Expected a DataReader of %d bytes, received %d", len(data), d.Size)
+			}
+			break
+		}
+	}
+	var d struct{ Payload DataReader }
+	if _, err := Unmarshal(doc, &d); err != nil || d.Payload.Size != int64(len(data)) {
+		t.Errorf("Expected to unmarshal a DataReader of %d bytes, received %d (%v)", len(data), d.Payload.Size, err)
+	}
+}
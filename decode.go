@@ -2,9 +2,16 @@ package plist
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type parser interface {
@@ -17,13 +24,78 @@ type Decoder struct {
 	Format int
 
 	reader io.ReadSeeker
+	start  int64 // the offset of the current document in reader
+
+	// mapping, if set, holds the contents of reader, which binary (and, unless detached, text) property lists
+	// are parsed from directly. Strings and data are copied out of it if it is detached, as it may not outlive
+	// the decoded values.
+	mapping  []byte
+	detached bool
+
 	lax    bool
+	tokens tokenizer
+
+	disallowUnknownFields      bool
+	disallowDuplicateKeys      bool
+	disallowUnparseableMapKeys bool
+	failFast                   bool
+	caseInsensitiveFields      bool
+	disallowComments           bool
+	useOrderedDict             bool
+	useNumber                  bool
+	preserveNumberText         bool
+	streamData                 bool
+	integerType                int // see IntegerType
+	preserveComments           bool
+
+	parallelArrays int // the minimum length of arrays decoded in parallel, or 0
+	maxDepth       int // the limit on nesting, or 0 for DefaultMaxDepth
+	maxObjects     int64
+	maxDataBytes   int64
+	budget         *decodeBudget // what remains of the limits above during Decode, or nil if there are none
+
+	ctx context.Context // the context of DecodeContext, or nil
+
+	uidResolver    UIDResolver
+	archiveObjects []cfValue
+	resolving      []cfUID // the UIDs whose objects the UIDResolver is decoding, innermost last
+	uidPointers    bool
+	uidObjects     []interface{} // the table given to ResolveUIDPointers, or nil for the document's $objects
+	uidGraph       *uidGraph     // the pointers decoded from UIDs during Decode, or nil
+
+	report func(Warning)
+	path   []string // the path of the value being decoded, as segments for joinPath; only kept if report is set
+
+	dateLayouts       []string
+	preserveTimeZones bool
+
+	decodeHooks []DecodeHook
+	fieldNames  func(field string) string // see MapFieldNames
+	jsonTags    bool                      // see UseJSONTags
 }
 
 // Decode works like Unmarshal, except it reads the decoder stream to find property list elements.
 //
 // After Decoding, the Decoder's Format field will be set to one of the plist format constants.
-func (p *Decoder) Decode(v interface{}) (err error) {
+//
+// Decode leaves the stream positioned after the property list it decoded, so that a stream holding several
+// concatenated property lists can be read by calling Decode repeatedly (see More). As OpenStep and GNUStep
+// property lists have no distinct end, a text property list always consumes the rest of the stream.
+func (p *Decoder) Decode(v interface{}) error {
+	return p.decodeValue(reflect.ValueOf(v))
+}
+
+// DecodeValue works like Decode, but stores the property list in v itself, which must either be settable, as the
+// fields of a struct reached through a pointer are, or be a non-nil pointer. This lets code that works with
+// reflection decode into a value it holds without passing it through an interface{}.
+func (p *Decoder) DecodeValue(v reflect.Value) error {
+	if !v.CanSet() && (v.Kind() != reflect.Ptr || v.IsNil()) {
+		return errors.New("plist: DecodeValue needs a settable value or a non-nil pointer")
+	}
+	return p.decodeValue(v)
+}
+
+func (p *Decoder) decodeValue(v reflect.Value) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -33,33 +105,91 @@ func (p *Decoder) Decode(v interface{}) (err error) {
 		}
 	}()
 
+	pval, err := p.parse()
+	if err != nil {
+		return err
+	}
+
+	p.archiveObjects = nil
+	p.resolving = p.resolving[:0]
+	if p.uidResolver != nil {
+		p.archiveObjects = archiveObjectTable(pval)
+	}
+	if p.uidPointers {
+		p.uidGraph = p.newUIDGraph(pval)
+		defer func() { p.uidGraph = nil }()
+	}
+
+	if p.disallowDuplicateKeys {
+		if err := checkDuplicateKeys(pval); err != nil {
+			return err
+		}
+	}
+
+	p.budget = p.newBudget()
+	p.path = p.path[:0]
+	defer func() { p.budget = nil }()
+	return p.unmarshal(pval, v)
+}
+
+// DecodeContext works like Decode, but stops decoding once ctx is done and returns ctx.Err(). The context is
+// checked as each object is parsed and as each value is stored, so that decoding even a very large property list
+// can be abandoned promptly.
+func (p *Decoder) DecodeContext(ctx context.Context, v interface{}) error {
+	p.ctx = ctx
+	defer func() { p.ctx = nil }()
+
+	err := p.Decode(v)
+	if cerr := ctx.Err(); cerr != nil && errors.Is(err, cerr) {
+		// Parsers wrap the errors they encounter; report cancellation as it is.
+		return cerr
+	}
+	return err
+}
+
+// parse detects the format of the property list in the decoder's stream and parses it.
+func (p *Decoder) parse() (pval cfValue, err error) {
 	header := make([]byte, 6)
+	p.reader.Seek(p.start, 0)
 	p.reader.Read(header)
-	p.reader.Seek(0, 0)
+	p.reader.Seek(p.start, 0)
 
-	var parser parser
-	var pval cfValue
 	if bytes.Equal(header, []byte("bplist")) {
-		parser = newBplistParser(p.reader)
-		pval, err = parser.parseDocument()
+		bp := p.newBplistParser()
+		pval, err = bp.parseDocument()
 		if err != nil {
 			// Had a bplist header, but still got an error: we have to die here.
-			return err
+			return nil, err
 		}
 		p.Format = BinaryFormat
+		p.start += bp.size
+		p.reader.Seek(p.start, 0)
 	} else {
-		parser = newXMLPlistParser(p.reader)
-		pval, err = parser.parseDocument()
+		xp := newXMLPlistParser(p.reader)
+		xp.maxDepth = p.depthLimit()
+		xp.ctx = p.ctx
+		xp.dateLayouts = p.dateLayouts
+		xp.keepComments = p.preserveComments
+		pval, err = xp.parseDocument()
 		if _, ok := err.(invalidPlistError); ok {
 			// Rewind: the XML parser might have exhausted the file.
-			p.reader.Seek(0, 0)
+			p.reader.Seek(p.start, 0)
 			// We don't use parser here because we want the textPlistParser type
 			tp := newTextPlistParser(p.reader)
+			if p.mapping != nil && !p.detached {
+				tp.buffer = p.mapping[p.start:]
+			}
+			tp.maxDepth = p.depthLimit()
+			tp.ctx = p.ctx
+			tp.dateLayouts = p.dateLayouts
+			tp.disallowComments = p.disallowComments
+			tp.keepComments = p.preserveComments
 			pval, err = tp.parseDocument()
 			if err != nil {
-				return err
+				return nil, err
 			}
 			p.Format = tp.format
+			p.start, _ = p.reader.Seek(0, io.SeekEnd)
 			if p.Format == OpenStepFormat {
 				// OpenStep property lists can only store strings,
 				// so we have to turn on lax mode here for the unmarshal step later.
@@ -67,21 +197,519 @@ func (p *Decoder) Decode(v interface{}) (err error) {
 			}
 		} else {
 			if err != nil {
-				return err
+				return nil, err
 			}
 			p.Format = XMLFormat
+			p.start += xp.xmlDecoder.InputOffset()
+			p.reader.Seek(p.start, 0)
+		}
+	}
+
+	return pval, nil
+}
+
+// newBplistParser returns a parser for the binary property list at the current position in the stream.
+func (p *Decoder) newBplistParser() *bplistParser {
+	bp := newBplistParser(p.reader)
+	if p.mapping != nil {
+		bp.buffer = p.mapping[p.start:]
+		bp.detached = p.detached
+	}
+	bp.maxDepth = p.depthLimit()
+	bp.ctx = p.ctx
+	bp.streamData = p.streamData
+	return bp
+}
+
+// More reports whether the stream holds another property list after the one most recently decoded.
+// Any whitespace preceding the next property list is consumed.
+func (p *Decoder) More() bool {
+	p.reader.Seek(p.start, 0)
+	b := make([]byte, 1)
+	for {
+		n, err := p.reader.Read(b)
+		if n == 0 {
+			if err != nil {
+				return false
+			}
+			continue
+		}
+		if !whitespace.ContainsByte(b[0]) {
+			p.reader.Seek(p.start, 0)
+			return true
+		}
+		p.start++
+	}
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the destination is a struct
+// and the input contains dictionary keys that do not match any exported field of the struct.
+func (p *Decoder) DisallowUnknownFields() {
+	p.disallowUnknownFields = true
+}
+
+// DisallowDuplicateKeys causes the Decoder to return an error when any dictionary in the input
+// contains the same key more than once. By default, the last value for a repeated key wins.
+func (p *Decoder) DisallowDuplicateKeys() {
+	p.disallowDuplicateKeys = true
+}
+
+// DisallowComments causes the Decoder to return an error when an OpenStep or GNUStep property list contains
+// a comment. By default, // and /* */ comments are skipped wherever whitespace is permitted, as they are in
+// hand-written .strings files and Xcode project files.
+func (p *Decoder) DisallowComments() {
+	p.disallowComments = true
+}
+
+// FailFast causes the Decoder to stop at the first value that cannot be decoded and return its error alone.
+// By default, the Decoder carries on, decoding everything it can, and returns every error it encountered together
+// (as a *multierror.Error), which is more useful for reporting problems in a property list but costs more when
+// the property list is expected to be rejected.
+func (p *Decoder) FailFast() {
+	p.failFast = true
+}
+
+// CaseInsensitiveFields causes the Decoder to match dictionary keys to struct fields without regard to case
+// when no key matches a field's name exactly, in the same manner as encoding/json.
+func (p *Decoder) CaseInsensitiveFields() {
+	p.caseInsensitiveFields = true
+}
+
+// DisallowUnparseableMapKeys causes the Decoder to return an error when a dictionary is decoded into a map
+// whose key type is not a string (for example, map[int]string) and one of its keys cannot be converted
+// to that type. By default, such keys are skipped.
+func (p *Decoder) DisallowUnparseableMapKeys() {
+	p.disallowUnparseableMapKeys = true
+}
+
+// MapFieldNames causes the Decoder to match each struct field whose tag does not name its key to the key mapping
+// returns for the name of the field, rather than to the name itself, as Encoder.MapFieldNames does when encoding.
+// CaseInsensitiveFields applies to the keys that mapping returns.
+func (p *Decoder) MapFieldNames(mapping func(field string) string) {
+	p.fieldNames = mapping
+}
+
+// UseJSONTags causes the Decoder to describe each struct field that has no plist tag by its json tag, if it has
+// one, as Encoder.UseJSONTags does.
+func (p *Decoder) UseJSONTags() {
+	p.jsonTags = true
+}
+
+// AddDateLayouts causes the Decoder to accept dates in any of the given layouts, as understood by time.Parse,
+// in addition to the standard layout of each property list format. Dates without a time zone are taken to be in UTC.
+//
+// The layouts also apply when a string is decoded into a time.Time, allowing dates stored as strings to be decoded
+// from any property list format.
+func (p *Decoder) AddDateLayouts(layouts ...string) {
+	p.dateLayouts = append(p.dateLayouts, layouts...)
+}
+
+// A DecodeHook converts a value read from a property list before the Decoder stores it in a value of type to.
+// The value is given as it would be stored in an empty interface (see Unmarshal), and from is its type; each hook
+// returns the value to pass on to the next, or v itself if it has nothing to convert. A hook that changes a
+// dictionary or array must return a new one, as one that is returned unchanged is decoded as it was read.
+// A hook that returns nil leaves the destination unchanged, and one that returns an error ends decoding with it.
+//
+// Hooks let the Decoder convert, for example, strings to enumerations or integers to custom ID types without
+// wrapper types that implement Unmarshaler.
+type DecodeHook func(from, to reflect.Type, v interface{}) (interface{}, error)
+
+// AddDecodeHooks causes the Decoder to pass every value through the given hooks, in order, before storing it.
+// If the value returned by the last hook can be assigned to the destination, it is stored there as it is;
+// otherwise it is decoded into the destination as if it had been read from the property list.
+//
+// Hooks see every value, including the dictionaries and arrays that hold the others, which are passed as
+// map[string]interface{} and []interface{}; as those are built for each hook, hooks slow decoding considerably.
+func (p *Decoder) AddDecodeHooks(hooks ...DecodeHook) {
+	p.decodeHooks = append(p.decodeHooks, hooks...)
+}
+
+// parseDate parses s using each of layouts in turn, returning the first successfully parsed time.
+func parseDate(s string, layouts []string) (t time.Time, err error) {
+	for _, layout := range layouts {
+		if t, err = time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// PreserveTimeZones causes the Decoder to retain the time zone offset of each date in the input,
+// rather than converting every date to UTC. Binary property lists do not record time zones,
+// so their dates are always decoded in UTC.
+func (p *Decoder) PreserveTimeZones() {
+	p.preserveTimeZones = true
+}
+
+// decodedTime returns t in UTC, unless the Decoder is preserving time zones.
+func (p *Decoder) decodedTime(t time.Time) time.Time {
+	if p.preserveTimeZones {
+		return t
+	}
+	return t.In(time.UTC)
+}
+
+// UseOrderedDict causes the Decoder to store dictionaries as *OrderedDict, rather than map[string]interface{},
+// when decoding into an empty interface. This retains the order of each dictionary's keys.
+func (p *Decoder) UseOrderedDict() {
+	p.useOrderedDict = true
+}
+
+// UseNumber causes the Decoder to store integers and reals as Number, rather than as int64, uint64, float32 or
+// float64, when decoding into an empty interface. This retains the exact value of every number, as it was written.
+func (p *Decoder) UseNumber() {
+	p.useNumber = true
+}
+
+// PreserveNumberText works like UseNumber, but stores each integer and real read from a GNUStep property list as a
+// Number holding the text with which it was written, such as 0x1F, 007 or 1.50, rather than in decimal. Together
+// with Encoder.PreserveNumberText, this leaves the numbers in a text property list untouched when it is decoded
+// and encoded again. The numbers of OpenStep property lists, which are stored as strings, are always preserved.
+func (p *Decoder) PreserveNumberText() {
+	p.preserveNumberText = true
+}
+
+// StreamData causes the Decoder to avoid holding data in memory where it can. Data unmarshaled into an io.Writer,
+// such as an *os.File or a bytes.Buffer, is written to it, and data is stored in empty interfaces, and reported by
+// Token, as DataReader values.
+//
+// The data of a binary property list read from an io.ReaderAt, such as an *os.File, is not read until it is used:
+// it is copied to each io.Writer in chunks, and the DataReaders read it from the input, which must remain open until
+// they have been read. The data of other property lists is decoded into memory as usual.
+func (p *Decoder) StreamData() {
+	p.streamData = true
+}
+
+// Integer types, for use with Decoder.IntegerType.
+const (
+	// IntegerDefault stores negative integers as int64, and all others as uint64, except for those beyond the range
+	// of both, which are stored as *big.Int.
+	IntegerDefault int = iota
+
+	// IntegerInt64 stores every integer as an int64.
+	IntegerInt64
+
+	// IntegerUint64 stores every integer as a uint64.
+	IntegerUint64
+
+	// IntegerInt stores every integer as an int.
+	IntegerInt
+)
+
+// IntegerType selects the Go type in which the Decoder stores integers when decoding into an empty interface. It
+// must be one of the Integer constants. Decoding fails with an OverflowError if an integer does not fit the chosen
+// type. UseNumber takes precedence over IntegerType.
+func (p *Decoder) IntegerType(t int) {
+	p.integerType = t
+}
+
+// PreserveComments causes the Decoder to record the comments that precede each dictionary entry in XML, OpenStep
+// and GNUStep property lists, and store them in OrderedDict.Comments. Comments are only retained by dictionaries
+// decoded as *OrderedDict (see UseOrderedDict); comments elsewhere, such as within arrays, are discarded.
+func (p *Decoder) PreserveComments() {
+	p.preserveComments = true
+}
+
+// DefaultMaxDepth is the deepest that arrays and dictionaries may be nested in a property list read by a Decoder,
+// unless another limit is set with MaxDepth.
+const DefaultMaxDepth = 10000
+
+// MaxDepth limits the depth to which arrays and dictionaries may be nested in the property lists read by the Decoder.
+// Decoding a property list that exceeds it fails with an error, rather than exhausting the stack.
+// Pass 0 to restore the default limit, DefaultMaxDepth.
+func (p *Decoder) MaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+func (p *Decoder) depthLimit() int {
+	if p.maxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return p.maxDepth
+}
+
+// MaxObjects limits the number of values, counting every array and dictionary, that Decode stores in a single call.
+// Values that a binary property list refers to more than once are counted each time they are stored, so this
+// prevents a small binary property list from expanding into a vast number of Go values.
+// Decode fails with an error once the limit is exceeded. Pass 0 to remove the limit, which is the default.
+func (p *Decoder) MaxObjects(n int) {
+	p.maxObjects = int64(n)
+}
+
+// MaxDataBytes limits the total length of the strings, data and dictionary keys that Decode stores in a single call,
+// counted in the same way as MaxObjects. Decode fails with an error once the limit is exceeded.
+// Pass 0 to remove the limit, which is the default.
+func (p *Decoder) MaxDataBytes(n int64) {
+	p.maxDataBytes = n
+}
+
+// decodeBudget counts down the values and bytes a Decoder may still store. It is shared by the goroutines of
+// ParallelArrays, so it is only updated atomically (and allocated separately, so that its fields are aligned).
+type decodeBudget struct {
+	objects, bytes       int64
+	maxObjects, maxBytes int64
+}
+
+func (p *Decoder) newBudget() *decodeBudget {
+	if p.maxObjects <= 0 && p.maxDataBytes <= 0 {
+		return nil
+	}
+	b := &decodeBudget{objects: math.MaxInt64, bytes: math.MaxInt64, maxObjects: p.maxObjects, maxBytes: p.maxDataBytes}
+	if p.maxObjects > 0 {
+		b.objects = p.maxObjects
+	}
+	if p.maxDataBytes > 0 {
+		b.bytes = p.maxDataBytes
+	}
+	return b
+}
+
+// account records that pval is about to be stored, and panics if that exceeds the Decoder's limits or the context
+// of DecodeContext is done.
+func (p *Decoder) account(pval cfValue) {
+	checkContext(p.ctx)
+
+	b := p.budget
+	if b == nil {
+		return
+	}
+	if atomic.AddInt64(&b.objects, -1) < 0 {
+		panic(fmt.Errorf("plist: property list holds more than %d values", b.maxObjects))
+	}
+
+	var n int
+	switch pval := pval.(type) {
+	case cfString:
+		n = len(pval)
+	case cfData:
+		n = len(pval)
+	case *cfDictionary:
+		for _, k := range pval.keys {
+			n += len(k)
 		}
 	}
+	if n > 0 && atomic.AddInt64(&b.bytes, -int64(n)) < 0 {
+		panic(fmt.Errorf("plist: property list holds more than %d bytes of strings and data", b.maxBytes))
+	}
+}
+
+func maxDepthError(max int) error {
+	return fmt.Errorf("arrays and dictionaries are nested more than %d deep", max)
+}
+
+// ParallelArrays causes the Decoder to divide the elements of each array holding at least minLength values between
+// several goroutines (up to GOMAXPROCS) when decoding it into a slice or array. This can greatly reduce the time
+// taken to decode very large arrays. Errors are reported exactly as they would be otherwise, in element order.
+// Pass 0 to decode every array on the calling goroutine, which is the default.
+//
+// Unmarshaler implementations, UIDResolvers and the function passed to ReportWarnings may be called concurrently when
+// decoding an array in parallel.
+// Arrays decoded into an empty interface are always decoded on the calling goroutine.
+func (p *Decoder) ParallelArrays(minLength int) {
+	p.parallelArrays = minLength
+}
+
+// WarningKind identifies the kind of a Warning.
+type WarningKind int
+
+// Kinds of Warning.
+const (
+	// WarnFloatNarrowed: a real number was stored in a float32, which cannot hold it exactly.
+	WarnFloatNarrowed WarningKind = iota + 1
+
+	// WarnSignReinterpreted: an unsigned integer too large for an int64 was stored in a time.Duration,
+	// and so became negative.
+	WarnSignReinterpreted
+
+	// WarnUIDCoerced: a UID was stored in an integer, or an integer in a UID.
+	WarnUIDCoerced
+)
+
+// A Warning describes a value that was decoded, but not exactly as it appears in the property list.
+type Warning struct {
+	Kind    WarningKind
+	Path    string // the path of the value, as in PathError; empty for the root
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%q: %s", w.Path, w.Message)
+}
 
-	return p.unmarshal(pval, reflect.ValueOf(v))
+// ReportWarnings causes the Decoder to call report for each value that it decodes, but cannot store exactly, rather
+// than doing so silently. Warnings never cause decoding to fail.
+func (p *Decoder) ReportWarnings(report func(Warning)) {
+	p.report = report
+}
+
+// warn reports a Warning about the value being decoded, if the Decoder reports warnings.
+func (p *Decoder) warn(kind WarningKind, format string, args ...interface{}) {
+	if p.report == nil {
+		return
+	}
+	var path string
+	for i := len(p.path) - 1; i >= 0; i-- {
+		path = joinPath(p.path[i], path)
+	}
+	p.report(Warning{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// enterKey records that the value of the dictionary key k is being decoded, for warnings. leavePath undoes it.
+func (p *Decoder) enterKey(k string) {
+	if p.report != nil {
+		p.path = append(p.path, keySegment(k))
+	}
+}
+
+// enterIndex records that the element at index i of an array is being decoded, for warnings.
+func (p *Decoder) enterIndex(i int) {
+	if p.report != nil {
+		p.path = append(p.path, indexSegment(i))
+	}
+}
+
+func (p *Decoder) leavePath() {
+	if p.report != nil {
+		p.path = p.path[:len(p.path)-1]
+	}
+}
+
+// A UIDResolver is called by a Decoder whenever a UID is about to be stored in a destination whose type is
+// neither UID nor an empty interface. v is a pointer to the destination, and decode unmarshals the object the
+// UID refers to (in a keyed archive's $objects array) into the value pointed to by its argument.
+// A resolver that wishes to flatten the archive can simply return decode(v). As a flattened archive cannot hold
+// a cycle, decode fails if it is asked for an object that is already being decoded; ResolveUIDPointers can
+// reproduce cycles.
+type UIDResolver func(uid UID, v interface{}, decode func(interface{}) error) error
+
+// ResolveUIDs causes the Decoder to call resolver instead of storing UIDs as integers.
+func (p *Decoder) ResolveUIDs(resolver UIDResolver) {
+	p.uidResolver = resolver
+}
+
+// ResolveUIDPointers causes the Decoder to reconstruct graphs of objects that refer to one another by UID, such as
+// the objects in a keyed archive. When a UID is decoded into a pointer, the object at that index in objects is
+// decoded into a new value of the pointer's element type. Every pointer of the same type into which the same UID
+// is decoded is given the same value, so shared references, and cycles, are reproduced faithfully.
+//
+// If objects is nil, UIDs refer to the elements of the document's $objects array, as for ResolveUIDs. Otherwise,
+// objects holds values such as those decoded into an empty interface, and may come from another document.
+//
+// The string $null, which stands for nil in keyed archives, is decoded as a nil pointer. UIDs decoded into
+// destinations other than pointers, and into pointers to UIDs, interfaces or other pointers, are decoded as they
+// would be otherwise. ResolveUIDPointers takes precedence over ResolveUIDs.
+func (p *Decoder) ResolveUIDPointers(objects []interface{}) {
+	p.uidPointers = true
+	p.uidObjects = objects
+}
+
+// A uidGraph records the pointers into which the objects referred to by UIDs have been decoded, during a single
+// call to Decode. It is shared by the goroutines used by ParallelArrays.
+type uidGraph struct {
+	objects []cfValue
+
+	mu       sync.Mutex
+	pointers map[uidPointerKey]reflect.Value
+}
+
+type uidPointerKey struct {
+	uid cfUID
+	typ reflect.Type
+}
+
+func (p *Decoder) newUIDGraph(pval cfValue) *uidGraph {
+	objects := archiveObjectTable(pval)
+	if p.uidObjects != nil {
+		objects = make([]cfValue, len(p.uidObjects))
+		for i, obj := range p.uidObjects {
+			objects[i] = (&Encoder{}).marshal(reflect.ValueOf(obj))
+		}
+	}
+	return &uidGraph{objects: objects, pointers: make(map[uidPointerKey]reflect.Value)}
+}
+
+// archiveObjectTable returns the $objects array of a keyed archive, if pval is one.
+func archiveObjectTable(pval cfValue) []cfValue {
+	if dict, ok := pval.(*cfDictionary); ok {
+		for i, k := range dict.keys {
+			if a, ok := dict.values[i].(*cfArray); ok && k == "$objects" {
+				return a.values
+			}
+		}
+	}
+	return nil
+}
+
+func checkDuplicateKeys(pval cfValue) error {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		seen := make(map[string]struct{}, len(pval.keys))
+		for _, k := range pval.keys {
+			if _, ok := seen[k]; ok {
+				return fmt.Errorf("plist: duplicate dictionary key %q", k)
+			}
+			seen[k] = struct{}{}
+		}
+		for i, v := range pval.values {
+			if err := checkDuplicateKeys(v); err != nil {
+				return fmt.Errorf("%w (in dictionary key %q)", err, pval.keys[i])
+			}
+		}
+	case *cfArray:
+		for i, v := range pval.values {
+			if err := checkDuplicateKeys(v); err != nil {
+				return fmt.Errorf("%w (in array element %d)", err, i)
+			}
+		}
+	}
+	return nil
+}
+
+// DetectFormat determines the format of the property list in r, returning one of the plist format constants.
+// It uses the same rules as Decode, and returns InvalidFormat and an error if r does not hold a valid property list.
+//
+// Binary and XML property lists are identified without being read in full. Distinguishing between OpenStep and
+// GNUStep property lists requires parsing the entire document.
+//
+// DetectFormat always reads from the beginning of r, and rewinds r to its beginning before returning.
+func DetectFormat(r io.ReadSeeker) (int, error) {
+	r.Seek(0, 0)
+	d := NewDecoder(r)
+	err := d.beginTokens()
+	r.Seek(0, 0)
+	if err != nil {
+		return InvalidFormat, err
+	}
+	return d.Format, nil
 }
 
 // NewDecoder returns a Decoder that reads property list elements from a stream reader, r.
 // NewDecoder requires a Seekable stream for the purposes of file type detection.
+//
+// If r also implements io.ReaderAt, as *os.File and *bytes.Reader do, binary property lists are read from it
+// object by object rather than being read into memory in full. A source that only implements io.ReaderAt
+// can be decoded by way of io.NewSectionReader.
 func NewDecoder(r io.ReadSeeker) *Decoder {
 	return &Decoder{Format: InvalidFormat, reader: r, lax: false}
 }
 
+// Reset discards the Decoder's state and makes it read from r, as if it had been returned by NewDecoder,
+// while retaining the options that have been set on it. This allows a configured Decoder to be reused.
+func (p *Decoder) Reset(r io.ReadSeeker) {
+	p.Format = InvalidFormat
+	p.reader = r
+	p.start = 0
+	p.mapping = nil
+	p.detached = false
+	p.lax = false
+	p.tokens = nil
+	p.archiveObjects = nil
+	p.uidGraph = nil
+}
+
 // Unmarshal parses a property list document and stores the result in the value pointed to by v.
 //
 // Unmarshal uses the inverse of the type encodings that Marshal uses, allocating heap-borne types as necessary.
@@ -92,16 +720,33 @@ func NewDecoder(r io.ReadSeeker) *Decoder {
 // in the interface value. If the interface value is nil, Unmarshal stores one of the following in the interface value:
 //
 //	string, bool, uint64, float64
+//	*big.Int, for integers beyond the range of int64 and uint64
 //	plist.UID for "CoreFoundation Keyed Archiver UIDs" (convertible to uint64)
 //	[]byte, for plist data
-//	[]interface{}, for plist arrays
-//	map[string]interface{}, for plist dictionaries
+//	[]interface{}, for plist arrays (and the sets and ordered sets that binary property lists may hold)
+//	map[string]interface{}, for plist dictionaries (or *OrderedDict; see Decoder.UseOrderedDict)
+//
+// Dictionaries can be decoded into maps whose keys are strings, integers, floating-point numbers or types that
+// implement encoding.TextUnmarshaler. Keys that cannot be converted to the map's key type are skipped
+// (see Decoder.DisallowUnparseableMapKeys).
+//
+// Dictionaries can also be decoded into slices of structs with fields named Key and Value, such as
+// []struct{ Key string; Value int }, which receive an element for each entry, in the order in which the entries
+// appear in the property list. The Key field may have any type that a map key may have. Such slices are
+// marshaled as arrays unless their struct field is tagged with the dict flag (see Marshal).
+//
+// A json.RawMessage receives the property list value converted to JSON, as by ToJSON.
+//
+// Values that implement encoding.TextUnmarshaler are decoded from strings. Those that implement encoding.BinaryUnmarshaler,
+// but not encoding.TextUnmarshaler, are decoded from data.
 //
 // If a property list value is not appropriate for a given value type, Unmarshal aborts immediately and returns an error.
 //
-// As Go does not support 128-bit types, and we don't want to pretend we're giving the user integer types (as opposed to
-// secretly passing them structs), Unmarshal will drop the high 64 bits of any 128-bit integers encoded in binary property lists.
-// (This is important because CoreFoundation serializes some large 64-bit values as 128-bit values with an empty high half.)
+// Property lists may hold integers of up to 128 bits, which binary property lists store as 16-byte integers. (CoreFoundation
+// serializes unsigned 64-bit values beyond the range of int64 this way, with an empty high half; these are decoded as uint64.)
+// Integers beyond the range of int64 and uint64 are stored in an empty interface as *big.Int, and may be unmarshaled into a
+// big.Int or big.Float; Unmarshal reports an OverflowError when storing them in any other integer type. Marshal stores big.Int
+// and big.Float values as integers and reals, rather than as the text they marshal themselves to.
 //
 // When Unmarshal encounters an OpenStep property list, it will enter a relaxed parsing mode: OpenStep property lists can only store
 // plain old data as strings, so we will attempt to recover integer, floating-point, boolean and date values wherever they are necessary.
@@ -112,6 +757,25 @@ func NewDecoder(r io.ReadSeeker) *Decoder {
 func Unmarshal(data []byte, v interface{}) (format int, err error) {
 	r := bytes.NewReader(data)
 	dec := NewDecoder(r)
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		// Binary property lists are parsed in place, and the strings decoded from them refer to their input;
+		// parse a copy, so that they do not refer to data.
+		dec.mapping = append([]byte(nil), data...)
+	}
+	err = dec.Decode(v)
+	format = dec.Format
+	return
+}
+
+// UnmarshalZeroCopy works like Unmarshal, except that strings and data decoded from binary, OpenStep and GNUStep
+// property lists refer to data itself wherever possible, rather than to copies of it. This avoids an allocation for
+// most strings, at the cost of safety: data must not be modified for as long as any value decoded from it is in use.
+//
+// Strings that must be converted (such as UTF-16 strings in binary property lists, and strings containing escape
+// sequences in text property lists) are still copied, as are all strings decoded from XML property lists.
+func UnmarshalZeroCopy(data []byte, v interface{}) (format int, err error) {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.mapping = data
 	err = dec.Decode(v)
 	format = dec.Format
 	return
@@ -2,9 +2,14 @@ package plist
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func BenchmarkXMLDecode(b *testing.B) {
@@ -176,6 +181,45 @@ func TestInterfaceDecode(t *testing.T) {
 	}
 }
 
+func TestIntegerType(t *testing.T) {
+	tests := []struct {
+		name        string
+		integerType int
+		doc         string
+		expected    []interface{}
+	}{
+		{"Default", IntegerDefault, `(<*I1>, <*I-1>, <*I18446744073709551615>)`, []interface{}{uint64(1), int64(-1), uint64(18446744073709551615)}},
+		{"Int64", IntegerInt64, `(<*I1>, <*I-1>, <*I9223372036854775807>)`, []interface{}{int64(1), int64(-1), int64(9223372036854775807)}},
+		{"Int64Overflow", IntegerInt64, `(<*I1>, <*I9223372036854775808>)`, nil},
+		{"Uint64", IntegerUint64, `(<*I1>, <*I18446744073709551615>)`, []interface{}{uint64(1), uint64(18446744073709551615)}},
+		{"Uint64Overflow", IntegerUint64, `(<*I1>, <*I-1>)`, nil},
+		{"Int", IntegerInt, `(<*I1>, <*I-1>)`, []interface{}{1, -1}},
+		{"IntOverflow", IntegerInt, `(<*I18446744073709551615>)`, nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(test.doc))
+			dec.IntegerType(test.integerType)
+			var v interface{}
+			err := dec.Decode(&v)
+			if test.expected == nil {
+				if !errors.Is(err, ErrOverflow) {
+					t.Errorf("Expected an overflow error, received %v (%#v)", err, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Errorf("Expected %#v, received %#v", test.expected, v)
+			}
+		})
+	}
+}
+
 func TestFormatDetection(t *testing.T) {
 	type formatTest struct {
 		expectedFormat int
@@ -238,3 +282,605 @@ func ExampleDecoder_Decode() {
 
 	// Output: {6.0 8388608 1 com.apple.diskimage.sparsebundle 4398046511104}
 }
+
+func TestDisallowDuplicateKeys(t *testing.T) {
+	var duplicateKeyTest TestData
+	for _, test := range tests {
+		if test.Name == "Duplicate Dictionary Keys" {
+			duplicateKeyTest = test
+		}
+	}
+
+	for format, doc := range duplicateKeyTest.Documents {
+		var val interface{}
+		d := NewDecoder(bytes.NewReader(doc))
+		d.DisallowDuplicateKeys()
+		err := d.Decode(&val)
+		t.Logf("%s: Error: %v", FormatNames[format], err)
+		if err == nil {
+			t.Errorf("%s: Expected error, received nothing.", FormatNames[format])
+		}
+	}
+
+	nested := `{a = ({b = 1; c = 2;}, {b = 1; b = 2;});}`
+	var val interface{}
+	d := NewDecoder(bytes.NewReader([]byte(nested)))
+	d.DisallowDuplicateKeys()
+	err := d.Decode(&val)
+	t.Logf("Error: %v", err)
+	if err == nil {
+		t.Error("Expected error for nested duplicate key, received nothing.")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, test := range tests {
+		for format, doc := range test.Documents {
+			if test.SkipDecode[format] {
+				continue
+			}
+			if _, err := Unmarshal(doc, nil); err != nil {
+				continue
+			}
+			if err := Validate(doc); err != nil {
+				t.Errorf("%s/%s: Expected valid property list, received %v", test.Name, FormatNames[format], err)
+			}
+		}
+	}
+
+	var invalid [][]byte
+	invalid = append(invalid, InvalidBplists...)
+	for _, plist := range InvalidXMLPlists {
+		invalid = append(invalid, []byte(plist))
+	}
+	for _, test := range InvalidTextPlists {
+		invalid = append(invalid, []byte(test.Data))
+	}
+
+	for i, doc := range invalid {
+		if Valid(doc) {
+			t.Errorf("plist %d: Expected invalid property list: %q", i, doc)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	type formatTest struct {
+		expectedFormat int
+		data           []byte
+	}
+	plists := []formatTest{
+		{BinaryFormat, []byte{98, 112, 108, 105, 115, 116, 48, 48, 85, 72, 101, 108, 108, 111, 8, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 14}},
+		{XMLFormat, []byte(`<string>&lt;*I3&gt;</string>`)},
+		{XMLFormat, []byte(xmlPreamble + `<plist version="1.0"><dict><key>a</key><integer>1</integer></dict></plist>`)},
+		{XMLFormat, []byte("\xEF\xBB\xBF<plist><string>a</string></plist>")},
+		{InvalidFormat, []byte(`bplist00`)},
+		{OpenStepFormat, []byte(`(1,2,3,4,5)`)},
+		{OpenStepFormat, []byte(`<abab>`)},
+		{OpenStepFormat, []byte("\xEF\xBB\xBF(a,b)")},
+		{OpenStepFormat, []byte("\xFF\xFE(\x00a\x00)\x00")},
+		{OpenStepFormat, []byte("\xFE\xFF\x00(\x00a\x00)")},
+		{GNUStepFormat, []byte(`(1,2,<*I3>)`)},
+		{InvalidFormat, []byte{0x00}},
+		{OpenStepFormat, []byte{}}, // An empty document is an empty .strings-style dictionary.
+	}
+
+	for i, fmttest := range plists {
+		r := bytes.NewReader(fmttest.data)
+		r.Seek(2, 0)
+		format, err := DetectFormat(r)
+		if format != fmttest.expectedFormat {
+			t.Errorf("plist %d: Wanted %s, received %s.", i, FormatNames[fmttest.expectedFormat], FormatNames[format])
+		}
+		if (err != nil) != (fmttest.expectedFormat == InvalidFormat) {
+			t.Errorf("plist %d: Unexpected error state: %v", i, err)
+		}
+		if pos, _ := r.Seek(0, 1); pos != 0 {
+			t.Errorf("plist %d: Expected reader to be rewound, but it is at %d", i, pos)
+		}
+	}
+}
+
+func TestDecodeConcatenated(t *testing.T) {
+	var buf bytes.Buffer
+	values := []interface{}{
+		map[string]interface{}{"a": uint64(1)},
+		"hello",
+		[]interface{}{"b", uint64(2)},
+		bytes.Repeat([]byte{7}, 100*1024), // longer than the window in which the end of a document is sought
+		map[string]interface{}{"nested": map[string]interface{}{"c": "bplist00"}},
+		[]interface{}{"c", "d"},
+	}
+	formats := []int{XMLFormat, BinaryFormat, BinaryFormat, BinaryFormat, XMLFormat, OpenStepFormat}
+	for i, v := range values {
+		doc, err := Marshal(v, formats[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(doc)
+		buf.WriteString("\n\n")
+	}
+
+	d := NewDecoder(bytes.NewReader(buf.Bytes()))
+	for i := 0; d.More(); i++ {
+		if i >= len(values) {
+			t.Fatalf("More reported a document after the final one")
+		}
+
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if d.Format != formats[i] {
+			t.Errorf("document %d: Expected format %s, got %s", i, FormatNames[formats[i]], FormatNames[d.Format])
+		}
+		if !reflect.DeepEqual(v, values[i]) {
+			t.Errorf("document %d: Expected %#v, got %#v", i, values[i], v)
+		}
+	}
+	if d.More() {
+		t.Error("Expected no more documents")
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	type Data struct {
+		Name string
+	}
+	bplist, err := Marshal(map[string]string{"name": "binary"}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte(`{name = text;}`)))
+	d.CaseInsensitiveFields()
+	d.DisallowUnknownFields()
+	var v Data
+	if err := d.Decode(&v); err != nil || v.Name != "text" || d.Format != OpenStepFormat {
+		t.Fatalf("got %+v (format %s, error %v)", v, FormatNames[d.Format], err)
+	}
+
+	d.Reset(bytes.NewReader(bplist))
+	if d.Format != InvalidFormat {
+		t.Errorf("Reset left format %s", FormatNames[d.Format])
+	}
+	if err := d.Decode(&v); err != nil || v.Name != "binary" || d.Format != BinaryFormat {
+		t.Fatalf("got %+v (format %s, error %v)", v, FormatNames[d.Format], err)
+	}
+
+	d.Reset(bytes.NewReader([]byte(`{name = text; other = 1;}`)))
+	if err := d.Decode(&v); err == nil {
+		t.Error("expected the options to survive Reset, but an unknown field was accepted")
+	}
+}
+
+func TestDecoderResetLax(t *testing.T) {
+	d := NewDecoder(bytes.NewReader([]byte(`{N = 7;}`)))
+	var v struct{ N int }
+	if err := d.Decode(&v); err != nil || v.N != 7 {
+		t.Fatalf("got %+v (error %v)", v, err)
+	}
+
+	// The lax decoding of strings that OpenStep property lists need must not carry over to the next document.
+	d.Reset(bytes.NewReader([]byte(`<plist><string>7</string></plist>`)))
+	var n int
+	if err := d.Decode(&n); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, received %v (decoded %d)", err, n)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(depth int) interface{} {
+		var v interface{} = "leaf"
+		for i := 0; i < depth; i++ {
+			v = []interface{}{v}
+		}
+		return v
+	}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat} {
+		format := format
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(nested(10), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tokens := range []bool{false, true} {
+				for limit, fails := range map[int]bool{9: true, 10: false} {
+					d := NewDecoder(bytes.NewReader(doc))
+					d.MaxDepth(limit)
+					err = nil
+					if tokens {
+						for err == nil {
+							_, err = d.Token()
+						}
+						if err == io.EOF {
+							err = nil
+						}
+					} else {
+						var v interface{}
+						err = d.Decode(&v)
+					}
+					if (err != nil) != fails {
+						t.Errorf("tokens %v, limit %d: got error %v", tokens, limit, err)
+					}
+				}
+			}
+		})
+	}
+
+	doc := strings.Repeat("(", DefaultMaxDepth+1) + strings.Repeat(")", DefaultMaxDepth+1)
+	var v interface{}
+	if _, err := Unmarshal([]byte(doc), &v); err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("expected the default limit to be exceeded, got %v", err)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	// Each level refers to the one below four times; deduplicated, the whole document holds a handful of objects.
+	var v interface{} = strings.Repeat("x", 100)
+	for i := 0; i < 8; i++ {
+		v = []interface{}{v, v, v, v}
+	}
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	enc.DeduplicateObjects()
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 200 {
+		t.Fatalf("expected a small document, got %d bytes", buf.Len())
+	}
+
+	tests := []struct {
+		name       string
+		limit      func(d *Decoder)
+		wantErr    string
+		intoStruct bool
+	}{
+		{"unlimited", func(d *Decoder) {}, "", false},
+		{"objects", func(d *Decoder) { d.MaxObjects(1000) }, "more than 1000 values", false},
+		{"objects/typed", func(d *Decoder) { d.MaxObjects(1000) }, "more than 1000 values", true},
+		{"objects/ordered", func(d *Decoder) { d.MaxObjects(1000); d.UseOrderedDict() }, "more than 1000 values", false},
+		{"bytes", func(d *Decoder) { d.MaxDataBytes(10000) }, "more than 10000 bytes", false},
+		{"bytes/typed", func(d *Decoder) { d.MaxDataBytes(10000) }, "more than 10000 bytes", true},
+		{"sufficient", func(d *Decoder) { d.MaxObjects(87381); d.MaxDataBytes(6553600) }, "", true},
+	}
+	for _, test := range tests {
+		test := test
+		subtest(t, test.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(buf.Bytes()))
+			test.limit(d)
+			var err error
+			if test.intoStruct {
+				var out [][][][][][][][]string
+				err = d.Decode(&out)
+			} else {
+				var out interface{}
+				err = d.Decode(&out)
+			}
+			if test.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("got error %v, expected one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// doneAfter is a context that becomes done after Done has been called n times.
+type doneAfter struct {
+	context.Context
+	n int
+}
+
+func (c *doneAfter) Done() <-chan struct{} {
+	c.n--
+	if c.n < 0 {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return nil
+}
+
+func (c *doneAfter) Err() error {
+	if c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestDecodeContext(t *testing.T) {
+	v := make([]interface{}, 100)
+	for i := range v {
+		v[i] = map[string]interface{}{"index": fmt.Sprint(i)}
+	}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat, GNUStepFormat} {
+		format := format
+		doc, err := Marshal(v, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var out interface{}
+			if err := NewDecoder(bytes.NewReader(doc)).DecodeContext(context.Background(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, v) {
+				t.Errorf("decoded %#v, expected %#v", out, v)
+			}
+
+			// Cancel before parsing, during parsing and while storing the values.
+			for _, n := range []int{0, 10, 250} {
+				var out []map[string]string
+				err := NewDecoder(bytes.NewReader(doc)).DecodeContext(&doneAfter{context.Background(), n}, &out)
+				if err != context.Canceled {
+					t.Errorf("cancelled after %d checks: got error %v, expected %v", n, err, context.Canceled)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	type config struct {
+		Name  string
+		Ports []int
+	}
+	doc := []byte(`{Name=web;Ports=(<*I80>,<*I443>);}`)
+	expected := config{Name: "web", Ports: []int{80, 443}}
+
+	// A field reached through a pointer is settable.
+	var holder struct{ Config config }
+	field := reflect.ValueOf(&holder).Elem().Field(0)
+	if err := NewDecoder(bytes.NewReader(doc)).DecodeValue(field); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(holder.Config, expected) {
+		t.Errorf("Expected %+v, received %+v", expected, holder.Config)
+	}
+
+	ptr := reflect.New(reflect.TypeOf(config{}))
+	if err := NewDecoder(bytes.NewReader(doc)).DecodeValue(ptr); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ptr.Elem().Interface(), expected) {
+		t.Errorf("Expected %+v, received %+v", expected, ptr.Elem().Interface())
+	}
+
+	for _, v := range []reflect.Value{{}, reflect.ValueOf(config{}), reflect.ValueOf((*config)(nil))} {
+		if err := NewDecoder(bytes.NewReader(doc)).DecodeValue(v); err == nil {
+			t.Errorf("Expected error decoding into %v, received nothing.", v)
+		}
+	}
+}
+
+type hookColor int
+
+const (
+	hookRed hookColor = iota + 1
+	hookGreen
+)
+
+type hookUserID int64
+
+func TestDecodeHooks(t *testing.T) {
+	colorType := reflect.TypeOf(hookColor(0))
+	userIDType := reflect.TypeOf(hookUserID(0))
+	stringType := reflect.TypeOf("")
+
+	trim := func(from, to reflect.Type, v interface{}) (interface{}, error) {
+		if from == stringType {
+			return strings.TrimSpace(v.(string)), nil
+		}
+		return v, nil
+	}
+	convert := func(from, to reflect.Type, v interface{}) (interface{}, error) {
+		switch {
+		case to == colorType && from == stringType:
+			switch v {
+			case "red":
+				return hookRed, nil
+			case "green":
+				return hookGreen, nil
+			}
+			return nil, fmt.Errorf("unknown color %q", v)
+		case to == userIDType && from == reflect.TypeOf(uint64(0)):
+			return hookUserID(v.(uint64)), nil
+		case from == reflect.TypeOf(map[string]interface{}{}):
+			// Accept an old name for a key.
+			if m := v.(map[string]interface{}); m["Owner"] == nil && m["owner"] != nil {
+				renamed := map[string]interface{}{"Owner": m["owner"]}
+				for k, v := range m {
+					renamed[k] = v
+				}
+				return renamed, nil
+			}
+		}
+		return v, nil
+	}
+
+	type doc struct {
+		Name   string
+		Color  hookColor
+		Owner  hookUserID
+		Colors []hookColor
+		Any    interface{}
+	}
+
+	var decoded doc
+	dec := NewDecoder(bytes.NewReader([]byte(`{Name=" web ";Color=red;owner=<*I42>;Colors=(green, " red");Any=" x ";}`)))
+	dec.AddDecodeHooks(trim, convert)
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{Name: "web", Color: hookRed, Owner: 42, Colors: []hookColor{hookGreen, hookRed}, Any: "x"}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %+v, received %+v", expected, decoded)
+	}
+
+	dec = NewDecoder(bytes.NewReader([]byte(`{Color=blue;}`)))
+	dec.AddDecodeHooks(convert)
+	if err := dec.Decode(&decoded); err == nil || !strings.Contains(err.Error(), `unknown color "blue"`) {
+		t.Errorf("Expected the hook's error, received %v", err)
+	}
+
+	// Hooks run in ParallelArrays workers without suspending the limits the workers share.
+	colors := make([]string, 64)
+	for i := range colors {
+		colors[i] = "green"
+	}
+	b, _ := Marshal(colors, GNUStepFormat)
+	var decodedColors []hookColor
+	dec = NewDecoder(bytes.NewReader(b))
+	dec.AddDecodeHooks(convert)
+	dec.ParallelArrays(2)
+	dec.MaxObjects(10)
+	if err := dec.Decode(&decodedColors); err == nil {
+		t.Error("Expected error exceeding MaxObjects, received nothing.")
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		line, column int
+	}{
+		{"XML/Empty Integer", "<plist><dict>\n<key>a</key>\n<integer></integer>\n</dict></plist>", 3, 20},
+		{"XML/Missing Key", "<plist>\n<dict>\n\t<key>a</key>\n\t<string>b</string>\n\t<string>c</string>\n</dict>\n</plist>", 5, 10},
+		{"XML/Mismatched Tag", "<plist>\n<array>\n  <string>a</strin>\n</array></plist>", 3, 20},
+		{"XML/Bad Date", "<plist>\n<array>\n  <date>yesterday</date>\n</array></plist>", 3, 25},
+		{"OpenStep/Missing Value", "{\n\ta = b;\n\tc = ;\n}", 3, 6},
+		{"GNUStep/Bad Integer", "{\n\ta = <*I12x>;\n}", 2, 13},
+		{"OpenStep/Unterminated Array", "(\n\ta,\n\tb\n", 4, 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.name, func(t *testing.T) {
+			var v interface{}
+			_, err := Unmarshal([]byte(test.doc), &v)
+			var perr PositionError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected a PositionError in %v", err)
+			}
+			if line, column := perr.Position(); line != test.line || column != test.column {
+				t.Errorf("got line %d column %d, expected line %d column %d (%v)", line, column, test.line, test.column, err)
+			}
+		})
+	}
+}
+
+func TestAddDateLayouts(t *testing.T) {
+	type Record struct {
+		Created  time.Time
+		Modified time.Time
+	}
+	doc := xmlPreamble + `<plist version="1.0"><dict><key>Created</key><date>2013-11-27 00:34:00</date><key>Modified</key><string>Wed, 27 Nov 2013 00:34:00 GMT</string></dict></plist>`
+	date := time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC)
+
+	var r Record
+	if _, err := Unmarshal([]byte(doc), &r); err == nil {
+		t.Error("Expected error decoding non-standard date layouts, received nothing.")
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte(doc)))
+	d.AddDateLayouts("2006-01-02 15:04:05", time.RFC1123)
+	if err := d.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Created.Equal(date) || !r.Modified.Equal(date) {
+		t.Errorf("Expected both dates to be %v, received %#v", date, r)
+	}
+}
+
+func TestPreserveTimeZones(t *testing.T) {
+	docs := []string{
+		`<*D2013-11-27 02:34:00 +0200>`,
+		xmlPreamble + `<plist version="1.0"><date>2013-11-27T02:34:00+02:00</date></plist>`,
+	}
+	date := time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC)
+
+	for _, doc := range docs {
+		var decoded time.Time
+		if _, err := Unmarshal([]byte(doc), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(date) || decoded.Location() != time.UTC {
+			t.Errorf("Expected %v in UTC by default, received %v", date, decoded)
+		}
+
+		d := NewDecoder(bytes.NewReader([]byte(doc)))
+		d.PreserveTimeZones()
+		if err := d.Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		if _, offset := decoded.Zone(); !decoded.Equal(date) || offset != 2*60*60 {
+			t.Errorf("Expected %v with a +0200 offset, received %v", date, decoded)
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoderForFormat(&buf, d.Format)
+		enc.PreserveTimeZones()
+		if err := enc.Encode(decoded); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != doc {
+			t.Errorf("Expected %s to round-trip, received %s", doc, buf.String())
+		}
+
+		// A date that is never a time.Time, as in a RawValue, is still written in UTC by default.
+		buf.Reset()
+		if err := NewEncoderForFormat(&buf, XMLFormat).Encode(RawValue(doc)); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "<date>2013-11-27T00:34:00Z</date>") {
+			t.Errorf("Expected the date in UTC, received %s", buf.String())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	doc := []byte(`// !$*UTF8*$!
+{
+	/* Begin PBXBuildFile section */
+	archiveVersion = 1; // trailing
+	objects = (a /* first */, b);
+	data = <0102 /* middle */ 0304>;
+	/*/ still a comment */
+}
+`)
+
+	var val map[string]interface{}
+	if _, err := Unmarshal(doc, &val); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"archiveVersion": "1",
+		"objects":        []interface{}{"a", "b"},
+		"data":           []byte{1, 2, 3, 4},
+	}
+	if !reflect.DeepEqual(val, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, val)
+	}
+
+	for _, doc := range []string{`// comment` + "\n{a=b;}", `{a=/* comment */b;}`, `<01 // comment` + "\n02>"} {
+		dec := NewDecoder(bytes.NewReader([]byte(doc)))
+		dec.DisallowComments()
+		var v interface{}
+		if err := dec.Decode(&v); err == nil {
+			t.Errorf("Expected an error decoding %q with comments disallowed", doc)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader([]byte(`{a="// not a comment";}`)))
+	dec.DisallowComments()
+	if err := dec.Decode(&val); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,244 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A DiffKind is the kind of a Difference.
+type DiffKind int
+
+const (
+	// DiffAdded is a value present only in the second property list.
+	DiffAdded DiffKind = iota + 1
+
+	// DiffRemoved is a value present only in the first property list.
+	DiffRemoved
+
+	// DiffChanged is a value present in both property lists, but that differs between them.
+	DiffChanged
+)
+
+var diffKindNames = map[DiffKind]string{
+	DiffAdded:   "added",
+	DiffRemoved: "removed",
+	DiffChanged: "changed",
+}
+
+func (k DiffKind) String() string {
+	if name, ok := diffKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// A Difference is a value that differs between two property lists.
+type Difference struct {
+	Kind DiffKind
+	Path string      // the path of the value, as described for ParsePath
+	Old  interface{} // the value in the first property list, unless Kind is DiffAdded
+	New  interface{} // the value in the second property list, unless Kind is DiffRemoved
+}
+
+// String returns d in the form "+ path: new", "- path: old" or "~ path: old -> new". The root is written as $.
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "$"
+	}
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("+ %s: %v", path, d.New)
+	case DiffRemoved:
+		return fmt.Sprintf("- %s: %v", path, d.Old)
+	}
+	return fmt.Sprintf("~ %s: %v -> %v", path, d.Old, d.New)
+}
+
+// Diff returns the differences between a and b, which hold property lists decoded into empty interfaces (see Get).
+// Dictionaries are compared key by key, and arrays element by element; a dictionary or array that has been replaced
+// by a value of another type is reported as changed as a whole. Numbers are compared by value, whatever their type.
+// Entries are reported in the order of a's keys (sorted, for a map), followed by the keys added in b.
+//
+// Diff is independent of the format in which a and b were stored, except that OpenStep property lists store
+// every value as a string.
+func Diff(a, b interface{}) []Difference {
+	var diffs []Difference
+	diffValues(a, b, nil, &diffs)
+	return diffs
+}
+
+// DiffDocuments decodes the property lists in a and b, in any formats, and returns the differences between them
+// as Diff would.
+func DiffDocuments(a, b []byte) ([]Difference, error) {
+	var av, bv interface{}
+	if _, err := Unmarshal(a, &av); err != nil {
+		return nil, err
+	}
+	if _, err := Unmarshal(b, &bv); err != nil {
+		return nil, err
+	}
+	return Diff(av, bv), nil
+}
+
+// Equal reports whether the property lists in a and b hold equal values, in any formats. Dictionaries are equal
+// if they have the same keys, in any order, with equal values; numbers are equal if they have the same value,
+// whether they are stored as integers or reals, and at whatever width; and dates are equal if they denote the same
+// instant. Equal returns an error if either document cannot be decoded.
+func Equal(a, b []byte) (bool, error) {
+	var av, bv interface{}
+	if _, err := Unmarshal(a, &av); err != nil {
+		return false, err
+	}
+	if _, err := Unmarshal(b, &bv); err != nil {
+		return false, err
+	}
+	return valuesEqual(av, bv), nil
+}
+
+// valuesEqual reports whether Diff would find no differences between a and b.
+func valuesEqual(a, b interface{}) bool {
+	if ak, ok := diffKeys(a); ok {
+		bk, ok := diffKeys(b)
+		if !ok || len(ak) != len(bk) {
+			return false
+		}
+		for _, k := range ak {
+			av, _ := queryChild(a, k)
+			bv, ok := queryChild(b, k)
+			if !ok || !valuesEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if aa, ok := a.([]interface{}); ok {
+		ba, ok := b.([]interface{})
+		if !ok || len(aa) != len(ba) {
+			return false
+		}
+		for i := range aa {
+			if !valuesEqual(aa[i], ba[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return !isDictionary(b) && diffScalarsEqual(a, b)
+}
+
+func diffValues(a, b interface{}, path []interface{}, diffs *[]Difference) {
+	if ak, ok := diffKeys(a); ok {
+		if bk, ok := diffKeys(b); ok {
+			for _, k := range ak {
+				av, _ := queryChild(a, k)
+				elemPath := append(path[:len(path):len(path)], k)
+				if bv, ok := queryChild(b, k); ok {
+					diffValues(av, bv, elemPath, diffs)
+				} else {
+					*diffs = append(*diffs, Difference{Kind: DiffRemoved, Path: formatPath(elemPath), Old: av})
+				}
+			}
+			for _, k := range bk {
+				if _, ok := queryChild(a, k); !ok {
+					bv, _ := queryChild(b, k)
+					elemPath := append(path[:len(path):len(path)], k)
+					*diffs = append(*diffs, Difference{Kind: DiffAdded, Path: formatPath(elemPath), New: bv})
+				}
+			}
+			return
+		}
+	}
+
+	if aa, ok := a.([]interface{}); ok {
+		if ba, ok := b.([]interface{}); ok {
+			for i := 0; i < len(aa) || i < len(ba); i++ {
+				elemPath := append(path[:len(path):len(path)], i)
+				switch {
+				case i >= len(ba):
+					*diffs = append(*diffs, Difference{Kind: DiffRemoved, Path: formatPath(elemPath), Old: aa[i]})
+				case i >= len(aa):
+					*diffs = append(*diffs, Difference{Kind: DiffAdded, Path: formatPath(elemPath), New: ba[i]})
+				default:
+					diffValues(aa[i], ba[i], elemPath, diffs)
+				}
+			}
+			return
+		}
+	}
+
+	if !diffScalarsEqual(a, b) {
+		*diffs = append(*diffs, Difference{Kind: DiffChanged, Path: formatPath(path), Old: a, New: b})
+	}
+}
+
+// diffKeys returns the keys of the dictionary v, in order, and whether v is a dictionary.
+func diffKeys(v interface{}) ([]string, bool) {
+	switch d := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys, true
+	case *OrderedDict:
+		return d.Keys, true
+	case OrderedDict:
+		return d.Keys, true
+	}
+	return nil, false
+}
+
+// diffScalarsEqual reports whether a and b, which are not both dictionaries or both arrays, are equal.
+func diffScalarsEqual(a, b interface{}) bool {
+	if _, ok := queryNumber(a); ok {
+		if _, isUID := a.(UID); !isUID {
+			_, isUID = b.(UID)
+			return !isUID && diffNumbersEqual(reflect.ValueOf(a), reflect.ValueOf(b))
+		}
+	}
+	switch a := a.(type) {
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// diffNumbersEqual reports whether the number a is equal to b, which may be a number of another type. Integers are
+// compared exactly.
+func diffNumbersEqual(a, b reflect.Value) bool {
+	switch ak, bk := diffIntegerKind(a), diffIntegerKind(b); {
+	case ak == reflect.Int && bk == reflect.Int:
+		return a.Int() == b.Int()
+	case ak == reflect.Uint && bk == reflect.Uint:
+		return a.Uint() == b.Uint()
+	case ak == reflect.Int && bk == reflect.Uint:
+		return a.Int() >= 0 && uint64(a.Int()) == b.Uint()
+	case ak == reflect.Uint && bk == reflect.Int:
+		return b.Int() >= 0 && uint64(b.Int()) == a.Uint()
+	}
+	an, _ := queryNumber(a.Interface())
+	bn, ok := queryNumber(b.Interface())
+	return ok && an == bn
+}
+
+// diffIntegerKind returns reflect.Int for a signed integer, reflect.Uint for an unsigned integer, or reflect.Invalid.
+func diffIntegerKind(v reflect.Value) reflect.Kind {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	}
+	return reflect.Invalid
+}
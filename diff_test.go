@@ -0,0 +1,150 @@
+package plist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	a := map[string]interface{}{
+		"Name":    "profile",
+		"Version": uint64(1),
+		"Removed": true,
+		"Payloads": []interface{}{
+			map[string]interface{}{"Type": "wifi", "SSID": "Office"},
+			map[string]interface{}{"Type": "vpn"},
+		},
+		"Created": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"Data":    []byte{1, 2},
+		"Kind":    []interface{}{"a"},
+	}
+	b := map[string]interface{}{
+		"Name":    "profile",
+		"Version": int64(2),
+		"Payloads": []interface{}{
+			map[string]interface{}{"Type": "wifi", "SSID": "Guest", "Hidden": true},
+		},
+		"Created": time.Date(2020, 1, 1, 1, 0, 0, 0, time.FixedZone("", 3600)),
+		"Data":    []byte{1, 2},
+		"Kind":    "a",
+		"Added":   "x",
+	}
+
+	expected := []Difference{
+		{Kind: DiffChanged, Path: "Kind", Old: []interface{}{"a"}, New: "a"},
+		{Kind: DiffChanged, Path: "Payloads[0].SSID", Old: "Office", New: "Guest"},
+		{Kind: DiffAdded, Path: "Payloads[0].Hidden", New: true},
+		{Kind: DiffRemoved, Path: "Payloads[1]", Old: map[string]interface{}{"Type": "vpn"}},
+		{Kind: DiffRemoved, Path: "Removed", Old: true},
+		{Kind: DiffChanged, Path: "Version", Old: uint64(1), New: int64(2)},
+		{Kind: DiffAdded, Path: "Added", New: "x"},
+	}
+	if diffs := Diff(a, b); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, diffs)
+	}
+
+	if diffs := Diff(a, a); len(diffs) != 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+	if diffs := Diff(uint64(1<<63+1), uint64(1<<63+2)); len(diffs) != 1 {
+		t.Errorf("expected one difference, got %v", diffs)
+	}
+	if diffs := Diff(int64(-1), uint64(1<<64-1)); len(diffs) != 1 {
+		t.Errorf("expected one difference, got %v", diffs)
+	}
+	if diffs := Diff(UID(1), uint64(1)); len(diffs) != 1 {
+		t.Errorf("expected one difference, got %v", diffs)
+	}
+	if diffs := Diff(float64(2), uint64(2)); len(diffs) != 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+}
+
+func TestDiffOrderedDict(t *testing.T) {
+	a := &OrderedDict{Keys: []string{"B", "A"}, Values: []interface{}{"b", "a"}}
+	b := &OrderedDict{Keys: []string{"C", "A"}, Values: []interface{}{"c", "A"}}
+
+	expected := []string{"- B: b", "~ A: a -> A", "+ C: c"}
+	diffs := Diff(a, b)
+	got := make([]string, len(diffs))
+	for i, d := range diffs {
+		got[i] = d.String()
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDiffDocuments(t *testing.T) {
+	v := map[string]interface{}{"Name": "profile", "Count": 3, "Items": []string{"a", "b"}}
+	xml, err := Marshal(v, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v["Count"] = 4
+	binary, err := Marshal(v, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := DiffDocuments(xml, binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Difference{{Kind: DiffChanged, Path: "Count", Old: uint64(3), New: uint64(4)}}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected %v, got %v", expected, diffs)
+	}
+
+	if _, err := DiffDocuments(xml, []byte("<plist><dict>")); err == nil {
+		t.Error("expected an error for an invalid document")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	xml := []byte(`<plist><dict>
+		<key>B</key><array><integer>1</integer><real>2.5</real></array>
+		<key>A</key><date>2020-01-02T03:04:05Z</date>
+		<key>C</key><dict><key>CF$UID</key><integer>4</integer></dict>
+	</dict></plist>`)
+	binary, err := Marshal(map[string]interface{}{
+		"A": time.Date(2020, 1, 2, 4, 4, 5, 0, time.FixedZone("", 3600)),
+		"B": []interface{}{1.0, float32(2.5)},
+		"C": UID(4),
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte(`{A = <*D2020-01-02 03:04:05 +0000>; B = (<*I1>, <*R2.5>); C = {CF$UID = <*I4>;};}`)
+
+	tests := []struct {
+		name  string
+		a, b  []byte
+		equal bool
+	}{
+		{"XML and binary", xml, binary, true},
+		{"binary and GNUStep", binary, text, true},
+		{"changed value", xml, []byte(`{A = <*D2020-01-02 03:04:05 +0000>; B = (<*I1>, <*R2.6>); C = {CF$UID = <*I4>;};}`), false},
+		{"extra key", xml, []byte(`{A = <*D2020-01-02 03:04:05 +0000>; B = (<*I1>, <*R2.5>); C = {CF$UID = <*I4>;}; D = d;}`), false},
+		{"missing element", xml, []byte(`{A = <*D2020-01-02 03:04:05 +0000>; B = (<*I1>); C = {CF$UID = <*I4>;};}`), false},
+		{"UID and integer", xml, []byte(`{A = <*D2020-01-02 03:04:05 +0000>; B = (<*I1>, <*R2.5>); C = <*I4>;}`), false},
+	}
+
+	for _, test := range tests {
+		equal, err := Equal(test.a, test.b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if equal != test.equal {
+			t.Errorf("%s: expected %v, got %v", test.name, test.equal, equal)
+		}
+		if diffs, _ := DiffDocuments(test.a, test.b); (len(diffs) == 0) != equal {
+			t.Errorf("%s: Equal is %v, but Diff found %v", test.name, equal, diffs)
+		}
+	}
+
+	if _, err := Equal(xml, []byte("bplist00")); err == nil {
+		t.Error("expected an error for an invalid document")
+	}
+}
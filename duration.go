@@ -0,0 +1,115 @@
+package plist
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// durationFormat describes how a time.Duration field is represented in a property list.
+type durationFormat int
+
+const (
+	durationNanoseconds  durationFormat = iota // an integer number of nanoseconds (the default)
+	durationSeconds                            // a real number of seconds (,seconds)
+	durationMilliseconds                       // an integer number of milliseconds (,milliseconds)
+	durationString                             // a string, as formatted by time.Duration.String (,durationstring)
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (p *Encoder) marshalDuration(val reflect.Value, format durationFormat) cfValue {
+	val = innermostValue(val)
+	if !val.IsValid() {
+		return nil
+	}
+
+	d := time.Duration(val.Int())
+	switch format {
+	case durationSeconds:
+		return &cfReal{wide: true, value: d.Seconds()}
+	case durationMilliseconds:
+		ms := int64(d / time.Millisecond)
+		return &cfNumber{signed: true, value: uint64(ms)}
+	case durationString:
+		return cfString(d.String())
+	}
+	return &cfNumber{signed: true, value: uint64(d)}
+}
+
+func (p *Decoder) unmarshalDuration(pval cfValue, val reflect.Value, format durationFormat) error {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+
+	var d time.Duration
+	unit := format.unit()
+	switch pval := pval.(type) {
+	case cfString:
+		var err error
+		if format == durationString {
+			d, err = time.ParseDuration(string(pval))
+		} else {
+			var f float64
+			if f, err = strconv.ParseFloat(string(pval), 64); err == nil {
+				d, err = realDuration(f, unit, string(pval), val.Type())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	case *cfNumber:
+		if format == durationString {
+			return &TypeMismatchError{Source: pval.typeName(), Dest: val.Type()}
+		}
+		if !pval.signed && int64(pval.value) < 0 {
+			p.warn(WarnSignReinterpreted, "integer %s stored as a negative duration", pval)
+		}
+		n := int64(pval.value)
+		if pval.big != nil || n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return &OverflowError{Value: pval.String(), Dest: val.Type()}
+		}
+		d = time.Duration(n) * unit
+	case *cfReal:
+		if format == durationString {
+			return &TypeMismatchError{Source: pval.typeName(), Dest: val.Type()}
+		}
+		if math.IsNaN(pval.value) || math.IsInf(pval.value, 0) {
+			return fmt.Errorf("plist: cannot decode %v as a duration", pval.value)
+		}
+		var err error
+		if d, err = realDuration(pval.value, unit, strconv.FormatFloat(pval.value, 'g', -1, 64), val.Type()); err != nil {
+			return err
+		}
+	default:
+		return &TypeMismatchError{Source: pval.typeName(), Dest: val.Type()}
+	}
+
+	val.SetInt(int64(d))
+	return nil
+}
+
+// realDuration returns f units as a Duration, or an OverflowError reporting text if it does not fit in one.
+func realDuration(f float64, unit time.Duration, text string, dest reflect.Type) (time.Duration, error) {
+	ns := f * float64(unit)
+	if math.IsNaN(ns) || ns >= math.MaxInt64 || ns < math.MinInt64 {
+		return 0, &OverflowError{Value: text, Dest: dest}
+	}
+	return time.Duration(ns), nil
+}
+
+// unit returns the length of one unit of a numeric duration in format.
+func (format durationFormat) unit() time.Duration {
+	switch format {
+	case durationSeconds:
+		return time.Second
+	case durationMilliseconds:
+		return time.Millisecond
+	}
+	return time.Nanosecond
+}
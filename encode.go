@@ -1,16 +1,135 @@
 package plist
 
 import (
-	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"runtime"
 )
 
 type generator interface {
 	generateDocument(cfValue)
-	Indent(string)
+	Options(EncoderOptions)
+	DateLayout(string)
+}
+
+// EncoderOptions controls the layout of XML and Text property lists written by an Encoder.
+// The zero value writes each property list on a single line.
+type EncoderOptions struct {
+	// Indent turns on pretty-printing: each element begins on a new line and is preceded by one or more
+	// copies of Indent according to its nesting depth.
+	Indent string
+
+	// Newline is written at the end of each line. It defaults to "\n".
+	Newline string
+
+	// FlatRoot writes the root element of an XML property list at the same depth as the <plist> element
+	// that contains it, rather than one level deeper, as Apple's tools do.
+	FlatRoot bool
+
+	// OmitXMLHeader omits the XML declaration and document type declaration from XML property lists.
+	OmitXMLHeader bool
+
+	// XMLDeclaration replaces the XML declaration written at the start of XML property lists,
+	// and XMLDoctype replaces the document type declaration that follows it. Each is written verbatim.
+	XMLDeclaration string
+	XMLDoctype     string
+
+	// OmitXMLDeclaration and OmitXMLDoctype omit the XML declaration and the document type declaration
+	// from XML property lists, respectively.
+	OmitXMLDeclaration bool
+	OmitXMLDoctype     bool
+
+	// PlistVersion sets the version attribute of the <plist> element. It defaults to "1.0".
+	PlistVersion string
+
+	// Fragment writes an XML property list as a bare fragment: its root element alone, without the XML declaration,
+	// the document type declaration or the <plist> element that would enclose it, as defaults write accepts.
+	// OpenStep and GNUStep property lists have no such wrapper, and binary property lists are always complete
+	// documents, so they are unaffected. Unmarshal and Decoder read fragments in every text format.
+	Fragment bool
+
+	// DataLineWidth wraps the base64 text of each <data> element in an XML property list into lines of at most
+	// DataLineWidth characters. Each line is written on its own, indented as deeply as the <data> element and
+	// followed by DataIndent. Zero, the default, writes the base64 text on the same line as the element.
+	// A DataLineWidth of 76 with an Indent of "\t" matches the output of plutil.
+	DataLineWidth int
+	DataIndent    string
+
+	// EscapeNonASCII writes every character outside the ASCII range as a numeric character reference
+	// (such as &#xE9;) in the keys and strings of XML property lists, so that the output is pure ASCII.
+	// Comments, which cannot contain character references, are written unchanged.
+	EscapeNonASCII bool
+
+	// CompactXML writes XML property lists on a single line, without indentation or line breaks between any of
+	// their parts, for embedding in HTTP headers and log lines. It overrides Indent, Newline, DataLineWidth and
+	// FinalNewline. Line breaks in comments are written as spaces; those in strings are always written as
+	// character references.
+	CompactXML bool
+
+	// FinalNewline ends the property list with a newline.
+	FinalNewline bool
+
+	// HexDataGroup is the number of bytes in each space-separated group of the hexadecimal data written into
+	// OpenStep and GNUStep property lists. Zero, the default, writes groups of four bytes, as Apple's tools do;
+	// a negative value writes the data as a single run of digits, as in <AABBCC>.
+	HexDataGroup int
+
+	// HexDataUppercase writes the hexadecimal digits of data in OpenStep and GNUStep property lists in upper case.
+	HexDataUppercase bool
+
+	// QuoteAllStrings writes every string and dictionary key in OpenStep and GNUStep property lists in quotes,
+	// even those that could be written without them.
+	QuoteAllStrings bool
+
+	// TextNonASCII selects how characters outside ASCII are written in the strings and dictionary keys of OpenStep
+	// and GNUStep property lists: as \U escapes, for example, or as they are, in UTF-8. It must be one of the
+	// NonASCII constants. Any string holding such characters is quoted. It has no effect together with
+	// Encoder.StrictGNUStep, which always writes escapes.
+	TextNonASCII int
+
+	// TextQuoting, if set, replaces the rules by which strings are quoted and escaped in OpenStep and GNUStep
+	// property lists. It has no effect together with Encoder.StrictGNUStep, whose rules take precedence.
+	TextQuoting *TextQuoting
+}
+
+// TextQuoting describes how the strings and dictionary keys of OpenStep and GNUStep property lists are quoted and
+// escaped. Backslashes and the quotation mark are always escaped, as are the control characters \a, \b, \v and \f;
+// other characters in ASCII are written as they are, and those outside it as EncoderOptions.TextNonASCII selects.
+//
+// For example, Xcode writes project files with only letters, digits, '_', '.' and '/' unquoted, and with
+// characters outside ASCII written as they are:
+//
+//	EncoderOptions{TextQuoting: &TextQuoting{Unquoted: "_./"}, TextNonASCII: NonASCIIVerbatim}
+type TextQuoting struct {
+	// Unquoted holds the characters in ASCII, besides letters and digits, that may appear in a string written
+	// without quotes. Characters that the parser requires to be quoted, such as ';' and '=', are quoted
+	// regardless, as is the empty string.
+	Unquoted string
+
+	// Quote is the quotation mark: '"', the default, or '\''.
+	Quote byte
+}
+
+// Escapes for characters outside ASCII, for use with EncoderOptions.TextNonASCII.
+const (
+	// NonASCIIDefault writes characters up to U+00FF as octal escapes, such as \351, and others as \U escapes of
+	// their UTF-16 code units, such as \U20ac, as Apple's tools do.
+	NonASCIIDefault = iota
+	// NonASCIIUnicode writes every character outside ASCII as \U escapes of its UTF-16 code units.
+	NonASCIIUnicode
+	// NonASCIIVerbatim writes characters outside ASCII as they are, in UTF-8.
+	NonASCIIVerbatim
+)
+
+func (o EncoderOptions) newline() string {
+	if o.Newline == "" {
+		return "\n"
+	}
+	return o.Newline
 }
 
 // An Encoder writes a property list to an output stream.
@@ -18,7 +137,34 @@ type Encoder struct {
 	writer io.Writer
 	format int
 
-	indent string
+	options           EncoderOptions
+	dateLayout        string
+	preserveTimeZones bool
+	binaryFeatures    int
+	deduplicate       bool
+	strictGNUStep     bool
+	canonical         bool // see Canonical
+	preserveNumbers   bool // see PreserveNumberText
+	untypedGNUStep    bool // see UntypedGNUStep
+	reproducible      bool // see Reproducible
+
+	fieldNames func(field string) string // see MapFieldNames
+	jsonTags   bool                      // see UseJSONTags
+
+	untyped bool // whether the value being marshaled is written to GNUStep property lists without typed literals
+
+	nonFinite            int
+	nonFiniteReplacement float64
+
+	streamBinary bool
+	tempDir      string
+
+	keyOrder int
+	keyLess  func(a, b string) bool
+
+	incremental *encoderStream
+
+	ctx context.Context // the context of EncodeContext, or nil
 }
 
 // Encode writes the property list encoding of v to the stream.
@@ -32,29 +178,292 @@ func (p *Encoder) Encode(v interface{}) (err error) {
 		}
 	}()
 
+	if p.incremental != nil {
+		panic(errStreamInProgress)
+	}
+
+	p.untyped = p.untypedGNUStep
 	pval := p.marshal(reflect.ValueOf(v))
 	if pval == nil {
 		panic(errors.New("plist: no root element to encode"))
 	}
+	p.generate(pval)
+	return
+}
+
+// generate writes pval, which has just been marshaled or parsed, to the stream as a complete property list.
+func (p *Encoder) generate(pval cfValue) {
+	pval = p.finiteReals(pval)
+	if p.canonical {
+		pval = canonicalize(pval)
+	}
+
+	g := newGeneratorForFormat(p.writer, p.format)
+	defer releaseGenerator(g)
+	p.configureGenerator(g)
+	g.generateDocument(pval)
+}
+
+// EncodeContext works like Encode, but stops encoding once ctx is done and returns ctx.Err(). The context is
+// checked as each value is marshaled and as each object is written; anything already written to the stream
+// before then is left there.
+func (p *Encoder) EncodeContext(ctx context.Context, v interface{}) error {
+	p.ctx = ctx
+	defer func() { p.ctx = nil }()
+	return p.Encode(v)
+}
+
+// configureGenerator applies the Encoder's options to g.
+func (p *Encoder) configureGenerator(g generator) {
+	g.Options(p.options)
+	g.DateLayout(p.dateLayout)
+	keepZones := p.preserveTimeZones && !p.reproducible
+	switch g := g.(type) {
+	case *xmlPlistGenerator:
+		g.ctx = p.ctx
+		g.keepZones = keepZones
+	case *textPlistGenerator:
+		g.ctx = p.ctx
+		g.keepZones = keepZones
+	}
+	if vb, ok := g.(*valueBuilderGenerator); ok {
+		g = vb.generator
+	}
+	if sg, ok := g.(*bplistStreamGenerator); ok {
+		g = sg.bplistGenerator
+	}
+	if bg, ok := g.(*bplistGenerator); ok {
+		bg.features = p.binaryFeatures
+		bg.deduplicate = p.deduplicate
+		bg.ctx = p.ctx
+	}
+	if tg, ok := g.(*textPlistGenerator); ok {
+		tg.strict = p.strictGNUStep && tg.format == GNUStepFormat
+		tg.preserveNumbers = p.preserveNumbers
+	}
+}
 
-	var g generator
-	switch p.format {
+func newGeneratorForFormat(w io.Writer, format int) generator {
+	switch format {
 	case XMLFormat:
-		g = newXMLPlistGenerator(p.writer)
-	case BinaryFormat, AutomaticFormat:
-		g = newBplistGenerator(p.writer)
+		return newXMLPlistGenerator(w)
 	case OpenStepFormat, GNUStepFormat:
-		g = newTextPlistGenerator(p.writer, p.format)
+		return newTextPlistGenerator(w, format)
 	}
-	g.Indent(p.indent)
-	g.generateDocument(pval)
-	return
+	return newBplistGenerator(w)
 }
 
 // Indent turns on pretty-printing for the XML and Text property list formats.
 // Each element begins on a new line and is preceded by one or more copies of indent according to its nesting depth.
 func (p *Encoder) Indent(indent string) {
-	p.indent = indent
+	p.options.Indent = indent
+}
+
+// Newline sets the sequence written at the end of each line of XML and Text property lists, such as "\r\n".
+// It defaults to "\n". Newlines within strings and data are written unchanged.
+func (p *Encoder) Newline(newline string) {
+	p.options.Newline = newline
+}
+
+// SetOptions sets the layout options for the XML and Text property list formats, replacing any
+// previously set with SetOptions, Indent or Newline.
+func (p *Encoder) SetOptions(options EncoderOptions) {
+	p.options = options
+}
+
+// DateLayout sets the layout, as understood by time.Time.Format, used to write dates in the XML and Text
+// property list formats. Dates are written in UTC (see PreserveTimeZones). By default, XML property lists use RFC 3339
+// and Text property lists use "2006-01-02 15:04:05 -0700"; pass an empty layout to restore the default.
+//
+// Property lists written with a non-default layout may not be readable by other implementations.
+// Binary property lists store dates numerically, and are unaffected.
+func (p *Encoder) DateLayout(layout string) {
+	p.dateLayout = layout
+}
+
+// Dictionary key orders, for use with Encoder.KeyOrder.
+const (
+	// KeyOrderDefault sorts the keys of every dictionary, except those marshaled from an OrderedDict,
+	// which retain their order.
+	KeyOrderDefault int = iota
+
+	// KeyOrderSorted sorts the keys of every dictionary, including those marshaled from an OrderedDict.
+	KeyOrderSorted
+
+	// KeyOrderDeclaration writes the fields of each struct in the order in which they are declared, followed by the
+	// sorted entries of its inline map, if any. Maps are sorted, and OrderedDicts retain their order.
+	KeyOrderDeclaration
+)
+
+// KeyOrder selects the order in which the Encoder writes dictionary keys. It must be one of the KeyOrder constants.
+func (p *Encoder) KeyOrder(order int) {
+	p.keyOrder = order
+}
+
+// KeyLess sets the comparison function used wherever the Encoder sorts dictionary keys, in place of the
+// default lexical order. Keys that compare equal retain their original order. Pass nil to restore the default.
+func (p *Encoder) KeyLess(less func(a, b string) bool) {
+	p.keyLess = less
+}
+
+// Binary property list feature sets, for use with Encoder.BinaryFeatures.
+const (
+	// BinaryFeaturesAll permits every object type supported by this package. This is the default.
+	BinaryFeaturesAll int = iota
+
+	// BinaryFeaturesBaseline restricts binary property lists to the subset of bplist00 understood by the oldest
+	// CoreFoundation readers: integers are at most 64 bits wide, and every real is written as a 64-bit double.
+	// Unsigned integers greater than math.MaxInt64, which require 128-bit storage, cannot be encoded, and Sets are
+	// written as arrays.
+	BinaryFeaturesBaseline
+)
+
+// BinaryFeatures selects the set of object types the Encoder may use when writing binary property lists.
+// It must be one of the BinaryFeatures constants. Other formats are unaffected.
+func (p *Encoder) BinaryFeatures(features int) {
+	p.binaryFeatures = features
+}
+
+// Policies for reals that are NaN or infinite, for use with Encoder.NonFinite.
+const (
+	// NonFiniteDefault writes NaN and infinities as each format represents them: as IEEE 754 values in binary
+	// property lists, as nan, inf and -inf in XML property lists, and as NaN, +Inf and -Inf in text property lists.
+	// OpenStep property lists, which have no reals, read these back as strings.
+	NonFiniteDefault int = iota
+
+	// NonFiniteError causes the Encoder to return an error when it encounters NaN or an infinity.
+	NonFiniteError
+
+	// NonFiniteReplace writes the replacement passed to Encoder.NonFinite in place of NaN and infinities.
+	NonFiniteReplace
+)
+
+// NonFinite selects how the Encoder writes reals that are NaN or infinite, in every format. It must be one of the
+// NonFinite constants; replacement is used only with NonFiniteReplace.
+func (p *Encoder) NonFinite(policy int, replacement float64) {
+	p.nonFinite = policy
+	p.nonFiniteReplacement = replacement
+}
+
+// finiteReals applies the Encoder's NonFinite policy to the reals in pval, which has just been marshaled, and
+// returns the value to write in its place.
+func (p *Encoder) finiteReals(pval cfValue) cfValue {
+	if p.nonFinite == NonFiniteDefault {
+		return pval
+	}
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		for i, v := range pval.values {
+			pval.values[i] = p.finiteReals(v)
+		}
+	case *cfArray:
+		for i, v := range pval.values {
+			pval.values[i] = p.finiteReals(v)
+		}
+	case *cfReal:
+		if !math.IsInf(pval.value, 0) && !math.IsNaN(pval.value) {
+			break
+		}
+		if p.nonFinite == NonFiniteError {
+			panic(fmt.Errorf("plist: cannot encode non-finite real %v", pval.value))
+		}
+		return &cfReal{wide: pval.wide, value: p.nonFiniteReplacement}
+	}
+	return pval
+}
+
+// PreserveNumberText causes the Encoder to write each Number into OpenStep and GNUStep property lists exactly as
+// its text is written, such as 0x1F or 1.50, rather than in the shortest form that reads back exactly. Together with
+// Decoder.PreserveNumberText, this leaves the numbers in a text property list untouched when it is decoded and
+// encoded again. Other formats are unaffected.
+func (p *Encoder) PreserveNumberText() {
+	p.preserveNumbers = true
+}
+
+// UntypedGNUStep causes the Encoder to write integers, reals, booleans and dates into GNUStep property lists as
+// plain strings, as they are written into OpenStep property lists, rather than as typed literals such as <*I5>
+// and <*BY>. Struct fields tagged ,typed are still written with typed literals, and those tagged ,untyped are
+// written as plain strings whether or not this is set. Other formats are unaffected.
+//
+// Values of a RawValue are written as they were given. A property list that holds no typed literals at all is
+// read back as an OpenStep property list, whose strings Unmarshal converts to numbers, booleans and dates as needed.
+func (p *Encoder) UntypedGNUStep() {
+	p.untypedGNUStep = true
+}
+
+// DeduplicateObjects causes the Encoder to store identical values only once when writing binary property lists,
+// as CoreFoundation does. Strings, numbers, dates and data are always shared; this extends sharing to booleans,
+// UIDs and entire arrays and dictionaries, which can greatly reduce the size of repetitive documents.
+// Other formats are unaffected.
+func (p *Encoder) DeduplicateObjects() {
+	p.deduplicate = true
+}
+
+// StrictGNUStep causes the Encoder to write GNUStep property lists using only the syntax that GNUstep's own
+// parser accepts: array elements are not followed by a trailing separator, strings are quoted unless they
+// consist entirely of letters, digits, '_', '.' and '/', every character outside printable ASCII is written
+// as a \n, \t, \r or \U escape, and dates are always written in GNUstep's layout, regardless of DateLayout.
+// Other formats are unaffected.
+func (p *Encoder) StrictGNUStep() {
+	p.strictGNUStep = true
+}
+
+// StreamBinary causes binary property lists written incrementally, with BeginDict, BeginArray, WriteKey, WriteValue
+// and End, to be written to the output stream as they are produced rather than assembled in memory, so that
+// property lists of any size can be written in bounded memory. Bookkeeping for the objects written so far is kept in
+// temporary files in dir (or the default directory for temporary files, if dir is empty), which are removed once the
+// property list is complete. A streamed property list is abandoned, and its temporary files removed, as soon as any
+// of those methods returns an error.
+//
+// To make this possible, strings are not shared between objects, dictionary keys are written in the order given,
+// and every object reference is eight bytes wide, so the output is larger than that of Encode.
+// DeduplicateObjects has no effect on streamed property lists.
+func (p *Encoder) StreamBinary(dir string) {
+	p.streamBinary = true
+	p.tempDir = dir
+}
+
+// PreserveTimeZones causes the Encoder to write each date in its own location, rather than converting it to UTC.
+// Binary property lists do not record time zones, and are unaffected.
+//
+// Apple's property list parsers only accept XML dates in UTC.
+func (p *Encoder) PreserveTimeZones() {
+	p.preserveTimeZones = true
+}
+
+// Reproducible causes the Encoder to write equal values as identical bytes, wherever and however often they are
+// encoded, so that generated property lists can be compared byte for byte. Dictionary keys are always written in a
+// fixed order, reals and integers in a fixed form, and the objects of binary property lists in the order in which
+// they are reached, so what Reproducible adds is:
+//
+//   - Dates are written in UTC, even with PreserveTimeZones, as the location of a time.Time, such as time.Local,
+//     depends on the machine it is encoded on.
+//   - Keys that the function given to KeyLess orders neither way, such as "a" and "A" under a case-insensitive
+//     ordering, are written in increasing order of their bytes, rather than in the order in which a map yields them.
+//
+// Everything else is left to the Encoder's options, which must of course be the same for each encoding.
+func (p *Encoder) Reproducible() {
+	p.reproducible = true
+}
+
+// MapFieldNames causes the Encoder to write each struct field whose tag does not name its key under the key
+// mapping returns for the name of the field, rather than under the name itself. For example, to write the field
+// Identifier as PayloadIdentifier:
+//
+//	enc.MapFieldNames(func(field string) string { return "Payload" + field })
+//
+// mapping should return a distinct key for each field of a struct. Pass the same function to
+// Decoder.MapFieldNames to read the property list back.
+func (p *Encoder) MapFieldNames(mapping func(field string) string) {
+	p.fieldNames = mapping
+}
+
+// UseJSONTags causes the Encoder to describe each struct field that has no plist tag by its json tag, if it has
+// one, so that types shared with encoding/json need not repeat their tags. The name, "-" and the omitempty,
+// omitzero and string options of a json tag have the same meaning as they do in a plist tag; its other
+// options are ignored.
+func (p *Encoder) UseJSONTags() {
+	p.jsonTags = true
 }
 
 // NewEncoder returns an Encoder that writes an XML property list to w.
@@ -71,6 +480,13 @@ func NewEncoderForFormat(w io.Writer, format int) *Encoder {
 	}
 }
 
+// Reset makes the Encoder write to w, while retaining its format and the options that have been set on it.
+// This allows a configured Encoder to be reused. Any property list being written incrementally is abandoned.
+func (p *Encoder) Reset(w io.Writer) {
+	p.abandonStream()
+	p.writer = w
+}
+
 // NewBinaryEncoder returns an Encoder that writes a binary property list to w.
 func NewBinaryEncoder(w io.Writer) *Encoder {
 	return NewEncoderForFormat(w, BinaryFormat)
@@ -89,7 +505,12 @@ func NewBinaryEncoder(w io.Writer) *Encoder {
 // UTF-8 for XML property lists and UTF-16 for binary property lists.
 //
 // Slice and Array values are encoded as property list arrays, except for
-// []byte values, which are encoded as data.
+// []byte values, which are encoded as data. A DataReader is encoded as data streamed from its Reader.
+//
+// The JSON document held by a json.RawMessage is converted to a property list value, as by FromJSON, and embedded in place.
+//
+// Values that implement encoding.TextMarshaler are encoded as strings. Those that implement encoding.BinaryMarshaler,
+// but not encoding.TextMarshaler, are encoded as data.
 //
 // Map values encode as dictionaries. The map's key type must be string; there is no provision for encoding non-string dictionary keys.
 //
@@ -101,6 +522,43 @@ func NewBinaryEncoder(w io.Writer) *Encoder {
 // The following flags are supported:
 //
 //     omitempty    Only include the field if it is not set to the zero value for its type.
+//     omitzero     Only include the field if it is not the zero value for its type, as reported by its IsZero()
+//                  method if it has one. Unlike omitempty, this applies to structs (such as time.Time) and arrays.
+//     inline       Encode a struct field as if its exported fields were exposed via the outer struct, or encode
+//                  the entries of a map field (which must have string keys) into the outer dictionary.
+//                  When decoding, an inline map collects every key that does not correspond to another field.
+//                  A struct may have only one inline map, and its entries never replace other fields.
+//     dict         Encode a slice or array of structs with a string field named Key and a field named Value,
+//                  such as []struct{ Key string; Value int }, as a dictionary holding an entry for each element,
+//                  in the order of the elements (unless KeyOrderSorted is in effect), rather than as an array.
+//                  The keys should be distinct. When decoding, such a field receives the entries of a
+//                  dictionary in order, as any slice of such structs does.
+//     remain       Like inline, for a map field only: the map collects the keys that correspond to no other field
+//                  when decoding, and its entries are written back into the outer dictionary when encoding,
+//                  so that keys a struct does not model survive a round trip. A map[string]RawValue keeps
+//                  each of their values as it was encoded.
+//     string       Encode an integer, floating-point or boolean field as a string. When decoding, such a field
+//                  is parsed from a string (or decoded from a number or boolean, if that is what the property
+//                  list holds), regardless of the property list's format.
+//     default=...  When decoding, set the field to the given value if its key is absent from the dictionary.
+//                  The value is parsed as described for the string flag. It extends to the end of the tag,
+//                  so it must be the last flag, and may contain commas. It has no effect on encoding.
+//     seconds      Encode a time.Duration field as a real number of seconds, rather than an integer number
+//                  of nanoseconds.
+//     milliseconds Encode a time.Duration field as an integer number of milliseconds.
+//     durationstring
+//                  Encode a time.Duration field as a string such as "1h30m", as formatted by time.Duration.String.
+//     required     When decoding, return an error if the field's key is absent from the dictionary.
+//                  It has no effect on encoding.
+//     notext       Encode and decode the field as if its type did not implement encoding.TextMarshaler and
+//                  encoding.TextUnmarshaler. This applies only to the field's own value, and not, for
+//                  example, to the elements of a slice it holds.
+//     typed        When writing a GNUStep property list, write the integers, reals, booleans and dates in the
+//                  field, including those in any slice, map or struct it holds, as typed literals such as
+//                  <*I5> and <*BY>, even if the Encoder has UntypedGNUStep set.
+//     untyped      When writing a GNUStep property list, write the integers, reals, booleans and dates in the
+//                  field as plain strings, as UntypedGNUStep does. Unlike the string flag, this leaves other
+//                  formats unaffected.
 //
 // If the key is "-", the field is ignored.
 //
@@ -116,11 +574,13 @@ func Marshal(v interface{}, format int) ([]byte, error) {
 // MarshalIndent works like Marshal, but each property list element
 // begins on a new line and is preceded by one or more copies of indent according to its nesting depth.
 func MarshalIndent(v interface{}, format int, indent string) ([]byte, error) {
-	buf := &bytes.Buffer{}
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
 	enc := NewEncoderForFormat(buf, format)
 	enc.Indent(indent)
 	if err := enc.Encode(v); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return append([]byte(nil), buf.Bytes()...), nil
 }
@@ -0,0 +1,280 @@
+package plist
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+)
+
+// A streamingGenerator can write a property list one element at a time.
+type streamingGenerator interface {
+	generator
+
+	beginDocument()
+	endDocument()
+
+	beginDictionary()
+	endDictionary()
+	beginArray()
+	endArray()
+
+	writeKey(string)
+	beginElement(inDictionary bool)
+	writePlistValue(cfValue)
+	endElement(inDictionary bool)
+}
+
+// valueBuilderGenerator collects streamed elements into a value tree, which it passes to a
+// document generator once complete. It is used for formats that cannot be written incrementally.
+type valueBuilderGenerator struct {
+	generator
+	stack []cfValue
+	keys  []string
+	root  cfValue
+}
+
+func (p *valueBuilderGenerator) beginDocument() {}
+
+func (p *valueBuilderGenerator) endDocument() {
+	p.generateDocument(p.root)
+}
+
+func (p *valueBuilderGenerator) add(pval cfValue) {
+	n := len(p.stack)
+	if n == 0 {
+		p.root = pval
+		return
+	}
+
+	switch container := p.stack[n-1].(type) {
+	case *cfDictionary:
+		container.keys = append(container.keys, p.keys[len(p.keys)-1])
+		container.values = append(container.values, pval)
+		p.keys = p.keys[:len(p.keys)-1]
+	case *cfArray:
+		container.values = append(container.values, pval)
+	}
+}
+
+func (p *valueBuilderGenerator) beginDictionary() {
+	p.stack = append(p.stack, &cfDictionary{})
+}
+
+func (p *valueBuilderGenerator) beginArray() {
+	p.stack = append(p.stack, &cfArray{})
+}
+
+func (p *valueBuilderGenerator) endContainer() {
+	n := len(p.stack)
+	pval := p.stack[n-1]
+	p.stack = p.stack[:n-1]
+	p.add(pval)
+}
+
+func (p *valueBuilderGenerator) endDictionary() {
+	p.endContainer()
+}
+
+func (p *valueBuilderGenerator) endArray() {
+	p.endContainer()
+}
+
+func (p *valueBuilderGenerator) writeKey(k string) {
+	p.keys = append(p.keys, k)
+}
+
+func (p *valueBuilderGenerator) beginElement(inDictionary bool) {}
+func (p *valueBuilderGenerator) endElement(inDictionary bool)   {}
+
+func (p *valueBuilderGenerator) writePlistValue(pval cfValue) {
+	p.add(pval)
+}
+
+type encoderStreamFrame struct {
+	dictionary bool
+	wantsKey   bool
+}
+
+type encoderStream struct {
+	generator streamingGenerator
+	stack     []encoderStreamFrame
+	started   bool
+	done      bool
+}
+
+var (
+	errStreamNotInDictionary = errors.New("plist: key written outside of a dictionary")
+	errStreamWantsKey        = errors.New("plist: dictionary value written without a key")
+	errStreamWantsValue      = errors.New("plist: dictionary key written without a value")
+	errStreamNothingToEnd    = errors.New("plist: End called outside of a dictionary or array")
+	errStreamInProgress      = errors.New("plist: Encode called while an incremental property list is being written")
+)
+
+func (p *Encoder) streamGenerator() streamingGenerator {
+	switch p.format {
+	case XMLFormat:
+		return newXMLPlistGenerator(p.writer)
+	case OpenStepFormat, GNUStepFormat:
+		return newTextPlistGenerator(p.writer, p.format)
+	}
+	if p.streamBinary {
+		return newBplistStreamGenerator(p.writer, p.tempDir)
+	}
+	return &valueBuilderGenerator{generator: newBplistGenerator(p.writer)}
+}
+
+// withStream runs f against the incremental encoding state, starting a new property list if necessary.
+// Panics raised by f are returned as errors. A streamed binary property list cannot recover from an error, so it is
+// abandoned.
+func (p *Encoder) withStream(f func(s *encoderStream)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+			if s := p.incremental; s != nil {
+				if _, ok := s.generator.(*bplistStreamGenerator); ok {
+					p.abandonStream()
+				}
+			}
+		}
+	}()
+
+	if p.incremental == nil {
+		g := p.streamGenerator()
+		p.configureGenerator(g)
+		p.incremental = &encoderStream{generator: g}
+	}
+
+	s := p.incremental
+	f(s)
+	if s.done {
+		p.incremental = nil
+	}
+	return
+}
+
+// abandonStream drops the property list being written incrementally, removing any temporary files it uses.
+func (p *Encoder) abandonStream() {
+	if s := p.incremental; s != nil {
+		if sg, ok := s.generator.(*bplistStreamGenerator); ok {
+			sg.discard()
+		}
+		p.incremental = nil
+	}
+}
+
+func (s *encoderStream) beginElement() {
+	n := len(s.stack)
+	if n == 0 {
+		if !s.started {
+			s.generator.beginDocument()
+			s.started = true
+		}
+		return
+	}
+
+	top := &s.stack[n-1]
+	if top.dictionary {
+		if top.wantsKey {
+			panic(errStreamWantsKey)
+		}
+		top.wantsKey = true
+	}
+	s.generator.beginElement(top.dictionary)
+}
+
+func (s *encoderStream) endElement() {
+	n := len(s.stack)
+	if n == 0 {
+		s.generator.endDocument()
+		s.done = true
+		return
+	}
+	s.generator.endElement(s.stack[n-1].dictionary)
+}
+
+// BeginDict starts writing a dictionary. It must be balanced by a call to End.
+//
+// BeginDict, BeginArray, WriteKey, WriteValue and End allow a property list to be written
+// incrementally rather than all at once with Encode.
+// XML and text property lists are written to the underlying stream as they are produced, with dictionary keys
+// in the order given; binary property lists are assembled in memory and written once the outermost value is complete,
+// unless StreamBinary is in effect.
+// A new property list may be started once the outermost value is complete.
+func (p *Encoder) BeginDict() error {
+	return p.withStream(func(s *encoderStream) {
+		s.beginElement()
+		s.generator.beginDictionary()
+		s.stack = append(s.stack, encoderStreamFrame{dictionary: true, wantsKey: true})
+	})
+}
+
+// BeginArray starts writing an array. It must be balanced by a call to End.
+func (p *Encoder) BeginArray() error {
+	return p.withStream(func(s *encoderStream) {
+		s.beginElement()
+		s.generator.beginArray()
+		s.stack = append(s.stack, encoderStreamFrame{})
+	})
+}
+
+// WriteKey writes a dictionary key. It must be followed by exactly one value.
+func (p *Encoder) WriteKey(key string) error {
+	if p.incremental == nil {
+		return errStreamNotInDictionary
+	}
+	return p.withStream(func(s *encoderStream) {
+		n := len(s.stack)
+		if n == 0 || !s.stack[n-1].dictionary {
+			panic(errStreamNotInDictionary)
+		}
+		if !s.stack[n-1].wantsKey {
+			panic(errStreamWantsValue)
+		}
+		s.stack[n-1].wantsKey = false
+		s.generator.writeKey(key)
+	})
+}
+
+// WriteValue writes v, encoded as it would be by Encode, into the current dictionary or array.
+// If no dictionary or array has been started, v is written as a complete property list.
+func (p *Encoder) WriteValue(v interface{}) error {
+	return p.withStream(func(s *encoderStream) {
+		p.untyped = p.untypedGNUStep
+		pval := p.marshal(reflect.ValueOf(v))
+		if pval == nil {
+			panic(errors.New("plist: no value to encode"))
+		}
+		pval = p.finiteReals(pval)
+		s.beginElement()
+		s.generator.writePlistValue(pval)
+		s.endElement()
+	})
+}
+
+// End finishes the most recently started dictionary or array.
+func (p *Encoder) End() error {
+	if p.incremental == nil {
+		return errStreamNothingToEnd
+	}
+	return p.withStream(func(s *encoderStream) {
+		n := len(s.stack)
+		if n == 0 {
+			panic(errStreamNothingToEnd)
+		}
+		top := s.stack[n-1]
+		if top.dictionary && !top.wantsKey {
+			panic(errStreamWantsValue)
+		}
+
+		s.stack = s.stack[:n-1]
+		if top.dictionary {
+			s.generator.endDictionary()
+		} else {
+			s.generator.endArray()
+		}
+		s.endElement()
+	})
+}
@@ -0,0 +1,222 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalEncode(t *testing.T) {
+	expected := map[string]interface{}{
+		"a": []interface{}{1, "two", map[string]interface{}{"x": true}},
+		"b": map[string]interface{}{},
+		"c": 3.5,
+	}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat, GNUStepFormat} {
+		for _, indent := range []string{"", "\t"} {
+			subtest(t, FormatNames[format], func(t *testing.T) {
+				want, err := MarshalIndent(expected, format, indent)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				buf := &bytes.Buffer{}
+				enc := NewEncoderForFormat(buf, format)
+				enc.Indent(indent)
+				steps := []func() error{
+					enc.BeginDict,
+					func() error { return enc.WriteKey("a") },
+					enc.BeginArray,
+					func() error { return enc.WriteValue(1) },
+					func() error { return enc.WriteValue("two") },
+					func() error { return enc.WriteValue(map[string]interface{}{"x": true}) },
+					enc.End,
+					func() error { return enc.WriteKey("b") },
+					enc.BeginDict,
+					enc.End,
+					func() error { return enc.WriteKey("c") },
+					func() error { return enc.WriteValue(3.5) },
+					enc.End,
+				}
+				for i, step := range steps {
+					if err := step(); err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+				}
+
+				if !bytes.Equal(want, buf.Bytes()) {
+					t.Logf("Expected: %q", want)
+					t.Logf("Received: %q", buf.Bytes())
+					t.Fail()
+				}
+			})
+		}
+	}
+}
+
+func TestIncrementalEncodeErrors(t *testing.T) {
+	sequences := [][]func(enc *Encoder) error{
+		{func(enc *Encoder) error { return enc.End() }},
+		{func(enc *Encoder) error { return enc.WriteKey("a") }},
+		{(*Encoder).BeginArray, func(enc *Encoder) error { return enc.WriteKey("a") }},
+		{(*Encoder).BeginDict, func(enc *Encoder) error { return enc.WriteValue(1) }},
+		{(*Encoder).BeginDict, func(enc *Encoder) error { return enc.WriteKey("a") }, (*Encoder).End},
+		{(*Encoder).BeginDict, func(enc *Encoder) error { return enc.WriteKey("a") }, func(enc *Encoder) error { return enc.WriteKey("b") }},
+		{(*Encoder).BeginArray, func(enc *Encoder) error { return enc.Encode(1) }},
+		{func(enc *Encoder) error { return enc.WriteValue(nil) }},
+	}
+
+	for i, seq := range sequences {
+		enc := NewEncoder(&bytes.Buffer{})
+		var err error
+		for _, step := range seq {
+			if err = step(enc); err != nil {
+				break
+			}
+		}
+		t.Logf("Error: %v", err)
+		if err == nil {
+			t.Errorf("sequence %d: Expected error, received nothing.", i)
+		}
+	}
+}
+
+func TestIncrementalEncodeStreamBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	enc := NewBinaryEncoder(buf)
+	enc.StreamBinary(dir)
+	steps := []func() error{
+		enc.BeginDict,
+		func() error { return enc.WriteKey("z") },
+		enc.BeginArray,
+		func() error { return enc.WriteValue(1) },
+		enc.BeginDict,
+		func() error { return enc.WriteKey("nested") },
+		func() error { return enc.WriteValue([]byte{1, 2, 3}) },
+		enc.End,
+		func() error { return enc.WriteValue(map[string]interface{}{"x": true, "y": []string{"a", "b"}}) },
+		enc.End,
+		func() error { return enc.WriteKey("a") },
+		func() error { return enc.WriteValue("z") },
+		func() error { return enc.WriteKey("empty") },
+		enc.BeginArray,
+		enc.End,
+		enc.End,
+	}
+	for i := 0; i < 20; i++ {
+		i := i
+		steps = append(steps[:len(steps)-1], func() error { return enc.WriteKey(fmt.Sprintf("key%d", i)) }, func() error { return enc.WriteValue(i) }, enc.End)
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	expected := map[string]interface{}{
+		"z": []interface{}{
+			uint64(1),
+			map[string]interface{}{"nested": []byte{1, 2, 3}},
+			map[string]interface{}{"x": true, "y": []interface{}{"a", "b"}},
+		},
+		"a":     "z",
+		"empty": []interface{}{},
+	}
+	for i := 0; i < 20; i++ {
+		expected[fmt.Sprintf("key%d", i)] = uint64(i)
+	}
+
+	var decoded map[string]interface{}
+	if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, decoded)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected temporary files to be removed, found %d", len(files))
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	var first, second bytes.Buffer
+	enc := NewEncoderForFormat(&first, OpenStepFormat)
+	enc.Indent("  ")
+	if err := enc.BeginArray(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unfinished array is abandoned.
+	enc.Reset(&second)
+	if err := enc.Encode([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := second.String(), "(\n  a,\n)"; got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+	if got := first.String(); got != "(" {
+		t.Errorf("first stream holds %q, expected only the abandoned array", got)
+	}
+}
+
+func TestEncoderResetStreamBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc := NewBinaryEncoder(ioutil.Discard)
+	enc.StreamBinary(dir)
+	enc.BeginArray()
+	enc.WriteValue("a")
+	enc.Reset(ioutil.Discard)
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Reset left %d temporary files behind", len(files))
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestIncrementalEncodeStreamBinaryWriteError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc := NewBinaryEncoder(failingWriter{})
+	enc.StreamBinary(dir)
+	enc.BeginArray()
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = enc.WriteValue(strings.Repeat("a", 1024))
+	}
+	if err == nil {
+		err = enc.End()
+	}
+	if err == nil {
+		t.Fatal("expected a write error")
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("a failed stream left %d temporary files behind", len(files))
+	}
+}
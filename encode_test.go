@@ -2,8 +2,14 @@ package plist
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func BenchmarkXMLEncode(b *testing.B) {
@@ -24,6 +30,37 @@ func BenchmarkOpenStepEncode(b *testing.B) {
 	}
 }
 
+func BenchmarkBplistMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Marshal(plistValueTreeRawData, BinaryFormat)
+	}
+}
+
+func TestMarshalPooledBuffers(t *testing.T) {
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat} {
+		first, err := Marshal(map[string]string{"key": "first"}, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := append([]byte(nil), first...)
+
+		// A second property list, encoded with recycled buffers, must not disturb the first.
+		for i := 0; i < 8; i++ {
+			if _, err := Marshal(map[string]string{"another": "second"}, format); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(first, want) {
+			t.Errorf("%s: first result changed to %q, expected %q", FormatNames[format], first, want)
+		}
+		again, _ := Marshal(map[string]string{"key": "first"}, format)
+		if !bytes.Equal(again, want) {
+			t.Errorf("%s: got %q on reuse, expected %q", FormatNames[format], again, want)
+		}
+	}
+}
+
 func TestEncode(t *testing.T) {
 	for _, test := range tests {
 		subtest(t, test.Name, func(t *testing.T) {
@@ -54,6 +91,649 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+func TestEncodeDateLayout(t *testing.T) {
+	date := time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC)
+	expected := map[int]string{
+		XMLFormat:      xmlPreamble + `<plist version="1.0"><date>27 Nov 2013 00:34</date></plist>`,
+		OpenStepFormat: `"27 Nov 2013 00:34"`,
+		GNUStepFormat:  `<*D27 Nov 2013 00:34>`,
+	}
+	for format, doc := range expected {
+		var buf bytes.Buffer
+		enc := NewEncoderForFormat(&buf, format)
+		enc.DateLayout("02 Jan 2006 15:04")
+		if err := enc.Encode(date); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != doc {
+			t.Errorf("%s: Expected %s, received %s", FormatNames[format], doc, buf.String())
+		}
+
+		dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+		dec.AddDateLayouts("02 Jan 2006 15:04")
+		var decoded time.Time
+		if err := dec.Decode(&decoded); err != nil {
+			t.Errorf("%s: %v", FormatNames[format], err)
+		} else if !decoded.Equal(date) {
+			t.Errorf("%s: Expected %v to round-trip, received %v", FormatNames[format], date, decoded)
+		}
+	}
+}
+
+func TestBinaryFeatures(t *testing.T) {
+	encode := func(v interface{}, features int) ([]byte, error) {
+		var buf bytes.Buffer
+		enc := NewBinaryEncoder(&buf)
+		enc.BinaryFeatures(features)
+		err := enc.Encode(v)
+		return buf.Bytes(), err
+	}
+
+	for _, features := range []int{BinaryFeaturesAll, BinaryFeaturesBaseline} {
+		doc, err := encode(float32(1.5), features)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag, expected := doc[8], byte(bpTagReal|0x2)
+		if features == BinaryFeaturesBaseline {
+			expected = bpTagReal | 0x3
+		}
+		if tag != expected {
+			t.Errorf("features %d: Expected real tag %#x, received %#x", features, expected, tag)
+		}
+
+		var f float32
+		if _, err := Unmarshal(doc, &f); err != nil || f != 1.5 {
+			t.Errorf("features %d: Expected 1.5 to round-trip, received %v (%v)", features, f, err)
+		}
+	}
+
+	if _, err := encode(uint64(1<<63), BinaryFeaturesAll); err != nil {
+		t.Error(err)
+	}
+	if _, err := encode(uint64(1<<63), BinaryFeaturesBaseline); err == nil {
+		t.Error("Expected error encoding a 128-bit integer with BinaryFeaturesBaseline, received nothing.")
+	}
+
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	enc.BinaryFeatures(BinaryFeaturesBaseline)
+	enc.BeginArray()
+	if err := enc.WriteValue(uint64(1 << 63)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.End(); err == nil {
+		t.Error("Expected error streaming a 128-bit integer with BinaryFeaturesBaseline, received nothing.")
+	}
+}
+
+func TestSet(t *testing.T) {
+	set := Set{"b", "a", uint64(3)}
+
+	encode := func(features int, deduplicate bool) []byte {
+		var buf bytes.Buffer
+		enc := NewBinaryEncoder(&buf)
+		enc.BinaryFeatures(features)
+		if deduplicate {
+			enc.DeduplicateObjects()
+		}
+		if err := enc.Encode(set); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, deduplicate := range []bool{false, true} {
+		doc := encode(BinaryFeaturesAll, deduplicate)
+		if !bytes.Contains(doc, []byte{bpTagSet | 3}) {
+			t.Errorf("deduplicate %v: Expected a Set to be written as a set", deduplicate)
+		}
+
+		var decoded Set
+		if _, err := Unmarshal(doc, &decoded); err != nil || !reflect.DeepEqual(decoded, set) {
+			t.Errorf("deduplicate %v: Expected %v to round-trip, received %v (%v)", deduplicate, set, decoded, err)
+		}
+	}
+
+	if doc := encode(BinaryFeaturesBaseline, false); bytes.Contains(doc, []byte{bpTagSet | 3}) {
+		t.Error("Expected a Set to be written as an array with BinaryFeaturesBaseline")
+	}
+
+	// A set and an array with the same members are distinct objects, even when objects are deduplicated.
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	enc.DeduplicateObjects()
+	if err := enc.Encode([]interface{}{set, []interface{}(set)}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{bpTagSet | 3}) || !bytes.Contains(buf.Bytes(), []byte{bpTagArray | 3}) {
+		t.Error("Expected a Set and an array with the same members to be written separately")
+	}
+
+	buf.Reset()
+	enc = NewBinaryEncoder(&buf)
+	enc.BeginArray()
+	if err := enc.WriteValue(set); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.End(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{bpTagSet | 3}) {
+		t.Error("Expected a streamed Set to be written as a set")
+	}
+
+	xml, err := Marshal(set, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(xml, []byte("<array><string>b</string><string>a</string><integer>3</integer></array>")) {
+		t.Errorf("Expected a Set to be written as an array in XML, received %s", xml)
+	}
+}
+
+func TestDeduplicateObjects(t *testing.T) {
+	entry := map[string]interface{}{"enabled": true, "tags": []interface{}{"a", "b"}, "owner": UID(3)}
+	value := map[string]interface{}{
+		"entries": []interface{}{entry, entry, entry, entry},
+		"copy":    map[string]interface{}{"enabled": true, "tags": []interface{}{"a", "b"}, "owner": UID(3)},
+	}
+
+	var plain, deduplicated bytes.Buffer
+	if err := NewBinaryEncoder(&plain).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	enc := NewBinaryEncoder(&deduplicated)
+	enc.DeduplicateObjects()
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if deduplicated.Len() >= plain.Len() {
+		t.Errorf("Expected deduplicated document (%d bytes) to be smaller than %d bytes", deduplicated.Len(), plain.Len())
+	}
+
+	// The top-level dictionary, its two keys, the array and one copy of entry (a dictionary, three keys,
+	// a boolean, an array, two strings and a UID) should be all that remains.
+	var trailer bplistTrailer
+	if err := binary.Read(bytes.NewReader(deduplicated.Bytes()[deduplicated.Len()-32:]), binary.BigEndian, &trailer); err != nil {
+		t.Fatal(err)
+	}
+	if trailer.NumObjects != 13 {
+		t.Errorf("Expected 13 objects, received %d", trailer.NumObjects)
+	}
+
+	var decoded interface{}
+	if _, err := Unmarshal(deduplicated.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %#v to round-trip, received %#v", value, decoded)
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	value := map[string]interface{}{"a": []interface{}{uint64(1)}}
+	tests := []struct {
+		Name     string
+		Format   int
+		Options  EncoderOptions
+		Expected string
+	}{
+		{"XML like plutil", XMLFormat, EncoderOptions{Indent: "\t", FlatRoot: true, FinalNewline: true}, xmlPreamble + "<plist version=\"1.0\">\n<dict>\n\t<key>a</key>\n\t<array>\n\t\t<integer>1</integer>\n\t</array>\n</dict>\n</plist>\n"},
+		{"XML with CRLF", XMLFormat, EncoderOptions{Indent: " ", Newline: "\r\n"}, strings.Replace(xmlPreamble, "\n", "\r\n", -1) + "<plist version=\"1.0\">\r\n <dict>\r\n  <key>a</key>\r\n  <array>\r\n   <integer>1</integer>\r\n  </array>\r\n </dict>\r\n</plist>"},
+		{"XML without header", XMLFormat, EncoderOptions{OmitXMLHeader: true}, `<plist version="1.0"><dict><key>a</key><array><integer>1</integer></array></dict></plist>`},
+		{"XML without doctype", XMLFormat, EncoderOptions{OmitXMLDoctype: true, PlistVersion: "1.1"}, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" + `<plist version="1.1"><dict><key>a</key><array><integer>1</integer></array></dict></plist>`},
+		{"XML with custom prolog", XMLFormat, EncoderOptions{XMLDeclaration: `<?xml version="1.0"?>`, XMLDoctype: `<!DOCTYPE plist SYSTEM "file://localhost/System/Library/DTDs/PropertyList.dtd">`}, "<?xml version=\"1.0\"?>\n<!DOCTYPE plist SYSTEM \"file://localhost/System/Library/DTDs/PropertyList.dtd\">\n" + `<plist version="1.0"><dict><key>a</key><array><integer>1</integer></array></dict></plist>`},
+		{"GNUStep with CRLF", GNUStepFormat, EncoderOptions{Indent: "\t", Newline: "\r\n", FinalNewline: true}, "{\r\n\ta = (\r\n\t\t<*I1>,\r\n\t);\r\n}\r\n"},
+		{"GNUStep on one line", GNUStepFormat, EncoderOptions{FinalNewline: true}, "{a=(<*I1>,);}\n"},
+	}
+
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, test.Format)
+			enc.SetOptions(test.Options)
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%q\nReceived:\n%q", test.Expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestEncodeWrappedData(t *testing.T) {
+	value := map[string]interface{}{"data": []byte{0, 1, 2, 3, 4, 5, 6}, "empty": []byte{}}
+	tests := []struct {
+		Name     string
+		Options  EncoderOptions
+		Expected string
+	}{
+		{"plutil", EncoderOptions{Indent: "\t", FlatRoot: true, DataLineWidth: 76}, xmlPreamble + "<plist version=\"1.0\">\n<dict>\n\t<key>data</key>\n\t<data>\n\tAAECAwQFBg==\n\t</data>\n\t<key>empty</key>\n\t<data>\n\t</data>\n</dict>\n</plist>"},
+		{"Narrow", EncoderOptions{Indent: "\t", DataLineWidth: 8, DataIndent: " "}, xmlPreamble + "<plist version=\"1.0\">\n\t<dict>\n\t\t<key>data</key>\n\t\t<data>\n\t\t AAECAwQF\n\t\t Bg==\n\t\t</data>\n\t\t<key>empty</key>\n\t\t<data>\n\t\t</data>\n\t</dict>\n</plist>"},
+		{"Unindented", EncoderOptions{DataLineWidth: 4}, xmlPreamble + "<plist version=\"1.0\"><dict><key>data</key><data>\nAAEC\nAwQF\nBg==\n</data><key>empty</key><data>\n</data></dict></plist>"},
+	}
+
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetOptions(test.Options)
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%q\nReceived:\n%q", test.Expected, buf.String())
+			}
+
+			var decoded map[string]interface{}
+			if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, value) {
+				t.Errorf("Expected %#v to round-trip, received %#v", value, decoded)
+			}
+		})
+	}
+}
+
+func TestEncodeHexData(t *testing.T) {
+	data := []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00}
+
+	tests := []struct {
+		Name     string
+		Options  EncoderOptions
+		Expected string
+	}{
+		{"Default", EncoderOptions{}, "<aabbccdd eeff00>"},
+		{"Pairs", EncoderOptions{HexDataGroup: 2}, "<aabb ccdd eeff 00>"},
+		{"Run", EncoderOptions{HexDataGroup: -1, HexDataUppercase: true}, "<AABBCCDDEEFF00>"},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.Name, func(t *testing.T) {
+			for _, format := range []int{OpenStepFormat, GNUStepFormat} {
+				var buf bytes.Buffer
+				enc := NewEncoderForFormat(&buf, format)
+				enc.SetOptions(test.Options)
+				if err := enc.Encode(data); err != nil {
+					t.Fatal(err)
+				}
+				if buf.String() != test.Expected {
+					t.Errorf("%s: Expected %s, received %s", FormatNames[format], test.Expected, buf.String())
+				}
+
+				// A DataReader is grouped as its data is, however it is read.
+				buf.Reset()
+				if err := enc.Encode(DataReader{bytes.NewReader(data), int64(len(data))}); err != nil || buf.String() != test.Expected {
+					t.Errorf("%s: Expected %s from a DataReader, received %s (%v)", FormatNames[format], test.Expected, buf.String(), err)
+				}
+
+				var decoded []byte
+				if _, err := Unmarshal([]byte(test.Expected), &decoded); err != nil || !bytes.Equal(decoded, data) {
+					t.Errorf("%s: Expected %s to read back, received %x (%v)", FormatNames[format], test.Expected, decoded, err)
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteAllStrings(t *testing.T) {
+	value := &OrderedDict{}
+	value.Set("name", "plain")
+	value.Set("empty", "")
+	value.Set("list", []interface{}{"a", "b c"})
+
+	for _, format := range []int{OpenStepFormat, GNUStepFormat} {
+		for _, strict := range []bool{false, true} {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, format)
+			enc.KeyOrder(KeyOrderDeclaration)
+			enc.SetOptions(EncoderOptions{QuoteAllStrings: true})
+			if strict {
+				enc.StrictGNUStep()
+			}
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := `{"name"="plain";"empty"="";"list"=("a","b c",);}`
+			if strict && format == GNUStepFormat {
+				expected = `{"name"="plain";"empty"="";"list"=("a","b c");}`
+			}
+			if buf.String() != expected {
+				t.Errorf("%s (strict %v): Expected %s, received %s", FormatNames[format], strict, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestTextQuoting(t *testing.T) {
+	value := []string{"Foo.swift", "$(inherited)", "caf\u00e9 \u20ac\U0001F600", `it's "here"`}
+
+	tests := []struct {
+		Name     string
+		Options  EncoderOptions
+		Expected string
+	}{
+		{"Default", EncoderOptions{}, `("Foo.swift","$(inherited)","caf\351 \U20ac\Ud83d\Ude00","it's \"here\"",)`},
+		{"Xcode", EncoderOptions{TextQuoting: &TextQuoting{Unquoted: "_./"}, TextNonASCII: NonASCIIVerbatim}, "(Foo.swift,\"$(inherited)\",\"caf\u00e9 \u20ac\U0001F600\",\"it's \\\"here\\\"\",)"},
+		{"Unicode", EncoderOptions{TextNonASCII: NonASCIIUnicode}, `("Foo.swift","$(inherited)","caf\U00e9 \U20ac\Ud83d\Ude00","it's \"here\"",)`},
+		{"Verbatim", EncoderOptions{TextNonASCII: NonASCIIVerbatim}, "(\"Foo.swift\",\"$(inherited)\",\"caf\u00e9 \u20ac\U0001F600\",\"it's \\\"here\\\"\",)"},
+		{"SingleQuote", EncoderOptions{TextQuoting: &TextQuoting{Quote: '\''}}, `('Foo.swift','$(inherited)','caf\351 \U20ac\Ud83d\Ude00','it\'s "here"',)`},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, OpenStepFormat)
+			enc.SetOptions(test.Options)
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%s\nReceived:\n%s", test.Expected, buf.String())
+			}
+
+			var decoded []string
+			if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded, value) {
+				t.Errorf("Expected %q to read back, received %q (%v)", value, decoded, err)
+			}
+		})
+	}
+}
+
+func TestEscapeNonASCII(t *testing.T) {
+	value := map[string]interface{}{"caf\u00e9": "\U0001F600 <&>"}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetOptions(EncoderOptions{EscapeNonASCII: true})
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := xmlPreamble + `<plist version="1.0"><dict><key>caf&#xe9;</key><string>&#x1f600; &lt;&amp;&gt;</string></dict></plist>`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, buf.String())
+	}
+
+	var decoded map[string]interface{}
+	if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %#v to round-trip, received %#v", value, decoded)
+	}
+}
+
+func TestEncodeNewline(t *testing.T) {
+	value := &OrderedDict{}
+	value.Set("a", []interface{}{"line\none", []byte{1}})
+	value.SetComment("a", "first\nsecond")
+
+	tests := []struct {
+		Name     string
+		Format   int
+		Expected string
+	}{
+		{"XML", XMLFormat, strings.Replace(xmlPreamble, "\n", "\r\n", -1) + "<plist version=\"1.0\">\r\n <dict>\r\n  <!-- first\r\nsecond -->\r\n  <key>a</key>\r\n  <array>\r\n   <string>line&#xA;one</string>\r\n   <data>\r\n   AQ==\r\n   </data>\r\n  </array>\r\n </dict>\r\n</plist>"},
+		{"GNUStep", GNUStepFormat, "{\r\n /* first */\r\n /* second */\r\n a = (\r\n  \"line\none\",\r\n  <01>,\r\n );\r\n}"},
+	}
+
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, test.Format)
+			enc.SetOptions(EncoderOptions{Indent: " ", DataLineWidth: 76})
+			enc.Newline("\r\n")
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%q\nReceived:\n%q", test.Expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestCompactXML(t *testing.T) {
+	value := &OrderedDict{}
+	value.Set("a", []interface{}{"line\none", []byte{1}})
+	value.SetComment("a", "first\nsecond")
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetOptions(EncoderOptions{Indent: " ", DataLineWidth: 76, FinalNewline: true, CompactXML: true})
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	expected := xmlHEADER + xmlDOCTYPE + `<plist version="1.0"><dict><!-- first second --><key>a</key><array><string>line&#xA;one</string><data>AQ==</data></array></dict></plist>`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%q\nReceived:\n%q", expected, buf.String())
+	}
+
+	var decoded interface{}
+	if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"a": []interface{}{"line\none", []byte{1}}}; !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, decoded)
+	}
+}
+
+func TestEncodeFragment(t *testing.T) {
+	value := map[string]interface{}{"a": 1, "b": []string{"x"}}
+
+	tests := []struct {
+		Name     string
+		Format   int
+		Options  EncoderOptions
+		Expected string
+	}{
+		{"XML", XMLFormat, EncoderOptions{Indent: "\t"}, "<dict>\n\t<key>a</key>\n\t<integer>1</integer>\n\t<key>b</key>\n\t<array>\n\t\t<string>x</string>\n\t</array>\n</dict>"},
+		{"XMLFlatRoot", XMLFormat, EncoderOptions{FlatRoot: true, FinalNewline: true}, "<dict><key>a</key><integer>1</integer><key>b</key><array><string>x</string></array></dict>\n"},
+		{"OpenStep", OpenStepFormat, EncoderOptions{}, "{a=1;b=(x,);}"},
+		{"GNUStep", GNUStepFormat, EncoderOptions{}, "{a=<*I1>;b=(x,);}"},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, test.Format)
+			test.Options.Fragment = true
+			enc.SetOptions(test.Options)
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%q\nReceived:\n%q", test.Expected, buf.String())
+			}
+
+			var decoded struct {
+				A int      `plist:"a"`
+				B []string `plist:"b"`
+			}
+			if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.A != 1 || !reflect.DeepEqual(decoded.B, []string{"x"}) {
+				t.Errorf("Expected the fragment to read back, received %+v (%v)", decoded, err)
+			}
+		})
+	}
+}
+
+func TestStrictGNUStep(t *testing.T) {
+	value := &OrderedDict{}
+	value.Set("list", []interface{}{uint64(1), "two", 3.5})
+	value.Set("empty", []interface{}{})
+	value.Set("text", "caf\u00e9 \"\U0001F600\"\n")
+	value.Set("id", "com.example-app")
+	value.Set("uid", UID(7))
+	value.Set("when", time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, GNUStepFormat)
+	enc.StrictGNUStep()
+	enc.KeyOrder(KeyOrderDeclaration)
+	enc.DateLayout(time.RFC3339)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{list=(<*I1>,two,<*R3.5>);empty=();text="caf\U00e9 \"\Ud83d\Ude00\"\n";id="com.example-app";uid={"CF$UID"=<*I7>;};when=<*D2013-11-27 00:34:00 +0000>;}`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, buf.String())
+	}
+
+	var decoded interface{}
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseOrderedDict()
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %#v to round-trip, received %#v", value, decoded)
+	}
+}
+
+func TestUntypedGNUStep(t *testing.T) {
+	type inner struct {
+		N int
+		B bool
+	}
+	type doc struct {
+		N     int
+		R     float64
+		B     bool
+		When  time.Time
+		Typed *inner `plist:",typed,omitempty"`
+		Plain []int  `plist:",untyped"`
+	}
+	value := doc{
+		N:     5,
+		R:     1.5,
+		B:     true,
+		When:  time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC),
+		Typed: &inner{N: 7, B: false},
+		Plain: []int{1, 2},
+	}
+
+	b, err := Marshal(value, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{B=<*BY>;N=<*I5>;Plain=(1,2,);R=<*R1.5>;Typed={B=<*BN>;N=<*I7>;};When=<*D2013-11-27 00:34:00 +0000>;}`
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, b)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, GNUStepFormat)
+	enc.UntypedGNUStep()
+	enc.KeyOrder(KeyOrderDeclaration)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{N=5;R=1.5;B=1;When="2013-11-27 00:34:00 +0000";Typed={N=<*I7>;B=<*BN>;};Plain=(1,2,);}`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nReceived:\n%s", expected, buf.String())
+	}
+
+	// Without typed literals, the property list reads back as an OpenStep property list.
+	value.Typed = nil
+	buf.Reset()
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	var decoded doc
+	if format, err := Unmarshal(buf.Bytes(), &decoded); err != nil || format != OpenStepFormat {
+		t.Fatalf("Expected an OpenStep property list, received %s (%v)", FormatNames[format], err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %#v to round-trip, received %#v", value, decoded)
+	}
+
+	// Other formats are unaffected.
+	b, err = Marshal(value, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("<key>Plain</key><array><integer>1</integer>")) {
+		t.Errorf("Expected integers in XML, received %s", b)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	value := map[string]interface{}{
+		"b": 1, "B": 2, "a": 3, "A": 4, "c": 5, "C": 6,
+		"when": time.Date(2013, 11, 27, 1, 34, 0, 0, time.FixedZone("CET", 3600)),
+	}
+	foldLess := func(a, b string) bool { return strings.ToLower(a) < strings.ToLower(b) }
+
+	expected := `{A=<*I4>;a=<*I3>;B=<*I2>;b=<*I1>;C=<*I6>;c=<*I5>;when=<*D2013-11-27 00:34:00 +0000>;}`
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		enc := NewEncoderForFormat(&buf, GNUStepFormat)
+		enc.KeyLess(foldLess)
+		enc.PreserveTimeZones()
+		enc.Reproducible()
+		if err := enc.Encode(value); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Fatalf("Expected:\n%s\nReceived:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	type Info struct {
+		Version string
+		Name    string
+		Extra   map[string]string `plist:",inline"`
+	}
+	ordered := &OrderedDict{}
+	ordered.Set("b", "1")
+	ordered.Set("a", "2")
+	value := map[string]interface{}{
+		"info":    Info{"v1", "App", map[string]string{"Z": "z", "B": "b"}},
+		"ordered": ordered,
+	}
+	byLength := func(a, b string) bool { return len(a) < len(b) }
+
+	tests := []struct {
+		Name     string
+		Order    int
+		Less     func(a, b string) bool
+		Expected string
+	}{
+		{"Default", KeyOrderDefault, nil, `{info={B=b;Name=App;Version=v1;Z=z;};ordered={b=1;a=2;};}`},
+		{"Sorted", KeyOrderSorted, nil, `{info={B=b;Name=App;Version=v1;Z=z;};ordered={a=2;b=1;};}`},
+		{"Declaration", KeyOrderDeclaration, nil, `{info={Version=v1;Name=App;B=b;Z=z;};ordered={b=1;a=2;};}`},
+		{"Comparator", KeyOrderDefault, byLength, `{info={B=b;Z=z;Name=App;Version=v1;};ordered={b=1;a=2;};}`},
+		{"Sorted with comparator", KeyOrderSorted, byLength, `{info={B=b;Z=z;Name=App;Version=v1;};ordered={b=1;a=2;};}`},
+	}
+
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, OpenStepFormat)
+			enc.KeyOrder(test.Order)
+			enc.KeyLess(test.Less)
+			if err := enc.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected %s, received %s", test.Expected, buf.String())
+			}
+		})
+	}
+}
+
 func ExampleEncoder_Encode() {
 	type sparseBundleHeader struct {
 		InfoDictionaryVersion string `plist:"CFBundleInfoDictionaryVersion"`
@@ -153,3 +833,82 @@ func ExampleMarshal_gnustep() {
 	// 	size = <*I4398046511104>;
 	// }
 }
+
+func TestEncodeContext(t *testing.T) {
+	v := make([]map[string]int, 100)
+	for i := range v {
+		v[i] = map[string]int{"index": i}
+	}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat, GNUStepFormat} {
+		format := format
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewEncoderForFormat(&buf, format).EncodeContext(context.Background(), v); err != nil {
+				t.Fatal(err)
+			}
+			want, _ := Marshal(v, format)
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("EncodeContext wrote %q, expected %q", buf.Bytes(), want)
+			}
+
+			// Cancel while marshaling and while writing the objects.
+			for _, n := range []int{0, 10, 250} {
+				err := NewEncoderForFormat(&bytes.Buffer{}, format).EncodeContext(&doneAfter{context.Background(), n}, v)
+				if err != context.Canceled {
+					t.Errorf("cancelled after %d checks: got error %v, expected %v", n, err, context.Canceled)
+				}
+			}
+		})
+	}
+}
+
+func TestNonFinite(t *testing.T) {
+	v := map[string]interface{}{
+		"Values": []float64{math.Inf(1), math.Inf(-1), math.NaN(), 1.5},
+		"Single": float32(math.NaN()),
+	}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		format := format
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, format)
+			if err := enc.Encode(v); err != nil {
+				t.Fatalf("Expected NaN and infinities to be encoded by default, received %v", err)
+			}
+			var decoded struct{ Values []float64 }
+			if _, err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if d := decoded.Values; len(d) != 4 || !math.IsInf(d[0], 1) || !math.IsInf(d[1], -1) || !math.IsNaN(d[2]) {
+				t.Errorf("Expected NaN and infinities to round-trip, received %v", d)
+			}
+
+			buf.Reset()
+			enc.NonFinite(NonFiniteError, 0)
+			if err := enc.Encode(v); err == nil {
+				t.Error("Expected error encoding NaN with NonFiniteError, received nothing.")
+			}
+			if err := enc.Encode(1.5); err != nil {
+				t.Errorf("Expected finite reals to be encoded with NonFiniteError, received %v", err)
+			}
+
+			buf.Reset()
+			enc.NonFinite(NonFiniteReplace, -1)
+			if err := enc.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			var replaced struct {
+				Values []float64
+				Single float32
+			}
+			if _, err := Unmarshal(buf.Bytes(), &replaced); err != nil {
+				t.Fatal(err)
+			}
+			if expected := []float64{-1, -1, -1, 1.5}; !reflect.DeepEqual(replaced.Values, expected) || replaced.Single != -1 {
+				t.Errorf("Expected %v and -1, received %v and %v", expected, replaced.Values, replaced.Single)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package plist
+
+import (
+	"bytes"
+	"os"
+)
+
+// A File is a property list file opened with OpenFile.
+type File struct {
+	data   []byte
+	mapped bool
+}
+
+// OpenFile opens the property list file at path. Where the operating system supports it, the file is memory-mapped
+// rather than read into memory: binary property lists are decoded directly from the mapping, so that only the
+// values being decoded are copied into memory, and the file is never read in full unless it must be.
+// XML and text property lists are parsed from the mapping as they would be from any other stream.
+//
+// Values decoded from a File never refer to the mapping, and remain valid once it has been closed.
+func OpenFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, mapped, err := mapFile(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &File{data: data, mapped: mapped}, nil
+}
+
+// Decoder returns a Decoder that reads from the file.
+func (f *File) Decoder() *Decoder {
+	d := NewDecoder(bytes.NewReader(f.data))
+	d.mapping = f.data
+	d.detached = true
+	return d
+}
+
+// Decode decodes the property list in the file into the value pointed to by v, as Unmarshal would,
+// and returns its format.
+func (f *File) Decode(v interface{}) (format int, err error) {
+	d := f.Decoder()
+	err = d.Decode(v)
+	return d.Format, err
+}
+
+// LazyPlist returns a LazyPlist that reads the binary property list in the file.
+// It must not be used once the file has been closed.
+func (f *File) LazyPlist() (*LazyPlist, error) {
+	lp, err := NewLazyPlist(bytes.NewReader(f.data))
+	if err != nil {
+		return nil, err
+	}
+	lp.parser.detached = true
+	return lp, nil
+}
+
+// Close releases the file's mapping.
+func (f *File) Close() error {
+	data, mapped := f.data, f.mapped
+	f.data, f.mapped = nil, false
+	if mapped {
+		return unmapFile(data)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package plist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	value := map[string]interface{}{"name": "value", "data": []byte{1, 2, 3}, "list": []interface{}{"a", uint64(1)}}
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(value, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, FormatNames[format]+".plist")
+			if err := ioutil.WriteFile(path, doc, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded map[string]interface{}
+			decodedFormat, err := f.Decode(&decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The decoded values must remain valid once the mapping is gone.
+			if decodedFormat != format {
+				t.Errorf("Expected format %s, received %s", FormatNames[format], FormatNames[decodedFormat])
+			}
+			if !reflect.DeepEqual(decoded, value) {
+				t.Errorf("Expected %#v, received %#v", value, decoded)
+			}
+		})
+	}
+
+	if _, err := OpenFile(filepath.Join(dir, "missing.plist")); err == nil {
+		t.Error("Expected an error opening a missing file")
+	}
+}
+
+func TestOpenFileLazyPlist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	doc, err := Marshal(map[string]interface{}{"a": map[string]string{"b": "c"}}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "lazy.plist")
+	if err := ioutil.WriteFile(path, doc, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := f.LazyPlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := lp.Decode(&s, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if s != "c" {
+		t.Errorf("Expected c, received %q", s)
+	}
+}
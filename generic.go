@@ -0,0 +1,18 @@
+//go:build go1.18
+// +build go1.18
+
+package plist
+
+// Decode parses the property list document in data and returns its value as a T.
+// It works like Unmarshal, but does not require the caller to provide a destination.
+func Decode[T any](data []byte) (T, error) {
+	var v T
+	_, err := Unmarshal(data, &v)
+	return v, err
+}
+
+// Encode returns the property list encoding of v in the specified format.
+// It works like Marshal, but accepts only values of type T.
+func Encode[T any](v T, format int) ([]byte, error) {
+	return Marshal(v, format)
+}
@@ -0,0 +1,33 @@
+//go:build go1.18
+// +build go1.18
+
+package plist
+
+import (
+	"testing"
+)
+
+func TestGenericDecodeEncode(t *testing.T) {
+	type config struct {
+		Name  string
+		Count int
+	}
+
+	expected := config{Name: "hello", Count: 3}
+	doc, err := Encode(expected, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := Decode[config](doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != expected {
+		t.Errorf("Expected %#v, received %#v", expected, val)
+	}
+
+	if _, err := Decode[int](doc); err == nil {
+		t.Error("Expected error decoding a dictionary into an int, received nothing.")
+	}
+}
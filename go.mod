@@ -6,5 +6,6 @@ require (
 	github.com/hashicorp/go-multierror v1.1.1
 	// for cmd/ply
 	github.com/jessevdk/go-flags v1.4.0
+	// for cmd/ply and plistyaml
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -2,6 +2,8 @@ package plist
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"testing"
 )
 
@@ -494,3 +496,58 @@ func TestInvalidBinaryPlists(t *testing.T) {
 		}
 	}
 }
+
+// craftBplist assembles a binary property list from an object table and the one-byte offsets of its objects.
+func craftBplist(objects []byte, offsets []byte, numObjects, topObject uint64) []byte {
+	doc := append([]byte("bplist00"), objects...)
+	tableOffset := uint64(len(doc))
+	doc = append(doc, offsets...)
+	trailer := make([]byte, 32)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], numObjects)
+	binary.BigEndian.PutUint64(trailer[16:], topObject)
+	binary.BigEndian.PutUint64(trailer[24:], tableOffset)
+	return append(doc, trailer...)
+}
+
+func TestBinaryFormatErrors(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Data   []byte
+		Defect BinaryDefect
+		Object int64
+		Offset uint64
+	}{
+		{"Too Short", []byte("bplist00\x08"), DefectHeader, -1, 0x0},
+		{"Huge Object Count", craftBplist([]byte{0x08}, []byte{0x08}, 1<<63, 0), DefectTrailer, -1, 0xA},
+		{"Top Object Out Of Range", craftBplist([]byte{0x08}, []byte{0x08}, 1, 1), DefectReference, -1, 0xA},
+		{"Offset In Header", craftBplist([]byte{0x08}, []byte{0x02}, 1, 0), DefectOffset, 0, 0x9},
+		{"Offset In Offset Table", craftBplist([]byte{0x08}, []byte{0x09}, 1, 0), DefectOffset, 0, 0x9},
+		{"Reference Out Of Range", craftBplist([]byte{0xA1, 0x05}, []byte{0x08}, 1, 0), DefectReference, 0, 0x9},
+		{"Self-Referential Array", craftBplist([]byte{0xA1, 0x00}, []byte{0x08}, 1, 0), DefectCycle, 0, 0x8},
+		{"Huge Array", craftBplist([]byte{0xAF, 0x13, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, []byte{0x08}, 1, 0), DefectLength, 0, 0x12},
+		{"Shared Offset", craftBplist([]byte{0xA2, 0x01, 0x02, 0x51, 'a'}, []byte{0x08, 0x0B, 0x0B}, 3, 0), DefectOverlap, 2, 0xB},
+		{"Overlapping Objects", craftBplist([]byte{0xA2, 0x01, 0x02, 0x52, 0x51, 'b'}, []byte{0x08, 0x0B, 0x0C}, 3, 0), DefectOverlap, 2, 0xC},
+		{"Non-String Key", craftBplist([]byte{0xD1, 0x01, 0x01, 0x08}, []byte{0x08, 0x0B}, 2, 0), DefectObject, 0, 0x8},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.Name, func(t *testing.T) {
+			var v interface{}
+			_, err := Unmarshal(test.Data, &v)
+			if err == nil {
+				t.Fatalf("expected an error, decoded %#v", v)
+			}
+
+			var bferr *BinaryFormatError
+			if !errors.As(err, &bferr) {
+				t.Fatalf("expected a *BinaryFormatError in %v", err)
+			}
+			if bferr.Defect != test.Defect || bferr.Object != test.Object || bferr.Offset != test.Offset {
+				t.Errorf("expected a %v defect in object %d at 0x%x, got %v in object %d at 0x%x (%v)",
+					test.Defect, test.Object, test.Offset, bferr.Defect, bferr.Object, bferr.Offset, err)
+			}
+		})
+	}
+}
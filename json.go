@@ -0,0 +1,243 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToJSON reads a property list in any format from r and writes it to w as JSON.
+//
+// Dictionary keys are written in the order they appear in the property list. Values are converted as follows:
+//
+//	strings, booleans       JSON strings and booleans
+//	integers, reals         JSON numbers (reals that are infinite or NaN cannot be converted)
+//	data                    JSON strings holding the standard base64 encoding of the data
+//	dates                   JSON strings holding the date in RFC 3339 format, in UTC
+//	UIDs                    JSON objects of the form {"CF$UID": <integer>}
+//
+// As JSON has no data or date types, data and dates cannot be recovered by FromJSON.
+func ToJSON(r io.Reader, w io.Writer) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	pval, err := NewDecoder(bytes.NewReader(buf)).parse()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeJSONValue(bw, pval); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+var jsonRawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// marshalJSONRawMessage converts the JSON document held by msg to a property list value, as FromJSON does.
+// A nil RawMessage, like a JSON null, is omitted.
+func (p *Encoder) marshalJSONRawMessage(msg json.RawMessage) cfValue {
+	if msg == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.UseNumber()
+	pval, err := readJSONValue(dec)
+	if err != nil {
+		panic(err)
+	}
+	return pval
+}
+
+// unmarshalJSONRawMessage stores pval in val, a json.RawMessage, converted to JSON as ToJSON does.
+func (p *Decoder) unmarshalJSONRawMessage(pval cfValue, val reflect.Value) error {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeJSONValue(w, pval); err != nil {
+		return err
+	}
+	w.Flush()
+	val.SetBytes(buf.Bytes())
+	return nil
+}
+
+func writeJSONString(w *bufio.Writer, s string) {
+	b, _ := json.Marshal(s)
+	w.Write(b)
+}
+
+func writeJSONValue(w *bufio.Writer, pval cfValue) error {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		w.WriteByte('{')
+		for i, k := range pval.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeJSONString(w, k)
+			w.WriteByte(':')
+			if err := writeJSONValue(w, pval.values[i]); err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+		}
+		w.WriteByte('}')
+	case *cfArray:
+		w.WriteByte('[')
+		for i, v := range pval.values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSONValue(w, v); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		w.WriteByte(']')
+	case cfString:
+		writeJSONString(w, string(pval))
+	case *cfNumber:
+		w.WriteString(pval.String())
+	case *cfReal:
+		if math.IsInf(pval.value, 0) || math.IsNaN(pval.value) {
+			return fmt.Errorf("plist: cannot convert %v to JSON", pval.value)
+		}
+		bits := 64
+		if !pval.wide {
+			bits = 32
+		}
+		w.WriteString(strconv.FormatFloat(pval.value, 'g', -1, bits))
+	case cfBoolean:
+		w.WriteString(strconv.FormatBool(bool(pval)))
+	case cfData:
+		writeJSONString(w, base64.StdEncoding.EncodeToString([]byte(pval)))
+	case *cfDataReader:
+		return writeJSONValue(w, pval.readAll())
+	case cfDate:
+		writeJSONString(w, time.Time(pval).In(time.UTC).Format(time.RFC3339))
+	case cfUID:
+		return writeJSONValue(w, pval.toDict())
+	}
+	return nil
+}
+
+// FromJSON reads a JSON document from r and writes it to w as a property list in the specified format.
+//
+// Dictionary keys are written in the order they appear in the JSON document. JSON numbers without a fraction
+// or exponent become integers, and all other numbers become reals. JSON objects of the form {"CF$UID": <integer>}
+// become UIDs. JSON nulls are discarded, as property lists bear no representation for them; a document
+// consisting only of null cannot be converted.
+func FromJSON(r io.Reader, w io.Writer, format int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	pval, err := readJSONValue(dec)
+	if err != nil {
+		return err
+	}
+	if pval == nil {
+		return errors.New("plist: no root element to encode")
+	}
+
+	newGeneratorForFormat(w, format).generateDocument(pval)
+	return nil
+}
+
+func readJSONValue(dec *json.Decoder) (cfValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			dict := &cfDictionary{ordered: true}
+			for dec.More() {
+				keytok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := keytok.(string)
+
+				v, err := readJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					dict.keys = append(dict.keys, key)
+					dict.values = append(dict.values, v)
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return dict.maybeUID(false), nil
+		case '[':
+			a := &cfArray{}
+			for dec.More() {
+				v, err := readJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					a.values = append(a.values, v)
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return a, nil
+		}
+	case string:
+		return cfString(tok), nil
+	case bool:
+		return cfBoolean(tok), nil
+	case json.Number:
+		s := string(tok)
+		if !strings.ContainsAny(s, ".eE") {
+			if s[0] == '-' {
+				if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+					return &cfNumber{signed: true, value: uint64(n)}, nil
+				}
+			} else if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return &cfNumber{signed: false, value: n}, nil
+			}
+			if n, ok := new(big.Int).SetString(s, 10); ok {
+				if pval := newIntegerValue(n); pval != nil {
+					return pval, nil
+				}
+			}
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &cfReal{wide: true, value: f, text: decimalText(s)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	doc := xmlPreamble + `<plist version="1.0"><dict>
+<key>z</key><string>a "quoted" string</string>
+<key>a</key><array><integer>-1</integer><integer>18446744073709551615</integer><real>1.5</real><true/></array>
+<key>data</key><data>AQID</data>
+<key>date</key><date>2013-11-27T00:34:00Z</date>
+<key>uid</key><dict><key>CF$UID</key><integer>3</integer></dict>
+</dict></plist>`
+	expected := `{"z":"a \"quoted\" string","a":[-1,18446744073709551615,1.5,true],"data":"AQID","date":"2013-11-27T00:34:00Z","uid":{"CF$UID":3}}`
+
+	buf := &bytes.Buffer{}
+	if err := ToJSON(strings.NewReader(doc), buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Logf("Expected: %s", expected)
+		t.Logf("Received: %s", buf.String())
+		t.Fail()
+	}
+
+	if err := ToJSON(strings.NewReader(`<real>nan</real>`), &bytes.Buffer{}); err == nil {
+		t.Error("Expected error converting NaN to JSON, received nothing.")
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	input := `{"z": "x", "a": [-1, 18446744073709551615, 1.5, 2e3, true, null], "n": null, "uid": {"CF$UID": 3}}`
+	expected := `{z=x;a=(<*I-1>,<*I18446744073709551615>,<*R1.5>,<*R2000>,<*BY>,);uid={CF$UID=<*I3>;};}`
+
+	buf := &bytes.Buffer{}
+	if err := FromJSON(strings.NewReader(input), buf, GNUStepFormat); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Logf("Expected: %s", expected)
+		t.Logf("Received: %s", buf.String())
+		t.Fail()
+	}
+
+	var val map[string]interface{}
+	if _, err := Unmarshal(buf.Bytes(), &val); err != nil {
+		t.Fatal(err)
+	}
+	if val["uid"] != UID(3) {
+		t.Errorf("Expected CF$UID object to become a UID, received %#v", val["uid"])
+	}
+
+	for _, invalid := range []string{`null`, `{"a":`, `[1,]`} {
+		if err := FromJSON(strings.NewReader(invalid), &bytes.Buffer{}, XMLFormat); err == nil {
+			t.Errorf("%s: Expected error, received nothing.", invalid)
+		}
+	}
+}
+
+func TestJSONRawMessage(t *testing.T) {
+	type S struct {
+		Name    string
+		Payload json.RawMessage
+	}
+
+	doc := `{Name = x; Payload = {b = (<*I1>, <*R1.5>, <*BY>); a = "s";};}`
+	var s S
+	if _, err := Unmarshal([]byte(doc), &s); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"b":[1,1.5,true],"a":"s"}`; string(s.Payload) != expected {
+		t.Errorf("Expected %s, received %s", expected, s.Payload)
+	}
+
+	out, err := Marshal(s, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{Name=x;Payload={b=(<*I1>,<*R1.5>,<*BY>,);a=s;};}`; string(out) != expected {
+		t.Errorf("Expected %s, received %s", expected, out)
+	}
+
+	if _, err := Marshal(S{Payload: json.RawMessage(`{"a":`)}, XMLFormat); err == nil {
+		t.Error("Expected error marshaling invalid JSON, received nothing.")
+	}
+	if _, err := Unmarshal([]byte(`{Payload = <*Rnan>;}`), &s); err == nil {
+		t.Error("Expected error unmarshaling NaN into a json.RawMessage, received nothing.")
+	}
+}
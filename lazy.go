@@ -0,0 +1,172 @@
+package plist
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+)
+
+// LazyPlist provides random access to the objects in a binary property list, without decoding it in full.
+// Only the document's header and trailer are validated when it is opened; each object is decoded when it is
+// first reached, and retained for later use. This makes extracting a few values from a large property list
+// far cheaper than decoding all of it.
+//
+// Objects are located by a path of dictionary keys (strings) and array indices (ints), beginning at the
+// root object. An empty path refers to the root object itself.
+//
+// A LazyPlist is not safe for concurrent use.
+type LazyPlist struct {
+	parser  *bplistParser
+	decoder *Decoder
+}
+
+// NewLazyPlist reads the binary property list in r. It returns an error if r does not hold a binary property list.
+func NewLazyPlist(r io.ReadSeeker) (lp *LazyPlist, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = plistParseError{"binary", r.(error)}
+		}
+	}()
+
+	p := newBplistParser(r)
+	p.maxDepth = DefaultMaxDepth
+	p.parseHeaderAndTrailer()
+	return &LazyPlist{parser: p, decoder: &Decoder{Format: BinaryFormat}}, nil
+}
+
+// find returns the index of the object at path, and the offset at which it is stored.
+func (l *LazyPlist) find(path []interface{}) (uint64, offset) {
+	p := l.parser
+	oid := p.trailer.TopObject
+	for i, elem := range path {
+		off := p.offsetForObject(oid)
+		tag := p.byteAt(off) & 0xF0
+
+		switch elem := elem.(type) {
+		case string:
+			if tag != bpTagDictionary {
+				panic(fmt.Errorf("plist: %v is not a dictionary", path[:i]))
+			}
+			cnt, start := l.list(off)
+			found := false
+			for j := uint64(0); j < cnt; j++ {
+				ref, _ := p.parseObjectRefAtOffset(l.refOffset(start, j))
+				if key, ok := p.objectAtIndex(ref).(cfString); ok && string(key) == elem {
+					oid, _ = p.parseObjectRefAtOffset(l.refOffset(start, cnt+j))
+					found = true
+					break
+				}
+			}
+			if !found {
+				panic(fmt.Errorf("plist: %v not found", path[:i+1]))
+			}
+		case int:
+			if tag != bpTagArray && tag != bpTagOrderedSet && tag != bpTagSet {
+				panic(fmt.Errorf("plist: %v is not an array", path[:i]))
+			}
+			cnt, start := l.list(off)
+			if elem < 0 || uint64(elem) >= cnt {
+				panic(fmt.Errorf("plist: %v out of range (array has %d elements)", path[:i+1], cnt))
+			}
+			oid, _ = p.parseObjectRefAtOffset(l.refOffset(start, uint64(elem)))
+		default:
+			panic(fmt.Errorf("plist: invalid path element %#v (must be a string or an int)", elem))
+		}
+	}
+	return oid, p.offsetForObject(oid)
+}
+
+// list returns the number of entries in the dictionary or array at off, and the offset of its object references,
+// having checked that the references end before the offset table, so that none of them is out of bounds.
+func (l *LazyPlist) list(off offset) (cnt uint64, start offset) {
+	p := l.parser
+	cnt, start = p.countForTagAtOffset(off)
+	refs := cnt
+	if p.byteAt(off)&0xF0 == bpTagDictionary {
+		refs = 2 * cnt
+	}
+	if refs < cnt || !p.contentsFit(start, refs, uint64(p.trailer.ObjectRefSize)) {
+		p.defect(DefectLength, start, -1, "list@0x%x length (%v) puts its end beyond the offset table at 0x%x", start, cnt, p.trailer.OffsetTableOffset)
+	}
+	return cnt, start
+}
+
+// refOffset returns the offset of the i'th object reference of a list whose references begin at start, which
+// list has checked.
+func (l *LazyPlist) refOffset(start offset, i uint64) offset {
+	return start + offset(i*uint64(l.parser.trailer.ObjectRefSize))
+}
+
+// recoverLazyError converts a panic raised while reading the property list into an error.
+func recoverLazyError(err *error) {
+	if r := recover(); r != nil {
+		if _, ok := r.(runtime.Error); ok {
+			panic(r)
+		}
+		*err = r.(error)
+	}
+}
+
+// Decode stores the object at path in the value pointed to by v, as Unmarshal would.
+// Only the object at path, and the objects it contains, are decoded.
+func (l *LazyPlist) Decode(v interface{}, path ...interface{}) (err error) {
+	defer recoverLazyError(&err)
+
+	oid, _ := l.find(path)
+	return l.decoder.unmarshal(l.parser.objectAtIndex(oid), reflect.ValueOf(v))
+}
+
+// Has reports whether an object exists at path.
+func (l *LazyPlist) Has(path ...interface{}) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isRuntime := r.(runtime.Error); isRuntime {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+
+	l.find(path)
+	return true
+}
+
+// Len returns the number of entries in the dictionary or array at path, without decoding them.
+func (l *LazyPlist) Len(path ...interface{}) (n int, err error) {
+	defer recoverLazyError(&err)
+
+	_, off := l.find(path)
+	switch l.parser.byteAt(off) & 0xF0 {
+	case bpTagDictionary, bpTagArray, bpTagOrderedSet, bpTagSet:
+		cnt, _ := l.list(off)
+		return int(cnt), nil
+	}
+	return 0, fmt.Errorf("plist: %v is neither a dictionary nor an array", path)
+}
+
+// Keys returns the keys of the dictionary at path, in the order in which they are stored, without decoding its values.
+func (l *LazyPlist) Keys(path ...interface{}) (keys []string, err error) {
+	defer recoverLazyError(&err)
+
+	p := l.parser
+	_, off := l.find(path)
+	if p.byteAt(off)&0xF0 != bpTagDictionary {
+		return nil, fmt.Errorf("plist: %v is not a dictionary", path)
+	}
+
+	cnt, start := l.list(off)
+	keys = make([]string, cnt)
+	for i := range keys {
+		ref, _ := p.parseObjectRefAtOffset(l.refOffset(start, uint64(i)))
+		key, ok := p.objectAtIndex(ref).(cfString)
+		if !ok {
+			return nil, fmt.Errorf("dictionary@0x%x contains non-string key at index %d", off, i)
+		}
+		keys[i] = string(key)
+	}
+	return keys, nil
+}
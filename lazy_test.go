@@ -0,0 +1,87 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLazyPlist(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count int
+	}
+	doc, err := Marshal(map[string]interface{}{
+		"items":   []Item{{"a", 1}, {"b", 2}},
+		"version": "1.0",
+		"nested":  map[string]interface{}{"deep": map[string]interface{}{"flag": true}},
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lp, err := NewLazyPlist(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var version string
+	if err := lp.Decode(&version, "version"); err != nil || version != "1.0" {
+		t.Errorf("Expected version 1.0, received %q (%v)", version, err)
+	}
+
+	var item Item
+	if err := lp.Decode(&item, "items", 1); err != nil || item != (Item{"b", 2}) {
+		t.Errorf("Expected item b, received %#v (%v)", item, err)
+	}
+
+	var flag bool
+	if err := lp.Decode(&flag, "nested", "deep", "flag"); err != nil || !flag {
+		t.Errorf("Expected flag to be true, received %v (%v)", flag, err)
+	}
+
+	if n, err := lp.Len("items"); err != nil || n != 2 {
+		t.Errorf("Expected 2 items, received %d (%v)", n, err)
+	}
+
+	keys, err := lp.Keys()
+	if expected := []string{"items", "nested", "version"}; err != nil || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, received %v (%v)", expected, keys, err)
+	}
+
+	if !lp.Has("nested", "deep") || lp.Has("nested", "shallow") || lp.Has("items", 2) {
+		t.Error("Has reported the wrong objects as present")
+	}
+
+	for _, path := range [][]interface{}{{"missing"}, {"items", -1}, {"version", "x"}, {"items", "x"}, {1.5}} {
+		var v interface{}
+		if err := lp.Decode(&v, path...); err == nil {
+			t.Errorf("Expected an error decoding %v, received %#v", path, v)
+		}
+	}
+	if _, err := lp.Len("version"); err == nil {
+		t.Error("Expected an error taking the length of a string")
+	}
+
+	if _, err := NewLazyPlist(bytes.NewReader([]byte(`<plist><string/></plist>`))); err == nil {
+		t.Error("Expected an error opening an XML property list")
+	}
+
+	// A dictionary whose count runs far past the end of the document.
+	huge := []byte("bplist00\xDF\x13\x20\x00\x00\x00\x00\x00\x00\x00\x08")
+	huge = append(huge, 0, 0, 0, 0, 0, 0, 1, 1)
+	huge = append(huge, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 18)
+	lp, err = NewLazyPlist(bytes.NewReader(huge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lp.Keys(); err == nil {
+		t.Error("Expected an error listing the keys of a dictionary that overruns the document")
+	}
+	if _, err := lp.Len(); err == nil {
+		t.Error("Expected an error taking the length of a dictionary that overruns the document")
+	}
+	if lp.Has("a") {
+		t.Error("Expected no key in a dictionary that overruns the document")
+	}
+}
@@ -2,14 +2,21 @@ package plist
 
 import (
 	"encoding"
+	"encoding/json"
+	"math/big"
 	"reflect"
+	"sort"
+	"strconv"
 	"time"
 )
 
 var (
 	plistMarshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	uidMarshalerType   = reflect.TypeOf((*UIDMarshaler)(nil)).Elem()
 	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
 	timeType           = reflect.TypeOf((*time.Time)(nil)).Elem()
+	setType            = reflect.TypeOf(Set(nil))
 )
 
 func implementsInterface(val reflect.Value, interfaceType reflect.Type) (interface{}, bool) {
@@ -48,9 +55,21 @@ func (p *Encoder) marshalTextInterface(marshalable encoding.TextMarshaler) cfVal
 	return cfString(s)
 }
 
+// marshalBinaryInterface marshals a BinaryMarshaler to plist data.
+func (p *Encoder) marshalBinaryInterface(marshalable encoding.BinaryMarshaler) cfValue {
+	b, err := marshalable.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return cfData(b)
+}
+
 // marshalStruct marshals a reflected struct value to a plist dictionary
 func (p *Encoder) marshalStruct(typ reflect.Type, val reflect.Value) cfValue {
-	tinfo, _ := getTypeInfo(typ)
+	tinfo, err := getTypeInfo(typ, p.jsonTags)
+	if err != nil {
+		panic(err)
+	}
 
 	dict := &cfDictionary{
 		keys:   make([]string, 0, len(tinfo.fields)),
@@ -61,16 +80,147 @@ func (p *Encoder) marshalStruct(typ reflect.Type, val reflect.Value) cfValue {
 		if !value.IsValid() {
 			continue
 		}
-		dict.keys = append(dict.keys, finfo.name)
-		dict.values = append(dict.values, p.marshal(value))
+		untyped := p.untyped
+		if finfo.typed || finfo.untyped {
+			p.untyped = finfo.untyped
+		}
+		var pval cfValue
+		if finfo.durationFormat != durationNanoseconds {
+			pval = p.marshalDuration(value, finfo.durationFormat)
+		} else if finfo.asDict {
+			pval = p.marshalKeyValues(value)
+		} else {
+			pval = p.marshalValue(value, !finfo.noText)
+		}
+		pval = p.untypedScalar(pval)
+		p.untyped = untyped
+		if pval == nil {
+			// Nil pointers and interfaces have no representation.
+			continue
+		}
+		if finfo.asString {
+			pval = quoteScalar(pval)
+		}
+		dict.keys = append(dict.keys, finfo.key(p.fieldNames))
+		dict.values = append(dict.values, pval)
 	}
 
+	if tinfo.inlineMap != nil {
+		if m := tinfo.inlineMap.value(val); m.IsValid() && !m.IsNil() {
+			fields := make(map[string]bool, len(dict.keys))
+			for _, k := range dict.keys {
+				fields[k] = true
+			}
+			// Struct fields take precedence over inline map entries with the same key.
+			mapKeys := m.MapKeys()
+			sort.Slice(mapKeys, func(i, j int) bool { return mapKeys[i].String() < mapKeys[j].String() })
+			for _, k := range mapKeys {
+				if fields[k.String()] {
+					continue
+				}
+				if subpval := p.marshal(m.MapIndex(k)); subpval != nil {
+					dict.keys = append(dict.keys, k.String())
+					dict.values = append(dict.values, subpval)
+				}
+			}
+		}
+	}
+
+	p.orderKeys(dict, p.keyOrder == KeyOrderDeclaration)
+	return dict
+}
+
+// marshalKeyValues marshals val, a slice or array of structs with Key and Value fields, as a dictionary holding
+// an entry for each element, in order; see the dict flag.
+func (p *Encoder) marshalKeyValues(val reflect.Value) cfValue {
+	keyIdx, valueIdx, _ := keyValueFields(val.Type().Elem())
+	dict := &cfDictionary{
+		keys:   make([]string, 0, val.Len()),
+		values: make([]cfValue, 0, val.Len()),
+	}
+	for i, n := 0, val.Len(); i < n; i++ {
+		entry := val.Index(i)
+		if subpval := p.marshal(entry.Field(valueIdx)); subpval != nil {
+			dict.keys = append(dict.keys, entry.Field(keyIdx).String())
+			dict.values = append(dict.values, subpval)
+		}
+	}
+	p.orderKeys(dict, p.keyOrder != KeyOrderSorted)
 	return dict
 }
 
+// quoteScalar converts numbers and booleans to strings, for fields tagged ,string.
+func quoteScalar(pval cfValue) cfValue {
+	switch pval := pval.(type) {
+	case *cfNumber:
+		return cfString(pval.String())
+	case *cfReal:
+		bits := 64
+		if !pval.wide {
+			bits = 32
+		}
+		return cfString(strconv.FormatFloat(pval.value, 'g', -1, bits))
+	case cfBoolean:
+		return cfString(strconv.FormatBool(bool(pval)))
+	}
+	return pval
+}
+
+// untypedScalar converts numbers, booleans and dates to strings, written as they would be into an OpenStep
+// property list, if they are to be written into a GNUStep property list without typed literals.
+func (p *Encoder) untypedScalar(pval cfValue) cfValue {
+	if !p.untyped || p.format != GNUStepFormat {
+		return pval
+	}
+
+	switch pval := pval.(type) {
+	case *cfNumber:
+		if p.preserveNumbers && pval.text != "" {
+			return cfString(pval.text)
+		}
+		return cfString(pval.String())
+	case *cfReal:
+		// The NonFinite policy applies before the real becomes a string.
+		pval = p.finiteReals(pval).(*cfReal)
+		if p.preserveNumbers && pval.text != "" {
+			return cfString(pval.text)
+		}
+		return cfString(strconv.FormatFloat(pval.value, 'g', -1, 64))
+	case cfBoolean:
+		if pval {
+			return cfString("1")
+		}
+		return cfString("0")
+	case cfDate:
+		layout := p.dateLayout
+		if layout == "" {
+			layout = textPlistTimeLayout
+		}
+		return cfString(time.Time(pval).Format(layout))
+	}
+	return pval
+}
+
+// orderKeys arranges the keys of dict according to the Encoder's key order. If inOrder is set,
+// dict's keys are already in the order they should be written.
+func (p *Encoder) orderKeys(dict *cfDictionary, inOrder bool) {
+	if inOrder {
+		dict.ordered = true
+	} else if p.keyLess != nil {
+		if p.reproducible {
+			// sortFunc is stable, so this breaks the ties that keyLess leaves.
+			sort.Sort(dict)
+		}
+		dict.sortFunc(p.keyLess)
+	}
+}
+
 func (p *Encoder) marshalTime(val reflect.Value) cfValue {
-	time := val.Interface().(time.Time)
-	return cfDate(time)
+	t := val.Interface().(time.Time)
+	if !p.preserveTimeZones || p.reproducible {
+		t = t.In(time.UTC)
+	}
+	return cfDate(t)
 }
 
 func innermostValue(val reflect.Value) reflect.Value {
@@ -81,14 +231,46 @@ func innermostValue(val reflect.Value) reflect.Value {
 }
 
 func (p *Encoder) marshal(val reflect.Value) cfValue {
+	return p.untypedScalar(p.marshalValue(val, true))
+}
+
+// marshalValue marshals val, using its MarshalText method, if it has one, only if text is set.
+func (p *Encoder) marshalValue(val reflect.Value, text bool) cfValue {
 	if !val.IsValid() {
 		return nil
 	}
 
+	checkContext(p.ctx)
+
+	if receiver, can := implementsInterface(val, uidMarshalerType); can {
+		if uid, ok := receiver.(UIDMarshaler).MarshalPlistUID(); ok {
+			return cfUID(uid)
+		}
+	}
 	if receiver, can := implementsInterface(val, plistMarshalerType); can {
 		return p.marshalPlistInterface(receiver.(Marshaler))
 	}
 
+	if ival := innermostValue(val); ival.IsValid() {
+		switch ival.Type() {
+		case rawValueType:
+			return p.marshalRawValue(ival.Interface().(RawValue))
+		case orderedDictType:
+			d := ival.Interface().(OrderedDict)
+			return p.marshalOrderedDict(&d)
+		case dataReaderType:
+			return marshalDataReader(ival.Interface().(DataReader))
+		case jsonRawMessageType:
+			return p.marshalJSONRawMessage(ival.Interface().(json.RawMessage))
+		case bigIntType:
+			n := ival.Interface().(big.Int)
+			return marshalBigInt(&n)
+		case bigFloatType:
+			f := ival.Interface().(big.Float)
+			return marshalBigFloat(&f)
+		}
+	}
+
 	// time.Time implements TextMarshaler, but we need to store it in RFC3339
 	if val.Type() == timeType {
 		return p.marshalTime(val)
@@ -101,9 +283,12 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 	}
 
 	// Check for text marshaler.
-	if receiver, can := implementsInterface(val, textMarshalerType); can {
+	if receiver, can := implementsInterface(val, textMarshalerType); can && text {
 		return p.marshalTextInterface(receiver.(encoding.TextMarshaler))
 	}
+	if receiver, can := implementsInterface(val, binMarshalerType); can {
+		return p.marshalBinaryInterface(receiver.(encoding.BinaryMarshaler))
+	}
 
 	// Descend into pointers or interfaces
 	val = innermostValue(val)
@@ -118,6 +303,9 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 	if typ == uidType {
 		return cfUID(val.Uint())
 	}
+	if typ == numberType {
+		return marshalNumber(Number(val.String()))
+	}
 
 	if val.Kind() == reflect.Struct {
 		return p.marshalStruct(typ, val)
@@ -154,7 +342,7 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 					values[i] = subpval
 				}
 			}
-			return &cfArray{values}
+			return &cfArray{values: values, set: typ == setType}
 		}
 	case reflect.Map:
 		if typ.Key().Kind() != reflect.String {
@@ -172,6 +360,7 @@ func (p *Encoder) marshal(val reflect.Value) cfValue {
 				dict.values = append(dict.values, subpval)
 			}
 		}
+		p.orderKeys(dict, false)
 		return dict
 	default:
 		panic(&unknownTypeError{typ})
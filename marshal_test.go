@@ -1,6 +1,9 @@
 package plist
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -58,6 +61,19 @@ func TestInvalidMarshal(t *testing.T) {
 		{"Nil", nil},
 		{"Map with integer keys", map[int]string{1: "hi"}},
 		{"Channel", make(chan int)},
+		{"Inline string", struct {
+			S string `plist:",inline"`
+		}{}},
+		{"Inline map with integer keys", struct {
+			M map[int]string `plist:",inline"`
+		}{}},
+		{"Duration flag on an integer", struct {
+			N int `plist:",seconds"`
+		}{}},
+		{"Two inline maps", struct {
+			A map[string]string `plist:",inline"`
+			B map[string]string `plist:",inline"`
+		}{}},
 	}
 
 	for _, v := range tests {
@@ -88,6 +104,140 @@ func TestInterfaceMarshal(t *testing.T) {
 	}
 }
 
+// archiveRef refers to an object in a keyed archive, or to nothing, if it is empty.
+type archiveRef string
+
+func (r archiveRef) MarshalPlistUID() (UID, bool) {
+	return UID(len(r)), r != ""
+}
+
+func (r archiveRef) MarshalPlist() (interface{}, error) {
+	return "$null", nil
+}
+
+func TestUIDMarshaler(t *testing.T) {
+	v := map[string]interface{}{
+		"root":  archiveRef("ab"),
+		"empty": archiveRef(""),
+		"list":  []archiveRef{"a", "abc"},
+	}
+
+	doc, err := Marshal(v, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if _, err := Unmarshal(doc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"root":  UID(2),
+		"empty": "$null",
+		"list":  []interface{}{UID(1), UID(3)},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, decoded)
+	}
+}
+
+// binaryPoint marshals itself only as binary, and textPoint as both text and binary.
+type binaryPoint struct{ X, Y byte }
+
+func (p binaryPoint) MarshalBinary() ([]byte, error) {
+	return []byte{p.X, p.Y}, nil
+}
+
+func (p *binaryPoint) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return errors.New("binaryPoint: invalid length")
+	}
+	p.X, p.Y = b[0], b[1]
+	return nil
+}
+
+type textPoint struct{ binaryPoint }
+
+func (p textPoint) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *textPoint) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func TestBinaryMarshaler(t *testing.T) {
+	type S struct {
+		Binary binaryPoint
+		Text   textPoint
+	}
+	v := S{binaryPoint{1, 2}, textPoint{binaryPoint{3, 4}}}
+
+	for _, format := range []int{BinaryFormat, XMLFormat, GNUStepFormat} {
+		doc, err := Marshal(v, format)
+		if err != nil {
+			t.Fatalf("%s: %v", FormatNames[format], err)
+		}
+
+		var generic map[string]interface{}
+		if _, err := Unmarshal(doc, &generic); err != nil {
+			t.Fatalf("%s: %v", FormatNames[format], err)
+		}
+		if expected := map[string]interface{}{"Binary": []byte{1, 2}, "Text": "3,4"}; !reflect.DeepEqual(generic, expected) {
+			t.Errorf("%s: Expected %#v, received %#v", FormatNames[format], expected, generic)
+		}
+
+		var decoded S
+		if _, err := Unmarshal(doc, &decoded); err != nil || decoded != v {
+			t.Errorf("%s: Expected %+v, received %+v (%v)", FormatNames[format], v, decoded, err)
+		}
+	}
+
+	var p binaryPoint
+	if _, err := Unmarshal([]byte(`<string>1,2</string>`), &p); err == nil {
+		t.Error("Expected error unmarshaling a string into a BinaryUnmarshaler, received nothing.")
+	}
+}
+
+// celsius marshals itself as text with a unit, which the notext flag bypasses.
+type celsius float64
+
+func (c celsius) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%g°C", float64(c))), nil
+}
+
+func (c *celsius) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%g°C", (*float64)(c))
+	return err
+}
+
+func TestNoTextFlag(t *testing.T) {
+	type S struct {
+		Text   celsius
+		NoText celsius   `plist:",notext"`
+		List   []celsius `plist:",notext"`
+	}
+	v := S{21.5, 21.5, []celsius{1}}
+
+	doc, err := Marshal(v, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generic map[string]interface{}
+	if _, err := Unmarshal(doc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"Text": "21.5°C", "NoText": 21.5, "List": []interface{}{"1°C"}}
+	if !reflect.DeepEqual(generic, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, generic)
+	}
+
+	var decoded S
+	if _, err := Unmarshal(doc, &decoded); err != nil || !reflect.DeepEqual(decoded, v) {
+		t.Errorf("Expected %+v, received %+v (%v)", v, decoded, err)
+	}
+}
+
 func TestInterfaceFieldMarshal(t *testing.T) {
 	type X struct {
 		C interface{} // C's type does not implement Marshaler
@@ -156,3 +306,275 @@ func TestInterfaceGeneralSliceMarshal(t *testing.T) {
 		t.Error("expect non-zero data")
 	}
 }
+
+func BenchmarkStructMarshalParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		e := &Encoder{}
+		for pb.Next() {
+			e.marshal(reflect.ValueOf(plistValueTreeRawData))
+		}
+	})
+}
+
+func TestPrepareType(t *testing.T) {
+	type Leaf struct {
+		Value string
+	}
+	type Branch struct {
+		Leaves map[string][]*Leaf
+	}
+	type Root struct {
+		Branches [2]Branch
+		Self     *Root
+	}
+
+	if err := PrepareType(&Root{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []interface{}{Root{}, Branch{}, Leaf{}} {
+		for _, jsonTags := range []bool{false, true} {
+			if _, ok := tinfoMap.Load(typeInfoKey{reflect.TypeOf(v), jsonTags}); !ok {
+				t.Errorf("%T was not cached (jsonTags %v)", v, jsonTags)
+			}
+		}
+	}
+
+	type Invalid struct {
+		Extra map[int]string `plist:",inline"`
+	}
+	if err := PrepareType([]Invalid{}); err == nil {
+		t.Error("expected an error for an invalid inline map")
+	}
+}
+
+func TestMapFieldNames(t *testing.T) {
+	type payload struct {
+		Identifier string
+		Version    int
+		Type       string `plist:"PayloadType"`
+		Extra      string `plist:",omitempty"`
+	}
+	value := payload{Identifier: "com.example", Version: 1, Type: "com.apple.wifi"}
+	prefix := func(field string) string { return "Payload" + field }
+
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, OpenStepFormat)
+	enc.MapFieldNames(prefix)
+	enc.KeyOrder(KeyOrderDeclaration)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{PayloadIdentifier="com.example";PayloadVersion=1;PayloadType="com.apple.wifi";}`
+	if buf.String() != expected {
+		t.Errorf("Expected %s, received %s", expected, buf.String())
+	}
+
+	var decoded payload
+	dec := NewDecoder(bytes.NewReader([]byte(`{PayloadIdentifier=com.example;payloadversion=1;PayloadType=com.apple.wifi;}`)))
+	dec.MapFieldNames(prefix)
+	dec.CaseInsensitiveFields()
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != value {
+		t.Errorf("Expected %+v, received %+v", value, decoded)
+	}
+
+	// Errors name the keys that were read.
+	dec = NewDecoder(bytes.NewReader([]byte(`{PayloadVersion=<*BY>;}`)))
+	dec.MapFieldNames(prefix)
+	var pathErr *PathError
+	if err := dec.Decode(&decoded); !errors.As(err, &pathErr) || pathErr.Path != "PayloadVersion" {
+		t.Errorf("Expected an error at PayloadVersion, received %v", err)
+	}
+}
+
+func TestUseJSONTags(t *testing.T) {
+	type model struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name,omitempty"`
+		Secret   string `json:"-"`
+		Count    int64  `json:"count,string"`
+		Override string `json:"json_name" plist:"PlistName"`
+		Plain    bool
+	}
+	value := model{ID: 7, Secret: "s", Count: 3, Override: "o", Plain: true}
+
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, GNUStepFormat)
+	enc.UseJSONTags()
+	enc.KeyOrder(KeyOrderDeclaration)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{id=<*I7>;count=3;PlistName=o;Plain=<*BY>;}`
+	if buf.String() != expected {
+		t.Errorf("Expected %s, received %s", expected, buf.String())
+	}
+
+	var decoded model
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseJSONTags()
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	value.Secret = ""
+	if decoded != value {
+		t.Errorf("Expected %+v, received %+v", value, decoded)
+	}
+
+	// Without UseJSONTags, json tags are ignored.
+	b, err := Marshal(value, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{Count=<*I3>;ID=<*I7>;Name="";Plain=<*BY>;PlistName=o;Secret="";}`; string(b) != expected {
+		t.Errorf("Expected %s, received %s", expected, b)
+	}
+
+	// Options that only plist tags have are not taken from json tags.
+	type inner struct{ A string }
+	type options struct {
+		Inner inner  `json:"inner,inline"`
+		Name  string `json:"name,required,default=x"`
+	}
+	var opts options
+	dec = NewDecoder(bytes.NewReader([]byte(`{inner={A=a;};}`)))
+	dec.UseJSONTags()
+	if err := dec.Decode(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts != (options{Inner: inner{"a"}}) {
+		t.Errorf("Expected the json options to be ignored, received %+v", opts)
+	}
+
+	// As in encoding/json, "-," names a field "-".
+	type dash struct {
+		Dash string `json:"-,"`
+		Skip string `json:"-"`
+	}
+	buf.Reset()
+	enc = NewEncoderForFormat(&buf, OpenStepFormat)
+	enc.UseJSONTags()
+	if err := enc.Encode(dash{Dash: "d", Skip: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"-"=d;}`; buf.String() != expected {
+		t.Errorf("Expected %s, received %s", expected, buf.String())
+	}
+}
+
+func TestRemainField(t *testing.T) {
+	type profile struct {
+		Name   string
+		Extras map[string]RawValue `plist:",remain"`
+	}
+	doc := `{Name=wifi;VendorInfo={Level=<*I3>;};VendorList=(a,b,);}`
+
+	var decoded profile
+	if _, err := Unmarshal([]byte(doc), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "wifi" || len(decoded.Extras) != 2 {
+		t.Fatalf("Expected two extra keys, received %+v", decoded)
+	}
+
+	b, err := Marshal(decoded, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != doc {
+		t.Errorf("Expected %s, received %s", doc, b)
+	}
+
+	type invalid struct {
+		Extras struct{ A string } `plist:",remain"`
+	}
+	if _, err := Marshal(invalid{}, XMLFormat); err == nil {
+		t.Error("Expected error marshaling a ,remain field that is not a map, received nothing.")
+	}
+}
+
+func TestDictField(t *testing.T) {
+	type entry struct {
+		Key   string
+		Value int
+	}
+	type section struct {
+		Entries []entry `plist:",dict"`
+		List    []entry
+	}
+	value := section{
+		Entries: []entry{{"zebra", 1}, {"apple", 2}, {"mango", 3}},
+		List:    []entry{{"a", 1}},
+	}
+	expected := `{Entries={zebra=<*I1>;apple=<*I2>;mango=<*I3>;};List=({Key=a;Value=<*I1>;},);}`
+
+	b, err := Marshal(value, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected %s, received %s", expected, b)
+	}
+
+	var decoded section
+	if _, err := Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %+v, received %+v", value, decoded)
+	}
+
+	// KeyOrderSorted sorts these dictionaries, as it does every other.
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, GNUStepFormat)
+	enc.KeyOrder(KeyOrderSorted)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{Entries={apple=<*I2>;mango=<*I3>;zebra=<*I1>;};List=({Key=a;Value=<*I1>;},);}`; buf.String() != expected {
+		t.Errorf("Expected %s, received %s", expected, buf.String())
+	}
+
+	invalid := []interface{}{
+		&struct {
+			Entries []string `plist:",dict"`
+		}{},
+		&struct {
+			Entries []struct{ Key, Value int } `plist:",dict"`
+		}{},
+		&struct {
+			Entries map[string]int `plist:",dict"`
+		}{},
+	}
+	for _, v := range invalid {
+		if _, err := Marshal(v, XMLFormat); err == nil {
+			t.Errorf("Expected error marshaling %T, received nothing.", v)
+		}
+	}
+}
+
+func TestMarshalNilFields(t *testing.T) {
+	type inner struct{ A string }
+	value := struct {
+		Ptr   *inner
+		Iface interface{}
+		Name  string
+		Extra map[string]interface{} `plist:",inline"`
+	}{Name: "n", Extra: map[string]interface{}{"nil": nil}}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, GNUStepFormat} {
+		b, err := Marshal(value, format)
+		if err != nil {
+			t.Fatalf("%s: %v", FormatNames[format], err)
+		}
+		var decoded map[string]interface{}
+		if _, err := Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("%s: %v", FormatNames[format], err)
+		}
+		if !reflect.DeepEqual(decoded, map[string]interface{}{"Name": "n"}) {
+			t.Errorf("%s: Expected nil fields to be discarded, received %#v", FormatNames[format], decoded)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package plist
+
+// A MergeStrategy determines how Merge combines the values in two property lists. It is made of one of
+// MergeDeep and MergeOverlayWins, which apply to dictionaries, and one of MergeArrayReplace and MergeArrayAppend,
+// which apply to arrays, combined with |. The zero MergeStrategy is MergeDeep|MergeArrayReplace.
+type MergeStrategy int
+
+const (
+	// MergeDeep merges dictionaries that appear in both property lists key by key, at any depth.
+	MergeDeep MergeStrategy = 0
+
+	// MergeArrayReplace replaces an array in base with the array in overlay.
+	MergeArrayReplace MergeStrategy = 0
+
+	// MergeOverlayWins merges only the root dictionaries of the property lists: each of their values in overlay
+	// replaces the value in base outright, even where both are dictionaries.
+	MergeOverlayWins MergeStrategy = 1 << iota
+
+	// MergeArrayAppend appends an array in overlay to the array in base.
+	MergeArrayAppend
+)
+
+// Merge combines base and overlay, which hold property lists decoded into empty interfaces (see Get), according
+// to strategy. Where a key appears in only one of two dictionaries being merged, its value is kept; where it appears
+// in both, or a value in base is not a dictionary or array of the same kind as the value in overlay, overlay's value
+// is used. The result contains new dictionaries and arrays wherever they have been merged, and shares all other
+// values with base and overlay, neither of which is modified.
+//
+// The keys of a merged dictionary are ordered as in base, followed by the keys added from overlay. A merged
+// dictionary is a *OrderedDict if the dictionary in base is an OrderedDict, and a map[string]interface{} otherwise.
+func Merge(base, overlay interface{}, strategy MergeStrategy) interface{} {
+	return mergeValues(base, overlay, strategy, 0)
+}
+
+func mergeValues(base, overlay interface{}, strategy MergeStrategy, depth int) interface{} {
+	if bk, ok := diffKeys(base); ok {
+		if isDictionary(overlay) && (depth == 0 || strategy&MergeOverlayWins == 0) {
+			return mergeDictionaries(base, bk, overlay, strategy, depth)
+		}
+	}
+
+	if ba, ok := base.([]interface{}); ok && strategy&MergeArrayAppend != 0 {
+		if oa, ok := overlay.([]interface{}); ok {
+			merged := make([]interface{}, 0, len(ba)+len(oa))
+			return append(append(merged, ba...), oa...)
+		}
+	}
+	return overlay
+}
+
+func mergeDictionaries(base interface{}, keys []string, overlay interface{}, strategy MergeStrategy, depth int) interface{} {
+	overlayKeys, _ := diffKeys(overlay)
+	merged := func(k string) interface{} {
+		bv, inBase := queryChild(base, k)
+		ov, inOverlay := queryChild(overlay, k)
+		switch {
+		case !inOverlay:
+			return bv
+		case !inBase:
+			return ov
+		}
+		return mergeValues(bv, ov, strategy, depth+1)
+	}
+
+	if b, ok := base.(map[string]interface{}); ok {
+		out := make(map[string]interface{}, len(b)+len(overlayKeys))
+		for _, k := range keys {
+			out[k] = merged(k)
+		}
+		for _, k := range overlayKeys {
+			if _, ok := b[k]; !ok {
+				out[k] = merged(k)
+			}
+		}
+		return out
+	}
+
+	out := &OrderedDict{
+		Keys:   make([]string, 0, len(keys)+len(overlayKeys)),
+		Values: make([]interface{}, 0, len(keys)+len(overlayKeys)),
+	}
+	for _, k := range keys {
+		out.Set(k, merged(k))
+	}
+	for _, k := range overlayKeys {
+		if _, ok := out.Get(k); !ok {
+			out.Set(k, merged(k))
+		}
+	}
+	for _, d := range []interface{}{base, overlay} {
+		var comments map[string]string
+		switch d := d.(type) {
+		case *OrderedDict:
+			comments = d.Comments
+		case OrderedDict:
+			comments = d.Comments
+		}
+		for k, c := range comments {
+			out.SetComment(k, c)
+		}
+	}
+	return out
+}
+
+// isDictionary reports whether v is a dictionary decoded into an empty interface.
+func isDictionary(v interface{}) bool {
+	_, ok := diffKeys(v)
+	return ok
+}
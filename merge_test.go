@@ -0,0 +1,90 @@
+package plist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := map[string]interface{}{
+		"Name":    "defaults",
+		"Servers": []interface{}{"a", "b"},
+		"Logging": map[string]interface{}{"Level": "info", "File": "/var/log/app.log"},
+	}
+	overlay := map[string]interface{}{
+		"Servers": []interface{}{"c"},
+		"Logging": map[string]interface{}{"Level": "debug"},
+		"Debug":   true,
+	}
+
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		expected interface{}
+	}{
+		{"Deep", MergeDeep, map[string]interface{}{
+			"Name":    "defaults",
+			"Servers": []interface{}{"c"},
+			"Logging": map[string]interface{}{"Level": "debug", "File": "/var/log/app.log"},
+			"Debug":   true,
+		}},
+		{"DeepArrayAppend", MergeDeep | MergeArrayAppend, map[string]interface{}{
+			"Name":    "defaults",
+			"Servers": []interface{}{"a", "b", "c"},
+			"Logging": map[string]interface{}{"Level": "debug", "File": "/var/log/app.log"},
+			"Debug":   true,
+		}},
+		{"OverlayWins", MergeOverlayWins, map[string]interface{}{
+			"Name":    "defaults",
+			"Servers": []interface{}{"c"},
+			"Logging": map[string]interface{}{"Level": "debug"},
+			"Debug":   true,
+		}},
+		{"OverlayWinsArrayAppend", MergeOverlayWins | MergeArrayAppend, map[string]interface{}{
+			"Name":    "defaults",
+			"Servers": []interface{}{"a", "b", "c"},
+			"Logging": map[string]interface{}{"Level": "debug"},
+			"Debug":   true,
+		}},
+	}
+
+	for _, test := range tests {
+		test := test
+		subtest(t, test.name, func(t *testing.T) {
+			merged := Merge(base, overlay, test.strategy)
+			if !reflect.DeepEqual(merged, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, merged)
+			}
+		})
+	}
+
+	if len(base["Servers"].([]interface{})) != 2 || len(base["Logging"].(map[string]interface{})) != 2 {
+		t.Errorf("base was modified: %#v", base)
+	}
+}
+
+func TestMergeOrderedDict(t *testing.T) {
+	base := &OrderedDict{
+		Keys:     []string{"B", "A"},
+		Values:   []interface{}{"b", &OrderedDict{Keys: []string{"X"}, Values: []interface{}{"x"}}},
+		Comments: map[string]string{"B": "from base"},
+	}
+	overlay := map[string]interface{}{"C": "c", "A": map[string]interface{}{"Y": "y"}, "B": []interface{}{"replaced"}}
+
+	expected := &OrderedDict{
+		Keys: []string{"B", "A", "C"},
+		Values: []interface{}{
+			[]interface{}{"replaced"},
+			&OrderedDict{Keys: []string{"X", "Y"}, Values: []interface{}{"x", "y"}},
+			"c",
+		},
+		Comments: map[string]string{"B": "from base"},
+	}
+	if merged := Merge(base, overlay, MergeDeep); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %#v, got %#v", expected, merged)
+	}
+
+	if merged := Merge(base, "scalar", MergeDeep); merged != "scalar" {
+		t.Errorf("expected overlay to replace base, got %#v", merged)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package plist
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mapFile reads f into memory, as memory-mapping is not supported on this platform.
+func mapFile(f *os.File, size int64) ([]byte, bool, error) {
+	data, err := ioutil.ReadAll(f)
+	return data, false, err
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package plist
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f into memory, reporting whether it did so.
+func mapFile(f *os.File, size int64) ([]byte, bool, error) {
+	if size == 0 {
+		// Empty files cannot be mapped.
+		return []byte{}, false, nil
+	}
+	if int64(int(size)) != size {
+		return nil, false, errors.New("plist: file is too large to map")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,141 @@
+package plist
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// A Number is an integer or real, as written in a property list. It is stored in an empty interface in place of an
+// int64, uint64, float32 or float64 when decoding with Decoder.UseNumber, and may be unmarshaled into directly.
+//
+// A real read from an XML, OpenStep, GNUStep or JSON property list keeps the text with which it was written, if
+// that text is a plain decimal number, so that no digits are lost; every other number is written in decimal, in
+// the shortest form that reads back exactly. A Decoder with PreserveNumberText keeps the text of every number read
+// from a GNUStep property list instead, which may be a hexadecimal integer such as 0x1F.
+//
+// A Number is marshaled as an integer if its text is a decimal or 0x-prefixed hexadecimal integer that fits in 64
+// bits, and as a real otherwise. The empty Number is marshaled as 0.
+type Number string
+
+var numberType = reflect.TypeOf(Number(""))
+
+// String returns the literal text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	s, base := integerBase(string(n))
+	return strconv.ParseInt(s, base, 64)
+}
+
+// Uint64 returns the number as a uint64.
+func (n Number) Uint64() (uint64, error) {
+	s, base := integerBase(string(n))
+	return strconv.ParseUint(s, base, 64)
+}
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// integerBase returns s, a decimal or 0x-prefixed hexadecimal integer with an optional minus sign, without its
+// prefix, and its base.
+func integerBase(s string) (string, int) {
+	if len(s) > 0 && s[0] == '-' {
+		digits, base := unsignedGetBase(s[1:])
+		return "-" + digits, base
+	}
+	return unsignedGetBase(s)
+}
+
+// numberValue returns the Number that holds the integer or real pval. If literal is set, it holds the text with
+// which pval was read, if any.
+func numberValue(pval cfValue, literal bool) Number {
+	switch pval := pval.(type) {
+	case *cfNumber:
+		if literal && pval.text != "" {
+			return Number(pval.text)
+		}
+		return Number(pval.String())
+	case *cfReal:
+		if literal && pval.text != "" {
+			return Number(pval.text)
+		}
+		if text := decimalText(pval.text); text != "" {
+			return Number(text)
+		}
+		bits := 64
+		if !pval.wide {
+			bits = 32
+		}
+		return Number(strconv.FormatFloat(pval.value, 'g', -1, bits))
+	}
+	return ""
+}
+
+// marshalNumber marshals n as an integer or a real, which keeps n's text. It panics if n is not a number.
+func marshalNumber(n Number) cfValue {
+	s := string(n)
+	if s == "" {
+		return &cfNumber{signed: false, value: 0}
+	}
+	if u, err := n.Uint64(); err == nil {
+		return &cfNumber{signed: false, value: u, text: s}
+	}
+	if i, err := n.Int64(); err == nil {
+		return &cfNumber{signed: true, value: uint64(i), text: s}
+	}
+	// Decimal numbers beyond the range of a float64 are stored as infinities.
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !isDecimalNumber(s) {
+		panic(fmt.Errorf("plist: invalid Number %q", s))
+	}
+	return &cfReal{wide: true, value: f, text: s}
+}
+
+// decimalText returns s, if it is a plain decimal number, and "" otherwise.
+func decimalText(s string) string {
+	if isDecimalNumber(s) {
+		return s
+	}
+	return ""
+}
+
+// isDecimalNumber reports whether s is a decimal number: an optional minus sign, digits with an optional fraction,
+// and an optional exponent.
+func isDecimalNumber(s string) bool {
+	i := 0
+	digits := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	n := digits()
+	if i < len(s) && s[i] == '.' {
+		i++
+		n += digits()
+	}
+	if n == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
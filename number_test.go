@@ -0,0 +1,139 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUseNumber(t *testing.T) {
+	docs := map[string]string{
+		"XML": xmlPreamble + `<plist version="1.0"><array>
+			<integer>18446744073709551615</integer>
+			<integer>-42</integer>
+			<integer>0x10</integer>
+			<real>0.10000000000000000555</real>
+			<real>2e3</real>
+			<real>nan</real>
+		</array></plist>`,
+		"GNUStep": `(<*I18446744073709551615>, <*I-42>, <*I16>, <*R0.10000000000000000555>, <*R2e3>, <*Rnan>)`,
+	}
+	expected := []interface{}{
+		Number("18446744073709551615"),
+		Number("-42"),
+		Number("16"),
+		Number("0.10000000000000000555"),
+		Number("2e3"),
+		Number("NaN"),
+	}
+
+	for name, doc := range docs {
+		dec := NewDecoder(bytes.NewReader([]byte(doc)))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("%s: Expected %#v, received %#v", name, expected, v)
+		}
+	}
+
+	var v interface{}
+	doc, _ := Marshal([]interface{}{uint64(7), float32(1.1), 1.1}, BinaryFormat)
+	dec := NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{Number("7"), Number("1.1"), Number("1.1")}; !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, v)
+	}
+
+	// A Number may be unmarshaled into directly, with or without UseNumber.
+	var s struct {
+		Big  Number
+		Real Number
+	}
+	if _, err := Unmarshal([]byte(`{Big = <*I18446744073709551615>; Real = <*R3.14159265358979323846>;}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Big != "18446744073709551615" || s.Real != "3.14159265358979323846" {
+		t.Errorf("Unexpected numbers %#v", s)
+	}
+	if u, err := s.Big.Uint64(); err != nil || u != 18446744073709551615 {
+		t.Errorf("Expected Uint64 to return the maximum uint64, received %v (%v)", u, err)
+	}
+	if _, err := s.Big.Int64(); err == nil {
+		t.Error("Expected Int64 to fail for a number beyond the range of an int64, received nothing.")
+	}
+	if f, err := s.Real.Float64(); err != nil || f != 3.141592653589793 {
+		t.Errorf("Expected Float64 to return pi, received %v (%v)", f, err)
+	}
+}
+
+func TestMarshalNumber(t *testing.T) {
+	v := []Number{"18446744073709551615", "-42", "1.5", "1e400", ""}
+	doc, err := Marshal(v, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "(<*I18446744073709551615>,<*I-42>,<*R1.5>,<*R+Inf>,<*I0>,)"; string(doc) != expected {
+		t.Errorf("Expected %s, received %s", expected, doc)
+	}
+
+	if _, err := Marshal(Number("twelve"), XMLFormat); err == nil {
+		t.Error("Expected error marshaling an invalid Number, received nothing.")
+	}
+}
+
+func TestIsDecimalNumber(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"0": true, "-12": true, "1.5": true, ".5": true, "5.": true, "1e10": true, "-1.5E-3": true,
+		"": false, "-": false, ".": false, "+1": false, "1e": false, "0x10": false, "inf": false, "NaN": false, "1 ": false,
+	} {
+		if isDecimalNumber(s) != expected {
+			t.Errorf("%q: Expected %v", s, expected)
+		}
+	}
+}
+
+func TestPreserveNumberText(t *testing.T) {
+	doc := `{A = <*I0x1F>; B = <*I007>; C = <*R1.50>; D = <*I-0x10>; E = <*R+Inf>; F = "1.50";}`
+
+	dec := NewDecoder(bytes.NewReader([]byte(doc)))
+	dec.PreserveNumberText()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"A": Number("0x1F"), "B": Number("007"), "C": Number("1.50"), "D": Number("-0x10"), "E": Number("+Inf"), "F": "1.50",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Expected %#v, received %#v", expected, v)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoderForFormat(&buf, GNUStepFormat)
+	enc.PreserveNumberText()
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{A=<*I0x1F>;B=<*I007>;C=<*R1.50>;D=<*I-0x10>;E=<*R+Inf>;F=1.50;}`; buf.String() != expected {
+		t.Errorf("Expected %s, received %s", expected, buf.String())
+	}
+
+	// Without PreserveNumberText, the numbers are written as their values.
+	out, err := Marshal(v, GNUStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{A=<*I31>;B=<*I7>;C=<*R1.5>;D=<*I-16>;E=<*R+Inf>;F=1.50;}`; string(out) != expected {
+		t.Errorf("Expected %s, received %s", expected, out)
+	}
+
+	if i, err := Number("-0x10").Int64(); err != nil || i != -16 {
+		t.Errorf("Expected -16, received %v (%v)", i, err)
+	}
+}
@@ -0,0 +1,154 @@
+package plist
+
+import (
+	"reflect"
+	"strings"
+)
+
+// OrderedDict is a property list dictionary that retains the order of its keys.
+//
+// Unlike a map, an OrderedDict is marshaled with its keys in the order they appear in Keys,
+// and unmarshaling into an OrderedDict records keys in the order they appear in the input.
+// When unmarshaled into, dictionaries nested within an OrderedDict are stored as *OrderedDict,
+// and all other values are stored as they would be in an empty interface.
+//
+// Comments holds the comment that precedes each entry, by key. It is populated when decoding
+// with Decoder.PreserveComments, and written out by the XML, OpenStep and GNUStep encoders.
+type OrderedDict struct {
+	Keys     []string
+	Values   []interface{}
+	Comments map[string]string
+}
+
+// Len returns the number of entries in d.
+func (d *OrderedDict) Len() int {
+	return len(d.Keys)
+}
+
+// Get returns the value stored under key, and whether it was present.
+func (d *OrderedDict) Get(key string) (interface{}, bool) {
+	for i, k := range d.Keys {
+		if k == key {
+			return d.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// Set stores value under key. If key is already present, its value is replaced in place;
+// otherwise, it is appended to the end of d.
+func (d *OrderedDict) Set(key string, value interface{}) {
+	for i, k := range d.Keys {
+		if k == key {
+			d.Values[i] = value
+			return
+		}
+	}
+	d.Keys = append(d.Keys, key)
+	d.Values = append(d.Values, value)
+}
+
+// SetComment attaches comment to the entry stored under key, replacing any existing comment.
+// An empty comment removes it. Comments may span several lines.
+func (d *OrderedDict) SetComment(key, comment string) {
+	if comment == "" {
+		delete(d.Comments, key)
+		return
+	}
+	if d.Comments == nil {
+		d.Comments = make(map[string]string)
+	}
+	d.Comments[key] = comment
+}
+
+// Delete removes key (and its comment) from d, preserving the order of the remaining entries.
+func (d *OrderedDict) Delete(key string) {
+	for i, k := range d.Keys {
+		if k == key {
+			delete(d.Comments, key)
+			d.Keys = append(d.Keys[:i], d.Keys[i+1:]...)
+			d.Values = append(d.Values[:i], d.Values[i+1:]...)
+			return
+		}
+	}
+}
+
+var orderedDictType = reflect.TypeOf(OrderedDict{})
+
+func (p *Encoder) marshalOrderedDict(d *OrderedDict) cfValue {
+	dict := &cfDictionary{
+		keys:   make([]string, 0, len(d.Keys)),
+		values: make([]cfValue, 0, len(d.Keys)),
+	}
+	for i, k := range d.Keys {
+		if subpval := p.marshal(reflect.ValueOf(d.Values[i])); subpval != nil {
+			dict.keys = append(dict.keys, k)
+			dict.values = append(dict.values, subpval)
+			if c := d.Comments[k]; c != "" {
+				if dict.comments == nil {
+					dict.comments = make(map[string]string)
+				}
+				dict.comments[k] = c
+			}
+		}
+	}
+	p.orderKeys(dict, p.keyOrder != KeyOrderSorted)
+	return dict
+}
+
+func (p *Decoder) unmarshalOrderedDict(pval cfValue, val reflect.Value) error {
+	dict, ok := pval.(*cfDictionary)
+	if !ok {
+		return &TypeMismatchError{Source: pval.typeName(), Dest: val.Type()}
+	}
+	val.Set(reflect.ValueOf(*p.orderedDictionaryInterface(dict)))
+	return nil
+}
+
+func (p *Decoder) orderedValueInterface(pval cfValue) interface{} {
+	switch pval := pval.(type) {
+	case *cfArray:
+		p.account(pval)
+		out := make([]interface{}, len(pval.values))
+		for i, subv := range pval.values {
+			out[i] = p.orderedValueInterface(subv)
+		}
+		return out
+	case *cfDictionary:
+		return p.orderedDictionaryInterface(pval)
+	}
+	return p.valueInterface(pval)
+}
+
+func (p *Decoder) orderedDictionaryInterface(dict *cfDictionary) *OrderedDict {
+	p.account(dict)
+	out := &OrderedDict{
+		Keys:   make([]string, 0, len(dict.keys)),
+		Values: make([]interface{}, 0, len(dict.keys)),
+	}
+	// Keys are appended directly rather than through Set, which would scan the keys for every entry. Parsed
+	// dictionaries may still repeat a key; as with Set, the last value wins and the first position is kept.
+	index := make(map[string]int, len(dict.keys))
+	for i, k := range dict.keys {
+		v := p.orderedValueInterface(dict.values[i])
+		if j, ok := index[k]; ok {
+			out.Values[j] = v
+			continue
+		}
+		index[k] = len(out.Keys)
+		out.Keys = append(out.Keys, k)
+		out.Values = append(out.Values, v)
+	}
+	for k, c := range dict.comments {
+		out.SetComment(k, c)
+	}
+	return out
+}
+
+// joinComments combines the comments preceding a dictionary entry, without their delimiters or surrounding space.
+func joinComments(comments []string) string {
+	for i, c := range comments {
+		comments[i] = strings.TrimSpace(c)
+	}
+	return strings.Join(comments, "\n")
+}
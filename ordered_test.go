@@ -0,0 +1,158 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedDictRoundTrip(t *testing.T) {
+	inner := &OrderedDict{Keys: []string{"z", "a"}, Values: []interface{}{"last", "first"}}
+	d := OrderedDict{
+		Keys:   []string{"zebra", "apple", "mango", "nested"},
+		Values: []interface{}{uint64(1), "two", []interface{}{inner}, inner},
+	}
+
+	for _, format := range []int{XMLFormat, BinaryFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(d, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var decoded OrderedDict
+			if _, err := Unmarshal(doc, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(d, decoded) {
+				t.Logf("Expected: %#v", d)
+				t.Logf("Received: %#v", decoded)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestOrderedDictUnmarshal(t *testing.T) {
+	var s struct {
+		Ordered *OrderedDict
+	}
+	if _, err := Unmarshal([]byte(`{Ordered={b=1;a=2;b=3;};}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	expected := &OrderedDict{Keys: []string{"b", "a"}, Values: []interface{}{"3", "2"}}
+	if !reflect.DeepEqual(expected, s.Ordered) {
+		t.Errorf("Expected %#v, received %#v", expected, s.Ordered)
+	}
+
+	var d OrderedDict
+	if _, err := Unmarshal([]byte(`(1,2)`), &d); err == nil {
+		t.Error("Expected error unmarshaling an array into an OrderedDict, received nothing.")
+	}
+}
+
+func TestOrderedDictMethods(t *testing.T) {
+	var d OrderedDict
+	d.Set("a", 1)
+	d.Set("b", 2)
+	d.Set("c", 3)
+	d.Set("a", 4)
+	d.Delete("b")
+	d.Delete("missing")
+
+	if !reflect.DeepEqual(d.Keys, []string{"a", "c"}) || d.Len() != 2 {
+		t.Errorf("Unexpected keys %v", d.Keys)
+	}
+	if v, ok := d.Get("a"); !ok || v != 4 {
+		t.Errorf("Expected a=4, received %v (%v)", v, ok)
+	}
+	if _, ok := d.Get("b"); ok {
+		t.Error("Expected b to be deleted")
+	}
+}
+
+func TestUseOrderedDict(t *testing.T) {
+	doc := []byte(`(1, {b=1;a={d=1;c=2;};})`)
+
+	var val interface{}
+	d := NewDecoder(bytes.NewReader(doc))
+	d.UseOrderedDict()
+	if err := d.Decode(&val); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{
+		"1",
+		&OrderedDict{
+			Keys:   []string{"b", "a"},
+			Values: []interface{}{"1", &OrderedDict{Keys: []string{"d", "c"}, Values: []interface{}{"1", "2"}}},
+		},
+	}
+	if !reflect.DeepEqual(expected, val) {
+		t.Logf("Expected: %#v", expected)
+		t.Logf("Received: %#v", val)
+		t.Fail()
+	}
+
+	redoc, err := Marshal(val, OpenStepFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(redoc) != `(1,{b=1;a={d=1;c=2;};},)` {
+		t.Errorf("Expected order to be retained on re-encoding, received %s", redoc)
+	}
+}
+
+func TestPreserveComments(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Format   int
+		Input    string
+		Expected string
+	}{
+		{
+			Name:     "OpenStep",
+			Format:   OpenStepFormat,
+			Input:    "// header\n{\n\t/* Begin section */\n\tb = 1;\n\t// first line\n\t// second line\n\ta = {\n\t\t/* inner */\n\t\tx = y;\n\t};\n}\n",
+			Expected: "{\n\t/* Begin section */\n\tb = 1;\n\t/* first line */\n\t/* second line */\n\ta = {\n\t\t/* inner */\n\t\tx = y;\n\t};\n\t/* added */\n\tc = 2;\n}",
+		},
+		{
+			Name:     "XML",
+			Format:   XMLFormat,
+			Input:    xmlPreamble + "<plist version=\"1.0\"><dict><!-- Begin section --><key>b</key><string>1</string><!-- first line --><!-- second line --><key>a</key><dict><!-- inner --><key>x</key><string>y</string></dict></dict></plist>",
+			Expected: xmlPreamble + "<plist version=\"1.0\">\n\t<dict>\n\t\t<!-- Begin section -->\n\t\t<key>b</key>\n\t\t<string>1</string>\n\t\t<!-- first line\nsecond line -->\n\t\t<key>a</key>\n\t\t<dict>\n\t\t\t<!-- inner -->\n\t\t\t<key>x</key>\n\t\t\t<string>y</string>\n\t\t</dict>\n\t\t<!-- added -->\n\t\t<key>c</key>\n\t\t<string>2</string>\n\t</dict>\n</plist>",
+		},
+	}
+
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			var d OrderedDict
+			dec := NewDecoder(bytes.NewReader([]byte(test.Input)))
+			dec.PreserveComments()
+			if err := dec.Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+			d.Set("c", "2")
+			d.SetComment("c", "added")
+
+			var buf bytes.Buffer
+			enc := NewEncoderForFormat(&buf, test.Format)
+			enc.Indent("\t")
+			if err := enc.Encode(&d); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Expected {
+				t.Errorf("Expected:\n%s\nReceived:\n%s", test.Expected, buf.String())
+			}
+		})
+	}
+
+	// Without PreserveComments, no comments are recorded.
+	var d OrderedDict
+	if _, err := Unmarshal([]byte("{/* comment */a=b;}"), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Comments != nil {
+		t.Errorf("Expected no comments, received %#v", d.Comments)
+	}
+}
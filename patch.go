@@ -0,0 +1,202 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A PatchOp is the kind of change made by a PatchOperation.
+type PatchOp int
+
+const (
+	// PatchSet stores Value at Path, replacing any value already there. The dictionary or array that contains
+	// Path must exist. A key that is not yet present is added to the end of its dictionary; an array index must
+	// already exist. Setting the empty path replaces the whole property list.
+	PatchSet PatchOp = iota + 1
+
+	// PatchDelete removes the dictionary entry or array element at Path, which must exist.
+	PatchDelete
+
+	// PatchInsert inserts Value into an array before the element at Path, which must be an index no greater
+	// than the length of the array. An index equal to the length appends Value to the array.
+	PatchInsert
+)
+
+var patchOpNames = map[PatchOp]string{
+	PatchSet:    "set",
+	PatchDelete: "delete",
+	PatchInsert: "insert",
+}
+
+func (o PatchOp) String() string {
+	if name, ok := patchOpNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("PatchOp(%d)", int(o))
+}
+
+// A PatchOperation is a single change to a property list. Path is written as described for ParsePath.
+type PatchOperation struct {
+	Op    PatchOp
+	Path  string
+	Value interface{} // the value to set or insert; unused by PatchDelete
+}
+
+// Patch applies ops, in order, to the property list in data, and returns it encoded in the format in which it
+// was read. The order of dictionary keys, and the comments that precede dictionary entries, are preserved;
+// XML, OpenStep and GNUStep property lists are indented with tabs.
+//
+// If any operation cannot be applied, Patch returns an error and none of ops take effect.
+func Patch(data []byte, ops ...PatchOperation) ([]byte, error) {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.UseOrderedDict()
+	dec.PreserveComments()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	v, err := ApplyPatch(v, ops...)
+	if err != nil {
+		return nil, err
+	}
+
+	indent := "\t"
+	if dec.Format == BinaryFormat {
+		indent = ""
+	}
+	return MarshalIndent(v, dec.Format, indent)
+}
+
+// ApplyPatch applies ops, in order, to v, which holds a property list decoded into an empty interface (see Get),
+// and returns the result. The dictionaries and arrays within v may be modified in place, even if an operation
+// fails.
+func ApplyPatch(v interface{}, ops ...PatchOperation) (interface{}, error) {
+	for i, op := range ops {
+		elems, err := ParsePath(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = applyPatchOperation(v, elems, 0, op); err != nil {
+			return nil, fmt.Errorf("plist: patch operation %d (%v %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return v, nil
+}
+
+// applyPatchOperation applies op to v, the value at elems[:depth], and returns v's replacement.
+func applyPatchOperation(v interface{}, elems []interface{}, depth int, op PatchOperation) (interface{}, error) {
+	if depth == len(elems) {
+		if op.Op != PatchSet {
+			return nil, fmt.Errorf("cannot %v the root", op.Op)
+		}
+		return op.Value, nil
+	}
+
+	elem := elems[depth]
+	if depth < len(elems)-1 {
+		child, ok := queryChild(v, elem)
+		if !ok {
+			return nil, fmt.Errorf("no value at %q", formatPath(elems[:depth+1]))
+		}
+		child, err := applyPatchOperation(child, elems, depth+1, op)
+		if err != nil {
+			return nil, err
+		}
+		return replacePatchChild(v, elem, child), nil
+	}
+
+	if key, ok := elem.(string); ok {
+		return patchDictionary(v, key, elems, op)
+	}
+	return patchArray(v, elem.(int), elems, op)
+}
+
+// replacePatchChild stores child under the key or index elem of the dictionary or array v, and returns v. An index
+// must already be in the array.
+func replacePatchChild(v interface{}, elem interface{}, child interface{}) interface{} {
+	switch d := v.(type) {
+	case map[string]interface{}:
+		d[elem.(string)] = child
+	case *OrderedDict:
+		d.Set(elem.(string), child)
+	case OrderedDict:
+		d.Set(elem.(string), child)
+		return d
+	case []interface{}:
+		d[elem.(int)] = child
+	}
+	return v
+}
+
+func patchDictionary(v interface{}, key string, elems []interface{}, op PatchOperation) (interface{}, error) {
+	switch v.(type) {
+	case map[string]interface{}, *OrderedDict, OrderedDict:
+	default:
+		return nil, fmt.Errorf("%q is not a dictionary", formatPath(elems[:len(elems)-1]))
+	}
+
+	switch op.Op {
+	case PatchSet:
+		return replacePatchChild(v, key, op.Value), nil
+	case PatchDelete:
+		if _, ok := queryChild(v, key); !ok {
+			return nil, fmt.Errorf("no value at %q", formatPath(elems))
+		}
+		switch d := v.(type) {
+		case map[string]interface{}:
+			delete(d, key)
+		case *OrderedDict:
+			d.Delete(key)
+		case OrderedDict:
+			d.Delete(key)
+			return d, nil
+		}
+		return v, nil
+	case PatchInsert:
+		return nil, fmt.Errorf("cannot insert into dictionary %q", formatPath(elems[:len(elems)-1]))
+	}
+	return nil, fmt.Errorf("unknown operation %v", op.Op)
+}
+
+func patchArray(v interface{}, i int, elems []interface{}, op PatchOperation) (interface{}, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q is not an array", formatPath(elems[:len(elems)-1]))
+	}
+
+	switch op.Op {
+	case PatchSet, PatchDelete:
+		if i >= len(a) {
+			return nil, fmt.Errorf("no value at %q (array has %d elements)", formatPath(elems), len(a))
+		}
+		if op.Op == PatchSet {
+			return replacePatchChild(a, i, op.Value), nil
+		}
+		return append(a[:i], a[i+1:]...), nil
+	case PatchInsert:
+		if i > len(a) {
+			return nil, fmt.Errorf("cannot insert at %q (array has %d elements)", formatPath(elems), len(a))
+		}
+		a = append(a, nil)
+		copy(a[i+1:], a[i:])
+		a[i] = op.Value
+		return a, nil
+	}
+	return nil, fmt.Errorf("unknown operation %v", op.Op)
+}
+
+// formatPath writes the keys and indices in elems as a path, as ParsePath would read it.
+func formatPath(elems []interface{}) string {
+	path := ""
+	for i := len(elems) - 1; i >= 0; i-- {
+		switch elem := elems[i].(type) {
+		case string:
+			path = joinPath(keySegment(elem), path)
+		case int:
+			path = joinPath(indexSegment(elem), path)
+		}
+	}
+	return path
+}
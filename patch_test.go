@@ -0,0 +1,146 @@
+package plist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const patchTestDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadIdentifier</key>
+	<string>com.example.profile</string>
+	<!-- The payloads installed by the profile -->
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.wifi.managed</string>
+			<key>SSID_STR</key>
+			<string>Office</string>
+		</dict>
+	</array>
+	<key>PayloadRemovalDisallowed</key>
+	<true/>
+</dict>
+</plist>
+`
+
+func TestPatch(t *testing.T) {
+	out, err := Patch([]byte(patchTestDocument),
+		PatchOperation{Op: PatchSet, Path: "PayloadContent[0].SSID_STR", Value: "Guest"},
+		PatchOperation{Op: PatchSet, Path: "PayloadContent[0].AutoJoin", Value: true},
+		PatchOperation{Op: PatchInsert, Path: "PayloadContent[0]", Value: map[string]interface{}{"PayloadType": "com.apple.vpn.managed"}},
+		PatchOperation{Op: PatchDelete, Path: "PayloadRemovalDisallowed"},
+		PatchOperation{Op: PatchSet, Path: `["com.example.version"]`, Value: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>PayloadIdentifier</key>
+		<string>com.example.profile</string>
+		<!-- The payloads installed by the profile -->
+		<key>PayloadContent</key>
+		<array>
+			<dict>
+				<key>PayloadType</key>
+				<string>com.apple.vpn.managed</string>
+			</dict>
+			<dict>
+				<key>PayloadType</key>
+				<string>com.apple.wifi.managed</string>
+				<key>SSID_STR</key>
+				<string>Guest</string>
+				<key>AutoJoin</key>
+				<true/>
+			</dict>
+		</array>
+		<key>com.example.version</key>
+		<integer>2</integer>
+	</dict>
+</plist>`
+	if string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+}
+
+func TestPatchKeepsFormat(t *testing.T) {
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat, GNUStepFormat} {
+		format := format
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(map[string]interface{}{"Names": []string{"a", "c"}, "Count": 2}, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := Patch(doc, PatchOperation{Op: PatchInsert, Path: "Names[1]", Value: "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v struct{ Names []string }
+			got, err := Unmarshal(out, &v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != format {
+				t.Errorf("expected %s, got %s", FormatNames[format], FormatNames[got])
+			}
+			if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(v.Names, expected) {
+				t.Errorf("expected %v, got %v", expected, v.Names)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	v := map[string]interface{}{"A": []interface{}{uint64(1), uint64(2)}}
+	out, err := ApplyPatch(v,
+		PatchOperation{Op: PatchDelete, Path: "A[0]"},
+		PatchOperation{Op: PatchInsert, Path: "A[1]", Value: uint64(3)},
+		PatchOperation{Op: PatchSet, Path: "B", Value: "b"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"A": []interface{}{uint64(2), uint64(3)}, "B": "b"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %#v, got %#v", expected, out)
+	}
+
+	out, err = ApplyPatch(v, PatchOperation{Op: PatchSet, Value: "root"})
+	if err != nil || out != "root" {
+		t.Errorf("expected the root to be replaced, got %#v (%v)", out, err)
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	tests := []struct {
+		op  PatchOperation
+		err string
+	}{
+		{PatchOperation{Op: PatchSet, Path: "Missing.Key", Value: 1}, `no value at "Missing"`},
+		{PatchOperation{Op: PatchDelete, Path: "Missing"}, `no value at "Missing"`},
+		{PatchOperation{Op: PatchSet, Path: "A[2]", Value: 1}, `no value at "A[2]" (array has 2 elements)`},
+		{PatchOperation{Op: PatchInsert, Path: "A[3]", Value: 1}, `cannot insert at "A[3]"`},
+		{PatchOperation{Op: PatchInsert, Path: "B", Value: 1}, `cannot insert into dictionary ""`},
+		{PatchOperation{Op: PatchSet, Path: "A.B", Value: 1}, `"A" is not a dictionary`},
+		{PatchOperation{Op: PatchSet, Path: "B[0]", Value: 1}, `"B" is not an array`},
+		{PatchOperation{Op: PatchDelete}, "cannot delete the root"},
+		{PatchOperation{Op: PatchSet, Path: "A[", Value: 1}, "unterminated index"},
+	}
+
+	for _, test := range tests {
+		v := map[string]interface{}{"A": []interface{}{uint64(1), uint64(2)}, "B": "b"}
+		_, err := ApplyPatch(v, test.op)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v %q: expected an error containing %q, got %v", test.op.Op, test.op.Path, test.err, err)
+		}
+	}
+}
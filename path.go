@@ -0,0 +1,151 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Paths name a value within a property list by the dictionary keys and array indices that lead to it from the
+// root, as in Payloads[2].PayloadUUID. Keys are separated by dots, and indices are written in brackets. A key that
+// is empty or contains any of . [ ] " is written as a quoted string in brackets, as in Payloads["com.example"].
+// The empty path names the root.
+//
+// PathError, Warning and the typed decoding errors report paths in this form, and Get and LazyPlist.Get accept it.
+
+// keySegment returns the segment of a path that names the dictionary key k.
+func keySegment(k string) string {
+	if strings.ContainsAny(k, ".[]\"") || k == "" {
+		return "[" + strconv.Quote(k) + "]"
+	}
+	return k
+}
+
+// indexSegment returns the segment of a path that names the array index i.
+func indexSegment(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// joinPath adds segment to the start of path.
+func joinPath(segment, path string) string {
+	if path == "" || path[0] == '[' {
+		return segment + path
+	}
+	return segment + "." + path
+}
+
+// ParsePath splits path into its dictionary keys (strings) and array indices (ints), in the form accepted by the
+// methods of LazyPlist.
+func ParsePath(path string) ([]interface{}, error) {
+	var elems []interface{}
+	for i := 0; i < len(path); {
+		if path[i] == '[' {
+			elem, n, err := parsePathBracket(path[i:])
+			if err != nil {
+				return nil, fmt.Errorf("plist: %v in path %q", err, path)
+			}
+			elems = append(elems, elem)
+			i += n
+			continue
+		}
+
+		if i > 0 {
+			if path[i] != '.' {
+				return nil, fmt.Errorf("plist: unexpected %q at offset %d in path %q", path[i], i, path)
+			}
+			i++
+		}
+		end := strings.IndexAny(path[i:], ".[")
+		if end < 0 {
+			end = len(path) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("plist: empty key at offset %d in path %q", i, path)
+		}
+		elems = append(elems, path[i:i+end])
+		i += end
+	}
+	return elems, nil
+}
+
+// parsePathBracket parses the index or quoted key in brackets at the start of s, returning it and its length.
+func parsePathBracket(s string) (interface{}, int, error) {
+	if len(s) > 1 && s[1] == '"' {
+		// A quoted key ends at the first unescaped quote.
+		end := 2
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end+1 >= len(s) || s[end+1] != ']' {
+			return nil, 0, errors.New("unterminated key")
+		}
+		key, err := strconv.Unquote(s[1 : end+1])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid key %s", s[1:end+1])
+		}
+		return key, end + 2, nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return nil, 0, errors.New("unterminated index")
+	}
+	n, err := strconv.Atoi(s[1:end])
+	if err != nil || n < 0 {
+		return nil, 0, fmt.Errorf("invalid index %q", s[1:end])
+	}
+	return n, end + 1, nil
+}
+
+// Get returns the value at path within v, which holds a property list decoded into an empty interface: that is,
+// a tree of map[string]interface{} (or *OrderedDict) and []interface{} values. It reports false if path is malformed
+// or there is no value at path.
+func Get(v interface{}, path string) (interface{}, bool) {
+	elems, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case string:
+			var ok bool
+			switch d := v.(type) {
+			case map[string]interface{}:
+				v, ok = d[elem]
+			case *OrderedDict:
+				v, ok = d.Get(elem)
+			case OrderedDict:
+				v, ok = d.Get(elem)
+			}
+			if !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || elem >= len(a) {
+				return nil, false
+			}
+			v = a[elem]
+		}
+	}
+	return v, true
+}
+
+// Get decodes the object at path, written as for the package-level Get, into an empty interface. It reports false
+// if path is malformed, there is no object at path, or the object cannot be decoded.
+func (l *LazyPlist) Get(path string) (interface{}, bool) {
+	elems, err := ParsePath(path)
+	if err != nil || !l.Has(elems...) {
+		return nil, false
+	}
+
+	var v interface{}
+	if err := l.Decode(&v, elems...); err != nil {
+		return nil, false
+	}
+	return v, true
+}
@@ -0,0 +1,100 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path  string
+		elems []interface{}
+	}{
+		{"", nil},
+		{"Name", []interface{}{"Name"}},
+		{"[2]", []interface{}{2}},
+		{"Payloads[2].PayloadUUID", []interface{}{"Payloads", 2, "PayloadUUID"}},
+		{"a[0][1].b.c", []interface{}{"a", 0, 1, "b", "c"}},
+		{`Domains["com.example.app"].Enabled`, []interface{}{"Domains", "com.example.app", "Enabled"}},
+		{`[""]["a\"]b"]`, []interface{}{"", `a"]b`}},
+	}
+	for _, test := range tests {
+		elems, err := ParsePath(test.path)
+		if err != nil || !reflect.DeepEqual(elems, test.elems) {
+			t.Errorf("ParsePath(%q) = %#v, %v; expected %#v", test.path, elems, err, test.elems)
+		}
+	}
+
+	for _, path := range []string{".a", "a.", "a..b", "a[", "a[x]", "a[-1]", "a[0]b", `a["b]`, `a["b"`} {
+		if elems, err := ParsePath(path); err == nil {
+			t.Errorf("ParsePath(%q) = %#v; expected an error", path, elems)
+		}
+	}
+}
+
+func TestPathSegmentsRoundTrip(t *testing.T) {
+	for _, key := range []string{"plain", "", "a.b", "[0]", `"quoted"`, `back\slash`} {
+		path := joinPath(keySegment(key), joinPath(indexSegment(3), "x"))
+		elems, err := ParsePath(path)
+		if expected := []interface{}{key, 3, "x"}; err != nil || !reflect.DeepEqual(elems, expected) {
+			t.Errorf("ParsePath(%q) = %#v, %v; expected %#v", path, elems, err, expected)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	doc := []byte(`<plist><dict>
+		<key>Payloads</key><array>
+			<dict><key>PayloadUUID</key><string>first</string></dict>
+			<dict><key>PayloadUUID</key><string>second</string></dict>
+		</array>
+		<key>com.example</key><dict><key>Enabled</key><true/></dict>
+	</dict></plist>`)
+
+	for _, ordered := range []bool{false, true} {
+		var v interface{}
+		d := NewDecoder(bytes.NewReader(doc))
+		if ordered {
+			d.UseOrderedDict()
+		}
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if uuid, ok := Get(v, "Payloads[1].PayloadUUID"); !ok || uuid != "second" {
+			t.Errorf("expected second, got %v (%v)", uuid, ok)
+		}
+		if enabled, ok := Get(v, `["com.example"].Enabled`); !ok || enabled != true {
+			t.Errorf("expected true, got %v (%v)", enabled, ok)
+		}
+		if root, ok := Get(v, ""); !ok || !reflect.DeepEqual(root, v) {
+			t.Errorf("expected the root, got %v (%v)", root, ok)
+		}
+		for _, path := range []string{"Payloads[2]", "Payloads.PayloadUUID", "Missing", "Payloads[0].PayloadUUID.x", "Payloads["} {
+			if value, ok := Get(v, path); ok {
+				t.Errorf("Get(%q) = %v; expected nothing", path, value)
+			}
+		}
+	}
+}
+
+func TestLazyPlistGet(t *testing.T) {
+	doc, err := Marshal(map[string]interface{}{
+		"Payloads": []map[string]string{{"PayloadUUID": "first"}, {"PayloadUUID": "second"}},
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := NewLazyPlist(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uuid, ok := lp.Get("Payloads[1].PayloadUUID"); !ok || uuid != "second" {
+		t.Errorf("expected second, got %v (%v)", uuid, ok)
+	}
+	if value, ok := lp.Get("Payloads[2]"); ok {
+		t.Errorf("expected nothing, got %v", value)
+	}
+}
@@ -1,6 +1,7 @@
 package plist
 
 import (
+	"fmt"
 	"reflect"
 )
 
@@ -60,10 +61,49 @@ func (e plistParseError) Error() string {
 	return s
 }
 
+// Unwrap returns the error that caused the property list to be rejected.
+func (e plistParseError) Unwrap() error {
+	return e.err
+}
+
+// A PositionError is an error that identifies where in a document it was found. Errors from parsing XML, OpenStep
+// and GNUStep property lists wrap a PositionError wherever a position is known, so it may be retrieved with errors.As.
+type PositionError interface {
+	error
+	Position() (line, column int)
+}
+
+// A SyntaxError describes a problem found at a particular position in an XML, OpenStep or GNUStep property list.
+type SyntaxError struct {
+	Line   int // the line number, beginning at 1
+	Column int // the offset in bytes from the start of the line, beginning at 1
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at line %d column %d", e.Err, e.Line, e.Column)
+}
+
+// Position returns the line and column at which the error was found.
+func (e *SyntaxError) Position() (line, column int) {
+	return e.Line, e.Column
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
 // A UID represents a unique object identifier. UIDs are serialized in a manner distinct from
 // that of integers.
 type UID uint64
 
+// A Set holds the members of a set, which binary property lists can store distinctly from an array (as Foundation
+// stores an NSSet). A Set is marshaled as a set in binary property lists, and as an array in every other format.
+// Sets are unmarshaled as arrays, so a Set may be unmarshaled from any array; members are kept in the order in
+// which they are stored, so a Set survives a round trip through a binary property list unchanged.
+type Set []interface{}
+
 // Marshaler is the interface implemented by types that can marshal themselves into valid
 // property list objects. The returned value is marshaled in place of the original value
 // implementing Marshaler
@@ -73,6 +113,14 @@ type Marshaler interface {
 	MarshalPlist() (interface{}, error)
 }
 
+// UIDMarshaler is the interface implemented by types that can marshal themselves as UIDs, such as the references
+// between the objects in a keyed archive. If MarshalPlistUID returns true, the UID it returns is marshaled in place
+// of the original value; otherwise, the value is marshaled as it would be if it did not implement UIDMarshaler
+// (including by its MarshalPlist method, if it also implements Marshaler).
+type UIDMarshaler interface {
+	MarshalPlistUID() (UID, bool)
+}
+
 // Unmarshaler is the interface implemented by types that can unmarshal themselves from
 // property list objects. The UnmarshalPlist method receives a function that may
 // be called to unmarshal the original property list value into a field or variable.
@@ -2,6 +2,8 @@ package plist
 
 import (
 	"hash/crc32"
+	"io"
+	"math/big"
 	"sort"
 	"time"
 	"strconv"
@@ -19,6 +21,12 @@ type cfValue interface {
 type cfDictionary struct {
 	keys   sort.StringSlice
 	values []cfValue
+
+	// ordered dictionaries retain their key order when they are generated.
+	ordered bool
+
+	// comments holds the comment preceding each entry, by key, for the text and XML formats.
+	comments map[string]string
 }
 
 func (*cfDictionary) typeName() string {
@@ -43,9 +51,28 @@ func (p *cfDictionary) Swap(i, j int) {
 }
 
 func (p *cfDictionary) sort() {
+	if p.ordered {
+		return
+	}
 	sort.Sort(p)
 }
 
+// cfDictionaryByFunc sorts a dictionary's keys with a user-supplied comparison function.
+type cfDictionaryByFunc struct {
+	*cfDictionary
+	less func(a, b string) bool
+}
+
+func (p cfDictionaryByFunc) Less(i, j int) bool {
+	return p.less(p.keys[i], p.keys[j])
+}
+
+// sortFunc sorts the dictionary's keys using less, and marks it as ordered.
+func (p *cfDictionary) sortFunc(less func(a, b string) bool) {
+	sort.Stable(cfDictionaryByFunc{p, less})
+	p.ordered = true
+}
+
 func (p *cfDictionary) maybeUID(lax bool) cfValue {
 	if len(p.keys) == 1 && p.keys[0] == "CF$UID" && len(p.values) == 1 {
 		pval := p.values[0]
@@ -66,6 +93,7 @@ func (p *cfDictionary) maybeUID(lax bool) cfValue {
 
 type cfArray struct {
 	values []cfValue
+	set    bool // written as a set in binary property lists; see Set
 }
 
 func (*cfArray) typeName() string {
@@ -89,6 +117,19 @@ func (p cfString) hash() interface{} {
 type cfNumber struct {
 	signed bool
 	value  uint64
+	big    *big.Int // the value of a 128-bit integer beyond the range of int64 and uint64, or nil; see newIntegerValue
+	text   string   // the text of an integer read from a text property list or marshaled from a Number, or ""
+}
+
+// String returns the number in decimal.
+func (p *cfNumber) String() string {
+	if p.big != nil {
+		return p.big.String()
+	}
+	if p.signed {
+		return strconv.FormatInt(int64(p.value), 10)
+	}
+	return strconv.FormatUint(p.value, 10)
 }
 
 func (*cfNumber) typeName() string {
@@ -96,6 +137,9 @@ func (*cfNumber) typeName() string {
 }
 
 func (p *cfNumber) hash() interface{} {
+	if p.big != nil {
+		return struct{ big string }{p.big.String()}
+	}
 	if p.signed {
 		return int64(p.value)
 	}
@@ -105,6 +149,7 @@ func (p *cfNumber) hash() interface{} {
 type cfReal struct {
 	wide  bool
 	value float64
+	text  string // the text of a real read from a textual property list or marshaled from a Number, or ""; see Number
 }
 
 func (cfReal) typeName() string {
@@ -112,6 +157,10 @@ func (cfReal) typeName() string {
 }
 
 func (p *cfReal) hash() interface{} {
+	if p.value != p.value {
+		// NaN is not equal to itself, so it cannot be used as a key.
+		return struct{ nan, wide bool }{true, p.wide}
+	}
 	if p.wide {
 		return p.value
 	}
@@ -154,6 +203,11 @@ func (cfData) typeName() string {
 	return "data"
 }
 
+// copyTo writes p to w.
+func (p cfData) copyTo(w io.Writer) {
+	w.Write(p)
+}
+
 func (p cfData) hash() interface{} {
 	// Data are uniqued by their checksums.
 	// Todo: Look at calculating this only once and storing it somewhere;
@@ -170,3 +224,11 @@ func (cfDate) typeName() string {
 func (p cfDate) hash() interface{} {
 	return time.Time(p)
 }
+
+// in returns the date to be written, in its own location if preserveTimeZones is set and in UTC otherwise.
+func (p cfDate) in(preserveTimeZones bool) time.Time {
+	if preserveTimeZones {
+		return time.Time(p)
+	}
+	return time.Time(p).In(time.UTC)
+}
@@ -0,0 +1,219 @@
+// Package plistyaml converts between property lists and YAML documents.
+//
+// Dictionary key order is preserved in both directions. Property list types with no YAML equivalent are tagged:
+//
+//	data     !!binary, holding the standard base64 encoding of the data
+//	date     !!timestamp, in RFC 3339 format
+//	UID      !plist/uid, holding the UID as a decimal integer
+//
+// YAML nulls are discarded when converting to a property list, as property lists bear no representation for them.
+package plistyaml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/wartiva/go-plist"
+	"gopkg.in/yaml.v3"
+)
+
+// UIDTag is the YAML tag used for property list UIDs.
+const UIDTag = "!plist/uid"
+
+// ToYAML reads a property list in any format from r and writes it to w as YAML.
+func ToYAML(r io.Reader, w io.Writer) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var val interface{}
+	dec := plist.NewDecoder(bytes.NewReader(buf))
+	dec.UseOrderedDict()
+	if err := dec.Decode(&val); err != nil {
+		return err
+	}
+
+	n, err := toNode(val)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(n); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+func toNode(val interface{}) (*yaml.Node, error) {
+	switch val := val.(type) {
+	case *plist.OrderedDict:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for i, k := range val.Keys {
+			v, err := toNode(val.Values[i])
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, scalarNode("!!str", k), v)
+		}
+		return n, nil
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, v := range val {
+			c, err := toNode(v)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, c)
+		}
+		return n, nil
+	case string:
+		return scalarNode("!!str", val), nil
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(val)), nil
+	case int64:
+		return scalarNode("!!int", strconv.FormatInt(val, 10)), nil
+	case uint64:
+		return scalarNode("!!int", strconv.FormatUint(val, 10)), nil
+	case *big.Int:
+		return scalarNode("!!int", val.String()), nil
+	case float32:
+		return scalarNode("!!float", formatFloat(float64(val), 32)), nil
+	case float64:
+		return scalarNode("!!float", formatFloat(val, 64)), nil
+	case []byte:
+		return scalarNode("!!binary", base64.StdEncoding.EncodeToString(val)), nil
+	case time.Time:
+		return scalarNode("!!timestamp", val.In(time.UTC).Format(time.RFC3339)), nil
+	case plist.UID:
+		return scalarNode(UIDTag, strconv.FormatUint(uint64(val), 10)), nil
+	}
+	return nil, fmt.Errorf("plistyaml: unexpected property list value of type %T", val)
+}
+
+// FromYAML reads a YAML document from r and writes it to w as a property list in the specified format.
+func FromYAML(r io.Reader, w io.Writer, format int) error {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	val, err := fromNode(&doc)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return errors.New("plistyaml: no root element to encode")
+	}
+
+	return plist.NewEncoderForFormat(w, format).Encode(val)
+}
+
+func fromNode(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return fromNode(n.Content[0])
+	case yaml.AliasNode:
+		return fromNode(n.Alias)
+	case yaml.MappingNode:
+		d := &plist.OrderedDict{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("plistyaml: line %d: dictionary keys must be scalars", k.Line)
+			}
+			v, err := fromNode(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				d.Set(k.Value, v)
+			}
+		}
+		return d, nil
+	case yaml.SequenceNode:
+		a := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := fromNode(c)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				a = append(a, v)
+			}
+		}
+		return a, nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!str":
+		return n.Value, nil
+	case "!!binary":
+		b, err := base64.StdEncoding.DecodeString(n.Value)
+		if err != nil {
+			return nil, fmt.Errorf("plistyaml: line %d: %w", n.Line, err)
+		}
+		return b, nil
+	case UIDTag:
+		u, err := strconv.ParseUint(n.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plistyaml: line %d: invalid UID: %w", n.Line, err)
+		}
+		return plist.UID(u), nil
+	case "!!timestamp":
+		var t time.Time
+		if err := n.Decode(&t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	case "!!int":
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return i, nil
+		}
+		var u uint64
+		if err := n.Decode(&u); err == nil {
+			return u, nil
+		}
+		// Property lists hold integers of up to 128 bits.
+		if b, ok := new(big.Int).SetString(n.Value, 10); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("plistyaml: line %d: invalid integer %q", n.Line, n.Value)
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
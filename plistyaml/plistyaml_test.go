@@ -0,0 +1,98 @@
+package plistyaml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wartiva/go-plist"
+)
+
+const testPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict>
+<key>Label</key><string>com.example.agent</string>
+<key>KeepAlive</key><true/>
+<key>Args</key><array><string>true</string><string>42</string><integer>-7</integer><real>1.5</real></array>
+<key>Blob</key><data>AQID</data>
+<key>When</key><date>2013-11-27T00:34:00Z</date>
+<key>Ref</key><dict><key>CF$UID</key><integer>3</integer></dict>
+</dict></plist>`
+
+const testYAML = `Label: com.example.agent
+KeepAlive: true
+Args:
+    - "true"
+    - "42"
+    - -7
+    - 1.5
+Blob: !!binary AQID
+When: 2013-11-27T00:34:00Z
+Ref: !plist/uid 3
+`
+
+func TestToYAML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := ToYAML(strings.NewReader(testPlist), buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != testYAML {
+		t.Logf("Expected:\n%s", testYAML)
+		t.Logf("Received:\n%s", buf.String())
+		t.Fail()
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := FromYAML(strings.NewReader(testYAML+"Ignored: null\n"), buf, plist.BinaryFormat); err != nil {
+		t.Fatal(err)
+	}
+
+	var val plist.OrderedDict
+	if _, err := plist.Unmarshal(buf.Bytes(), &val); err != nil {
+		t.Fatal(err)
+	}
+	expected := plist.OrderedDict{
+		Keys: []string{"Label", "KeepAlive", "Args", "Blob", "When", "Ref"},
+		Values: []interface{}{
+			"com.example.agent",
+			true,
+			[]interface{}{"true", "42", int64(-7), 1.5},
+			[]byte{1, 2, 3},
+			time.Date(2013, 11, 27, 0, 34, 0, 0, time.UTC),
+			plist.UID(3),
+		},
+	}
+	if !reflect.DeepEqual(expected, val) {
+		t.Logf("Expected: %#v", expected)
+		t.Logf("Received: %#v", val)
+		t.Fail()
+	}
+
+	for _, invalid := range []string{"", "null", "? [a]\n: b\n", "a: !!binary '*'\n", "a: !plist/uid x\n"} {
+		if err := FromYAML(strings.NewReader(invalid), &bytes.Buffer{}, plist.XMLFormat); err == nil {
+			t.Errorf("%q: Expected error, received nothing.", invalid)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	const doc = `<plist><array><integer>170141183460469231731687303715884105727</integer></array></plist>`
+	buf := &bytes.Buffer{}
+	if err := ToYAML(strings.NewReader(doc), buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "- !!int 170141183460469231731687303715884105727\n"; buf.String() != expected {
+		t.Errorf("Expected %q, received %q", expected, buf.String())
+	}
+
+	out := &bytes.Buffer{}
+	if err := FromYAML(buf, out, plist.XMLFormat); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<integer>170141183460469231731687303715884105727</integer>") {
+		t.Errorf("Expected the integer to round-trip, received %s", out)
+	}
+}
@@ -0,0 +1,101 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Marshal and Encode are often called in tight loops, so the buffers they write through, and the tables built
+// by the binary generator, are recycled rather than allocated afresh for each property list.
+//
+// Anything that has grown larger than maxPooledSize is left for the garbage collector, so that one unusually
+// large property list does not pin its buffers for the lifetime of the process.
+const maxPooledSize = 64 << 10
+
+var encodeBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getEncodeBuffer() *bytes.Buffer {
+	buf := encodeBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putEncodeBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledSize {
+		encodeBufferPool.Put(buf)
+	}
+}
+
+var bufioWriterPool = sync.Pool{
+	New: func() interface{} { return bufio.NewWriter(nil) },
+}
+
+func getBufioWriter(w io.Writer) *bufio.Writer {
+	bw := bufioWriterPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw
+}
+
+func putBufioWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	bufioWriterPool.Put(bw)
+}
+
+// bplistTables holds the working state of a bplistGenerator.
+type bplistTables struct {
+	objmap   map[interface{}]uint64
+	objtable []cfValue
+	offtable []uint64
+}
+
+var bplistTablesPool = sync.Pool{
+	New: func() interface{} {
+		return &bplistTables{
+			objmap:   make(map[interface{}]uint64),
+			objtable: make([]cfValue, 0, 16),
+		}
+	},
+}
+
+func getBplistTables() *bplistTables {
+	return bplistTablesPool.Get().(*bplistTables)
+}
+
+func putBplistTables(t *bplistTables) {
+	if len(t.objtable) > maxPooledSize {
+		return
+	}
+	t.reset()
+	bplistTablesPool.Put(t)
+}
+
+func (t *bplistTables) reset() {
+	for k := range t.objmap {
+		delete(t.objmap, k)
+	}
+	// Drop the references to the values that were encoded, so that they can be collected.
+	for i := range t.objtable {
+		t.objtable[i] = nil
+	}
+	t.objtable = t.objtable[:0]
+	t.offtable = t.offtable[:0]
+}
+
+// releaseGenerator returns the buffers and tables used by g to their pools. g must not be used afterwards.
+func releaseGenerator(g generator) {
+	switch g := g.(type) {
+	case *xmlPlistGenerator:
+		putBufioWriter(g.Writer)
+		g.Writer = nil
+	case *bplistGenerator:
+		if g.tables != nil {
+			g.tables.objtable = g.objtable
+			putBplistTables(g.tables)
+			g.tables, g.objmap, g.objtable = nil, nil, nil
+		}
+	}
+}
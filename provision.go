@@ -0,0 +1,73 @@
+package plist
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// A ProvisioningProfile is an Apple provisioning profile, as found in .mobileprovision and .provisionprofile
+// files and embedded in application bundles. The fields hold the values of the profile's best-known keys; Values
+// holds all of them.
+type ProvisioningProfile struct {
+	AppIDName                   string                 `plist:"AppIDName"`
+	ApplicationIdentifierPrefix []string               `plist:"ApplicationIdentifierPrefix"`
+	CreationDate                time.Time              `plist:"CreationDate"`
+	ExpirationDate              time.Time              `plist:"ExpirationDate"`
+	Name                        string                 `plist:"Name"`
+	Platform                    []string               `plist:"Platform"`
+	IsXcodeManaged              bool                   `plist:"IsXcodeManaged"`
+	DeveloperCertificates       [][]byte               `plist:"DeveloperCertificates"`
+	Entitlements                map[string]interface{} `plist:"Entitlements"`
+	ProvisionedDevices          []string               `plist:"ProvisionedDevices"`
+	ProvisionsAllDevices        bool                   `plist:"ProvisionsAllDevices"`
+	TeamIdentifier              []string               `plist:"TeamIdentifier"`
+	TeamName                    string                 `plist:"TeamName"`
+	TimeToLive                  int                    `plist:"TimeToLive"`
+	UUID                        string                 `plist:"UUID"`
+	Version                     int                    `plist:"Version"`
+
+	// Values holds every key in the profile, decoded as by Unmarshal into an empty interface.
+	Values map[string]interface{} `plist:"-"`
+
+	// Envelope is the signed structure in which the profile was found. Its Content is the profile's property list,
+	// and its Verify method checks the profile's signature.
+	Envelope *SignedProfile `plist:"-"`
+}
+
+// ParseProvisioningProfile extracts and decodes the property list signed by the provisioning profile in data. The
+// signature is not verified; see SignedProfile.Verify.
+func ParseProvisioningProfile(data []byte) (*ProvisioningProfile, error) {
+	envelope, err := ParseSignedProfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ProvisioningProfile{Envelope: envelope}
+	if _, err := Unmarshal(envelope.Content, p); err != nil {
+		return nil, fmt.Errorf("plist: invalid provisioning profile: %w", err)
+	}
+	if _, err := Unmarshal(envelope.Content, &p.Values); err != nil {
+		return nil, fmt.Errorf("plist: invalid provisioning profile: %w", err)
+	}
+	return p, nil
+}
+
+// Certificates parses the certificates in DeveloperCertificates: those with which the profile permits code to be
+// signed.
+func (p *ProvisioningProfile) Certificates() ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, len(p.DeveloperCertificates))
+	for i, der := range p.DeveloperCertificates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("plist: developer certificate %d: %w", i, err)
+		}
+		certs[i] = cert
+	}
+	return certs, nil
+}
+
+// Expired reports whether the profile has expired at time t.
+func (p *ProvisioningProfile) Expired(t time.Time) bool {
+	return !p.ExpirationDate.IsZero() && !t.Before(p.ExpirationDate)
+}
@@ -0,0 +1,93 @@
+package plist
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestParseProvisioningProfile(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cert := testCertificate(t, "Apple Development: Test", key, nil, nil)
+
+	content := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>AppIDName</key>
+	<string>Example</string>
+	<key>ApplicationIdentifierPrefix</key>
+	<array><string>ABCDE12345</string></array>
+	<key>CreationDate</key>
+	<date>2024-01-02T03:04:05Z</date>
+	<key>DeveloperCertificates</key>
+	<array><data>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</data></array>
+	<key>Entitlements</key>
+	<dict>
+		<key>application-identifier</key>
+		<string>ABCDE12345.com.example.app</string>
+		<key>get-task-allow</key>
+		<true/>
+	</dict>
+	<key>ExpirationDate</key>
+	<date>2025-01-02T03:04:05Z</date>
+	<key>Name</key>
+	<string>Example Development</string>
+	<key>ProvisionedDevices</key>
+	<array><string>00008030-001A2B3C4D5E6F70</string></array>
+	<key>TeamIdentifier</key>
+	<array><string>ABCDE12345</string></array>
+	<key>TimeToLive</key>
+	<integer>366</integer>
+	<key>UUID</key>
+	<string>6F9619FF-8B86-D011-B42D-00C04FC964FF</string>
+	<key>Version</key>
+	<integer>1</integer>
+	<key>ppqCheck</key>
+	<false/>
+</dict>
+</plist>
+`)
+	signed := signTestProfile(t, content, cert, key, []*x509.Certificate{cert}, false)
+
+	p, err := ParseProvisioningProfile(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p.Envelope.Content) != string(content) {
+		t.Error("expected the envelope to hold the profile's property list")
+	}
+	if p.Name != "Example Development" || p.UUID != "6F9619FF-8B86-D011-B42D-00C04FC964FF" || p.TimeToLive != 366 || p.Version != 1 {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	if len(p.TeamIdentifier) != 1 || p.TeamIdentifier[0] != "ABCDE12345" || len(p.ProvisionedDevices) != 1 {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	if p.Entitlements["application-identifier"] != "ABCDE12345.com.example.app" || p.Entitlements["get-task-allow"] != true {
+		t.Errorf("unexpected entitlements %v", p.Entitlements)
+	}
+	if v, ok := p.Values["ppqCheck"]; !ok || v != false {
+		t.Errorf("expected Values to hold every key, got %v", p.Values)
+	}
+
+	expiry := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !p.ExpirationDate.Equal(expiry) || p.Expired(expiry.Add(-time.Second)) || !p.Expired(expiry) {
+		t.Errorf("unexpected expiration date %v", p.ExpirationDate)
+	}
+
+	certs, err := p.Certificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || !certs[0].Equal(cert) {
+		t.Errorf("expected the developer certificate, got %v", certs)
+	}
+
+	if _, err := ParseProvisioningProfile(content); err == nil {
+		t.Error("expected an unsigned profile to be rejected")
+	}
+}
@@ -0,0 +1,540 @@
+package plist
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Queries select any number of values within a decoded property list, in a subset of JSONPath. A query begins
+// with an optional $, naming the root, followed by any number of steps:
+//
+//	.Key, ["Key"]   the value under Key, in a dictionary
+//	[2]             the third element of an array
+//	.*, [*]         every value in a dictionary, or every element of an array
+//	..Key, ..*      as .Key or .*, applied to the value and to every value nested within it at any depth
+//	[?(filter)]     every value in a dictionary, or element of an array, for which filter holds
+//
+// Keys are written as in paths (see ParsePath). A filter tests the value it is applied to, written @, with the
+// operators == != < <= > >=, combined with && || ! and parentheses. An operand is @, optionally followed by
+// keys and indices, as in @.PayloadType or @["com.example"][0]; a double-quoted string; a number; true; or
+// false. An operand on its own holds if it names a value. Strings compare with strings and numbers with numbers;
+// values of different types are never equal, and never ordered.
+//
+// For example, $..PayloadType selects every PayloadType at any depth, and
+// $.PayloadContent[?(@.PayloadType == "com.apple.wifi.managed")].SSID_STR selects the SSID of every Wi-Fi payload.
+
+// A CompiledQuery is a query that has been parsed, and may be run against any number of property lists.
+type CompiledQuery struct {
+	query string
+	steps []queryStep
+}
+
+type queryStepKind int
+
+const (
+	queryKey queryStepKind = iota
+	queryIndex
+	queryWildcard
+	queryFilter
+)
+
+type queryStep struct {
+	kind    queryStepKind
+	descend bool // apply the step to the value and to everything nested within it
+	key     string
+	index   int
+	filter  queryExpr
+}
+
+// CompileQuery parses query, which is written as described above.
+func CompileQuery(query string) (q *CompiledQuery, err error) {
+	p := &queryParser{s: query}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(queryError)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("plist: %s at offset %d in query %q", e.msg, e.pos, query)
+		}
+	}()
+
+	p.consume("$")
+	q = &CompiledQuery{query: query}
+	for !p.done() {
+		q.steps = append(q.steps, p.parseStep())
+	}
+	return q, nil
+}
+
+// String returns the query from which q was compiled.
+func (q *CompiledQuery) String() string {
+	return q.query
+}
+
+// Find returns the values within v that q selects, in the order in which they appear. v holds a property list
+// decoded into an empty interface, as for Get; the entries of a map are visited in order of their keys.
+func (q *CompiledQuery) Find(v interface{}) []interface{} {
+	matches := []interface{}{v}
+	for _, step := range q.steps {
+		var next []interface{}
+		for _, m := range matches {
+			if step.descend {
+				walkQueryValue(m, func(d interface{}) { next = step.apply(d, next) })
+			} else {
+				next = step.apply(m, next)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// Query returns the values within v that query selects. It is shorthand for compiling query and calling Find.
+func Query(v interface{}, query string) ([]interface{}, error) {
+	q, err := CompileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Find(v), nil
+}
+
+// apply appends the values that s selects from v to out.
+func (s *queryStep) apply(v interface{}, out []interface{}) []interface{} {
+	switch s.kind {
+	case queryKey:
+		if c, ok := queryChild(v, s.key); ok {
+			out = append(out, c)
+		}
+	case queryIndex:
+		if c, ok := queryChild(v, s.index); ok {
+			out = append(out, c)
+		}
+	case queryWildcard:
+		eachQueryChild(v, func(c interface{}) { out = append(out, c) })
+	case queryFilter:
+		eachQueryChild(v, func(c interface{}) {
+			if s.filter.holds(c) {
+				out = append(out, c)
+			}
+		})
+	}
+	return out
+}
+
+// queryChild returns the value under the key (a string) or index (an int) elem of v.
+func queryChild(v interface{}, elem interface{}) (interface{}, bool) {
+	switch elem := elem.(type) {
+	case string:
+		switch d := v.(type) {
+		case map[string]interface{}:
+			c, ok := d[elem]
+			return c, ok
+		case *OrderedDict:
+			return d.Get(elem)
+		case OrderedDict:
+			return d.Get(elem)
+		}
+	case int:
+		if a, ok := v.([]interface{}); ok && elem < len(a) {
+			return a[elem], true
+		}
+	}
+	return nil, false
+}
+
+// eachQueryChild calls f with each value in the dictionary or array v.
+func eachQueryChild(v interface{}, f func(interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			f(v[k])
+		}
+	case *OrderedDict:
+		for _, c := range v.Values {
+			f(c)
+		}
+	case OrderedDict:
+		for _, c := range v.Values {
+			f(c)
+		}
+	case []interface{}:
+		for _, c := range v {
+			f(c)
+		}
+	}
+}
+
+// walkQueryValue calls f with v, and then with each value nested within it, depth first.
+func walkQueryValue(v interface{}, f func(interface{})) {
+	f(v)
+	eachQueryChild(v, func(c interface{}) { walkQueryValue(c, f) })
+}
+
+// A queryExpr is a filter, or an operand within one.
+type queryExpr interface {
+	// value returns the operand's value when applied to v, and whether it has one.
+	value(v interface{}) (interface{}, bool)
+	holds(v interface{}) bool
+}
+
+// queryCurrent is @, followed by the keys and indices in path.
+type queryCurrent struct {
+	path []interface{}
+}
+
+func (e queryCurrent) value(v interface{}) (interface{}, bool) {
+	for _, elem := range e.path {
+		var ok bool
+		if v, ok = queryChild(v, elem); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (e queryCurrent) holds(v interface{}) bool {
+	_, ok := e.value(v)
+	return ok
+}
+
+type queryLiteral struct {
+	v interface{} // a string, float64 or bool
+}
+
+func (e queryLiteral) value(interface{}) (interface{}, bool) {
+	return e.v, true
+}
+
+func (e queryLiteral) holds(interface{}) bool {
+	b, ok := e.v.(bool)
+	return !ok || b
+}
+
+type queryLogical struct {
+	op          string // "&&", "||" or "!"
+	left, right queryExpr
+}
+
+func (e queryLogical) value(v interface{}) (interface{}, bool) {
+	return e.holds(v), true
+}
+
+func (e queryLogical) holds(v interface{}) bool {
+	switch e.op {
+	case "&&":
+		return e.left.holds(v) && e.right.holds(v)
+	case "||":
+		return e.left.holds(v) || e.right.holds(v)
+	}
+	return !e.left.holds(v)
+}
+
+type queryComparison struct {
+	op          string
+	left, right queryExpr
+}
+
+func (e queryComparison) value(v interface{}) (interface{}, bool) {
+	return e.holds(v), true
+}
+
+func (e queryComparison) holds(v interface{}) bool {
+	l, lok := e.left.value(v)
+	r, rok := e.right.value(v)
+	if !lok || !rok {
+		return false
+	}
+
+	cmp, ordered, ok := compareQueryValues(l, r)
+	if !ok {
+		return e.op == "!="
+	}
+	switch e.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	}
+	if !ordered {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// compareQueryValues compares l and r, if they are comparable; ordered reports whether they may also be ordered.
+func compareQueryValues(l, r interface{}) (cmp int, ordered, ok bool) {
+	if ls, isString := l.(string); isString {
+		rs, isString := r.(string)
+		return strings.Compare(ls, rs), true, isString
+	}
+	if lb, isBool := l.(bool); isBool {
+		rb, isBool := r.(bool)
+		if lb == rb {
+			return 0, false, isBool
+		}
+		return 1, false, isBool
+	}
+
+	ln, lok := queryNumber(l)
+	rn, rok := queryNumber(r)
+	if !lok || !rok {
+		return 0, false, false
+	}
+	switch {
+	case ln < rn:
+		return -1, true, true
+	case ln > rn:
+		return 1, true, true
+	}
+	return 0, true, true
+}
+
+// queryNumber returns the value of v as a float64, if v is a number.
+func queryNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+type queryError struct {
+	msg string
+	pos int
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) fail(format string, args ...interface{}) {
+	panic(queryError{fmt.Sprintf(format, args...), p.pos})
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// consume advances past tok, and reports whether it was found.
+func (p *queryParser) consume(tok string) bool {
+	if strings.HasPrefix(p.s[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(tok string) {
+	if !p.consume(tok) {
+		p.unexpected()
+	}
+}
+
+// peek returns the next byte, or 0 at the end of the query.
+func (p *queryParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *queryParser) unexpected() {
+	if p.done() {
+		p.fail("unexpected end")
+	}
+	p.fail("unexpected %q", p.s[p.pos])
+}
+
+func (p *queryParser) parseStep() queryStep {
+	descend := p.consume("..")
+	if !descend && !p.consume(".") {
+		if p.peek() != '[' {
+			p.unexpected()
+		}
+		return p.parseBracket()
+	}
+
+	var step queryStep
+	switch {
+	case descend && p.peek() == '[':
+		step = p.parseBracket()
+	case p.consume("*"):
+		step = queryStep{kind: queryWildcard}
+	default:
+		step = queryStep{kind: queryKey, key: p.parseName()}
+	}
+	step.descend = descend
+	return step
+}
+
+func (p *queryParser) parseName() string {
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(".[]()\"'=!<>&| \t", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.unexpected()
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *queryParser) parseBracket() queryStep {
+	p.expect("[")
+	var step queryStep
+	switch {
+	case p.consume("*"):
+		step.kind = queryWildcard
+	case p.consume("?("):
+		step.kind = queryFilter
+		step.filter = p.parseOr()
+		p.skipSpace()
+		p.expect(")")
+	case p.peek() == '"':
+		step.kind = queryKey
+		step.key = p.parseString()
+	default:
+		step.kind = queryIndex
+		step.index = p.parseIndex()
+	}
+	p.expect("]")
+	return step
+}
+
+// parseString parses a double-quoted string, with Go escape sequences.
+func (p *queryParser) parseString() string {
+	end := p.pos + 1
+	for ; end < len(p.s) && p.s[end] != '"'; end++ {
+		if p.s[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(p.s) {
+		p.fail("unterminated string")
+	}
+	str, err := strconv.Unquote(p.s[p.pos : end+1])
+	if err != nil {
+		p.fail("invalid string %s", p.s[p.pos:end+1])
+	}
+	p.pos = end + 1
+	return str
+}
+
+func (p *queryParser) parseIndex() int {
+	start := p.pos
+	for !p.done() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		p.unexpected()
+	}
+	return n
+}
+
+func (p *queryParser) parseOr() queryExpr {
+	e := p.parseAnd()
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		e = queryLogical{"||", e, p.parseAnd()}
+	}
+	return e
+}
+
+func (p *queryParser) parseAnd() queryExpr {
+	e := p.parseUnary()
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		e = queryLogical{"&&", e, p.parseUnary()}
+	}
+	return e
+}
+
+func (p *queryParser) parseUnary() queryExpr {
+	p.skipSpace()
+	if p.consume("!") {
+		return queryLogical{"!", p.parseUnary(), nil}
+	}
+	if p.consume("(") {
+		e := p.parseOr()
+		p.skipSpace()
+		p.expect(")")
+		return e
+	}
+
+	left := p.parseOperand()
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			p.skipSpace()
+			return queryComparison{op, left, p.parseOperand()}
+		}
+	}
+	return left
+}
+
+func (p *queryParser) parseOperand() queryExpr {
+	switch {
+	case p.consume("@"):
+		var e queryCurrent
+		for {
+			switch {
+			case p.consume("."):
+				e.path = append(e.path, p.parseName())
+			case p.consume("["):
+				if p.peek() == '"' {
+					e.path = append(e.path, p.parseString())
+				} else {
+					e.path = append(e.path, p.parseIndex())
+				}
+				p.expect("]")
+			default:
+				return e
+			}
+		}
+	case p.consume("true"):
+		return queryLiteral{true}
+	case p.consume("false"):
+		return queryLiteral{false}
+	case p.peek() == '"':
+		return queryLiteral{p.parseString()}
+	}
+
+	start := p.pos
+	for !p.done() && strings.ContainsRune("+-.0123456789eE", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	n, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		p.unexpected()
+	}
+	return queryLiteral{n}
+}
@@ -0,0 +1,106 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const queryTestDocument = `<plist><dict>
+	<key>PayloadType</key><string>Configuration</string>
+	<key>PayloadContent</key><array>
+		<dict>
+			<key>PayloadType</key><string>com.apple.wifi.managed</string>
+			<key>SSID_STR</key><string>Office</string>
+			<key>Priority</key><integer>10</integer>
+		</dict>
+		<dict>
+			<key>PayloadType</key><string>com.apple.vpn.managed</string>
+			<key>Priority</key><integer>5</integer>
+			<key>VPN</key><dict><key>AuthName</key><string>user</string></dict>
+		</dict>
+		<dict>
+			<key>PayloadType</key><string>com.apple.wifi.managed</string>
+			<key>SSID_STR</key><string>Guest</string>
+			<key>Hidden</key><true/>
+		</dict>
+	</array>
+	<key>com.example</key><array><string>a</string><string>b</string></array>
+</dict></plist>`
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		matches []interface{}
+	}{
+		{"$", nil}, // the root
+		{"$..PayloadType", []interface{}{"Configuration", "com.apple.wifi.managed", "com.apple.vpn.managed", "com.apple.wifi.managed"}},
+		{"$.PayloadContent[*].SSID_STR", []interface{}{"Office", "Guest"}},
+		{"$.PayloadContent.*.Priority", []interface{}{uint64(10), uint64(5)}},
+		{"$.PayloadContent[2].SSID_STR", []interface{}{"Guest"}},
+		{"$.PayloadContent[3]", []interface{}{}},
+		{`$["com.example"][1]`, []interface{}{"b"}},
+		{`$..[1]`, []interface{}{map[string]interface{}{
+			"PayloadType": "com.apple.vpn.managed",
+			"Priority":    uint64(5),
+			"VPN":         map[string]interface{}{"AuthName": "user"},
+		}, "b"}},
+		{"$..AuthName", []interface{}{"user"}},
+		{`$.PayloadContent[?(@.PayloadType == "com.apple.wifi.managed")].SSID_STR`, []interface{}{"Office", "Guest"}},
+		{`$.PayloadContent[?(@.PayloadType != "com.apple.wifi.managed")].PayloadType`, []interface{}{"com.apple.vpn.managed"}},
+		{`$.PayloadContent[?(@.Priority > 5)].PayloadType`, []interface{}{"com.apple.wifi.managed"}},
+		{`$.PayloadContent[?(@.Priority <= 5 || @.Hidden == true)].PayloadType`, []interface{}{"com.apple.vpn.managed", "com.apple.wifi.managed"}},
+		{`$.PayloadContent[?(@.SSID_STR && !@.Hidden)].SSID_STR`, []interface{}{"Office"}},
+		{`$.PayloadContent[?(@.VPN.AuthName)].PayloadType`, []interface{}{"com.apple.vpn.managed"}},
+		{`$.PayloadContent[?(@.Priority == "10")]`, []interface{}{}},
+		{`$..[?(@ == "a")]`, []interface{}{"a"}},
+	}
+
+	var v interface{}
+	if err := NewDecoder(bytes.NewReader([]byte(queryTestDocument))).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		test := test
+		subtest(t, test.query, func(t *testing.T) {
+			matches, err := Query(v, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := test.matches
+			if expected == nil {
+				expected = []interface{}{v}
+			}
+			if len(matches) != 0 || len(expected) != 0 {
+				if !reflect.DeepEqual(matches, expected) {
+					t.Errorf("expected %#v, got %#v", expected, matches)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryOrderedDict(t *testing.T) {
+	var v interface{}
+	d := NewDecoder(bytes.NewReader([]byte(queryTestDocument)))
+	d.UseOrderedDict()
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := Query(v, `$..*[?(@.PayloadType == "com.apple.wifi.managed")].SSID_STR`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{"Office", "Guest"}; !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %#v, got %#v", expected, matches)
+	}
+}
+
+func TestInvalidQuery(t *testing.T) {
+	for _, query := range []string{"Payloads", "$.", "$..", "$.[0]", "$[", "$[x]", `$["a]`, "$[?(@.a == )]", "$[?(@.a == 1]", "$[?(@.a)", "$[*"} {
+		if _, err := CompileQuery(query); err == nil {
+			t.Errorf("CompileQuery(%q) succeeded; expected an error", query)
+		}
+	}
+}
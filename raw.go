@@ -0,0 +1,36 @@
+package plist
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// RawValue is a raw encoded property list value. It can be used to delay decoding part of a property list,
+// or to embed a precomputed encoding in another property list.
+//
+// When unmarshaled into, a RawValue receives its value as a complete, standalone property list document
+// in the same format as the document it was decoded from. It may later be decoded with Unmarshal.
+//
+// When marshaled, the property list document held by a RawValue is decoded and its value is embedded
+// in place; it may be in any format. A nil RawValue is treated like any other nil value and omitted.
+type RawValue []byte
+
+var rawValueType = reflect.TypeOf(RawValue(nil))
+
+func (p *Encoder) marshalRawValue(raw RawValue) cfValue {
+	if raw == nil {
+		return nil
+	}
+
+	pval, err := NewDecoder(bytes.NewReader(raw)).parse()
+	if err != nil {
+		panic(err)
+	}
+	return pval
+}
+
+func (p *Decoder) unmarshalRawValue(pval cfValue, val reflect.Value) {
+	buf := &bytes.Buffer{}
+	newGeneratorForFormat(buf, p.Format).generateDocument(pval)
+	val.SetBytes(buf.Bytes())
+}
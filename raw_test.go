@@ -0,0 +1,82 @@
+package plist
+
+import (
+	"reflect"
+	"testing"
+)
+
+type rawEnvelope struct {
+	Type    string
+	Payload RawValue
+}
+
+type rawPayload struct {
+	Name  string
+	Count int
+}
+
+func TestRawValueRoundTrip(t *testing.T) {
+	expected := rawPayload{Name: "hello", Count: 3}
+	for _, format := range []int{XMLFormat, BinaryFormat, OpenStepFormat, GNUStepFormat} {
+		subtest(t, FormatNames[format], func(t *testing.T) {
+			doc, err := Marshal(map[string]interface{}{"Type": "payload", "Payload": expected}, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var env rawEnvelope
+			if _, err := Unmarshal(doc, &env); err != nil {
+				t.Fatal(err)
+			}
+			if env.Type != "payload" || len(env.Payload) == 0 {
+				t.Fatalf("Unexpected envelope %#v", env)
+			}
+
+			var payload rawPayload
+			payloadFormat, err := Unmarshal(env.Payload, &payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != expected {
+				t.Errorf("Expected %#v, received %#v", expected, payload)
+			}
+			if format != OpenStepFormat && format != GNUStepFormat && payloadFormat != format {
+				t.Errorf("Expected payload in %s format, received %s", FormatNames[format], FormatNames[payloadFormat])
+			}
+
+			redoc, err := Marshal(&env, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reenv map[string]interface{}
+			var orig map[string]interface{}
+			Unmarshal(redoc, &reenv)
+			Unmarshal(doc, &orig)
+			if !reflect.DeepEqual(orig, reenv) {
+				t.Logf("Expected: %#v", orig)
+				t.Logf("Received: %#v", reenv)
+				t.Fail()
+			}
+		})
+	}
+}
+
+func TestRawValueMarshal(t *testing.T) {
+	doc, err := Marshal(map[string]interface{}{"a": RawValue(`(1,2)`), "b": RawValue(nil)}, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var val map[string]interface{}
+	if _, err := Unmarshal(doc, &val); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"a": []interface{}{"1", "2"}}
+	if !reflect.DeepEqual(expected, val) {
+		t.Errorf("Expected %#v, received %#v", expected, val)
+	}
+
+	if _, err := Marshal(RawValue(`bplist00`), XMLFormat); err == nil {
+		t.Error("Expected error marshaling invalid raw value, received nothing.")
+	}
+}
@@ -0,0 +1,428 @@
+package plist
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	// Register the hashes used by signed profiles.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// A SignedProfile is a property list, such as a configuration profile, wrapped in a CMS (PKCS #7) SignedData
+// structure, as produced by Apple's profile signing tools and by openssl smime -sign -nodetach.
+type SignedProfile struct {
+	// Content is the signed property list.
+	Content []byte
+
+	// Certificates holds the certificates included with the signatures, which usually include the signers'.
+	Certificates []*x509.Certificate
+
+	signers []cmsSignerInfo
+	eType   asn1.ObjectIdentifier
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+)
+
+var cmsDigestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerialNumber struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// IsSignedProfile reports whether data holds a CMS SignedData structure, rather than a bare property list.
+func IsSignedProfile(data []byte) bool {
+	// Every property list format begins with a printable character, or a byte order mark; DER begins with 0x30.
+	if len(data) == 0 || data[0] != 0x30 {
+		return false
+	}
+	_, err := parseCMSContentInfo(data)
+	return err == nil
+}
+
+func parseCMSContentInfo(data []byte) (*cmsContentInfo, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, err
+	}
+	var ci cmsContentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after signed data")
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("content type %v is not signed data", ci.ContentType)
+	}
+	return &ci, nil
+}
+
+// ParseSignedProfile unwraps the property list signed by the CMS SignedData structure in data, which may be
+// encoded in DER or BER. The signatures are not verified; see Verify.
+func ParseSignedProfile(data []byte) (*SignedProfile, error) {
+	ci, err := parseCMSContentInfo(data)
+	if err != nil {
+		return nil, fmt.Errorf("plist: invalid signed profile: %w", err)
+	}
+
+	var sd cmsSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("plist: invalid signed profile: %w", err)
+	}
+	if sd.EncapContentInfo.Content == nil {
+		return nil, errors.New("plist: signed profile does not contain its content (it has a detached signature)")
+	}
+
+	p := &SignedProfile{Content: sd.EncapContentInfo.Content, signers: sd.SignerInfos, eType: sd.EncapContentInfo.ContentType}
+	if len(sd.Certificates.Bytes) > 0 {
+		if p.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, fmt.Errorf("plist: invalid certificate in signed profile: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Verify checks every signature on the profile, and that each signer's certificate chains to a root in opts.Roots
+// (or the system's roots, if opts.Roots is nil), using the profile's other certificates as intermediates. If
+// opts.KeyUsages is empty, any extended key usage is accepted, rather than only server authentication.
+// Verify returns the signers' certificates.
+//
+// Signatures made with RSA (PKCS #1 v1.5) or ECDSA keys, over SHA-1, SHA-256, SHA-384 or SHA-512 digests, are
+// supported.
+func (p *SignedProfile) Verify(opts x509.VerifyOptions) ([]*x509.Certificate, error) {
+	if len(p.signers) == 0 {
+		return nil, errors.New("plist: profile is not signed")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range p.Certificates {
+		intermediates.AddCert(cert)
+	}
+	opts.Intermediates = intermediates
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	signers := make([]*x509.Certificate, len(p.signers))
+	for i, si := range p.signers {
+		cert, err := p.verifySigner(&si)
+		if err != nil {
+			return nil, fmt.Errorf("plist: signature %d: %w", i, err)
+		}
+		if _, err := cert.Verify(opts); err != nil {
+			return nil, fmt.Errorf("plist: signature %d: %w", i, err)
+		}
+		signers[i] = cert
+	}
+	return signers, nil
+}
+
+// verifySigner checks the signature in si, and returns the certificate that made it.
+func (p *SignedProfile) verifySigner(si *cmsSignerInfo) (*x509.Certificate, error) {
+	cert, err := p.signerCertificate(si.SID)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := cmsDigestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return nil, fmt.Errorf("unsupported digest algorithm %v", si.DigestAlgorithm.Algorithm)
+	}
+	h := hash.New()
+	h.Write(p.Content)
+	digest := h.Sum(nil)
+
+	signed := p.Content
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		if err := checkSignedAttributes(si.SignedAttrs.Bytes, p.eType, digest); err != nil {
+			return nil, err
+		}
+		// The signature covers the DER encoding of the attributes as a SET, rather than as the implicitly tagged
+		// field in which they are stored.
+		signed = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, hash, digest, si.Signature)
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(si.Signature, &sig); err != nil {
+			return nil, fmt.Errorf("invalid ECDSA signature: %w", err)
+		}
+		if !ecdsa.Verify(pub, digest, sig.R, sig.S) {
+			err = errors.New("ECDSA verification failure")
+		}
+	default:
+		err = fmt.Errorf("unsupported public key type %T", pub)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// signerCertificate returns the certificate named by the signer identifier sid.
+func (p *SignedProfile) signerCertificate(sid asn1.RawValue) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range p.Certificates {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+		return nil, fmt.Errorf("no certificate with subject key identifier %x", sid.Bytes)
+	}
+
+	var ias cmsIssuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("invalid signer identifier: %w", err)
+	}
+	for _, cert := range p.Certificates {
+		if cert.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) {
+			return cert, nil
+		}
+	}
+	return nil, fmt.Errorf("no certificate with serial number %v", ias.Serial)
+}
+
+// checkSignedAttributes checks that the signed attributes in attrs name the content type eType, and hold digest.
+func checkSignedAttributes(attrs []byte, eType asn1.ObjectIdentifier, digest []byte) error {
+	var sawType, sawDigest bool
+	for len(attrs) > 0 {
+		var attr cmsAttribute
+		var err error
+		if attrs, err = asn1.Unmarshal(attrs, &attr); err != nil {
+			return fmt.Errorf("invalid signed attribute: %w", err)
+		}
+
+		switch {
+		case attr.Type.Equal(oidContentType):
+			var t asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &t); err != nil || !t.Equal(eType) {
+				return errors.New("signed content type does not match the content")
+			}
+			sawType = true
+		case attr.Type.Equal(oidMessageDigest):
+			var d []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &d); err != nil || !bytes.Equal(d, digest) {
+				return errors.New("signed message digest does not match the content")
+			}
+			sawDigest = true
+		}
+	}
+	if !sawType || !sawDigest {
+		return errors.New("signed attributes lack the content type or message digest")
+	}
+	return nil
+}
+
+// UnmarshalProfile works like Unmarshal, but first unwraps data if it is a signed profile (see IsSignedProfile).
+// If opts is not nil, the profile must be signed, and its signatures are verified as by SignedProfile.Verify.
+func UnmarshalProfile(data []byte, v interface{}, opts *x509.VerifyOptions) (format int, err error) {
+	if IsSignedProfile(data) {
+		p, err := ParseSignedProfile(data)
+		if err != nil {
+			return InvalidFormat, err
+		}
+		if opts != nil {
+			if _, err := p.Verify(*opts); err != nil {
+				return InvalidFormat, err
+			}
+		}
+		data = p.Content
+	} else if opts != nil {
+		return InvalidFormat, errors.New("plist: profile is not signed")
+	}
+	return Unmarshal(data, v)
+}
+
+// berToDER converts a BER encoding, which may use indefinite lengths and constructed strings, to DER, so that it
+// can be read by encoding/asn1. Other BER liberties, such as non-minimal lengths, are not corrected.
+func berToDER(ber []byte) ([]byte, error) {
+	out, rest, err := berElementToDER(ber, 0)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, rest...), nil
+}
+
+// maxBERDepth limits the nesting of elements converted by berToDER.
+const maxBERDepth = 64
+
+// berElementToDER converts the element at the start of ber to DER, and returns it and the remaining input.
+func berElementToDER(ber []byte, depth int) ([]byte, []byte, error) {
+	if depth > maxBERDepth {
+		return nil, nil, errors.New("asn1: elements nested too deeply")
+	}
+	if len(ber) < 2 {
+		return nil, nil, errors.New("asn1: truncated element")
+	}
+
+	// Identifier octets: a single byte, or the high-tag-number form.
+	id := 1
+	if ber[0]&0x1f == 0x1f {
+		for id < len(ber) && ber[id]&0x80 != 0 {
+			id++
+		}
+		id++
+		if id >= len(ber) {
+			return nil, nil, errors.New("asn1: truncated tag")
+		}
+	}
+	tag, constructed := ber[:id], ber[0]&0x20 != 0
+
+	if ber[id] == 0x80 {
+		// Indefinite length: the contents are elements, ending with two zero bytes.
+		if !constructed {
+			return nil, nil, errors.New("asn1: indefinite length on a primitive element")
+		}
+		rest := ber[id+1:]
+		var children [][]byte
+		for {
+			if len(rest) >= 2 && rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			if len(rest) == 0 {
+				return nil, nil, errors.New("asn1: missing end of contents")
+			}
+			child, r, err := berElementToDER(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			children = append(children, child)
+			rest = r
+		}
+		return derElement(tag, children), rest, nil
+	}
+
+	length, n, err := berLength(ber[id:])
+	if err != nil {
+		return nil, nil, err
+	}
+	start := id + n
+	if uint64(len(ber)-start) < length {
+		return nil, nil, errors.New("asn1: truncated contents")
+	}
+	end := start + int(length)
+	if !constructed {
+		return ber[:end], ber[end:], nil
+	}
+
+	var children [][]byte
+	for contents := ber[start:end]; len(contents) > 0; {
+		child, r, err := berElementToDER(contents, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child)
+		contents = r
+	}
+	return derElement(tag, children), ber[end:], nil
+}
+
+// berLength reads the definite length at the start of b, and returns it and the number of bytes it occupies.
+func berLength(b []byte) (uint64, int, error) {
+	if b[0]&0x80 == 0 {
+		return uint64(b[0]), 1, nil
+	}
+	n := int(b[0] & 0x7f)
+	if n > 8 || len(b) < 1+n {
+		return 0, 0, errors.New("asn1: invalid length")
+	}
+	var length uint64
+	for _, c := range b[1 : 1+n] {
+		length = length<<8 | uint64(c)
+	}
+	return length, 1 + n, nil
+}
+
+// derElement encodes a constructed element with the identifier octets tag and the DER-encoded children. The
+// segments of a constructed OCTET STRING are joined into a primitive one, as DER requires.
+func derElement(tag []byte, children [][]byte) []byte {
+	if len(tag) == 1 && tag[0] == 0x24 {
+		var contents []byte
+		for _, child := range children {
+			_, n, _ := berLength(child[1:])
+			contents = append(contents, child[1+n:]...)
+		}
+		return derPrimitive([]byte{0x04}, contents)
+	}
+	var contents []byte
+	for _, child := range children {
+		contents = append(contents, child...)
+	}
+	return derPrimitive(tag, contents)
+}
+
+// derPrimitive encodes an element with the identifier octets tag and the given contents.
+func derPrimitive(tag, contents []byte) []byte {
+	out := append([]byte(nil), tag...)
+	if n := len(contents); n < 0x80 {
+		out = append(out, byte(n))
+	} else {
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, contents...)
+}
@@ -0,0 +1,220 @@
+package plist
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var (
+	oidData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSHA256      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSASHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// testCertificate creates a certificate for key, signed by parent (or self-signed, if parent is nil).
+func testCertificate(t *testing.T, name string, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func mustMarshalASN1(t *testing.T, v interface{}) []byte {
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// signTestProfile wraps content in a SignedData structure signed by key, whose certificate is signer.
+// If ber is set, the structure uses indefinite lengths and a constructed OCTET STRING, as openssl may write.
+func signTestProfile(t *testing.T, content []byte, signer *x509.Certificate, key *ecdsa.PrivateKey, certs []*x509.Certificate, ber bool) []byte {
+	digest := sha256.Sum256(content)
+	attr := func(oid asn1.ObjectIdentifier, value interface{}) []byte {
+		return mustMarshalASN1(t, cmsAttribute{Type: oid, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshalASN1(t, value)}})
+	}
+	attrs := append(attr(oidContentType, oidData), attr(oidMessageDigest, digest[:])...)
+
+	set := mustMarshalASN1(t, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	attrDigest := sha256.Sum256(set)
+	signature, err := key.Sign(rand.Reader, attrDigest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	si := cmsSignerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: mustMarshalASN1(t, cmsIssuerAndSerialNumber{asn1.RawValue{FullBytes: signer.RawIssuer}, signer.SerialNumber})},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSASHA256},
+		Signature:          signature,
+	}
+
+	var rawCerts []byte
+	for _, cert := range certs {
+		rawCerts = append(rawCerts, cert.Raw...)
+	}
+
+	encap := mustMarshalASN1(t, cmsEncapContentInfo{ContentType: oidData, Content: content})
+	if ber {
+		// [0] EXPLICIT OCTET STRING, with the string split in two, all with indefinite lengths.
+		half := len(content) / 2
+		octets := append([]byte{0x24, 0x80}, derPrimitive([]byte{0x04}, content[:half])...)
+		octets = append(append(octets, derPrimitive([]byte{0x04}, content[half:])...), 0, 0)
+		explicit := append(append([]byte{0xa0, 0x80}, octets...), 0, 0)
+		encap = append(append([]byte{0x30, 0x80}, append(mustMarshalASN1(t, oidData), explicit...)...), 0, 0)
+	}
+
+	sd := mustMarshalASN1(t, struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+		EncapContentInfo asn1.RawValue
+		Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+		SignerInfos      []cmsSignerInfo `asn1:"set"`
+	}{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: asn1.RawValue{FullBytes: encap},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: rawCerts},
+		SignerInfos:      []cmsSignerInfo{si},
+	})
+
+	ci := mustMarshalASN1(t, cmsContentInfo{ContentType: oidSignedData, Content: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd}})
+	if ber {
+		// Rewrite the outermost SEQUENCE with an indefinite length.
+		_, n, _ := berLength(ci[1:])
+		ci = append(append([]byte{0x30, 0x80}, ci[1+n:]...), 0, 0)
+	}
+	return ci
+}
+
+func TestSignedProfile(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := testCertificate(t, "Test CA", caKey, nil, nil)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf := testCertificate(t, "Profile Signer", leafKey, ca, caKey)
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other := testCertificate(t, "Other CA", otherKey, nil, nil)
+
+	content := []byte(`<plist><dict><key>PayloadIdentifier</key><string>com.example.profile</string></dict></plist>`)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other)
+
+	for _, ber := range []bool{false, true} {
+		name := "DER"
+		if ber {
+			name = "BER"
+		}
+		ber := ber
+		subtest(t, name, func(t *testing.T) {
+			signed := signTestProfile(t, content, leaf, leafKey, []*x509.Certificate{leaf, ca}, ber)
+
+			if !IsSignedProfile(signed) || IsSignedProfile(content) {
+				t.Error("expected IsSignedProfile to distinguish the signed profile from its content")
+			}
+
+			p, err := ParseSignedProfile(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(p.Content) != string(content) {
+				t.Errorf("expected content %q, got %q", content, p.Content)
+			}
+
+			signers, err := p.Verify(x509.VerifyOptions{Roots: roots})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(signers) != 1 || !signers[0].Equal(leaf) {
+				t.Errorf("expected the leaf certificate to be the signer, got %v", signers)
+			}
+
+			if _, err := p.Verify(x509.VerifyOptions{Roots: otherRoots}); err == nil {
+				t.Error("expected verification against another root to fail")
+			}
+
+			p.Content = append([]byte(nil), p.Content...)
+			p.Content[len(p.Content)-2] = 'X'
+			if _, err := p.Verify(x509.VerifyOptions{Roots: roots}); err == nil {
+				t.Error("expected verification of altered content to fail")
+			}
+		})
+	}
+
+	subtest(t, "UnmarshalProfile", func(t *testing.T) {
+		signed := signTestProfile(t, content, leaf, leafKey, []*x509.Certificate{leaf}, false)
+
+		var v struct{ PayloadIdentifier string }
+		if _, err := UnmarshalProfile(signed, &v, &x509.VerifyOptions{Roots: roots}); err != nil {
+			t.Fatal(err)
+		}
+		if v.PayloadIdentifier != "com.example.profile" {
+			t.Errorf("expected com.example.profile, got %q", v.PayloadIdentifier)
+		}
+
+		// The intermediate is missing, so the chain cannot be built to the other root.
+		if _, err := UnmarshalProfile(signed, &v, &x509.VerifyOptions{Roots: otherRoots}); err == nil {
+			t.Error("expected verification against another root to fail")
+		}
+		if _, err := UnmarshalProfile(content, &v, &x509.VerifyOptions{Roots: roots}); err == nil {
+			t.Error("expected an unsigned profile to fail verification")
+		}
+		if _, err := UnmarshalProfile(content, &v, nil); err != nil {
+			t.Errorf("expected an unsigned profile to be decoded without verification, got %v", err)
+		}
+	})
+}
+
+func TestBERToDER(t *testing.T) {
+	tests := []struct {
+		name     string
+		ber, der []byte
+	}{
+		{"DER", []byte{0x30, 0x03, 0x02, 0x01, 0x05}, []byte{0x30, 0x03, 0x02, 0x01, 0x05}},
+		{"Indefinite", []byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00}, []byte{0x30, 0x03, 0x02, 0x01, 0x05}},
+		{"Constructed OCTET STRING", []byte{0x24, 0x80, 0x04, 0x01, 'a', 0x24, 0x03, 0x04, 0x01, 'b', 0x00, 0x00}, []byte{0x04, 0x02, 'a', 'b'}},
+	}
+	for _, test := range tests {
+		der, err := berToDER(test.ber)
+		if err != nil || string(der) != string(test.der) {
+			t.Errorf("%s: expected % x, got % x (%v)", test.name, test.der, der, err)
+		}
+	}
+
+	for _, ber := range [][]byte{{0x30}, {0x30, 0x80, 0x02, 0x01, 0x05}, {0x04, 0x80, 0x00, 0x00}, {0x30, 0x05, 0x02, 0x01}} {
+		if _, err := berToDER(ber); err == nil {
+			t.Errorf("expected an error for % x", ber)
+		}
+	}
+}
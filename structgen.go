@@ -0,0 +1,300 @@
+package plist
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// GenerateStructs reads a sample property list from r, in any format, and returns the source of a Go file in
+// package pkg that declares a type named name, with which the sample (and documents like it) may be unmarshaled.
+//
+// Each dictionary in the sample becomes a struct type, with a field for each of its keys, named by a plist tag;
+// the types of nested dictionaries are named after the fields that hold them. The dictionaries in an array are
+// described by a single struct type, with a field for every key found in any of them; fields that are absent from
+// some of them are tagged omitempty. Integers are stored as int64, unless they are too large, and values of
+// differing types in an array are stored as interface{}.
+//
+// The generated types are a starting point: a sample seldom holds every key a format allows, and dictionaries
+// that are used as maps, rather than records, are better described by map types.
+func GenerateStructs(r io.Reader, pkg, name string) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.UseOrderedDict()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	g := &structGenerator{names: make(map[string]bool), imports: make(map[string]bool)}
+	root := inferSampleType(v)
+	if root.kind == sampleStruct {
+		g.name(root, name)
+	} else {
+		// The element types of a root array are named after the items it holds.
+		g.names[name] = true
+		g.name(root, name+"Item")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if g.imports["time"] || g.imports["plist"] {
+		fmt.Fprintln(&out, "import (")
+		if g.imports["time"] {
+			fmt.Fprintln(&out, "\t\"time\"")
+		}
+		if g.imports["plist"] {
+			fmt.Fprintln(&out, "\n\tplist \"github.com/wartiva/go-plist\"")
+		}
+		fmt.Fprintln(&out, ")")
+		fmt.Fprintln(&out)
+	}
+	if root.kind != sampleStruct {
+		fmt.Fprintf(&out, "type %s %s\n\n", name, g.typeString(root))
+	}
+	for _, s := range g.structs {
+		fmt.Fprintf(&out, "type %s struct {\n", s.name)
+		for _, f := range s.fields {
+			if strings.Contains(f.key, ",") {
+				fmt.Fprintf(&out, "\t// %q cannot be named by a plist tag.\n", f.key)
+				continue
+			}
+			tag := f.key
+			if f.count < s.count {
+				tag += ",omitempty"
+			} else if tag == "-" {
+				tag += ","
+			}
+			fmt.Fprintf(&out, "\t%s %s %s\n", f.name, g.typeString(f.typ), structTagLiteral(`plist:`+strconv.Quote(tag)))
+		}
+		fmt.Fprintln(&out, "}")
+		fmt.Fprintln(&out)
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("plist: formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+type sampleKind int
+
+const (
+	sampleUnknown sampleKind = iota // the elements of an empty array
+	sampleString
+	sampleBool
+	sampleInt
+	sampleUint
+	sampleFloat
+	sampleData
+	sampleDate
+	sampleUID
+	sampleStruct
+	sampleSlice
+	sampleAny
+)
+
+var sampleTypeNames = map[sampleKind]string{
+	sampleUnknown: "interface{}",
+	sampleString:  "string",
+	sampleBool:    "bool",
+	sampleInt:     "int64",
+	sampleUint:    "uint64",
+	sampleFloat:   "float64",
+	sampleData:    "[]byte",
+	sampleDate:    "time.Time",
+	sampleUID:     "plist.UID",
+	sampleAny:     "interface{}",
+}
+
+// A sampleType is the type inferred for the values found at one place in a sample.
+type sampleType struct {
+	kind   sampleKind
+	elem   *sampleType    // for slices
+	fields []*sampleField // for structs, in the order in which they were found
+	count  int            // for structs, the number of dictionaries described
+	name   string         // for structs, once named
+}
+
+type sampleField struct {
+	key   string
+	name  string // the Go field name, once named
+	typ   *sampleType
+	count int // the number of dictionaries in which the key was found
+}
+
+func inferSampleType(v interface{}) *sampleType {
+	switch v := v.(type) {
+	case string:
+		return &sampleType{kind: sampleString}
+	case bool:
+		return &sampleType{kind: sampleBool}
+	case int64:
+		return &sampleType{kind: sampleInt}
+	case uint64:
+		if v > math.MaxInt64 {
+			return &sampleType{kind: sampleUint}
+		}
+		return &sampleType{kind: sampleInt}
+	case float64:
+		return &sampleType{kind: sampleFloat}
+	case []byte:
+		return &sampleType{kind: sampleData}
+	case time.Time:
+		return &sampleType{kind: sampleDate}
+	case UID:
+		return &sampleType{kind: sampleUID}
+	case *OrderedDict:
+		t := &sampleType{kind: sampleStruct, count: 1}
+		for i, k := range v.Keys {
+			t.fields = append(t.fields, &sampleField{key: k, typ: inferSampleType(v.Values[i]), count: 1})
+		}
+		return t
+	case []interface{}:
+		elem := &sampleType{kind: sampleUnknown}
+		for _, e := range v {
+			elem = mergeSampleTypes(elem, inferSampleType(e))
+		}
+		return &sampleType{kind: sampleSlice, elem: elem}
+	}
+	return &sampleType{kind: sampleAny}
+}
+
+// mergeSampleTypes returns a type that describes the values of both a and b.
+func mergeSampleTypes(a, b *sampleType) *sampleType {
+	switch {
+	case a.kind == sampleUnknown:
+		return b
+	case b.kind == sampleUnknown:
+		return a
+	case a.kind == b.kind:
+		switch a.kind {
+		case sampleStruct:
+			merged := &sampleType{kind: sampleStruct, count: a.count + b.count}
+			fields := make(map[string]*sampleField)
+			for _, f := range append(append([]*sampleField(nil), a.fields...), b.fields...) {
+				if existing, ok := fields[f.key]; ok {
+					existing.typ = mergeSampleTypes(existing.typ, f.typ)
+					existing.count += f.count
+					continue
+				}
+				f := &sampleField{key: f.key, typ: f.typ, count: f.count}
+				fields[f.key] = f
+				merged.fields = append(merged.fields, f)
+			}
+			return merged
+		case sampleSlice:
+			return &sampleType{kind: sampleSlice, elem: mergeSampleTypes(a.elem, b.elem)}
+		}
+		return a
+	}
+
+	// Reals and integers, or negative integers and those too large for an int64, are all stored as float64.
+	numeric := map[sampleKind]bool{sampleInt: true, sampleUint: true, sampleFloat: true}
+	if numeric[a.kind] && numeric[b.kind] {
+		return &sampleType{kind: sampleFloat}
+	}
+	return &sampleType{kind: sampleAny}
+}
+
+type structGenerator struct {
+	structs []*sampleType // in the order in which they are declared
+	names   map[string]bool
+	imports map[string]bool
+}
+
+// name names t, which describes the value of the field (or type) named name, and the struct types within it.
+func (g *structGenerator) name(t *sampleType, name string) {
+	switch t.kind {
+	case sampleStruct:
+		t.name = g.uniqueName(name)
+		g.structs = append(g.structs, t)
+
+		used := make(map[string]bool)
+		for _, f := range t.fields {
+			f.name = goFieldName(f.key)
+			for i := 2; used[f.name]; i++ {
+				f.name = goFieldName(f.key) + strconv.Itoa(i)
+			}
+			used[f.name] = true
+		}
+		for _, f := range t.fields {
+			if !strings.Contains(f.key, ",") {
+				g.name(f.typ, t.name+f.name)
+			}
+		}
+	case sampleSlice:
+		g.name(t.elem, name)
+	case sampleDate:
+		g.imports["time"] = true
+	case sampleUID:
+		g.imports["plist"] = true
+	}
+}
+
+func (g *structGenerator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+func (g *structGenerator) typeString(t *sampleType) string {
+	switch t.kind {
+	case sampleStruct:
+		return t.name
+	case sampleSlice:
+		return "[]" + g.typeString(t.elem)
+	}
+	return sampleTypeNames[t.kind]
+}
+
+// goFieldName returns an exported Go identifier for the dictionary key k: its words, each beginning with a capital
+// letter, with everything but letters and digits removed.
+func goFieldName(k string) string {
+	var name strings.Builder
+	upper := true
+	for _, r := range k {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		name.WriteRune(r)
+	}
+
+	s := name.String()
+	if s == "" {
+		return "Field"
+	}
+	if first := []rune(s)[0]; !unicode.IsUpper(first) {
+		// Digits, and letters without case, cannot begin an exported identifier.
+		s = "X" + s
+	}
+	return s
+}
+
+// structTagLiteral returns tag as a Go string literal: a raw string literal, if tag permits it.
+func structTagLiteral(tag string) string {
+	if strings.ContainsAny(tag, "`\r") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
@@ -0,0 +1,98 @@
+package plist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateStructs(t *testing.T) {
+	sample := `<plist><dict>
+		<key>PayloadIdentifier</key><string>com.example</string>
+		<key>payload-version</key><integer>1</integer>
+		<key>Created</key><date>2020-01-01T00:00:00Z</date>
+		<key>PayloadContent</key><array>
+			<dict><key>PayloadType</key><string>a</string><key>Priority</key><integer>-1</integer></dict>
+			<dict><key>PayloadType</key><string>b</string><key>Priority</key><real>1.5</real><key>Extra</key><dict><key>Z</key><data>AA==</data></dict></dict>
+		</array>
+		<key>Mixed</key><array><string>a</string><true/></array>
+		<key>a,b</key><string>unnamable</string>
+		<key>-</key><string>dash</string>
+		<key>Large</key><integer>18446744073709551615</integer>
+	</dict></plist>`
+
+	expected := `package config
+
+import (
+	"time"
+)
+
+type Profile struct {
+	PayloadIdentifier string                  ` + "`plist:\"PayloadIdentifier\"`" + `
+	PayloadVersion    int64                   ` + "`plist:\"payload-version\"`" + `
+	Created           time.Time               ` + "`plist:\"Created\"`" + `
+	PayloadContent    []ProfilePayloadContent ` + "`plist:\"PayloadContent\"`" + `
+	Mixed             []interface{}           ` + "`plist:\"Mixed\"`" + `
+	// "a,b" cannot be named by a plist tag.
+	Field string ` + "`plist:\"-,\"`" + `
+	Large uint64 ` + "`plist:\"Large\"`" + `
+}
+
+type ProfilePayloadContent struct {
+	PayloadType string                     ` + "`plist:\"PayloadType\"`" + `
+	Priority    float64                    ` + "`plist:\"Priority\"`" + `
+	Extra       ProfilePayloadContentExtra ` + "`plist:\"Extra,omitempty\"`" + `
+}
+
+type ProfilePayloadContentExtra struct {
+	Z []byte ` + "`plist:\"Z\"`" + `
+}
+`
+	src, err := GenerateStructs(strings.NewReader(sample), "config", "Profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, src)
+	}
+}
+
+func TestGenerateStructsRootArray(t *testing.T) {
+	doc, err := Marshal([]interface{}{
+		map[string]interface{}{"Label": "a", "Ref": UID(1)},
+		map[string]interface{}{"Label": "b", "KeepAlive": true},
+	}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := GenerateStructs(bytes.NewReader(doc), "jobs", "Jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range []string{
+		`plist "github.com/wartiva/go-plist"`,
+		"type Jobs []JobsItem",
+		"type JobsItem struct {",
+		"\tLabel     string    `plist:\"Label\"`",
+		"\tRef       plist.UID `plist:\"Ref,omitempty\"`",
+		"\tKeepAlive bool      `plist:\"KeepAlive,omitempty\"`",
+	} {
+		if !strings.Contains(string(src), decl) {
+			t.Errorf("expected %q in\n%s", decl, src)
+		}
+	}
+}
+
+func TestGenerateStructsDashKey(t *testing.T) {
+	// The tag generated for the key "-" names it, rather than skipping the field.
+	var v struct {
+		Field string `plist:"-,"`
+	}
+	if _, err := Unmarshal([]byte(`<plist><dict><key>-</key><string>dash</string></dict></plist>`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Field != "dash" {
+		t.Errorf("expected dash, got %q", v.Field)
+	}
+}
@@ -1,10 +1,12 @@
 package plist
 
 import (
-	"encoding/hex"
+	"context"
+	"fmt"
 	"io"
 	"strconv"
-	"time"
+	"strings"
+	"unicode/utf16"
 )
 
 type textPlistGenerator struct {
@@ -13,70 +15,233 @@ type textPlistGenerator struct {
 
 	quotableTable *characterSet
 
-	indent string
-	depth  int
+	// strict restricts GNUStep output to the syntax accepted by GNUstep's own parser.
+	strict bool
+	// preserveNumbers writes numbers in the text with which they were read or marshaled; see Encoder.PreserveNumberText.
+	preserveNumbers bool
+	// arrayStarted records, for each array being written in strict mode, whether it has any elements yet.
+	arrayStarted []bool
+
+	indent     string
+	newline    string
+	endNewline bool
+	hexGroup   int  // the number of bytes in each group of hexadecimal data, or 0 for a single run
+	hexUpper   bool // see EncoderOptions.HexDataUppercase
+	quoteAll   bool // see EncoderOptions.QuoteAllStrings
+	quote      byte // the character that strings are quoted with
+	nonASCII   int  // see EncoderOptions.TextNonASCII
+	dateLayout string
+	keepZones  bool // see Encoder.PreserveTimeZones
+	depth      int
+
+	ctx context.Context // checked before each value is written, if set
 
 	dictKvDelimiter, dictEntryDelimiter, arrayDelimiter []byte
 }
 
-var (
-	textPlistTimeLayout = "2006-01-02 15:04:05 -0700"
-	padding             = "0000"
-)
+var textPlistTimeLayout = "2006-01-02 15:04:05 -0700"
 
 func (p *textPlistGenerator) generateDocument(pval cfValue) {
+	p.beginDocument()
 	p.writePlistValue(pval)
+	p.endDocument()
 }
 
-func (p *textPlistGenerator) plistQuotedString(str string) string {
+// Text property lists have no header or trailer.
+func (p *textPlistGenerator) beginDocument() {}
+
+func (p *textPlistGenerator) endDocument() {
+	if p.endNewline {
+		io.WriteString(p.writer, p.newline)
+	}
+}
+
+func (p *textPlistGenerator) beginDictionary() {
+	p.writer.Write([]byte(`{`))
+	p.deltaIndent(1)
+}
+
+func (p *textPlistGenerator) endDictionary() {
+	p.deltaIndent(-1)
+	p.writeIndent()
+	p.writer.Write([]byte(`}`))
+}
+
+func (p *textPlistGenerator) beginArray() {
+	p.writer.Write([]byte(`(`))
+	p.deltaIndent(1)
+	if p.strict {
+		p.arrayStarted = append(p.arrayStarted, false)
+	}
+}
+
+func (p *textPlistGenerator) endArray() {
+	if p.strict {
+		p.arrayStarted = p.arrayStarted[:len(p.arrayStarted)-1]
+	}
+	p.deltaIndent(-1)
+	p.writeIndent()
+	p.writer.Write([]byte(`)`))
+}
+
+// writeComment writes each line of comment as a block comment of its own.
+func (p *textPlistGenerator) writeComment(comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		p.writeIndent()
+		io.WriteString(p.writer, "/* "+strings.Replace(line, "*/", "* /", -1)+" */")
+	}
+}
+
+func (p *textPlistGenerator) writeKey(k string) {
+	p.writeIndent()
+	io.WriteString(p.writer, p.plistQuotedString(k))
+	p.writer.Write(p.dictKvDelimiter)
+}
+
+func (p *textPlistGenerator) beginElement(inDictionary bool) {
+	if !inDictionary {
+		if p.strict {
+			// Array elements are separated, rather than terminated, by delimiters.
+			n := len(p.arrayStarted) - 1
+			if p.arrayStarted[n] {
+				p.writer.Write(p.arrayDelimiter)
+			}
+			p.arrayStarted[n] = true
+		}
+		p.writeIndent()
+	}
+}
+
+func (p *textPlistGenerator) endElement(inDictionary bool) {
+	if inDictionary {
+		p.writer.Write(p.dictEntryDelimiter)
+	} else if !p.strict {
+		p.writer.Write(p.arrayDelimiter)
+	}
+}
+
+// strictQuotedString quotes str for GNUstep's parser: only letters, digits and a few punctuation characters
+// are left unquoted, and every character outside printable ASCII is escaped.
+func (p *textPlistGenerator) strictQuotedString(str string) string {
 	if str == "" {
 		return `""`
 	}
-	s := ""
+
+	var b strings.Builder
 	quot := false
 	for _, r := range str {
-		if r > 0xFF {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '/':
+			b.WriteRune(r)
+		case r == '"' || r == '\\':
 			quot = true
-			s += `\U`
-			us := strconv.FormatInt(int64(r), 16)
-			s += padding[len(us):]
-			s += us
-		} else if r > 0x7F {
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
 			quot = true
-			s += `\`
-			us := strconv.FormatInt(int64(r), 8)
-			s += padding[1+len(us):]
-			s += us
-		} else {
-			c := uint8(r)
-			if p.quotableTable.ContainsByte(c) {
-				quot = true
+			b.WriteString(`\n`)
+		case r == '\t':
+			quot = true
+			b.WriteString(`\t`)
+		case r == '\r':
+			quot = true
+			b.WriteString(`\r`)
+		case r >= 0x20 && r < 0x7F:
+			quot = true
+			b.WriteRune(r)
+		default:
+			quot = true
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\U%04x`, u)
 			}
+		}
+	}
 
-			switch c {
-			case '\a':
-				s += `\a`
-			case '\b':
-				s += `\b`
-			case '\v':
-				s += `\v`
-			case '\f':
-				s += `\f`
-			case '\\':
-				s += `\\`
-			case '"':
-				s += `\"`
-			case '\t', '\r', '\n':
-				fallthrough
+	if quot || p.quoteAll {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
+
+func (p *textPlistGenerator) plistQuotedString(str string) string {
+	if p.strict {
+		return p.strictQuotedString(str)
+	}
+	quote := string(p.quote)
+	if str == "" {
+		return quote + quote
+	}
+
+	var b strings.Builder
+	quot := false
+	for _, r := range str {
+		if r > 0x7F {
+			quot = true
+			switch {
+			case p.nonASCII == NonASCIIVerbatim:
+				b.WriteRune(r)
+			case r > 0xFF || p.nonASCII == NonASCIIUnicode:
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\U%04x`, u)
+				}
 			default:
-				s += string(c)
+				fmt.Fprintf(&b, `\%03o`, r)
 			}
+			continue
+		}
+
+		c := uint8(r)
+		if p.quotableTable.ContainsByte(c) {
+			quot = true
+		}
+
+		switch c {
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\\':
+			b.WriteString(`\\`)
+		case p.quote:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if quot || p.quoteAll {
+		return quote + b.String() + quote
+	}
+	return b.String()
+}
+
+// quotable returns the characters that q requires to be quoted: those in ASCII other than letters, digits and the
+// characters in q.Unquoted, and any that the text property list parser does not read without quotes.
+func (q *TextQuoting) quotable() *characterSet {
+	table := gsQuotable
+	for c := 0; c < 0x80; c++ {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte(q.Unquoted, byte(c)) >= 0:
+		default:
+			table[c/64] |= 1 << uint(c%64)
 		}
 	}
-	if quot {
-		s = `"` + s + `"`
+	return &table
+}
+
+func (q *TextQuoting) quote() byte {
+	if q.Quote == '\'' {
+		return '\''
 	}
-	return s
+	return '"'
 }
 
 func (p *textPlistGenerator) deltaIndent(depthDelta int) {
@@ -92,54 +257,67 @@ func (p *textPlistGenerator) writeIndent() {
 		return
 	}
 	if len(p.indent) > 0 {
-		p.writer.Write([]byte("\n"))
+		io.WriteString(p.writer, p.newline)
 		for i := 0; i < p.depth; i++ {
 			io.WriteString(p.writer, p.indent)
 		}
 	}
 }
 
+// writeHexData writes b, which begins pos bytes into its data, in hexadecimal, separating each group of
+// p.hexGroup bytes from the next with a space.
+func (p *textPlistGenerator) writeHexData(b []byte, pos int64) {
+	digits := "0123456789abcdef"
+	if p.hexUpper {
+		digits = "0123456789ABCDEF"
+	}
+
+	out := make([]byte, 0, 2*len(b)+len(b)/4+1)
+	for _, c := range b {
+		if p.hexGroup > 0 && pos > 0 && pos%int64(p.hexGroup) == 0 {
+			out = append(out, ' ')
+		}
+		out = append(out, digits[c>>4], digits[c&0xF])
+		pos++
+	}
+	p.writer.Write(out)
+}
+
 func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 	if pval == nil {
 		return
 	}
+	checkContext(p.ctx)
 
 	switch pval := pval.(type) {
 	case *cfDictionary:
 		pval.sort()
-		p.writer.Write([]byte(`{`))
-		p.deltaIndent(1)
+		p.beginDictionary()
 		for i, k := range pval.keys {
-			p.writeIndent()
-			io.WriteString(p.writer, p.plistQuotedString(k))
-			p.writer.Write(p.dictKvDelimiter)
+			p.writeComment(pval.comments[k])
+			p.writeKey(k)
 			p.writePlistValue(pval.values[i])
-			p.writer.Write(p.dictEntryDelimiter)
+			p.endElement(true)
 		}
-		p.deltaIndent(-1)
-		p.writeIndent()
-		p.writer.Write([]byte(`}`))
+		p.endDictionary()
 	case *cfArray:
-		p.writer.Write([]byte(`(`))
-		p.deltaIndent(1)
+		p.beginArray()
 		for _, v := range pval.values {
-			p.writeIndent()
+			p.beginElement(false)
 			p.writePlistValue(v)
-			p.writer.Write(p.arrayDelimiter)
+			p.endElement(false)
 		}
-		p.deltaIndent(-1)
-		p.writeIndent()
-		p.writer.Write([]byte(`)`))
+		p.endArray()
 	case cfString:
 		io.WriteString(p.writer, p.plistQuotedString(string(pval)))
 	case *cfNumber:
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`<*I`))
 		}
-		if pval.signed {
-			io.WriteString(p.writer, strconv.FormatInt(int64(pval.value), 10))
+		if p.preserveNumbers && pval.text != "" {
+			io.WriteString(p.writer, pval.text)
 		} else {
-			io.WriteString(p.writer, strconv.FormatUint(pval.value, 10))
+			io.WriteString(p.writer, pval.String())
 		}
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`>`))
@@ -148,8 +326,12 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`<*R`))
 		}
-		// GNUstep does not differentiate between 32/64-bit floats.
-		io.WriteString(p.writer, strconv.FormatFloat(pval.value, 'g', -1, 64))
+		if p.preserveNumbers && pval.text != "" {
+			io.WriteString(p.writer, pval.text)
+		} else {
+			// GNUstep does not differentiate between 32/64-bit floats.
+			io.WriteString(p.writer, strconv.FormatFloat(pval.value, 'g', -1, 64))
+		}
 		if p.format == GNUStepFormat {
 			p.writer.Write([]byte(`>`))
 		}
@@ -168,43 +350,65 @@ func (p *textPlistGenerator) writePlistValue(pval cfValue) {
 			}
 		}
 	case cfData:
-		var hexencoded [9]byte
-		var l int
-		var asc = 9
-		hexencoded[8] = ' '
-
 		p.writer.Write([]byte(`<`))
-		b := []byte(pval)
-		for i := 0; i < len(b); i += 4 {
-			l = i + 4
-			if l >= len(b) {
-				l = len(b)
-				// We no longer need the space - or the rest of the buffer.
-				// (we used >= above to get this part without another conditional :P)
-				asc = (l - i) * 2
+		p.writeHexData([]byte(pval), 0)
+		p.writer.Write([]byte(`>`))
+	case *cfDataReader:
+		p.writer.Write([]byte(`<`))
+		buf := make([]byte, 4096)
+		r := pval.open()
+		for pos := int64(0); pos < pval.size; {
+			b := buf
+			if pval.size-pos < int64(len(b)) {
+				b = b[:pval.size-pos]
 			}
-			// Fill the buffer (only up to 8 characters, to preserve the space we implicitly include
-			// at the end of every encode)
-			hex.Encode(hexencoded[:8], b[i:l])
-			io.WriteString(p.writer, string(hexencoded[:asc]))
+			pval.readFull(r, b)
+			p.writeHexData(b, pos)
+			pos += int64(len(b))
 		}
 		p.writer.Write([]byte(`>`))
 	case cfDate:
 		if p.format == GNUStepFormat {
+			layout := p.dateLayout
+			if p.strict {
+				// GNUstep only reads dates in its own layout.
+				layout = textPlistTimeLayout
+			}
 			p.writer.Write([]byte(`<*D`))
-			io.WriteString(p.writer, time.Time(pval).In(time.UTC).Format(textPlistTimeLayout))
+			io.WriteString(p.writer, pval.in(p.keepZones).Format(layout))
 			p.writer.Write([]byte(`>`))
 		} else {
-			io.WriteString(p.writer, p.plistQuotedString(time.Time(pval).In(time.UTC).Format(textPlistTimeLayout)))
+			io.WriteString(p.writer, p.plistQuotedString(pval.in(p.keepZones).Format(p.dateLayout)))
 		}
 	case cfUID:
 		p.writePlistValue(pval.toDict())
 	}
 }
 
-func (p *textPlistGenerator) Indent(i string) {
-	p.indent = i
-	if i == "" {
+func (p *textPlistGenerator) DateLayout(layout string) {
+	if layout == "" {
+		layout = textPlistTimeLayout
+	}
+	p.dateLayout = layout
+}
+
+func (p *textPlistGenerator) Options(o EncoderOptions) {
+	p.indent = o.Indent
+	p.newline = o.newline()
+	p.endNewline = o.FinalNewline
+	p.hexGroup, p.hexUpper = o.HexDataGroup, o.HexDataUppercase
+	p.quoteAll, p.nonASCII = o.QuoteAllStrings, o.TextNonASCII
+	if q := o.TextQuoting; q != nil {
+		p.quotableTable = q.quotable()
+		p.quote = q.quote()
+	}
+	switch {
+	case o.HexDataGroup == 0:
+		p.hexGroup = 4
+	case o.HexDataGroup < 0:
+		p.hexGroup = 0
+	}
+	if o.Indent == "" {
 		p.dictKvDelimiter = []byte(`=`)
 	} else {
 		// For pretty-printing
@@ -221,6 +425,9 @@ func newTextPlistGenerator(w io.Writer, format int) *textPlistGenerator {
 		writer:             mustWriter{w},
 		format:             format,
 		quotableTable:      table,
+		quote:              '"',
+		newline:            "\n",
+		dateLayout:         textPlistTimeLayout,
 		dictKvDelimiter:    []byte(`=`),
 		arrayDelimiter:     []byte(`,`),
 		dictEntryDelimiter: []byte(`;`),
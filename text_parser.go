@@ -6,6 +6,7 @@
 package plist
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"io"
 	"io/ioutil"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf16"
@@ -21,12 +23,32 @@ import (
 
 type textPlistParser struct {
 	reader io.Reader
+	buffer []byte // if set, the document is parsed from buffer rather than read from reader
 	format int
 
 	input string
 	start int
 	pos   int
 	width int
+
+	dateLayouts      []string
+	disallowComments bool
+
+	keepComments bool
+	comments     []string // the comments skipped since they were last taken
+
+	depth    int // the number of dictionaries and arrays being parsed
+	maxDepth int // the limit on depth, or 0 for none
+
+	ctx context.Context // checked before each value is parsed, if set
+}
+
+// enter records the start of a dictionary or array, and panics if it is nested too deeply.
+func (p *textPlistParser) enter() {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		panic(maxDepthError(p.maxDepth))
+	}
 }
 
 func convertU16(buffer []byte, bo binary.ByteOrder) (string, error) {
@@ -73,14 +95,22 @@ func (p *textPlistParser) parseDocument() (pval cfValue, parseError error) {
 			if _, ok := r.(runtime.Error); ok {
 				panic(r)
 			}
+			err := r.(error)
+			if _, ok := err.(*SyntaxError); !ok && p.input != "" {
+				// Errors raised once the document has been read, such as maxDepthError, have a position too.
+				err = p.syntaxError(err)
+			}
 			// Wrap all non-invalid-plist errors.
-			parseError = plistParseError{"text", r.(error)}
+			parseError = plistParseError{"text", err}
 		}
 	}()
 
-	buffer, err := ioutil.ReadAll(p.reader)
-	if err != nil {
-		panic(err)
+	var err error
+	buffer := p.buffer
+	if buffer == nil {
+		if buffer, err = ioutil.ReadAll(p.reader); err != nil {
+			panic(err)
+		}
 	}
 
 	p.input, err = guessEncodingAndConvert(buffer)
@@ -111,9 +141,14 @@ func (p *textPlistParser) parseDocument() (pval cfValue, parseError error) {
 const eof rune = -1
 
 func (p *textPlistParser) error(e string, args ...interface{}) {
-	line := strings.Count(p.input[:p.pos], "\n")
-	char := p.pos - strings.LastIndex(p.input[:p.pos], "\n") - 1
-	panic(fmt.Errorf("%s at line %d character %d", fmt.Sprintf(e, args...), line, char))
+	panic(p.syntaxError(fmt.Errorf(e, args...)))
+}
+
+// syntaxError returns err annotated with the current position in the document.
+func (p *textPlistParser) syntaxError(err error) *SyntaxError {
+	line := strings.Count(p.input[:p.pos], "\n") + 1
+	column := p.pos - strings.LastIndex(p.input[:p.pos], "\n")
+	return &SyntaxError{Line: line, Column: column, Err: err}
 }
 
 func (p *textPlistParser) next() rune {
@@ -184,20 +219,38 @@ func (p *textPlistParser) scanCharactersNotInSet(ch *characterSet) {
 	p.backup()
 }
 
+// atComment reports whether the input at the current position begins a comment.
+// It is an error to encounter a comment if comments have been disallowed.
+func (p *textPlistParser) atComment() bool {
+	rest := p.input[p.pos:]
+	if !strings.HasPrefix(rest, "//") && !strings.HasPrefix(rest, "/*") {
+		return false
+	}
+	if p.disallowComments {
+		p.error("unexpected comment")
+	}
+	return true
+}
+
 func (p *textPlistParser) skipWhitespaceAndComments() {
 	for {
 		p.scanCharactersInSet(&whitespace)
+		if !p.atComment() {
+			break
+		}
+		start := p.pos
 		if strings.HasPrefix(p.input[p.pos:], "//") {
 			p.scanCharactersNotInSet(&newlineCharacterSet)
-		} else if strings.HasPrefix(p.input[p.pos:], "/*") {
-			if x := strings.Index(p.input[p.pos:], "*/"); x >= 0 {
-				p.pos += x + 2 // skip the */ as well
-				continue       // consume more whitespace
-			} else {
-				p.error("unexpected eof in block comment")
+			if p.keepComments {
+				p.comments = append(p.comments, p.input[start+2:p.pos])
+			}
+		} else if x := strings.Index(p.input[p.pos+2:], "*/"); x >= 0 {
+			p.pos += x + 4 // skip the /* and */ as well
+			if p.keepComments {
+				p.comments = append(p.comments, p.input[start+2:p.pos-2])
 			}
 		} else {
-			break
+			p.error("unexpected eof in block comment")
 		}
 	}
 	p.ignore()
@@ -246,7 +299,7 @@ func (p *textPlistParser) parseHexDigits(max int) uint64 {
 // the \ has already been consumed
 func (p *textPlistParser) parseEscape() string {
 	var s string
-	switch p.next() {
+	switch c := p.next(); c {
 	case 'a':
 		s = "\a"
 	case 'b':
@@ -263,12 +316,25 @@ func (p *textPlistParser) parseEscape() string {
 		s = "\n"
 	case '\\':
 		s = `\`
-	case '"':
-		s = `"`
+	case '"', '\'':
+		s = string(c)
 	case 'x': // This is our extension.
 		s = string(rune(p.parseHexDigits(2)))
 	case 'u', 'U': // 'u' is a GNUstep extension.
-		s = string(rune(p.parseHexDigits(4)))
+		r := rune(p.parseHexDigits(4))
+		if r >= 0xD800 && r < 0xDC00 {
+			// A high surrogate escape may be followed by its low surrogate, encoding a character outside the BMP.
+			// Any other escape that follows is left to be decoded on its own.
+			rest := p.input[p.pos:]
+			if len(rest) >= 6 && rest[0] == '\\' && (rest[1] == 'u' || rest[1] == 'U') {
+				if low, err := strconv.ParseUint(rest[2:6], 16, 16); err == nil && low >= 0xDC00 && low < 0xE000 {
+					p.next()
+					p.next()
+					r = utf16.DecodeRune(r, rune(p.parseHexDigits(4)))
+				}
+			}
+		}
+		s = string(r)
 	case '0', '1', '2', '3', '4', '5', '6', '7':
 		p.backup() // we've already consumed one of the digits
 		s = string(rune(p.parseOctalDigits(3)))
@@ -279,21 +345,21 @@ func (p *textPlistParser) parseEscape() string {
 	return s
 }
 
-// the " has already been consumed
-func (p *textPlistParser) parseQuotedString() cfString {
-	p.ignore() // ignore the "
+// the opening quote, which is either " or ', has already been consumed
+func (p *textPlistParser) parseQuotedString(quote byte) cfString {
+	p.ignore() // ignore the quote
 
 	slowPath := false
 	s := ""
 
 	for {
-		p.scanUntilAny(`"\`)
+		p.scanUntilAny(string(quote) + `\`)
 		switch p.peek() {
 		case eof:
 			p.error("unexpected eof in quoted string")
-		case '"':
+		case rune(quote):
 			section := p.emit()
-			p.pos++ // skip "
+			p.pos++ // skip the quote
 			if !slowPath {
 				return cfString(section)
 			} else {
@@ -322,14 +388,19 @@ func (p *textPlistParser) parseUnquotedString() cfString {
 // the { has already been consumed
 func (p *textPlistParser) parseDictionary(ignoreEof bool) cfValue {
 	//p.ignore() // ignore the {
+	p.enter()
 	var keypv cfValue
 	keys := make([]string, 0, 32)
 	values := make([]cfValue, 0, 32)
+	var comments map[string]string
 outer:
 	for {
+		// Comments preceding a key belong to its entry; any others are discarded.
+		p.comments = nil
 		p.skipWhitespaceAndComments()
+		comment := joinComments(p.comments)
 
-		switch p.next() {
+		switch r := p.next(); r {
 		case eof:
 			if !ignoreEof {
 				p.error("unexpected eof in dictionary")
@@ -337,8 +408,8 @@ outer:
 			fallthrough
 		case '}':
 			break outer
-		case '"':
-			keypv = p.parseQuotedString()
+		case '"', '\'':
+			keypv = p.parseQuotedString(byte(r))
 		default:
 			p.backup()
 			keypv = p.parseUnquotedString()
@@ -371,15 +442,23 @@ outer:
 
 		keys = append(keys, string(keypv.(cfString)))
 		values = append(values, val)
+		if comment != "" {
+			if comments == nil {
+				comments = make(map[string]string)
+			}
+			comments[string(keypv.(cfString))] = comment
+		}
 	}
 
-	dict := &cfDictionary{keys: keys, values: values}
+	p.depth--
+	dict := &cfDictionary{keys: keys, values: values, comments: comments}
 	return dict.maybeUID(p.format == OpenStepFormat)
 }
 
 // the ( has already been consumed
 func (p *textPlistParser) parseArray() *cfArray {
 	//p.ignore() // ignore the (
+	p.enter()
 	values := make([]cfValue, 0, 32)
 outer:
 	for {
@@ -404,7 +483,8 @@ outer:
 		}
 		values = append(values, pval)
 	}
-	return &cfArray{values}
+	p.depth--
+	return &cfArray{values: values}
 }
 
 // the <* have already been consumed
@@ -449,16 +529,12 @@ func (p *textPlistParser) parseGNUStepValue() cfValue {
 		if len(v) == 0 {
 			p.error("truncated GNUStep extended value")
 		}
-		if v[0] == '-' {
-			n := mustParseInt(v, 10, 64)
-			return &cfNumber{signed: true, value: uint64(n)}
-		} else {
-			n := mustParseUint(v, 10, 64)
-			return &cfNumber{signed: false, value: n}
-		}
+		n := mustParseIntegerLiteral(v)
+		n.text = v
+		return n
 	case 'R':
 		n := mustParseFloat(v, 64)
-		return &cfReal{wide: true, value: n} // TODO(DH) 32/64
+		return &cfReal{wide: true, value: n, text: v} // TODO(DH) 32/64
 	case 'B':
 		if len(v) == 0 {
 			p.error("truncated GNUStep extended value")
@@ -467,11 +543,14 @@ func (p *textPlistParser) parseGNUStepValue() cfValue {
 		return cfBoolean(b)
 	case 'D':
 		t, err := time.Parse(textPlistTimeLayout, v)
+		if err != nil && len(p.dateLayouts) > 0 {
+			t, err = parseDate(v, p.dateLayouts)
+		}
 		if err != nil {
 			p.error(err.Error())
 		}
 
-		return cfDate(t.In(time.UTC))
+		return cfDate(t)
 	}
 	// We should never get here; we checked the type above
 	return nil
@@ -518,9 +597,16 @@ func (p *textPlistParser) parseHexData() cfData {
 			p.ignore()
 			return cfData(buf[:i])
 		// Apple and GNUstep both want these in pairs. We are a bit more lax.
-		// GS accepts comments too, but that seems like a lot of work.
 		case ' ', '\t', '\n', '\r', '\u2028', '\u2029':
 			continue
+		case '/':
+			// GNUstep accepts comments between the digits, too.
+			p.backup()
+			if p.atComment() {
+				p.skipWhitespaceAndComments()
+				continue
+			}
+			p.next()
 		}
 
 		buf[i] <<= 4
@@ -547,10 +633,11 @@ func (p *textPlistParser) parseHexData() cfData {
 }
 
 func (p *textPlistParser) parsePlistValue() cfValue {
+	checkContext(p.ctx)
 	for {
 		p.skipWhitespaceAndComments()
 
-		switch p.next() {
+		switch r := p.next(); r {
 		case eof:
 			return &cfDictionary{}
 		case '<':
@@ -565,8 +652,8 @@ func (p *textPlistParser) parsePlistValue() cfValue {
 				p.backup()
 				return p.parseHexData()
 			}
-		case '"':
-			return p.parseQuotedString()
+		case '"', '\'':
+			return p.parseQuotedString(byte(r))
 		case '{':
 			return p.parseDictionary(false)
 		case '(':
@@ -38,3 +38,23 @@ func BenchmarkGNUStepParse(b *testing.B) {
 }
 
 // The valid text test cases have been merged into the common/global test cases.
+
+func TestTextSurrogateEscapes(t *testing.T) {
+	tests := []struct {
+		doc, expected string
+	}{
+		{`"\Ud83d\Ude00"`, "\U0001F600"},
+		{`"\UD800\U0041"`, "\uFFFDA"},
+		{`"\UD800\UD800\UDC00"`, "\uFFFD\U00010000"},
+		{`"\UD800x"`, "\uFFFDx"},
+	}
+
+	for _, test := range tests {
+		var decoded string
+		if _, err := Unmarshal([]byte(test.doc), &decoded); err != nil {
+			t.Errorf("%s: %v", test.doc, err)
+		} else if decoded != test.expected {
+			t.Errorf("%s: expected %q, received %q", test.doc, test.expected, decoded)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+)
+
+// A Token holds a value of one of these types:
+//
+//	DictStart, DictEnd, ArrayStart, ArrayEnd
+//	Key, for dictionary keys
+//	string, bool, uint64, int64, float32, float64
+//	[]byte, for plist data (or DataReader; see Decoder.StreamData)
+//	time.Time, for plist dates
+//	UID, for "CoreFoundation Keyed Archiver UIDs"
+//
+// Integers and reals are reported with the same types Unmarshal would use when decoding into an empty interface.
+type Token interface{}
+
+// DictStart marks the beginning of a dictionary. It is followed by zero or more Key and value pairs, then a DictEnd.
+type DictStart struct{}
+
+// DictEnd marks the end of a dictionary.
+type DictEnd struct{}
+
+// ArrayStart marks the beginning of an array. It is followed by zero or more values, then an ArrayEnd.
+type ArrayStart struct{}
+
+// ArrayEnd marks the end of an array.
+type ArrayEnd struct{}
+
+// A Key is a dictionary key. Every Key token is immediately followed by its value.
+type Key string
+
+// An EventHandler receives callbacks from Parse for every value and container boundary in a property list.
+// Returning an error from any callback stops parsing; Parse returns that error unchanged.
+type EventHandler interface {
+	BeginDict() error
+	EndDict() error
+	BeginArray() error
+	EndArray() error
+
+	// Key is called for each dictionary key, immediately before the callbacks for its value.
+	Key(key string) error
+
+	// Value is called for every non-container value, with one of the types listed for Token.
+	Value(v interface{}) error
+}
+
+type tokenizer interface {
+	nextToken() (Token, error)
+}
+
+// Token returns the next property list token in the input stream.
+// At the end of the property list, Token returns nil, io.EOF.
+//
+// Token does not build the whole property list in memory for XML and binary property lists:
+// XML is read incrementally, and binary objects are only parsed as they are reached.
+// OpenStep and GNUStep property lists are parsed in full before the first token is returned.
+//
+// After the first call to Token, the Decoder's Format field will be set to one of the plist format constants.
+// Token and Decode should not be mixed on the same Decoder.
+func (p *Decoder) Token() (Token, error) {
+	if p.tokens == nil {
+		if err := p.beginTokens(); err != nil {
+			return nil, err
+		}
+	}
+	return p.tokens.nextToken()
+}
+
+// Parse reads a property list from r and reports its structure to handler as it is read.
+//
+// If r is also an io.Seeker, it is read incrementally as described for Decoder.Token;
+// otherwise, it is read into memory in its entirety so that its format can be detected.
+func Parse(r io.Reader, handler EventHandler) error {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		rs = bytes.NewReader(buf)
+	}
+
+	d := NewDecoder(rs)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case DictStart:
+			err = handler.BeginDict()
+		case DictEnd:
+			err = handler.EndDict()
+		case ArrayStart:
+			err = handler.BeginArray()
+		case ArrayEnd:
+			err = handler.EndArray()
+		case Key:
+			err = handler.Key(string(tok))
+		default:
+			err = handler.Value(tok)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Decoder) beginTokens() error {
+	header := make([]byte, 6)
+	p.reader.Read(header)
+	p.reader.Seek(p.start, 0)
+
+	if bytes.Equal(header, []byte("bplist")) {
+		bt := &bplistTokenizer{decoder: p, parser: p.newBplistParser()}
+		if err := bt.begin(); err != nil {
+			return err
+		}
+		p.tokens = bt
+		p.Format = BinaryFormat
+		return nil
+	}
+
+	xt := &xmlTokenizer{decoder: p, parser: newXMLPlistParser(p.reader)}
+	xt.parser.maxDepth = p.depthLimit()
+	xt.parser.dateLayouts = p.dateLayouts
+	tok, err := xt.nextToken()
+	if _, ok := err.(invalidPlistError); ok {
+		// Rewind: the XML parser might have exhausted the file.
+		p.reader.Seek(p.start, 0)
+		tp := newTextPlistParser(p.reader)
+		tp.maxDepth = p.depthLimit()
+		tp.dateLayouts = p.dateLayouts
+		tp.disallowComments = p.disallowComments
+		pval, err := tp.parseDocument()
+		if err != nil {
+			return err
+		}
+		p.tokens = &valueTokenizer{decoder: p, stack: []valueTokenFrame{{values: []cfValue{pval}}}}
+		p.Format = tp.format
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	xt.pending = tok
+	p.tokens = xt
+	p.Format = XMLFormat
+	return nil
+}
+
+type xmlTokenFrame struct {
+	element  string
+	wantsKey bool // only meaningful for dictionaries
+}
+
+type xmlTokenizer struct {
+	decoder *Decoder
+	parser  *xmlPlistParser
+	stack   []xmlTokenFrame
+	pending Token
+	done    bool
+}
+
+// valueEmitted must be called after any complete value has been produced.
+func (t *xmlTokenizer) valueEmitted() {
+	if n := len(t.stack); n > 0 && t.stack[n-1].element == "dict" {
+		t.stack[n-1].wantsKey = true
+	}
+	for _, f := range t.stack {
+		if f.element != "plist" {
+			return
+		}
+	}
+	t.done = true
+}
+
+func (t *xmlTokenizer) checkValueAllowed() {
+	if n := len(t.stack); n > 0 && t.stack[n-1].element == "dict" && t.stack[n-1].wantsKey {
+		panic(errors.New("missing key in dictionary"))
+	}
+}
+
+func (t *xmlTokenizer) nextToken() (tok Token, err error) {
+	if t.pending != nil {
+		tok, t.pending = t.pending, nil
+		return tok, nil
+	}
+
+	if t.done {
+		return nil, io.EOF
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			if _, ok := r.(invalidPlistError); ok {
+				err = r.(error)
+			} else {
+				err = plistParseError{"XML", t.parser.syntaxError(r.(error))}
+			}
+			t.done = true
+		}
+	}()
+
+	p := t.parser
+	for {
+		token, err := p.xmlDecoder.Token()
+		if err != nil {
+			if p.ntags == 0 {
+				panic(invalidPlistError{"XML", err})
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			panic(err)
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "plist":
+				p.ntags++
+				t.stack = append(t.stack, xmlTokenFrame{element: "plist"})
+			case "dict", "array":
+				t.checkValueAllowed()
+				p.ntags++
+				p.enter()
+				t.stack = append(t.stack, xmlTokenFrame{element: el.Name.Local, wantsKey: true})
+				if el.Name.Local == "dict" {
+					return DictStart{}, nil
+				}
+				return ArrayStart{}, nil
+			case "key":
+				n := len(t.stack)
+				if n == 0 || t.stack[n-1].element != "dict" {
+					panic(errors.New("encountered key outside of dictionary"))
+				}
+				if !t.stack[n-1].wantsKey {
+					panic(errors.New("missing value in dictionary"))
+				}
+				var k string
+				if err := p.xmlDecoder.DecodeElement(&k, &el); err != nil {
+					panic(err)
+				}
+				t.stack[n-1].wantsKey = false
+				return Key(k), nil
+			default:
+				t.checkValueAllowed()
+				pval := p.parseXMLElement(el)
+				t.valueEmitted()
+				return t.decoder.valueInterface(pval), nil
+			}
+		case xml.EndElement:
+			n := len(t.stack)
+			if n == 0 || t.stack[n-1].element != el.Name.Local {
+				panic(fmt.Errorf("unexpected end element %s", el.Name.Local))
+			}
+			f := t.stack[n-1]
+			t.stack = t.stack[:n-1]
+			if f.element != "plist" {
+				p.depth--
+			}
+			switch f.element {
+			case "dict":
+				if !f.wantsKey {
+					panic(errors.New("missing value in dictionary"))
+				}
+				t.valueEmitted()
+				return DictEnd{}, nil
+			case "array":
+				t.valueEmitted()
+				return ArrayEnd{}, nil
+			}
+		}
+	}
+}
+
+type bplistTokenFrame struct {
+	oid    uint64 // the index of the dictionary or array
+	dict   bool
+	refs   []uint64 // object references; for dictionaries, all keys followed by all values
+	pos    int
+	nitems int
+	inKey  bool
+}
+
+type bplistTokenizer struct {
+	decoder *Decoder
+	parser  *bplistParser
+	stack   []bplistTokenFrame
+	started bool
+	done    bool
+}
+
+func (t *bplistTokenizer) begin() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = plistParseError{"binary", r.(error)}
+		}
+	}()
+
+	t.parser.parseHeaderAndTrailer()
+	return nil
+}
+
+func (t *bplistTokenizer) objectToken(oid uint64) Token {
+	p := t.parser
+	off := p.offsetForObject(oid)
+	p.current = int64(oid)
+	defer func() { p.current = -1 }()
+
+	switch p.byteAt(off) & 0xF0 {
+	case bpTagDictionary, bpTagArray, bpTagOrderedSet, bpTagSet:
+		dict := p.byteAt(off)&0xF0 == bpTagDictionary
+		p.pushNestedObject(off)
+
+		cnt, start := p.countForTagAtOffset(off)
+		nrefs := cnt
+		if dict {
+			nrefs *= 2
+		}
+		if !p.contentsFit(start, nrefs, uint64(p.trailer.ObjectRefSize)) {
+			p.defect(DefectLength, start, -1, "list@0x%x length (%v) puts its end beyond the offset table at 0x%x", start, nrefs, p.trailer.OffsetTableOffset)
+		}
+
+		refs := make([]uint64, nrefs)
+		next := start
+		for i := range refs {
+			ref := next
+			refs[i], next = p.parseObjectRefAtOffset(next)
+			if refs[i] >= p.trailer.NumObjects {
+				p.defect(DefectReference, ref, -1, "invalid object#%d (max %d)", refs[i], p.trailer.NumObjects)
+			}
+		}
+
+		t.stack = append(t.stack, bplistTokenFrame{oid: oid, dict: dict, refs: refs, nitems: int(cnt)})
+		if dict {
+			return DictStart{}
+		}
+		return ArrayStart{}
+	}
+
+	return t.decoder.valueInterface(p.parseTagAtOffset(off))
+}
+
+func (t *bplistTokenizer) nextToken() (tok Token, err error) {
+	if t.done {
+		return nil, io.EOF
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = plistParseError{"binary", r.(error)}
+			t.done = true
+		}
+	}()
+
+	if !t.started {
+		t.started = true
+		tok = t.objectToken(t.parser.trailer.TopObject)
+		t.done = len(t.stack) == 0
+		return tok, nil
+	}
+
+	n := len(t.stack)
+	f := &t.stack[n-1]
+	if f.pos == f.nitems {
+		t.stack = t.stack[:n-1]
+		t.parser.popNestedObject()
+		t.done = len(t.stack) == 0
+		if f.dict {
+			return DictEnd{}, nil
+		}
+		return ArrayEnd{}, nil
+	}
+
+	if !f.dict {
+		f.pos++
+		return t.objectToken(f.refs[f.pos-1]), nil
+	}
+
+	if !f.inKey {
+		f.inKey = true
+		key, ok := t.parser.parseObject(f.refs[f.pos]).(cfString)
+		if !ok {
+			off := t.parser.offsetForObject(f.oid)
+			t.parser.defect(DefectObject, off, int64(f.oid), "dictionary@0x%x contains non-string key at index %d", off, f.pos)
+		}
+		return Key(key), nil
+	}
+
+	f.inKey = false
+	f.pos++
+	return t.objectToken(f.refs[f.nitems+f.pos-1]), nil
+}
+
+type valueTokenFrame struct {
+	dict   *cfDictionary
+	values []cfValue
+	pos    int
+	inKey  bool
+}
+
+// valueTokenizer produces tokens from an already-parsed property list.
+type valueTokenizer struct {
+	decoder *Decoder
+	stack   []valueTokenFrame
+}
+
+func (t *valueTokenizer) valueToken(pval cfValue) Token {
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		t.stack = append(t.stack, valueTokenFrame{dict: pval, values: pval.values})
+		return DictStart{}
+	case *cfArray:
+		t.stack = append(t.stack, valueTokenFrame{values: pval.values})
+		return ArrayStart{}
+	}
+	return t.decoder.valueInterface(pval)
+}
+
+func (t *valueTokenizer) nextToken() (Token, error) {
+	n := len(t.stack)
+	if n == 0 {
+		return nil, io.EOF
+	}
+
+	f := &t.stack[n-1]
+	if f.pos == len(f.values) {
+		dict := f.dict
+		t.stack = t.stack[:n-1]
+		if n == 1 {
+			// The outermost frame holds only the document's top-level value.
+			return nil, io.EOF
+		}
+		if dict != nil {
+			return DictEnd{}, nil
+		}
+		return ArrayEnd{}, nil
+	}
+
+	if f.dict != nil && !f.inKey {
+		f.inKey = true
+		return Key(f.dict.keys[f.pos]), nil
+	}
+
+	f.inKey = false
+	f.pos++
+	return t.valueToken(f.values[f.pos-1]), nil
+}
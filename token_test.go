@@ -0,0 +1,180 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// valueFromTokens rebuilds a value from a token stream in the same shape Unmarshal would produce for an empty interface.
+func valueFromTokens(t *testing.T, d *Decoder) interface{} {
+	tok, err := d.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch tok.(type) {
+	case DictStart:
+		m := make(map[string]interface{})
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := tok.(DictEnd); ok {
+				break
+			}
+			key, ok := tok.(Key)
+			if !ok {
+				t.Fatalf("expected Key, received %#v", tok)
+			}
+			m[string(key)] = valueFromTokens(t, d)
+		}
+
+		// XML streams cannot look ahead to discover UIDs.
+		if uid, ok := m[cfUIDMagic].(uint64); ok && len(m) == 1 {
+			return UID(uid)
+		}
+		return m
+	case ArrayStart:
+		a := make([]interface{}, 0)
+		for {
+			v := valueFromTokens(t, d)
+			if v == (ArrayEnd{}) {
+				break
+			}
+			a = append(a, v)
+		}
+		return a
+	}
+	return tok
+}
+
+func TestToken(t *testing.T) {
+	for _, test := range tests {
+		subtest(t, test.Name, func(t *testing.T) {
+			for format, doc := range test.Documents {
+				if test.SkipDecode[format] {
+					continue
+				}
+
+				var expected interface{}
+				expectedFormat, err := Unmarshal(doc, &expected)
+				if err != nil {
+					continue
+				}
+
+				subtest(t, FormatNames[format], func(t *testing.T) {
+					d := NewDecoder(bytes.NewReader(doc))
+					val := valueFromTokens(t, d)
+					if d.Format != expectedFormat {
+						t.Errorf("Expected format %s, received %s", FormatNames[expectedFormat], FormatNames[d.Format])
+					}
+
+					if !reflect.DeepEqual(expected, val) {
+						t.Logf("Expected: %#v\n", expected)
+						t.Logf("Received: %#v\n", val)
+						t.Fail()
+					}
+
+					if tok, err := d.Token(); err != io.EOF {
+						t.Errorf("Expected io.EOF, received %#v, %v", tok, err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestTokenSequence(t *testing.T) {
+	doc := xmlPreamble + `<plist version="1.0"><dict><key>a</key><array><integer>1</integer><true/></array><key>b</key><string>x</string></dict></plist>`
+	expected := []Token{DictStart{}, Key("a"), ArrayStart{}, uint64(1), true, ArrayEnd{}, Key("b"), "x", DictEnd{}}
+
+	d := NewDecoder(bytes.NewReader([]byte(doc)))
+	for i, exp := range expected {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatalf("token %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(exp, tok) {
+			t.Errorf("token %d: expected %#v, received %#v", i, exp, tok)
+		}
+	}
+}
+
+func TestInvalidTokens(t *testing.T) {
+	plists := []string{
+		`<dict><string>a</string></dict>`,
+		`<dict><key>a</key></dict>`,
+		`<dict><key>a</key><key>b</key></dict>`,
+		`<array><key>a</key></array>`,
+		`<array><string>a</string>`,
+		`(1, 2`,
+	}
+
+	for _, plist := range plists {
+		d := NewDecoder(bytes.NewReader([]byte(plist)))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		t.Logf("Error: %v", err)
+		if err == io.EOF {
+			t.Errorf("%s: Expected error, received io.EOF", plist)
+		}
+	}
+}
+
+type countingHandler struct {
+	dicts, arrays, keys, values int
+	stopAfter                   int
+}
+
+func (h *countingHandler) BeginDict() error  { h.dicts++; return nil }
+func (h *countingHandler) EndDict() error    { return nil }
+func (h *countingHandler) BeginArray() error { h.arrays++; return nil }
+func (h *countingHandler) EndArray() error   { return nil }
+func (h *countingHandler) Key(string) error  { h.keys++; return nil }
+func (h *countingHandler) Value(interface{}) error {
+	h.values++
+	if h.stopAfter > 0 && h.values == h.stopAfter {
+		return errors.New("stop")
+	}
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	h := &countingHandler{}
+	err := Parse(strings.NewReader(plistValueTreeAsXML), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.dicts == 0 || h.arrays == 0 || h.keys == 0 || h.values == 0 {
+		t.Errorf("Expected callbacks for every kind of event, received %+v", h)
+	}
+
+	// A plain io.Reader is buffered before parsing.
+	bh := &countingHandler{}
+	err = Parse(ioutil.NopCloser(bytes.NewReader(plistValueTreeAsBplist)), bh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *bh != *h {
+		t.Errorf("Binary and XML parsing yielded different events: %+v, %+v", bh, h)
+	}
+}
+
+func TestParseHandlerError(t *testing.T) {
+	h := &countingHandler{stopAfter: 2}
+	err := Parse(strings.NewReader(plistValueTreeAsXML), h)
+	if err == nil || err.Error() != "stop" {
+		t.Errorf("Expected handler error, received %v", err)
+	}
+	if h.values != 2 {
+		t.Errorf("Expected parsing to stop after 2 values, received %d", h.values)
+	}
+}
@@ -1,6 +1,8 @@
 package plist
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"strings"
 	"sync"
@@ -24,15 +26,81 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isZeroValue reports whether v is the zero value for its type. If v has an IsZero method, it is used instead.
+func isZeroValue(v reflect.Value) bool {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+	if receiver, can := implementsInterface(v, isZeroerType); can {
+		return receiver.(isZeroer).IsZero()
+	}
+
+	switch v.Kind() {
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZeroValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZeroValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(v.Float()) == 0
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return math.Float64bits(real(c)) == 0 && math.Float64bits(imag(c)) == 0
+	}
+	return false
+}
+
 // typeInfo holds details for the plist representation of a type.
 type typeInfo struct {
 	fields []fieldInfo
+
+	// inlineMap, if set, is a map field tagged ,inline or ,remain: it holds the dictionary entries
+	// that do not correspond to any other field.
+	inlineMap *fieldInfo
 }
 
 // fieldInfo holds details for the plist representation of a single field.
 type fieldInfo struct {
-	idx  []int
-	name string
+	idx      []int
+	name     string
+	inline   bool
+	asString bool
+	required bool
+	noText   bool // ignore the field type's TextMarshaler and TextUnmarshaler methods
+	typed    bool // write typed literals into GNUStep property lists, even with UntypedGNUStep
+	untyped  bool // write plain strings into GNUStep property lists, as with UntypedGNUStep
+	tagged   bool // name was given in the field's tag, rather than taken from the field
+	asDict   bool // a slice of Key/Value structs, encoded as a dictionary
+
+	durationFormat durationFormat
+
+	hasDefault   bool
+	defaultValue string
 
 	// omitEmptyDepthMap stores, for each entry in idx, whether at that level the user had specified
 	// omitempty. This matters for anonymous embedded structs, where the index path to a given field
@@ -47,88 +115,231 @@ type fieldInfo struct {
 	// As an optimization, we store it as a bit field. This means anonymous embedded structs more than 64 entries
 	// may forget their omitempty states.
 	omitEmptyDepthMap uint64
+
+	// omitZeroDepthMap is like omitEmptyDepthMap, but records omitzero.
+	omitZeroDepthMap uint64
 }
 
-var tinfoMap = make(map[reflect.Type]*typeInfo)
-var tinfoLock sync.RWMutex
+// tinfoMap caches the typeInfo of every type seen so far. It is written once per type and read on every
+// struct encoded or decoded, so a sync.Map allows lookups to proceed without contention.
+var tinfoMap sync.Map // map[typeInfoKey]*typeInfo
+
+// typeInfoKey identifies a cached typeInfo: a type's fields are described differently when json tags are used.
+type typeInfoKey struct {
+	typ      reflect.Type
+	jsonTags bool
+}
 
 // getTypeInfo returns the typeInfo structure with details necessary
-// for marshalling and unmarshalling typ.
-func getTypeInfo(typ reflect.Type) (*typeInfo, error) {
-	tinfoLock.RLock()
-	tinfo, ok := tinfoMap[typ]
-	tinfoLock.RUnlock()
-	if ok {
-		return tinfo, nil
-	}
-	tinfo = &typeInfo{}
+// for marshalling and unmarshalling typ. If jsonTags is set, fields without
+// a plist tag are described by their json tag instead (see Encoder.UseJSONTags).
+func getTypeInfo(typ reflect.Type, jsonTags bool) (*typeInfo, error) {
+	key := typeInfoKey{typ, jsonTags}
+	if tinfo, ok := tinfoMap.Load(key); ok {
+		return tinfo.(*typeInfo), nil
+	}
+	tinfo := &typeInfo{}
 	if typ.Kind() == reflect.Struct {
 		n := typ.NumField()
 		for i := 0; i < n; i++ {
 			f := typ.Field(i)
-			if f.PkgPath != "" || f.Tag.Get("plist") == "-" {
+			tag := fieldTag(&f, jsonTags)
+			if f.PkgPath != "" || tag == "-" {
 				continue // Private field
 			}
 
-			finfo, err := structFieldInfo(typ, &f)
+			finfo, err := structFieldInfo(typ, &f, tag)
 			if err != nil {
 				return nil, err
 			}
 
-			// For embedded structs, embed its fields.
-			if f.Anonymous {
+			// For embedded and inline structs, embed its fields.
+			if f.Anonymous || finfo.inline {
 				t := f.Type
 				if t.Kind() == reflect.Ptr {
 					t = t.Elem()
 				}
 				if t.Kind() == reflect.Struct {
-					inner, err := getTypeInfo(t)
+					inner, err := getTypeInfo(t, jsonTags)
 					if err != nil {
 						return nil, err
 					}
 					for _, innerFinfo := range inner.fields {
 						innerFinfo.idx = append(finfo.idx, innerFinfo.idx...)
 						innerFinfo.omitEmptyDepthMap = finfo.omitEmptyDepthMap | (innerFinfo.omitEmptyDepthMap << uint(len(finfo.idx)))
+						innerFinfo.omitZeroDepthMap = finfo.omitZeroDepthMap | (innerFinfo.omitZeroDepthMap << uint(len(finfo.idx)))
 						if err := addFieldInfo(typ, tinfo, &innerFinfo); err != nil {
 							return nil, err
 						}
 					}
+					if inner.inlineMap != nil {
+						innerMap := *inner.inlineMap
+						innerMap.idx = append(finfo.idx, innerMap.idx...)
+						if err := setInlineMap(typ, tinfo, &innerMap); err != nil {
+							return nil, err
+						}
+					}
 					continue
 				}
 			}
 
+			if finfo.inline {
+				if f.Type.Kind() != reflect.Map || f.Type.Key().Kind() != reflect.String {
+					return nil, fmt.Errorf("plist: ,inline field %s of type %v must be a struct or a map with string keys", f.Name, typ)
+				}
+				if err := setInlineMap(typ, tinfo, finfo); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
 			// Add the field if it doesn't conflict with other fields.
 			if err := addFieldInfo(typ, tinfo, finfo); err != nil {
 				return nil, err
 			}
 		}
 	}
-	tinfoLock.Lock()
-	tinfoMap[typ] = tinfo
-	tinfoLock.Unlock()
-	return tinfo, nil
+	// Another goroutine may have got here first; share its result.
+	cached, _ := tinfoMap.LoadOrStore(key, tinfo)
+	return cached.(*typeInfo), nil
 }
 
-// structFieldInfo builds and returns a fieldInfo for f.
-func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, error) {
-	finfo := &fieldInfo{idx: f.Index}
+// PrepareType computes and caches the field information used to encode and decode the type of v, and every
+// struct type reachable from it through fields, pointers, slices, arrays and maps. Otherwise, this happens when
+// each type is first encountered. PrepareType returns an error if any of these types has an invalid struct tag.
+//
+// The information is prepared both for Encoders and Decoders that use json tags (see Encoder.UseJSONTags) and for
+// those that do not.
+//
+// Calling PrepareType for known types at startup moves this work out of the first requests a server handles.
+func PrepareType(v interface{}) error {
+	for _, jsonTags := range []bool{false, true} {
+		if err := prepareType(reflect.TypeOf(v), jsonTags, make(map[reflect.Type]bool)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prepareType(typ reflect.Type, jsonTags bool, seen map[reflect.Type]bool) error {
+	if typ == nil || seen[typ] {
+		return nil
+	}
+	seen[typ] = true
 
-	// Split the tag from the xml namespace if necessary.
-	tag := f.Tag.Get("plist")
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return prepareType(typ.Elem(), jsonTags, seen)
+	case reflect.Struct:
+		tinfo, err := getTypeInfo(typ, jsonTags)
+		if err != nil {
+			return err
+		}
+		for i := range tinfo.fields {
+			if err := prepareType(typ.FieldByIndex(tinfo.fields[i].idx).Type, jsonTags, seen); err != nil {
+				return err
+			}
+		}
+		if tinfo.inlineMap != nil {
+			return prepareType(typ.FieldByIndex(tinfo.inlineMap.idx).Type, jsonTags, seen)
+		}
+	}
+	return nil
+}
+
+// fieldTag returns the tag that describes f: its plist tag or, if it has none and jsonTags is set, its json tag.
+// The name and the omitempty, omitzero and string options of a json tag mean the same in a plist tag; its other
+// options are dropped, so that they are not mistaken for plist flags. As in both kinds of tag "-," names a field
+// "-", rather than skipping it, the comma is kept.
+func fieldTag(f *reflect.StructField, jsonTags bool) string {
+	tag, ok := f.Tag.Lookup("plist")
+	if ok || !jsonTags {
+		return tag
+	}
+
+	tokens := strings.Split(f.Tag.Get("json"), ",")
+	kept := []string{tokens[0]}
+	if tokens[0] == "-" && len(tokens) > 1 {
+		kept = append(kept, "")
+	}
+	for _, opt := range tokens[1:] {
+		switch opt {
+		case "omitempty", "omitzero", "string":
+			kept = append(kept, opt)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// structFieldInfo builds and returns a fieldInfo for f, which tag describes.
+func structFieldInfo(typ reflect.Type, f *reflect.StructField, tag string) (*fieldInfo, error) {
+	finfo := &fieldInfo{idx: f.Index}
 
 	// Parse flags.
 	tokens := strings.Split(tag, ",")
 	tag = tokens[0]
 	if len(tokens) > 1 {
 		tag = tokens[0]
-		for _, flag := range tokens[1:] {
+		for i, flag := range tokens[1:] {
+			if strings.HasPrefix(flag, "default=") {
+				// The default value extends to the end of the tag, and so may contain commas.
+				finfo.hasDefault = true
+				finfo.defaultValue = strings.Join(tokens[i+1:], ",")[len("default="):]
+				break
+			}
+
 			switch flag {
 			case "omitempty":
 				finfo.omitEmptyDepthMap = 1 << uint(len(f.Index)-1)
+			case "omitzero":
+				finfo.omitZeroDepthMap = 1 << uint(len(f.Index)-1)
+			case "inline":
+				finfo.inline = true
+			case "dict":
+				t := f.Type
+				if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+					return nil, fmt.Errorf("plist: ,dict field %s of type %v must be a slice or array", f.Name, typ)
+				}
+				if keyIdx, _, ok := keyValueFields(t.Elem()); !ok || t.Elem().Field(keyIdx).Type.Kind() != reflect.String {
+					return nil, fmt.Errorf("plist: ,dict field %s of type %v must hold structs with a string Key and a Value", f.Name, typ)
+				}
+				finfo.asDict = true
+			case "remain":
+				// remain is inline, restricted to maps: it never embeds a struct's fields.
+				if f.Type.Kind() != reflect.Map || f.Type.Key().Kind() != reflect.String {
+					return nil, fmt.Errorf("plist: ,remain field %s of type %v must be a map with string keys", f.Name, typ)
+				}
+				finfo.inline = true
+			case "string":
+				finfo.asString = true
+			case "required":
+				finfo.required = true
+			case "notext":
+				finfo.noText = true
+			case "typed":
+				finfo.typed = true
+			case "untyped":
+				finfo.untyped = true
+			case "seconds":
+				finfo.durationFormat = durationSeconds
+			case "milliseconds":
+				finfo.durationFormat = durationMilliseconds
+			case "durationstring":
+				finfo.durationFormat = durationString
 			}
 		}
 	}
 
+	if finfo.durationFormat != durationNanoseconds {
+		t := f.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t != durationType {
+			return nil, fmt.Errorf("plist: field %s of type %v must be a time.Duration to use a duration flag", f.Name, typ)
+		}
+	}
+
 	if tag == "" {
 		// If the name part of the tag is completely empty,
 		// use the field name
@@ -137,9 +348,19 @@ func structFieldInfo(typ reflect.Type, f *reflect.StructField) (*fieldInfo, erro
 	}
 
 	finfo.name = tag
+	finfo.tagged = true
 	return finfo, nil
 }
 
+// key returns the dictionary key of the field: its name, or, if the name was not given in its tag and mapping is
+// set, the key that mapping derives from it (see Encoder.MapFieldNames).
+func (finfo *fieldInfo) key(mapping func(field string) string) string {
+	if mapping == nil || finfo.tagged {
+		return finfo.name
+	}
+	return mapping(finfo.name)
+}
+
 // addFieldInfo adds finfo to tinfo.fields if there are no
 // conflicts, or if conflicts arise from previous fields that were
 // obtained from deeper embedded structures than finfo. In the latter
@@ -182,6 +403,15 @@ func addFieldInfo(typ reflect.Type, tinfo *typeInfo, newf *fieldInfo) error {
 	return nil
 }
 
+// setInlineMap records finfo as the ,inline map field of tinfo, of which there may only be one.
+func setInlineMap(typ reflect.Type, tinfo *typeInfo, finfo *fieldInfo) error {
+	if tinfo.inlineMap != nil {
+		return fmt.Errorf("plist: type %v has more than one ,inline map", typ)
+	}
+	tinfo.inlineMap = finfo
+	return nil
+}
+
 // valueForWriting returns v's field value corresponding to finfo.
 // It's equivalent to v.FieldByIndex(finfo.idx), but initializes
 // and dereferences pointers as necessary.
@@ -208,6 +438,9 @@ func (finfo *fieldInfo) value(v reflect.Value) reflect.Value {
 	for i, x := range finfo.idx {
 		t := v.Type()
 		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
 			v = v.Elem()
 		}
 
@@ -216,6 +449,10 @@ func (finfo *fieldInfo) value(v reflect.Value) reflect.Value {
 		if (finfo.omitEmptyDepthMap&(1<<uint(i))) != 0 && isEmptyValue(v) {
 			return reflect.Value{}
 		}
+
+		if (finfo.omitZeroDepthMap&(1<<uint(i))) != 0 && isZeroValue(v) {
+			return reflect.Value{}
+		}
 	}
 	return v
 }
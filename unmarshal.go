@@ -2,27 +2,137 @@ package plist
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 )
 
-type incompatibleDecodeTypeError struct {
-	dest reflect.Type
-	src  string // type name (from cfValue)
+// Errors matched, with errors.Is, by the typed errors that describe them in more detail.
+var (
+	ErrTypeMismatch = errors.New("plist: type mismatch")
+	ErrOverflow     = errors.New("plist: value out of range")
+	ErrUnknownKey   = errors.New("plist: unknown key")
+)
+
+// A TypeMismatchError reports a value that cannot be stored in a Go value of the destination type.
+type TypeMismatchError struct {
+	Path   string       // the path of the value, as in PathError; empty for the root
+	Source string       // the property list type of the value, such as "string" or "dictionary"
+	Dest   reflect.Type // the type of the Go value
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("plist: type mismatch: tried to decode plist type `%v' into value of type `%v'", e.Source, e.Dest)
+}
+
+// Unwrap returns ErrTypeMismatch.
+func (e *TypeMismatchError) Unwrap() error {
+	return ErrTypeMismatch
+}
+
+// An OverflowError reports a number that does not fit in the Go value of the destination type.
+type OverflowError struct {
+	Path  string // the path of the value, as in PathError; empty for the root
+	Value string // the number, as written in the property list
+	Dest  reflect.Type
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("plist: value %s overflows `%v'", e.Value, e.Dest)
+}
+
+// Unwrap returns ErrOverflow.
+func (e *OverflowError) Unwrap() error {
+	return ErrOverflow
+}
+
+// An UnknownKeyError reports a dictionary key that matches no field of the destination struct.
+// It is only returned by a Decoder that disallows unknown fields.
+type UnknownKeyError struct {
+	Path string // the path of the dictionary, as in PathError; empty for the root
+	Key  string
+	Dest reflect.Type // the struct type
+}
+
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Key)
+}
+
+// Unwrap returns ErrUnknownKey.
+func (e *UnknownKeyError) Unwrap() error {
+	return ErrUnknownKey
+}
+
+// A PathError records where in a property list a value that could not be decoded was found.
+type PathError struct {
+	// Path leads from the root of the property list to the value, naming dictionary keys and array indices;
+	// for example, Items[3].Name. Keys that would be ambiguous are quoted, as in Items["a.b"].
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%q: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the error that the value caused.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// atKey returns err, which occurred within the value of the dictionary key k, with k added to the start of its path.
+// Each of the errors in a multierror.Error is given the path in turn.
+func atKey(k string, err error) error {
+	return atPathSegment(keySegment(k), err)
 }
 
-func (u *incompatibleDecodeTypeError) Error() string {
-	return fmt.Sprintf("plist: type mismatch: tried to decode plist type `%v' into value of type `%v'", u.src, u.dest)
+// atIndex works like atKey, for errors that occurred within the element of an array at index i.
+func atIndex(i int, err error) error {
+	return atPathSegment(indexSegment(i), err)
+}
+
+func atPathSegment(segment string, err error) error {
+	if merr, ok := err.(*multierror.Error); ok {
+		var resultErr error
+		for _, e := range merr.Errors {
+			resultErr = multierror.Append(resultErr, atPathSegment(segment, e))
+		}
+		return resultErr
+	}
+
+	// The typed errors record their paths too.
+	var tm *TypeMismatchError
+	var oe *OverflowError
+	var uk *UnknownKeyError
+	switch {
+	case errors.As(err, &tm):
+		tm.Path = joinPath(segment, tm.Path)
+	case errors.As(err, &oe):
+		oe.Path = joinPath(segment, oe.Path)
+	case errors.As(err, &uk):
+		uk.Path = joinPath(segment, uk.Path)
+	}
+
+	if perr, ok := err.(*PathError); ok {
+		perr.Path = joinPath(segment, perr.Path)
+		return perr
+	}
+	return &PathError{Path: segment, Err: err}
 }
 
 var (
 	plistUnmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binUnmarshalerType   = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
 	uidType              = reflect.TypeOf(UID(0))
-	stringType           = reflect.TypeOf("")
 )
 
 func isEmptyInterface(v reflect.Value) bool {
@@ -39,22 +149,86 @@ func (p *Decoder) unmarshalTextInterface(pval cfString, unmarshalable encoding.T
 	return unmarshalable.UnmarshalText([]byte(pval))
 }
 
+func (p *Decoder) unmarshalBinaryInterface(pval cfData, unmarshalable encoding.BinaryUnmarshaler) error {
+	return unmarshalable.UnmarshalBinary([]byte(pval))
+}
+
+func (p *Decoder) unmarshalResolvedUID(uid cfUID, val reflect.Value) error {
+	decode := func(v interface{}) error {
+		if uint64(uid) >= uint64(len(p.archiveObjects)) {
+			return fmt.Errorf("plist: UID %d does not refer to an archived object", uid)
+		}
+		// A resolver that always decodes would otherwise recurse forever on an object that refers to itself.
+		for _, r := range p.resolving {
+			if r == uid {
+				return fmt.Errorf("plist: UID %d refers to an object that is already being decoded", uid)
+			}
+		}
+		if len(p.resolving) >= p.depthLimit() {
+			return fmt.Errorf("plist: UIDs are resolved more than %d deep", p.depthLimit())
+		}
+		p.resolving = append(p.resolving, uid)
+		defer func() { p.resolving = p.resolving[:len(p.resolving)-1] }()
+		return p.unmarshal(p.archiveObjects[uid], reflect.ValueOf(v))
+	}
+	return p.uidResolver(UID(uid), val.Addr().Interface(), decode)
+}
+
+// unmarshalUIDPointer stores in the pointer val the value decoded from the object uid refers to (see
+// ResolveUIDPointers), decoding it only the first time it is referred to.
+func (p *Decoder) unmarshalUIDPointer(uid cfUID, val reflect.Value) error {
+	g := p.uidGraph
+	if uint64(uid) >= uint64(len(g.objects)) {
+		return fmt.Errorf("plist: UID %d does not refer to an object", uid)
+	}
+	if s, ok := g.objects[uid].(cfString); ok && s == "$null" {
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	}
+
+	// The pointer is recorded before its value is decoded, so that an object that refers to itself is decoded once.
+	key := uidPointerKey{uid, val.Type()}
+	g.mu.Lock()
+	ptr, decoded := g.pointers[key]
+	if !decoded {
+		ptr = reflect.New(val.Type().Elem())
+		g.pointers[key] = ptr
+	}
+	g.mu.Unlock()
+
+	val.Set(ptr)
+	if decoded {
+		return nil
+	}
+	return p.unmarshal(g.objects[uid], ptr)
+}
+
 func (p *Decoder) unmarshalTime(pval cfDate, val reflect.Value) {
-	val.Set(reflect.ValueOf(time.Time(pval)))
+	val.Set(reflect.ValueOf(p.decodedTime(time.Time(pval))))
 }
 
 func (p *Decoder) unmarshalLaxString(s string, val reflect.Value) error {
 	switch val.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		i := mustParseInt(s, 10, 64)
+		if val.OverflowInt(i) {
+			return &OverflowError{Value: s, Dest: val.Type()}
+		}
 		val.SetInt(i)
 		return nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		i := mustParseUint(s, 10, 64)
+		if val.OverflowUint(i) {
+			return &OverflowError{Value: s, Dest: val.Type()}
+		}
 		val.SetUint(i)
 		return nil
 	case reflect.Float32, reflect.Float64:
 		f := mustParseFloat(s, 64)
+		if val.OverflowFloat(f) {
+			return &OverflowError{Value: s, Dest: val.Type()}
+		}
+		p.checkNarrowing(f, val)
 		val.SetFloat(f)
 		return nil
 	case reflect.Bool:
@@ -64,23 +238,176 @@ func (p *Decoder) unmarshalLaxString(s string, val reflect.Value) error {
 	case reflect.Struct:
 		if val.Type() == timeType {
 			t, err := time.Parse(textPlistTimeLayout, s)
+			if err != nil && len(p.dateLayouts) > 0 {
+				t, err = parseDate(s, p.dateLayouts)
+			}
 			if err != nil {
 				return err
 			}
-			val.Set(reflect.ValueOf(t.In(time.UTC)))
+			val.Set(reflect.ValueOf(p.decodedTime(t)))
 			return nil
 		}
 		fallthrough
 	default:
-		return &incompatibleDecodeTypeError{val.Type(), "string"}
+		return &TypeMismatchError{Source: "string", Dest: val.Type()}
+	}
+}
+
+// checkNarrowing warns if f, which is about to be stored in val, cannot be held exactly by it.
+func (p *Decoder) checkNarrowing(f float64, val reflect.Value) {
+	if val.Kind() == reflect.Float32 && float64(float32(f)) != f && !math.IsNaN(f) {
+		p.warn(WarnFloatNarrowed, "%v stored as %v in a float32", f, float64(float32(f)))
 	}
 }
 
+// addError records err alongside the errors already in resultErr, which is returned once the value being decoded
+// is done. If the Decoder fails fast, resultErr is always nil, as decoding stops at the first error, so err is
+// returned as it is.
+func (p *Decoder) addError(resultErr, err error) error {
+	if p.failFast {
+		return err
+	}
+	return multierror.Append(resultErr, err)
+}
+
+// unmarshalField decodes pval into the struct field described by finfo, appending any error to resultErr.
+func (p *Decoder) unmarshalField(pval cfValue, finfo *fieldInfo, val reflect.Value, resultErr error) error {
+	key := finfo.key(p.fieldNames)
+	fieldVal := finfo.valueForWriting(val)
+	if !fieldVal.CanSet() {
+		return p.addError(resultErr, fmt.Errorf("field %q not settable", key))
+	}
+	p.enterKey(key)
+	defer p.leavePath()
+
+	var err error
+	if finfo.durationFormat != durationNanoseconds {
+		err = p.unmarshalDuration(pval, fieldVal, finfo.durationFormat)
+	} else if str, ok := pval.(cfString); ok && finfo.asString {
+		err = p.unmarshalQuotedScalar(str, fieldVal)
+	} else {
+		err = p.unmarshalValue(pval, fieldVal, !finfo.noText)
+	}
+	if err != nil {
+		return p.addError(resultErr, atKey(key, err))
+	}
+	return resultErr
+}
+
+// unmarshalQuotedScalar parses a number or boolean from a string, for fields tagged ,string
+// and for default values.
+func (p *Decoder) unmarshalQuotedScalar(pval cfString, val reflect.Value) error {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
+
+	s := string(pval)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	default:
+		return p.unmarshal(pval, val)
+	}
+	return nil
+}
+
+// canUnmarshalMapKey reports whether dictionary keys can be converted to map keys of type typ.
+func canUnmarshalMapKey(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// unmarshalMapKey converts the dictionary key s to a map key of type typ.
+func unmarshalMapKey(s string, typ reflect.Type) (reflect.Value, error) {
+	keyv := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(s).Convert(typ), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, typ.Bits())
+		if err != nil {
+			return keyv, err
+		}
+		keyv.SetInt(i)
+		return keyv, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(s, 10, typ.Bits())
+		if err != nil {
+			return keyv, err
+		}
+		keyv.SetUint(i)
+		return keyv, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, typ.Bits())
+		if err != nil {
+			return keyv, err
+		}
+		keyv.SetFloat(f)
+		return keyv, nil
+	}
+
+	err := keyv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	return keyv, err
+}
+
 func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
+	return p.unmarshalValue(pval, val, true)
+}
+
+// unmarshalValue decodes pval into val, using its UnmarshalText method, if it has one, only if text is set.
+func (p *Decoder) unmarshalValue(pval cfValue, val reflect.Value, text bool) error {
 	if pval == nil {
 		return nil
 	}
 
+	if uid, ok := pval.(cfUID); ok && p.uidGraph != nil && val.Kind() == reflect.Ptr && val.CanSet() {
+		elem := val.Type().Elem()
+		if elem != uidType && elem.Kind() != reflect.Ptr && elem.Kind() != reflect.Interface {
+			return p.unmarshalUIDPointer(uid, val)
+		}
+	}
+
+	if uid, ok := pval.(cfUID); ok && p.uidResolver != nil && val.CanAddr() {
+		typ := val.Type()
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ != uidType && !(typ.Kind() == reflect.Interface && typ.NumMethod() == 0) {
+			return p.unmarshalResolvedUID(uid, val)
+		}
+	}
+
 	for val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			val.Set(reflect.New(val.Type().Elem()))
@@ -88,18 +415,60 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 		val = val.Elem()
 	}
 
+	if len(p.decodeHooks) > 0 {
+		var stored bool
+		var err error
+		if pval, stored, err = p.applyDecodeHooks(pval, val); err != nil || stored || pval == nil {
+			return err
+		}
+	}
+
 	if isEmptyInterface(val) {
 		v := p.valueInterface(pval)
 		val.Set(reflect.ValueOf(v))
 		return nil
 	}
 
-	incompatibleTypeError := &incompatibleDecodeTypeError{val.Type(), pval.typeName()}
+	if data, ok := pval.(dataValue); ok && p.unmarshalStreamedData(data, val) {
+		return nil
+	}
+	if r, ok := pval.(*cfDataReader); ok {
+		pval = r.readAll()
+	}
+
+	switch val.Type() {
+	case rawValueType:
+		p.unmarshalRawValue(pval, val)
+		return nil
+	case orderedDictType:
+		return p.unmarshalOrderedDict(pval, val)
+	case jsonRawMessageType:
+		return p.unmarshalJSONRawMessage(pval, val)
+	}
+
+	incompatibleTypeError := &TypeMismatchError{Source: pval.typeName(), Dest: val.Type()}
 
 	if receiver, can := implementsInterface(val, plistUnmarshalerType); can {
 		return p.unmarshalPlistInterface(pval, receiver.(Unmarshaler))
 	}
 
+	p.account(pval)
+
+	// big.Int and big.Float implement TextUnmarshaler, which handles strings; numbers are stored directly.
+	switch pval := pval.(type) {
+	case *cfNumber:
+		if val.Type() == bigIntType {
+			unmarshalBigInt(pval, val)
+			return nil
+		} else if val.Type() == bigFloatType {
+			return unmarshalBigFloat(pval, val)
+		}
+	case *cfReal:
+		if val.Type() == bigFloatType {
+			return unmarshalBigFloat(pval, val)
+		}
+	}
+
 	// time.Time implements TextMarshaler, but we need to parse it as RFC3339
 	if date, ok := pval.(cfDate); ok {
 		if val.Type() == timeType {
@@ -110,12 +479,18 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 	}
 
 	if val.Type() != timeType {
-		if receiver, can := implementsInterface(val, textUnmarshalerType); can {
+		if receiver, can := implementsInterface(val, textUnmarshalerType); can && text {
 			if str, ok := pval.(cfString); ok {
 				return p.unmarshalTextInterface(str, receiver.(encoding.TextUnmarshaler))
 			}
 			return incompatibleTypeError
 		}
+		if receiver, can := implementsInterface(val, binUnmarshalerType); can {
+			if data, ok := pval.(cfData); ok {
+				return p.unmarshalBinaryInterface(data, receiver.(encoding.BinaryUnmarshaler))
+			}
+			return incompatibleTypeError
+		}
 	}
 
 	typ := val.Type()
@@ -126,16 +501,36 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 			val.SetString(string(pval))
 			return nil
 		}
+		if typ == timeType && len(p.dateLayouts) > 0 {
+			if t, err := parseDate(string(pval), p.dateLayouts); err == nil {
+				val.Set(reflect.ValueOf(p.decodedTime(t)))
+				return nil
+			}
+		}
 		if p.lax {
 			return p.unmarshalLaxString(string(pval), val)
 		}
 		return incompatibleTypeError
 
 	case *cfNumber:
+		if typ == numberType {
+			val.SetString(string(numberValue(pval, p.preserveNumberText)))
+			return nil
+		}
 		switch val.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val.SetInt(int64(pval.value))
+			n := int64(pval.value)
+			if pval.big != nil || (!pval.signed && n < 0) || val.OverflowInt(n) {
+				return &OverflowError{Value: pval.String(), Dest: typ}
+			}
+			val.SetInt(n)
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if pval.big != nil || (pval.signed && int64(pval.value) < 0) || val.OverflowUint(pval.value) {
+				return &OverflowError{Value: pval.String(), Dest: typ}
+			}
+			if typ == uidType {
+				p.warn(WarnUIDCoerced, "integer %s stored as a UID", pval)
+			}
 			val.SetUint(pval.value)
 		default:
 			return incompatibleTypeError
@@ -143,8 +538,15 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 		return nil
 
 	case *cfReal:
+		if typ == numberType {
+			val.SetString(string(numberValue(pval, p.preserveNumberText)))
+			return nil
+		}
 		if val.Kind() == reflect.Float32 || val.Kind() == reflect.Float64 {
-			// TODO: Consider warning on a downcast (storing a 64-bit value in a 32-bit reflect)
+			if val.OverflowFloat(pval.value) {
+				return &OverflowError{Value: strconv.FormatFloat(pval.value, 'g', -1, 64), Dest: typ}
+			}
+			p.checkNarrowing(pval.value, val)
 			val.SetFloat(pval.value)
 			return nil
 		}
@@ -186,8 +588,16 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 		}
 		switch val.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if int64(pval) < 0 || val.OverflowInt(int64(pval)) {
+				return &OverflowError{Value: strconv.FormatUint(uint64(pval), 10), Dest: typ}
+			}
+			p.warn(WarnUIDCoerced, "UID %d stored as an integer", uint64(pval))
 			val.SetInt(int64(pval))
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if val.OverflowUint(uint64(pval)) {
+				return &OverflowError{Value: strconv.FormatUint(uint64(pval), 10), Dest: typ}
+			}
+			p.warn(WarnUIDCoerced, "UID %d stored as an integer", uint64(pval))
 			val.SetUint(uint64(pval))
 		default:
 			return incompatibleTypeError
@@ -206,7 +616,6 @@ func (p *Decoder) unmarshal(pval cfValue, val reflect.Value) error {
 }
 
 func (p *Decoder) unmarshalArray(a *cfArray, val reflect.Value) error {
-	var resultErr error
 	var n int
 	if val.Kind() == reflect.Slice {
 		// Slice of element values.
@@ -228,17 +637,92 @@ func (p *Decoder) unmarshalArray(a *cfArray, val reflect.Value) error {
 			return fmt.Errorf("plist: attempted to unmarshal %d values into an array of size %d", len(a.values), val.Cap())
 		}
 	} else {
-		return &incompatibleDecodeTypeError{val.Type(), a.typeName()}
+		return &TypeMismatchError{Source: a.typeName(), Dest: val.Type()}
+	}
+
+	if p.parallelArrays > 0 && len(a.values) >= p.parallelArrays {
+		return p.unmarshalElementsParallel(a.values, val, n)
 	}
+	return p.unmarshalElements(a.values, val, n)
+}
 
-	// Recur to read element into slice.
-	for _, sval := range a.values {
-		if err := p.unmarshal(sval, val.Index(n)); err != nil {
-			resultErr = multierror.Append(resultErr, fmt.Errorf("element %d: %w", n, err))
+// unmarshalElements decodes values into consecutive elements of val, beginning at index n.
+func (p *Decoder) unmarshalElements(values []cfValue, val reflect.Value, n int) error {
+	var resultErr error
+	for _, sval := range values {
+		p.enterIndex(n)
+		err := p.unmarshal(sval, val.Index(n))
+		p.leavePath()
+		if err != nil {
+			resultErr = p.addError(resultErr, atIndex(n, err))
+			if p.failFast {
+				return resultErr
+			}
 		}
 		n++
 	}
+	return resultErr
+}
+
+// unmarshalElementsParallel works like unmarshalElements, but divides values between up to GOMAXPROCS goroutines.
+// Errors are reported in element order, and a panic raised while decoding any element is re-raised on the calling
+// goroutine, so that the outcome is the same as that of unmarshalElements.
+func (p *Decoder) unmarshalElementsParallel(values []cfValue, val reflect.Value, n int) error {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(values) {
+		workers = len(values)
+	}
+	if workers < 2 {
+		return p.unmarshalElements(values, val, n)
+	}
 
+	chunk := (len(values) + workers - 1) / workers
+	errs := make([]error, workers)
+	panics := make([]interface{}, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		start := i * chunk
+		if start >= len(values) {
+			break
+		}
+		end := start + chunk
+		if end > len(values) {
+			end = len(values)
+		}
+
+		i := i
+		worker := p
+		if p.report != nil || p.uidResolver != nil {
+			// Each worker needs its own path and UIDs being resolved; everything else is shared.
+			w := *p
+			w.path = append([]string(nil), p.path...)
+			w.resolving = append([]cfUID(nil), p.resolving...)
+			worker = &w
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				panics[i] = recover()
+			}()
+			errs[i] = worker.unmarshalElements(values[start:end], val, n+start)
+		}()
+	}
+	wg.Wait()
+
+	var resultErr error
+	for i := range errs {
+		if panics[i] != nil {
+			panic(panics[i])
+		}
+		if errs[i] != nil {
+			if p.failFast {
+				// Later chunks may have failed too, but this chunk's error comes first.
+				return errs[i]
+			}
+			resultErr = multierror.Append(resultErr, errs[i])
+		}
+	}
 	return resultErr
 }
 
@@ -256,7 +740,7 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) err
 	typ := val.Type()
 	switch val.Kind() {
 	case reflect.Struct:
-		tinfo, err := getTypeInfo(typ)
+		tinfo, err := getTypeInfo(typ, p.jsonTags)
 		if err != nil {
 			return err
 		}
@@ -268,17 +752,95 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) err
 		}
 
 		var resultErr error
+		var unmatched []*fieldInfo
 
-		for _, finfo := range tinfo.fields {
-			if ent, ok := entries[finfo.name]; ok {
-				fieldVal := finfo.valueForWriting(val)
-				if fieldVal.CanSet() {
-					if err := p.unmarshal(ent, fieldVal); err != nil {
-						resultErr = multierror.Append(resultErr, fmt.Errorf("field %q: %w", finfo.name, err))
+		for i := range tinfo.fields {
+			finfo := &tinfo.fields[i]
+			key := finfo.key(p.fieldNames)
+			if ent, ok := entries[key]; ok {
+				resultErr = p.unmarshalField(ent, finfo, val, resultErr)
+				if resultErr != nil && p.failFast {
+					return resultErr
+				}
+				delete(entries, key)
+			} else {
+				unmatched = append(unmatched, finfo)
+			}
+		}
+
+		// Exact matches take precedence, so fields are only matched case-insensitively once they are done.
+		for _, finfo := range unmatched {
+			matched := false
+			if p.caseInsensitiveFields {
+				for _, k := range dict.keys {
+					if ent, ok := entries[k]; ok && strings.EqualFold(k, finfo.key(p.fieldNames)) {
+						resultErr = p.unmarshalField(ent, finfo, val, resultErr)
+						if resultErr != nil && p.failFast {
+							return resultErr
+						}
+						delete(entries, k)
+						matched = true
+						break
 					}
+				}
+			}
+
+			if matched {
+				continue
+			}
+
+			if finfo.required {
+				resultErr = p.addError(resultErr, fmt.Errorf("missing required field %q", finfo.key(p.fieldNames)))
+			} else if finfo.hasDefault {
+				var err error
+				if finfo.durationFormat != durationNanoseconds {
+					err = p.unmarshalDuration(cfString(finfo.defaultValue), finfo.valueForWriting(val), finfo.durationFormat)
 				} else {
-					resultErr = multierror.Append(resultErr,
-						fmt.Errorf("field %q not settable", finfo.name))
+					err = p.unmarshalQuotedScalar(cfString(finfo.defaultValue), finfo.valueForWriting(val))
+				}
+				if err != nil {
+					resultErr = p.addError(resultErr, atKey(finfo.key(p.fieldNames), fmt.Errorf("default value: %w", err)))
+				}
+			}
+			if resultErr != nil && p.failFast {
+				return resultErr
+			}
+		}
+
+		if tinfo.inlineMap != nil {
+			m := tinfo.inlineMap.valueForWriting(val)
+			for _, k := range dict.keys {
+				ent, ok := entries[k]
+				if !ok {
+					continue
+				}
+				if m.IsNil() {
+					m.Set(reflect.MakeMap(m.Type()))
+				}
+				mapElem := reflect.New(m.Type().Elem()).Elem()
+				p.enterKey(k)
+				err := p.unmarshal(ent, mapElem)
+				p.leavePath()
+				if err != nil {
+					resultErr = p.addError(resultErr, atKey(k, err))
+					if p.failFast {
+						return resultErr
+					}
+				} else {
+					m.SetMapIndex(reflect.ValueOf(k).Convert(m.Type().Key()), mapElem)
+				}
+				delete(entries, k)
+			}
+		}
+
+		if p.disallowUnknownFields {
+			for _, k := range dict.keys {
+				if _, ok := entries[k]; ok {
+					resultErr = p.addError(resultErr, &UnknownKeyError{Key: k, Dest: typ})
+					if p.failFast {
+						return resultErr
+					}
+					delete(entries, k)
 				}
 			}
 		}
@@ -290,8 +852,8 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) err
 			val.Set(reflect.MakeMap(typ))
 		}
 
-		if !stringType.ConvertibleTo(val.Type().Key()) {
-			return fmt.Errorf("plist: attempt to decode dictionary into map with non-string key type `%v'", val.Type().Key())
+		if !canUnmarshalMapKey(typ.Key()) {
+			return fmt.Errorf("plist: attempt to decode dictionary into map with unsupported key type `%v'", typ.Key())
 		}
 
 		var resultErr error
@@ -299,11 +861,27 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) err
 		for i, k := range dict.keys {
 			sval := dict.values[i]
 
-			keyv := reflect.ValueOf(k).Convert(typ.Key())
+			keyv, err := unmarshalMapKey(k, typ.Key())
+			if err != nil {
+				if p.disallowUnparseableMapKeys {
+					resultErr = p.addError(resultErr, fmt.Errorf("map key %q: %w", k, err))
+					if p.failFast {
+						return resultErr
+					}
+				}
+				continue
+			}
+
 			mapElem := reflect.New(typ.Elem()).Elem()
 
-			if err := p.unmarshal(sval, mapElem); err != nil {
-				resultErr = multierror.Append(resultErr, fmt.Errorf("map key %q: %w", k, err))
+			p.enterKey(k)
+			err = p.unmarshal(sval, mapElem)
+			p.leavePath()
+			if err != nil {
+				resultErr = p.addError(resultErr, atKey(k, err))
+				if p.failFast {
+					return resultErr
+				}
 				continue
 			}
 
@@ -312,22 +890,149 @@ func (p *Decoder) unmarshalDictionary(dict *cfDictionary, val reflect.Value) err
 
 		return resultErr
 
+	case reflect.Slice:
+		keyIdx, valueIdx, ok := keyValueFields(typ.Elem())
+		if !ok {
+			return &TypeMismatchError{Source: dict.typeName(), Dest: typ}
+		}
+
+		var resultErr error
+
+		entries := reflect.MakeSlice(typ, 0, len(dict.keys))
+		for i, k := range dict.keys {
+			entry := reflect.New(typ.Elem()).Elem()
+
+			keyv, err := unmarshalMapKey(k, entry.Field(keyIdx).Type())
+			if err != nil {
+				if p.disallowUnparseableMapKeys {
+					resultErr = p.addError(resultErr, fmt.Errorf("key %q: %w", k, err))
+					if p.failFast {
+						return resultErr
+					}
+				}
+				continue
+			}
+			entry.Field(keyIdx).Set(keyv)
+
+			p.enterKey(k)
+			err = p.unmarshal(dict.values[i], entry.Field(valueIdx))
+			p.leavePath()
+			if err != nil {
+				resultErr = p.addError(resultErr, atKey(k, err))
+				if p.failFast {
+					return resultErr
+				}
+				continue
+			}
+
+			entries = reflect.Append(entries, entry)
+		}
+		val.Set(entries)
+
+		return resultErr
+
 	default:
-		return &incompatibleDecodeTypeError{typ, dict.typeName()}
+		return &TypeMismatchError{Source: dict.typeName(), Dest: typ}
 	}
 }
 
+// keyValueFields returns the indices of the Key and Value fields of typ, if it is a struct that has both, with a
+// Key that dictionary keys can be converted to, so that a slice of typ can hold the entries of a dictionary.
+func keyValueFields(typ reflect.Type) (key, value int, ok bool) {
+	if typ.Kind() != reflect.Struct {
+		return 0, 0, false
+	}
+	k, kok := typ.FieldByName("Key")
+	v, vok := typ.FieldByName("Value")
+	if !kok || !vok || len(k.Index) != 1 || len(v.Index) != 1 || k.PkgPath != "" || v.PkgPath != "" {
+		return 0, 0, false
+	}
+	if !canUnmarshalMapKey(k.Type) {
+		return 0, 0, false
+	}
+	return k.Index[0], v.Index[0], true
+}
+
 /* *Interface is modelled after encoding/json */
+// applyDecodeHooks passes pval through the Decoder's hooks on its way to val. It stores the result in val, and
+// reports that it did so, if it can be assigned there; otherwise, it returns the result as a value to decode.
+func (p *Decoder) applyDecodeHooks(pval cfValue, val reflect.Value) (cfValue, bool, error) {
+	// The value is accounted for once it is stored; building it for the hooks does not count. It is built by a
+	// copy of the Decoder without a budget, as the Decoder itself may be shared by ParallelArrays workers.
+	unlimited := *p
+	unlimited.budget = nil
+	orig := unlimited.valueInterface(pval)
+
+	v := orig
+	for _, hook := range p.decodeHooks {
+		var err error
+		if v, err = hook(reflect.TypeOf(v), val.Type(), v); err != nil {
+			return nil, false, err
+		}
+		if v == nil {
+			return nil, false, nil
+		}
+	}
+
+	if reflect.TypeOf(v).AssignableTo(val.Type()) {
+		p.accountAll(pval)
+		val.Set(reflect.ValueOf(v))
+		return nil, true, nil
+	}
+	if sameValue(v, orig) {
+		return pval, false, nil
+	}
+	return (&Encoder{}).marshal(reflect.ValueOf(v)), false, nil
+}
+
+// accountAll accounts for pval and every value it holds.
+func (p *Decoder) accountAll(pval cfValue) {
+	p.account(pval)
+	switch pval := pval.(type) {
+	case *cfDictionary:
+		for _, v := range pval.values {
+			p.accountAll(v)
+		}
+	case *cfArray:
+		for _, v := range pval.values {
+			p.accountAll(v)
+		}
+	}
+}
+
+// sameValue reports whether a, which a hook returned, is b, the value it was given.
+func sameValue(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	return va.Type().Comparable() && a == b
+}
+
 func (p *Decoder) valueInterface(pval cfValue) interface{} {
+	if dict, ok := pval.(*cfDictionary); ok && p.useOrderedDict {
+		return p.orderedDictionaryInterface(dict)
+	}
+
+	p.account(pval)
 	switch pval := pval.(type) {
 	case cfString:
 		return string(pval)
 	case *cfNumber:
-		if pval.signed {
-			return int64(pval.value)
+		if p.useNumber || p.preserveNumberText {
+			return numberValue(pval, p.preserveNumberText)
 		}
-		return pval.value
+		return p.integerInterface(pval)
 	case *cfReal:
+		if p.useNumber || p.preserveNumberText {
+			return numberValue(pval, p.preserveNumberText)
+		}
 		if pval.wide {
 			return pval.value
 		} else {
@@ -340,15 +1045,54 @@ func (p *Decoder) valueInterface(pval cfValue) interface{} {
 	case *cfDictionary:
 		return p.dictionaryInterface(pval)
 	case cfData:
+		if p.streamData {
+			return dataReaderValue(pval)
+		}
 		return []byte(pval)
+	case *cfDataReader:
+		return dataReaderValue(pval)
 	case cfDate:
-		return time.Time(pval)
+		return p.decodedTime(time.Time(pval))
 	case cfUID:
 		return UID(pval)
 	}
 	return nil
 }
 
+// integerInterface returns n as the type chosen by IntegerType. It panics if n does not fit that type.
+func (p *Decoder) integerInterface(n *cfNumber) interface{} {
+	negative := n.signed && int64(n.value) < 0
+	tooLarge := !negative && n.value > math.MaxInt64
+	if n.big != nil {
+		negative, tooLarge = true, true // fits neither signed nor unsigned types
+	}
+	switch p.integerType {
+	case IntegerInt64:
+		if tooLarge {
+			panic(&OverflowError{Value: n.String(), Dest: reflect.TypeOf(int64(0))})
+		}
+		return int64(n.value)
+	case IntegerUint64:
+		if negative {
+			panic(&OverflowError{Value: n.String(), Dest: reflect.TypeOf(uint64(0))})
+		}
+		return n.value
+	case IntegerInt:
+		if i := int64(n.value); tooLarge || int64(int(i)) != i {
+			panic(&OverflowError{Value: n.String(), Dest: reflect.TypeOf(0)})
+		}
+		return int(n.value)
+	}
+
+	if n.big != nil {
+		return n.bigInt()
+	}
+	if n.signed {
+		return int64(n.value)
+	}
+	return n.value
+}
+
 func (p *Decoder) arrayInterface(a *cfArray) []interface{} {
 	out := make([]interface{}, len(a.values))
 	for i, subv := range a.values {
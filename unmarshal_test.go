@@ -1,9 +1,15 @@
 package plist
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/hashicorp/go-multierror"
 )
 
 func BenchmarkStructUnmarshal(b *testing.B) {
@@ -59,7 +65,7 @@ func TestCustomDateUnmarshal(t *testing.T) {
 }
 
 func TestInvalidMapKeyTypeUnmarshal(t *testing.T) {
-	m := make(map[int]string)
+	m := make(map[[2]int]string)
 	dict := &cfDictionary{
 		keys: []string{"1", "2"},
 		values: []cfValue{
@@ -71,7 +77,7 @@ func TestInvalidMapKeyTypeUnmarshal(t *testing.T) {
 	d := &Decoder{}
 	err := d.unmarshalDictionary(dict, reflect.ValueOf(m))
 	if err == nil {
-		t.Fatal("expected error for non-string key type, got nil")
+		t.Fatal("expected error for unsupported key type, got nil")
 	}
 	t.Log("error:", err)
 }
@@ -104,3 +110,536 @@ func TestValidButAliasedMapKeyTypeUnmarshal(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestNumericMapKeyUnmarshal(t *testing.T) {
+	dict := &cfDictionary{
+		keys: []string{"1", "-2", "three"},
+		values: []cfValue{
+			cfString("first"),
+			cfString("second"),
+			cfString("third"),
+		},
+	}
+
+	ints := make(map[int]string)
+	d := &Decoder{}
+	if err := d.unmarshalDictionary(dict, reflect.ValueOf(ints)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[int]string{1: "first", -2: "second"}; !reflect.DeepEqual(ints, expected) {
+		t.Errorf("expected %v, got %v", expected, ints)
+	}
+
+	floats := make(map[float64]string)
+	if err := d.unmarshalDictionary(dict, reflect.ValueOf(floats)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[float64]string{1: "first", -2: "second"}; !reflect.DeepEqual(floats, expected) {
+		t.Errorf("expected %v, got %v", expected, floats)
+	}
+
+	uints := make(map[uint8]string)
+	d = &Decoder{}
+	d.DisallowUnparseableMapKeys()
+	err := d.unmarshalDictionary(dict, reflect.ValueOf(uints))
+	if err == nil {
+		t.Fatal("expected error for unparseable keys, got nil")
+	}
+	if !strings.Contains(err.Error(), `map key "-2"`) || !strings.Contains(err.Error(), `map key "three"`) {
+		t.Errorf("expected errors for both unparseable keys, got %v", err)
+	}
+	if expected := map[uint8]string{1: "first"}; !reflect.DeepEqual(uints, expected) {
+		t.Errorf("expected %v, got %v", expected, uints)
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	type Config struct {
+		Name    string
+		Enabled bool `plist:"enabled"`
+	}
+	input := `{Name=abc;enabled=1;Nmae=typo;extra=1;}`
+
+	var c Config
+	if _, err := Unmarshal([]byte(input), &c); err != nil {
+		t.Errorf("Expected unknown fields to be ignored by default, received %v", err)
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte(input)))
+	d.DisallowUnknownFields()
+	err := d.Decode(&c)
+	if err == nil {
+		t.Fatal("Expected error for unknown fields, received nothing.")
+	}
+	t.Log("error:", err)
+	for _, k := range []string{`"Nmae"`, `"extra"`} {
+		if !strings.Contains(err.Error(), k) {
+			t.Errorf("Expected error to mention %s", k)
+		}
+	}
+	if c.Name != "abc" || !c.Enabled {
+		t.Errorf("Expected known fields to be decoded, received %#v", c)
+	}
+}
+
+func TestCaseInsensitiveFields(t *testing.T) {
+	type Bundle struct {
+		CFBundleVersion string
+		Identifier      string `plist:"CFBundleIdentifier"`
+		Name            string
+	}
+	input := `{cfbundleversion=1.0;CFBUNDLEIDENTIFIER=com.example;name=lower;Name=exact;}`
+
+	var b Bundle
+	if _, err := Unmarshal([]byte(input), &b); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Bundle{Name: "exact"}); b != expected {
+		t.Errorf("Expected keys to match case-sensitively by default, received %#v", b)
+	}
+
+	b = Bundle{}
+	d := NewDecoder(bytes.NewReader([]byte(input)))
+	d.CaseInsensitiveFields()
+	d.DisallowUnknownFields()
+	err := d.Decode(&b)
+	if err == nil || !strings.Contains(err.Error(), `"name"`) {
+		t.Errorf("Expected the inexact duplicate of Name to be reported as unknown, received %v", err)
+	}
+	if expected := (Bundle{"1.0", "com.example", "exact"}); b != expected {
+		t.Errorf("Expected %#v, received %#v", expected, b)
+	}
+}
+
+func TestQuotedScalarUnmarshal(t *testing.T) {
+	type Quoted struct {
+		A int  `plist:",string"`
+		B bool `plist:",string"`
+	}
+
+	var q Quoted
+	if _, err := Unmarshal([]byte(xmlPreamble+`<plist><dict><key>A</key><integer>4</integer><key>B</key><true/></dict></plist>`), &q); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Quoted{4, true}); q != expected {
+		t.Errorf("Expected unquoted values to decode to %#v, received %#v", expected, q)
+	}
+
+	_, err := Unmarshal([]byte(xmlPreamble+`<plist><dict><key>A</key><string>four</string><key>B</key><string>maybe</string></dict></plist>`), &q)
+	if err == nil {
+		t.Fatal("Expected error for unparseable quoted values, received nothing.")
+	}
+	for _, k := range []string{`"A"`, `"B"`} {
+		if !strings.Contains(err.Error(), k) {
+			t.Errorf("Expected error to mention %s, received %v", k, err)
+		}
+	}
+}
+
+func TestDefaultValueUnmarshal(t *testing.T) {
+	type Config struct {
+		Port    int     `plist:"port,default=8080"`
+		Host    string  `plist:"host,default=localhost"`
+		Verbose *bool   `plist:"verbose,default=true"`
+		Ratio   float64 `plist:"ratio,omitempty,default=0.5"`
+		Tags    string  `plist:"tags,default=a,b,c"`
+	}
+
+	var c Config
+	if _, err := Unmarshal([]byte(`{port=80;}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 80 || c.Host != "localhost" || c.Verbose == nil || !*c.Verbose || c.Ratio != 0.5 || c.Tags != "a,b,c" {
+		t.Errorf("Expected defaults for missing keys, received %#v", c)
+	}
+
+	var bad struct {
+		N int `plist:",default=many"`
+	}
+	if _, err := Unmarshal([]byte(`{}`), &bad); err == nil {
+		t.Error("Expected error for unparseable default, received nothing.")
+	} else {
+		t.Log("error:", err)
+	}
+}
+
+func TestRequiredFieldUnmarshal(t *testing.T) {
+	type Info struct {
+		Identifier string `plist:"CFBundleIdentifier,required"`
+		Version    string `plist:"CFBundleVersion,required"`
+		Name       string `plist:"CFBundleName"`
+	}
+
+	var info Info
+	if _, err := Unmarshal([]byte(`{CFBundleIdentifier=com.example;CFBundleVersion=1;}`), &info); err != nil {
+		t.Errorf("Expected no error when required keys are present, received %v", err)
+	}
+
+	info = Info{}
+	_, err := Unmarshal([]byte(`{CFBundleName=Example;}`), &info)
+	if err == nil {
+		t.Fatal("Expected error for missing required keys, received nothing.")
+	}
+	t.Log("error:", err)
+	for _, k := range []string{`"CFBundleIdentifier"`, `"CFBundleVersion"`} {
+		if !strings.Contains(err.Error(), k) {
+			t.Errorf("Expected error to mention %s", k)
+		}
+	}
+	if info.Name != "Example" {
+		t.Errorf("Expected other fields to be decoded, received %#v", info)
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	type Timeouts struct {
+		Interval time.Duration  `plist:",seconds"`
+		Delay    *time.Duration `plist:",milliseconds"`
+		Expiry   time.Duration  `plist:",durationstring"`
+	}
+
+	var ts Timeouts
+	if _, err := Unmarshal([]byte(`{Interval=2.5;Delay=250;Expiry=1m;}`), &ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Interval != 2500*time.Millisecond || ts.Delay == nil || *ts.Delay != 250*time.Millisecond || ts.Expiry != time.Minute {
+		t.Errorf("Unexpected durations decoded from OpenStep strings: %#v", ts)
+	}
+
+	ts = Timeouts{}
+	if _, err := Unmarshal([]byte(`{Interval=<*I3>;}`), &ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Interval != 3*time.Second {
+		t.Errorf("Expected an integer number of seconds to decode, received %v", ts.Interval)
+	}
+
+	if _, err := Unmarshal([]byte(`{Expiry=<*I3>;}`), &ts); err == nil {
+		t.Error("Expected error decoding an integer into a ,durationstring field, received nothing.")
+	}
+	if _, err := Unmarshal([]byte(`{Expiry=soon;}`), &ts); err == nil {
+		t.Error("Expected error decoding an invalid duration string, received nothing.")
+	}
+
+	for _, doc := range []string{`{Interval=<*I99999999999999>;}`, `{Interval=<*R1e12>;}`, `{Interval=1e12;}`} {
+		if _, err := Unmarshal([]byte(doc), &ts); !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s: Expected an overflow error, received %v", doc, err)
+		}
+	}
+
+	var defaults struct {
+		Interval time.Duration `plist:",seconds,default=5"`
+		Expiry   time.Duration `plist:",durationstring,default=1m"`
+	}
+	if _, err := Unmarshal([]byte(`{}`), &defaults); err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Interval != 5*time.Second || defaults.Expiry != time.Minute {
+		t.Errorf("Expected default durations of 5s and 1m, received %v and %v", defaults.Interval, defaults.Expiry)
+	}
+}
+
+func TestUnmarshalZeroCopy(t *testing.T) {
+	bplist, err := Marshal([]string{"alpha", "omega"}, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string][]byte{
+		"binary":   bplist,
+		"openstep": []byte(`(alpha, "omega")`),
+	}
+
+	for name, doc := range docs {
+		subtest(t, name, func(t *testing.T) {
+			for _, zeroCopy := range []bool{false, true} {
+				data := append([]byte(nil), doc...)
+				var strs []string
+				if zeroCopy {
+					_, err = UnmarshalZeroCopy(data, &strs)
+				} else {
+					_, err = Unmarshal(data, &strs)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Overwrite the input; only zero-copy strings should observe the change.
+				for _, s := range []string{"alpha", "omega"} {
+					i := bytes.Index(data, []byte(s))
+					copy(data[i:], strings.ToUpper(s))
+				}
+				want := []string{"alpha", "omega"}
+				if zeroCopy {
+					want = []string{"ALPHA", "OMEGA"}
+				}
+				if !reflect.DeepEqual(strs, want) {
+					t.Errorf("zero copy %v: got %q, expected %q", zeroCopy, strs, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelArrays(t *testing.T) {
+	values := make([]interface{}, 5000)
+	for i := range values {
+		values[i] = map[string]interface{}{"n": uint64(i)}
+	}
+	// Elements that cannot be decoded into the element type.
+	values[7], values[4321] = "seven", "four thousand"
+	doc, err := Marshal(values, BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Element struct {
+		N int `plist:"n"`
+	}
+	decode := func(minLength int) ([]Element, error) {
+		var out []Element
+		d := NewDecoder(bytes.NewReader(doc))
+		d.ParallelArrays(minLength)
+		err := d.Decode(&out)
+		return out, err
+	}
+
+	want, wantErr := decode(0)
+	got, gotErr := decode(100)
+	if wantErr == nil || gotErr == nil {
+		t.Fatalf("expected errors, got %v and %v", wantErr, gotErr)
+	}
+	if gotErr.Error() != wantErr.Error() {
+		t.Errorf("got error %q, expected %q", gotErr, wantErr)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("parallel decoding produced different elements")
+	}
+	if got[4999].N != 4999 {
+		t.Errorf("got %d for the final element, expected 4999", got[4999].N)
+	}
+}
+
+func TestUnmarshalErrorPaths(t *testing.T) {
+	type Item struct {
+		Name  int
+		Count int `plist:",required"`
+	}
+	type Root struct {
+		Items []Item
+		Meta  map[string][]int
+	}
+
+	doc, err := Marshal(map[string]interface{}{
+		"Items": []interface{}{
+			map[string]interface{}{"Name": 1, "Count": 1},
+			map[string]interface{}{"Name": "one", "Count": 1},
+			map[string]interface{}{"Name": 3},
+		},
+		"Meta": map[string]interface{}{
+			"a.b": []interface{}{1, 2, "three"},
+		},
+	}, XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parallel := range []int{0, 1} {
+		var root Root
+		d := NewDecoder(bytes.NewReader(doc))
+		d.ParallelArrays(parallel)
+		err := d.Decode(&root)
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+
+		var paths []string
+		merr, ok := err.(*multierror.Error)
+		if !ok {
+			t.Fatalf("expected several errors, got %v", err)
+		}
+		for _, e := range merr.Errors {
+			var perr *PathError
+			if !errors.As(e, &perr) {
+				t.Fatalf("expected a *PathError, got %v", e)
+			}
+			paths = append(paths, perr.Path)
+		}
+		sort.Strings(paths)
+		if expected := []string{"Items[1].Name", "Items[2]", `Meta["a.b"][2]`}; !reflect.DeepEqual(paths, expected) {
+			t.Errorf("got paths %q, expected %q (%v)", paths, expected, err)
+		}
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	type Item struct {
+		Name  int
+		Count int
+	}
+
+	doc := []byte(`<plist><array>
+		<dict><key>Name</key><integer>1</integer></dict>
+		<dict><key>Name</key><string>two</string><key>Count</key><string>two</string></dict>
+		<dict><key>Name</key><string>three</string></dict>
+	</array></plist>`)
+
+	for _, parallel := range []int{0, 1} {
+		var items []Item
+		d := NewDecoder(bytes.NewReader(doc))
+		d.FailFast()
+		d.ParallelArrays(parallel)
+		err := d.Decode(&items)
+
+		perr, ok := err.(*PathError)
+		if !ok {
+			t.Fatalf("expected a single *PathError, got %v", err)
+		}
+		if perr.Path != "[1].Name" {
+			t.Errorf("expected the error for [1].Name, got %v", err)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	type Item struct {
+		N uint8
+	}
+	type Root struct {
+		Small int8
+		Items []Item
+		Name  string
+	}
+
+	doc := []byte(`<plist><dict>
+		<key>Small</key><integer>300</integer>
+		<key>Items</key><array>
+			<dict><key>N</key><integer>1</integer></dict>
+			<dict><key>N</key><integer>-1</integer><key>Extra</key><true/></dict>
+		</array>
+		<key>Name</key><integer>1</integer>
+	</dict></plist>`)
+
+	var root Root
+	d := NewDecoder(bytes.NewReader(doc))
+	d.DisallowUnknownFields()
+	err := d.Decode(&root)
+
+	for _, target := range []error{ErrTypeMismatch, ErrOverflow, ErrUnknownKey} {
+		if !errors.Is(err, target) {
+			t.Errorf("expected errors.Is(err, %v), error was %v", target, err)
+		}
+	}
+
+	var mismatch *TypeMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a *TypeMismatchError in %v", err)
+	}
+	if mismatch.Path != "Name" || mismatch.Source != "integer" || mismatch.Dest != reflect.TypeOf("") {
+		t.Errorf("unexpected %#v", mismatch)
+	}
+
+	var overflows []*OverflowError
+	var unknown *UnknownKeyError
+	for _, e := range err.(*multierror.Error).Errors {
+		var oe *OverflowError
+		if errors.As(e, &oe) {
+			overflows = append(overflows, oe)
+		}
+		errors.As(e, &unknown)
+	}
+	if len(overflows) != 2 {
+		t.Fatalf("expected two overflows, got %d in %v", len(overflows), err)
+	}
+	if o := overflows[0]; o.Path != "Small" || o.Value != "300" || o.Dest != reflect.TypeOf(int8(0)) {
+		t.Errorf("unexpected %#v", o)
+	}
+	if o := overflows[1]; o.Path != "Items[1].N" || o.Value != "-1" || o.Dest != reflect.TypeOf(uint8(0)) {
+		t.Errorf("unexpected %#v", o)
+	}
+	if unknown == nil || unknown.Path != "Items[1]" || unknown.Key != "Extra" || unknown.Dest != reflect.TypeOf(Item{}) {
+		t.Errorf("unexpected %#v", unknown)
+	}
+}
+
+func TestReportWarnings(t *testing.T) {
+	type Record struct {
+		F    float32
+		List []float32
+		ID   int
+		Ref  UID
+		D    time.Duration `plist:",milliseconds"`
+	}
+
+	doc := []byte(`<plist><dict>
+		<key>F</key><real>0.1</real>
+		<key>List</key><array><real>0.5</real><real>0.1</real></array>
+		<key>ID</key><dict><key>CF$UID</key><integer>7</integer></dict>
+		<key>Ref</key><integer>3</integer>
+		<key>D</key><integer>18446744073709551615</integer>
+	</dict></plist>`)
+
+	var warnings []Warning
+	var r Record
+	d := NewDecoder(bytes.NewReader(doc))
+	d.ReportWarnings(func(w Warning) { warnings = append(warnings, w) })
+	if err := d.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range warnings {
+		t.Log(w)
+	}
+
+	expected := []struct {
+		kind WarningKind
+		path string
+	}{
+		{WarnFloatNarrowed, "F"},
+		{WarnFloatNarrowed, "List[1]"},
+		{WarnUIDCoerced, "ID"},
+		{WarnUIDCoerced, "Ref"},
+		{WarnSignReinterpreted, "D"},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, got %d", len(expected), len(warnings))
+	}
+	for i, w := range warnings {
+		if w.Kind != expected[i].kind || w.Path != expected[i].path {
+			t.Errorf("warning %d: expected kind %d at %q, got %#v", i, expected[i].kind, expected[i].path, w)
+		}
+	}
+	if r.F != 0.1 || r.ID != 7 || r.Ref != 3 {
+		t.Errorf("values were not stored despite the warnings: %#v", r)
+	}
+}
+
+func TestUnmarshalKeyValueSlice(t *testing.T) {
+	type entry struct {
+		Key   string
+		Value int
+	}
+	var entries []entry
+	if _, err := Unmarshal([]byte(`{zeta=<*I1>;alpha=<*I2>;mid=<*I3>;}`), &entries); err != nil {
+		t.Fatal(err)
+	}
+	expected := []entry{{"zeta", 1}, {"alpha", 2}, {"mid", 3}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, received %v", expected, entries)
+	}
+
+	// Keys are converted as map keys are, and entries whose keys cannot be converted are skipped.
+	var numbered []struct {
+		Key   int
+		Value []string
+	}
+	if _, err := Unmarshal([]byte(`{2=(b);one=(x);1=(a);}`), &numbered); err != nil {
+		t.Fatal(err)
+	}
+	if len(numbered) != 2 || numbered[0].Key != 2 || numbered[1].Key != 1 || numbered[1].Value[0] != "a" {
+		t.Errorf("Expected entries 2 and 1, received %v", numbered)
+	}
+
+	var other []struct{ Name string }
+	if _, err := Unmarshal([]byte(`{a=b;}`), &other); err == nil {
+		t.Error("Expected error decoding a dictionary into a slice of other structs, received nothing.")
+	}
+}
@@ -1,19 +1,34 @@
 package plist
 
-import "io"
+import (
+	"context"
+	"io"
+)
+
+// checkContext panics with ctx's error if ctx is done. A nil ctx is never done.
+func checkContext(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		panic(ctx.Err())
+	default:
+	}
+}
 
 type countedWriter struct {
 	io.Writer
-	nbytes int
+	nbytes int64
 }
 
 func (w *countedWriter) Write(p []byte) (int, error) {
 	n, err := w.Writer.Write(p)
-	w.nbytes += n
+	w.nbytes += int64(n)
 	return n, err
 }
 
-func (w *countedWriter) BytesWritten() int {
+func (w *countedWriter) BytesWritten() int64 {
 	return w.nbytes
 }
 
@@ -0,0 +1,29 @@
+package plist
+
+import (
+	"bytes"
+	"io"
+)
+
+// Valid reports whether data is a well-formed property list in any supported format.
+func Valid(data []byte) bool {
+	return Validate(data) == nil
+}
+
+// Validate checks that data is a well-formed property list in any supported format, and returns
+// the error that decoding it would produce if it is not.
+//
+// Validate does not require a destination value. Like Decoder.Token, it avoids building the
+// property list in memory for XML and binary property lists.
+func Validate(data []byte) error {
+	d := NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
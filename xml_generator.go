@@ -2,17 +2,20 @@ package plist
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
 	"encoding/xml"
 	"io"
 	"math"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	xmlHEADER     string = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
-	xmlDOCTYPE           = `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n"
+	xmlHEADER     string = `<?xml version="1.0" encoding="UTF-8"?>`
+	xmlDOCTYPE           = `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`
 	xmlArrayTag          = "array"
 	xmlDataTag           = "data"
 	xmlDateTag           = "date"
@@ -41,18 +44,62 @@ func formatXMLFloat(f float64) string {
 type xmlPlistGenerator struct {
 	*bufio.Writer
 
-	indent     string
-	depth      int
-	putNewline bool
+	indent      string
+	newline     string
+	flatRoot    bool
+	declaration string // omitted if empty
+	doctype     string // omitted if empty
+	version     string
+	fragment    bool // see EncoderOptions.Fragment
+	dataWidth   int
+	dataIndent  string
+	asciiOnly   bool
+	endNewline  bool
+	dateLayout  string
+	keepZones   bool // see Encoder.PreserveTimeZones
+	depth       int
+	putNewline  bool
+
+	ctx context.Context // checked before each value is written, if set
 }
 
 func (p *xmlPlistGenerator) generateDocument(root cfValue) {
-	p.WriteString(xmlHEADER)
-	p.WriteString(xmlDOCTYPE)
-
-	p.openTag(`plist version="1.0"`)
+	p.beginDocument()
 	p.writePlistValue(root)
-	p.closeTag(xmlPlistTag)
+	p.endDocument()
+}
+
+func (p *xmlPlistGenerator) beginDocument() {
+	if p.fragment {
+		return
+	}
+	if p.declaration != "" {
+		p.WriteString(p.declaration)
+		p.WriteString(p.newline)
+	}
+	if p.doctype != "" {
+		p.WriteString(p.doctype)
+		p.WriteString(p.newline)
+	}
+
+	var version strings.Builder
+	xml.EscapeText(&version, []byte(p.version))
+	p.openTag(`plist version="` + version.String() + `"`)
+	if p.flatRoot {
+		p.depth--
+	}
+}
+
+func (p *xmlPlistGenerator) endDocument() {
+	if !p.fragment {
+		if p.flatRoot {
+			p.depth++
+		}
+		p.closeTag(xmlPlistTag)
+	}
+	if p.endNewline {
+		p.WriteString(p.newline)
+	}
 	p.Flush()
 }
 
@@ -81,8 +128,9 @@ func (p *xmlPlistGenerator) element(n string, v string) {
 		p.WriteString(n)
 		p.WriteByte('>')
 
-		err := xml.EscapeText(p.Writer, []byte(v))
-		if err != nil {
+		if p.asciiOnly {
+			p.writeASCIIText(v)
+		} else if err := xml.EscapeText(p.Writer, []byte(v)); err != nil {
 			panic(err)
 		}
 
@@ -94,36 +142,78 @@ func (p *xmlPlistGenerator) element(n string, v string) {
 
 func (p *xmlPlistGenerator) writeDictionary(dict *cfDictionary) {
 	dict.sort()
-	p.openTag(xmlDictTag)
+	p.beginDictionary()
 	for i, k := range dict.keys {
-		p.element(xmlKeyTag, k)
+		p.writeComment(dict.comments[k])
+		p.writeKey(k)
 		p.writePlistValue(dict.values[i])
 	}
-	p.closeTag(xmlDictTag)
+	p.endDictionary()
+}
+
+// writeComment writes comment as an XML comment, breaking up any "--" it contains.
+func (p *xmlPlistGenerator) writeComment(comment string) {
+	if comment == "" {
+		return
+	}
+	for strings.Contains(comment, "--") {
+		comment = strings.Replace(comment, "--", "- -", -1)
+	}
+	newline := p.newline
+	if newline == "" {
+		// Compact output has no line breaks.
+		newline = " "
+	}
+	comment = strings.Replace(comment, "\n", newline, -1)
+	p.writeIndent(0)
+	p.WriteString("<!-- ")
+	p.WriteString(comment)
+	p.WriteString(" -->")
 }
 
 func (p *xmlPlistGenerator) writeArray(a *cfArray) {
-	p.openTag(xmlArrayTag)
+	p.beginArray()
 	for _, v := range a.values {
 		p.writePlistValue(v)
 	}
+	p.endArray()
+}
+
+func (p *xmlPlistGenerator) beginDictionary() {
+	p.openTag(xmlDictTag)
+}
+
+func (p *xmlPlistGenerator) endDictionary() {
+	p.closeTag(xmlDictTag)
+}
+
+func (p *xmlPlistGenerator) beginArray() {
+	p.openTag(xmlArrayTag)
+}
+
+func (p *xmlPlistGenerator) endArray() {
 	p.closeTag(xmlArrayTag)
 }
 
+func (p *xmlPlistGenerator) writeKey(k string) {
+	p.element(xmlKeyTag, k)
+}
+
+// XML property list elements need no delimiters.
+func (p *xmlPlistGenerator) beginElement(inDictionary bool) {}
+func (p *xmlPlistGenerator) endElement(inDictionary bool)   {}
+
 func (p *xmlPlistGenerator) writePlistValue(pval cfValue) {
 	if pval == nil {
 		return
 	}
+	checkContext(p.ctx)
 
 	switch pval := pval.(type) {
 	case cfString:
 		p.element(xmlStringTag, string(pval))
 	case *cfNumber:
-		if pval.signed {
-			p.element(xmlIntegerTag, strconv.FormatInt(int64(pval.value), 10))
-		} else {
-			p.element(xmlIntegerTag, strconv.FormatUint(pval.value, 10))
-		}
+		p.element(xmlIntegerTag, pval.String())
 	case *cfReal:
 		p.element(xmlRealTag, formatXMLFloat(pval.value))
 	case cfBoolean:
@@ -133,9 +223,26 @@ func (p *xmlPlistGenerator) writePlistValue(pval cfValue) {
 			p.element(xmlFalseTag, "")
 		}
 	case cfData:
-		p.element(xmlDataTag, base64.StdEncoding.EncodeToString([]byte(pval)))
+		if p.dataWidth > 0 {
+			p.writeWrappedData(pval)
+		} else {
+			p.element(xmlDataTag, base64.StdEncoding.EncodeToString([]byte(pval)))
+		}
+	case *cfDataReader:
+		if p.dataWidth > 0 {
+			p.writeWrappedData(pval)
+		} else if pval.size == 0 {
+			p.element(xmlDataTag, "")
+		} else {
+			p.writeIndent(0)
+			p.WriteString("<" + xmlDataTag + ">")
+			enc := base64.NewEncoder(base64.StdEncoding, p.Writer)
+			pval.copyTo(enc)
+			enc.Close()
+			p.WriteString("</" + xmlDataTag + ">")
+		}
 	case cfDate:
-		p.element(xmlDateTag, time.Time(pval).In(time.UTC).Format(time.RFC3339))
+		p.element(xmlDateTag, pval.in(p.keepZones).Format(p.dateLayout))
 	case *cfDictionary:
 		p.writeDictionary(pval)
 	case *cfArray:
@@ -145,6 +252,69 @@ func (p *xmlPlistGenerator) writePlistValue(pval cfValue) {
 	}
 }
 
+// writeASCIIText escapes v as xml.EscapeText does, and writes any non-ASCII characters as character references.
+func (p *xmlPlistGenerator) writeASCIIText(v string) {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(v))
+	for _, r := range escaped.String() {
+		if r < utf8.RuneSelf {
+			p.WriteByte(byte(r))
+		} else {
+			p.WriteString("&#x" + strconv.FormatInt(int64(r), 16) + ";")
+		}
+	}
+}
+
+// writeWrappedData writes a <data> element whose base64 text is broken into lines of p.dataWidth characters.
+func (p *xmlPlistGenerator) writeWrappedData(data dataValue) {
+	p.writeIndent(0)
+	p.WriteString("<" + xmlDataTag + ">")
+	p.WriteString(p.newline)
+
+	lines := &xmlDataLineWriter{p: p}
+	enc := base64.NewEncoder(base64.StdEncoding, lines)
+	data.copyTo(enc)
+	enc.Close()
+	if lines.n > 0 {
+		p.WriteString(p.newline)
+	}
+
+	for i := 0; i < p.depth; i++ {
+		p.WriteString(p.indent)
+	}
+	p.WriteString("</" + xmlDataTag + ">")
+}
+
+// xmlDataLineWriter writes base64 text for writeWrappedData, starting each line with the indentation of the
+// <data> element and DataIndent, and ending it after p.dataWidth characters.
+type xmlDataLineWriter struct {
+	p *xmlPlistGenerator
+	n int // the number of characters on the current line
+}
+
+func (w *xmlDataLineWriter) Write(b []byte) (int, error) {
+	p, written := w.p, len(b)
+	for len(b) > 0 {
+		if w.n == 0 {
+			for i := 0; i < p.depth; i++ {
+				p.WriteString(p.indent)
+			}
+			p.WriteString(p.dataIndent)
+		}
+		n := p.dataWidth - w.n
+		if n > len(b) {
+			n = len(b)
+		}
+		p.Write(b[:n])
+		b, w.n = b[n:], w.n+n
+		if w.n == p.dataWidth {
+			p.WriteString(p.newline)
+			w.n = 0
+		}
+	}
+	return written, nil
+}
+
 func (p *xmlPlistGenerator) writeIndent(delta int) {
 	if len(p.indent) == 0 {
 		return
@@ -157,7 +327,7 @@ func (p *xmlPlistGenerator) writeIndent(delta int) {
 	if p.putNewline {
 		// from encoding/xml/marshal.go; it seems to be intended
 		// to suppress the first newline.
-		p.WriteByte('\n')
+		p.WriteString(p.newline)
 	} else {
 		p.putNewline = true
 	}
@@ -169,10 +339,53 @@ func (p *xmlPlistGenerator) writeIndent(delta int) {
 	}
 }
 
-func (p *xmlPlistGenerator) Indent(i string) {
-	p.indent = i
+func (p *xmlPlistGenerator) Options(o EncoderOptions) {
+	p.indent = o.Indent
+	p.newline = o.newline()
+	p.flatRoot = o.FlatRoot
+	p.endNewline = o.FinalNewline
+	p.dataWidth = o.DataLineWidth
+	p.dataIndent = o.DataIndent
+	p.asciiOnly = o.EscapeNonASCII
+	if o.CompactXML {
+		p.indent, p.newline, p.dataWidth, p.endNewline = "", "", 0, false
+	}
+
+	p.declaration, p.doctype = xmlHEADER, xmlDOCTYPE
+	if o.XMLDeclaration != "" {
+		p.declaration = o.XMLDeclaration
+	}
+	if o.XMLDoctype != "" {
+		p.doctype = o.XMLDoctype
+	}
+	if o.OmitXMLHeader || o.OmitXMLDeclaration {
+		p.declaration = ""
+	}
+	if o.OmitXMLHeader || o.OmitXMLDoctype {
+		p.doctype = ""
+	}
+
+	p.fragment = o.Fragment
+	p.version = "1.0"
+	if o.PlistVersion != "" {
+		p.version = o.PlistVersion
+	}
+}
+
+func (p *xmlPlistGenerator) DateLayout(layout string) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	p.dateLayout = layout
 }
 
 func newXMLPlistGenerator(w io.Writer) *xmlPlistGenerator {
-	return &xmlPlistGenerator{Writer: bufio.NewWriter(w)}
+	return &xmlPlistGenerator{
+		Writer:      getBufioWriter(w),
+		newline:     "\n",
+		declaration: xmlHEADER,
+		doctype:     xmlDOCTYPE,
+		version:     "1.0",
+		dateLayout:  time.RFC3339,
+	}
 }
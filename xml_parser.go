@@ -1,6 +1,8 @@
 package plist
 
 import (
+	"bufio"
+	"context"
 	"encoding/base64"
 	"encoding/xml"
 	"errors"
@@ -13,9 +15,25 @@ import (
 
 type xmlPlistParser struct {
 	reader             io.Reader
+	lines              *lineCounter // the reader, as read by xmlDecoder
 	xmlDecoder         *xml.Decoder
 	whitespaceReplacer *strings.Replacer
 	ntags              int
+	dateLayouts        []string
+	keepComments       bool
+
+	depth    int // the number of elements being parsed that may contain others
+	maxDepth int // the limit on depth, or 0 for none
+
+	ctx context.Context // checked before each element is parsed, if set
+}
+
+// enter records the start of an element that may contain others, and panics if it is nested too deeply.
+func (p *xmlPlistParser) enter() {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		panic(maxDepthError(p.maxDepth))
+	}
 }
 
 func (p *xmlPlistParser) parseDocument() (pval cfValue, parseError error) {
@@ -28,7 +46,7 @@ func (p *xmlPlistParser) parseDocument() (pval cfValue, parseError error) {
 				parseError = r.(error)
 			} else {
 				// Wrap all non-invalid-plist errors.
-				parseError = plistParseError{"XML", r.(error)}
+				parseError = plistParseError{"XML", p.syntaxError(r.(error))}
 			}
 		}
 	}()
@@ -50,10 +68,16 @@ func (p *xmlPlistParser) parseDocument() (pval cfValue, parseError error) {
 }
 
 func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
+	checkContext(p.ctx)
 	var charData xml.CharData
 	switch element.Name.Local {
 	case "plist":
 		p.ntags++
+		if p.ntags > 1 {
+			// The root <plist> element does not count towards the nesting depth, but any nested within it do.
+			p.enter()
+			defer func() { p.depth-- }()
+		}
 		for {
 			token, err := p.xmlDecoder.Token()
 			if err != nil {
@@ -65,7 +89,9 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 			}
 
 			if el, ok := token.(xml.StartElement); ok {
-				return p.parseXMLElement(el)
+				pval := p.parseXMLElement(el)
+				p.skipToEndOfPlist()
+				return pval
 			}
 		}
 		return nil
@@ -89,15 +115,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 			panic(errors.New("invalid empty <integer/>"))
 		}
 
-		if s[0] == '-' {
-			s, base := unsignedGetBase(s[1:])
-			n := mustParseInt("-"+s, base, 64)
-			return &cfNumber{signed: true, value: uint64(n)}
-		} else {
-			s, base := unsignedGetBase(s)
-			n := mustParseUint(s, base, 64)
-			return &cfNumber{signed: false, value: n}
-		}
+		return mustParseIntegerLiteral(s)
 	case "real":
 		p.ntags++
 		err := p.xmlDecoder.DecodeElement(&charData, &element)
@@ -106,7 +124,7 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		}
 
 		n := mustParseFloat(string(charData), 64)
-		return &cfReal{wide: true, value: n}
+		return &cfReal{wide: true, value: n, text: decimalText(string(charData))}
 	case "true", "false":
 		p.ntags++
 		p.xmlDecoder.Skip()
@@ -121,6 +139,9 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		}
 
 		t, err := time.ParseInLocation(time.RFC3339, string(charData), time.UTC)
+		if err != nil && len(p.dateLayouts) > 0 {
+			t, err = parseDate(string(charData), p.dateLayouts)
+		}
 		if err != nil {
 			panic(err)
 		}
@@ -145,9 +166,12 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 		return cfData(bytes[:l])
 	case "dict":
 		p.ntags++
+		p.enter()
 		var key *string
 		keys := make([]string, 0, 32)
 		values := make([]cfValue, 0, 32)
+		var comments map[string]string
+		var pending []string // comments since the last entry
 		for {
 			token, err := p.xmlDecoder.Token()
 			if err != nil {
@@ -161,11 +185,22 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 				break
 			}
 
+			if c, ok := token.(xml.Comment); ok && p.keepComments {
+				pending = append(pending, string(c))
+			}
+
 			if el, ok := token.(xml.StartElement); ok {
 				if el.Name.Local == "key" {
 					var k string
 					p.xmlDecoder.DecodeElement(&k, &el)
 					key = &k
+					if comment := joinComments(pending); comment != "" {
+						if comments == nil {
+							comments = make(map[string]string)
+						}
+						comments[k] = comment
+					}
+					pending = nil
 				} else {
 					if key == nil {
 						panic(errors.New("missing key in dictionary"))
@@ -177,10 +212,12 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 			}
 		}
 
-		dict := &cfDictionary{keys: keys, values: values}
+		p.depth--
+		dict := &cfDictionary{keys: keys, values: values, comments: comments}
 		return dict.maybeUID(false)
 	case "array":
 		p.ntags++
+		p.enter()
 		values := make([]cfValue, 0, 10)
 		for {
 			token, err := p.xmlDecoder.Token()
@@ -196,7 +233,8 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 				values = append(values, p.parseXMLElement(el))
 			}
 		}
-		return &cfArray{values}
+		p.depth--
+		return &cfArray{values: values}
 	}
 	err := fmt.Errorf("encountered unknown element %s", element.Name.Local)
 	if p.ntags == 0 {
@@ -206,6 +244,89 @@ func (p *xmlPlistParser) parseXMLElement(element xml.StartElement) cfValue {
 	panic(err)
 }
 
+// skipToEndOfPlist consumes the remainder of a <plist> element, so that the decoder is positioned
+// at the end of the document. Anything unexpected is ignored, as it always has been.
+func (p *xmlPlistParser) skipToEndOfPlist() {
+	depth := 0
+	for {
+		token, err := p.xmlDecoder.Token()
+		if err != nil {
+			return
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 && el.Name.Local == "plist" {
+				return
+			}
+			depth--
+		}
+	}
+}
+
+// syntaxError returns err annotated with the decoder's position in the document.
+func (p *xmlPlistParser) syntaxError(err error) *SyntaxError {
+	line, column := p.lines.position(p.xmlDecoder.InputOffset())
+	return &SyntaxError{Line: line, Column: column, Err: err}
+}
+
 func newXMLPlistParser(r io.Reader) *xmlPlistParser {
-	return &xmlPlistParser{r, xml.NewDecoder(r), strings.NewReplacer("\t", "", "\n", "", " ", "", "\r", ""), 0}
+	lines := newLineCounter(r)
+	return &xmlPlistParser{r, lines, xml.NewDecoder(lines), strings.NewReplacer("\t", "", "\n", "", " ", "", "\r", ""), 0, nil, false, 0, 0, nil}
+}
+
+// lineCounter is an io.ByteReader that records where the lines it has read begin. xml.Decoder reads from an
+// io.ByteReader one byte at a time, without buffering its own copy, so lineCounter can translate the decoder's
+// InputOffset into a line and column.
+type lineCounter struct {
+	r      io.ByteReader
+	offset int64 // the number of bytes read
+
+	line          int   // the number of newlines read
+	lineStart     int64 // the offset of the line being read
+	prevLineStart int64 // the offset of the line before it
+}
+
+func newLineCounter(r io.Reader) *lineCounter {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &lineCounter{r: br}
+}
+
+func (c *lineCounter) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return b, err
+	}
+	c.offset++
+	if b == '\n' {
+		c.line++
+		c.prevLineStart, c.lineStart = c.lineStart, c.offset
+	}
+	return b, nil
+}
+
+func (c *lineCounter) Read(b []byte) (int, error) {
+	for i := range b {
+		next, err := c.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		b[i] = next
+	}
+	return len(b), nil
+}
+
+// position returns the line and column of offset off, which may be at most one byte behind the last byte read,
+// as xml.Decoder may read one byte ahead.
+func (c *lineCounter) position(off int64) (line, column int) {
+	line, start := c.line+1, c.lineStart
+	if off < start {
+		line, start = line-1, c.prevLineStart
+	}
+	return line, int(off-start) + 1
 }